@@ -0,0 +1,619 @@
+package buffer
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// api holds the Buffer class created for a runtime. Buffer instances are Uint8Arrays whose prototype is
+// Buffer.prototype, the same way as Node does it: they are created by FastBuffer, a Uint8Array subclass
+// sharing its prototype with Buffer, so the TypedArray methods returning new arrays also return Buffers.
+type api struct {
+	runtime *goja.Runtime
+
+	buffer, proto, fastBuffer *goja.Object
+}
+
+func (a *api) init() {
+	r := a.runtime
+	ctor, err := r.RunString(`(function(construct) {
+		class FastBuffer extends Uint8Array {
+			constructor(bufferOrLength, byteOffset, length) {
+				super(bufferOrLength, byteOffset, length);
+			}
+		}
+		function Buffer(arg, encodingOrOffset, length) {
+			return construct(arg, encodingOrOffset, length);
+		}
+		Buffer.prototype = FastBuffer.prototype;
+		Object.defineProperty(FastBuffer.prototype, "constructor", {
+			value: Buffer, writable: true, configurable: true
+		});
+		Object.setPrototypeOf(Buffer, Uint8Array);
+		return [Buffer, FastBuffer];
+	})`)
+	if err != nil {
+		panic(err)
+	}
+	wrap, _ := goja.AssertFunction(ctor)
+	ret, err := wrap(goja.Undefined(), r.ToValue(a.construct))
+	if err != nil {
+		panic(err)
+	}
+	classes := ret.(*goja.Object)
+	a.buffer = classes.Get("0").(*goja.Object)
+	a.fastBuffer = classes.Get("1").(*goja.Object)
+	a.proto = a.buffer.Get("prototype").(*goja.Object)
+
+	a.buffer.Set("poolSize", 8192)
+	a.buffer.Set("from", a.from)
+	a.buffer.Set("alloc", a.alloc)
+	a.buffer.Set("allocUnsafe", a.allocUnsafe)
+	a.buffer.Set("allocUnsafeSlow", a.allocUnsafe)
+	a.buffer.Set("concat", a.concat)
+	a.buffer.Set("byteLength", a.byteLength)
+	a.buffer.Set("compare", a.compareStatic)
+	a.buffer.Set("isBuffer", a.isBuffer)
+	a.buffer.Set("isEncoding", a.isEncoding)
+
+	proto := a.proto
+	proto.Set("toString", a.toString)
+	proto.Set("toLocaleString", a.toString)
+	proto.Set("toJSON", a.toJSON)
+	proto.Set("equals", a.equals)
+	proto.Set("compare", a.compare)
+	proto.Set("copy", a.copy)
+	proto.Set("slice", a.subarray)
+	proto.Set("subarray", a.subarray)
+	proto.Set("write", a.write)
+	proto.Set("fill", a.fill)
+	proto.Set("indexOf", a.indexOf)
+	proto.Set("lastIndexOf", a.lastIndexOf)
+	proto.Set("includes", a.includes)
+	proto.Set("swap16", a.swap(2))
+	proto.Set("swap32", a.swap(4))
+	proto.Set("swap64", a.swap(8))
+	a.initNumberMethods()
+}
+
+func (a *api) newError(ctor, code, format string, args ...interface{}) *goja.Object {
+	err, _ := a.runtime.New(a.runtime.GlobalObject().Get(ctor), a.runtime.ToValue(fmt.Sprintf(format, args...)))
+	err.Set("code", code)
+	return err
+}
+
+func (a *api) typeError(code, format string, args ...interface{}) *goja.Object {
+	return a.newError("TypeError", code, format, args...)
+}
+
+func (a *api) rangeError(code, format string, args ...interface{}) *goja.Object {
+	return a.newError("RangeError", code, format, args...)
+}
+
+func isString(v goja.Value) bool {
+	_, ok := v.Export().(string)
+	return ok && !isObject(v)
+}
+
+func isNumber(v goja.Value) bool {
+	if isObject(v) {
+		return false
+	}
+	switch v.Export().(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func isObject(v goja.Value) bool {
+	_, ok := v.(*goja.Object)
+	return ok
+}
+
+func isMissing(v goja.Value) bool {
+	return v == nil || goja.IsUndefined(v) || goja.IsNull(v)
+}
+
+// bytesOf returns the bytes of an ArrayBuffer or a view of it.
+func bytesOf(v goja.Value) ([]byte, bool) {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil, false
+	}
+	switch e := obj.Export().(type) {
+	case []byte:
+		return e, true
+	case goja.ArrayBuffer:
+		return e.Bytes(), true
+	}
+	if buf, ok := obj.Get("buffer").(*goja.Object); ok {
+		if ab, ok := buf.Export().(goja.ArrayBuffer); ok {
+			offset := obj.Get("byteOffset").ToInteger()
+			length := obj.Get("byteLength").ToInteger()
+			return ab.Bytes()[offset : offset+length], true
+		}
+	}
+	return nil, false
+}
+
+// bytes returns the bytes of a Buffer or an Uint8Array.
+func (a *api) bytes(v goja.Value, name string) []byte {
+	if obj, ok := v.(*goja.Object); ok {
+		if b, ok := obj.Export().([]byte); ok {
+			return b
+		}
+	}
+	panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "%s" argument must be an instance of Buffer or Uint8Array`, name))
+}
+
+func (a *api) encoding(v goja.Value) int {
+	if isMissing(v) {
+		return encUTF8
+	}
+	enc, ok := parseEncoding(v.String())
+	if !ok {
+		panic(a.typeError("ERR_UNKNOWN_ENCODING", "Unknown encoding: %s", v.String()))
+	}
+	return enc
+}
+
+func (a *api) newBuffer(data []byte) *goja.Object {
+	buf, err := a.runtime.New(a.fastBuffer, a.runtime.ToValue(a.runtime.NewArrayBuffer(data)))
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+func (a *api) view(arrayBuffer goja.Value, offset, length int) *goja.Object {
+	r := a.runtime
+	buf, err := r.New(a.fastBuffer, arrayBuffer, r.ToValue(offset), r.ToValue(length))
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
+
+// index converts an optional integer argument and checks that it is within [min, max].
+func (a *api) index(v goja.Value, name string, def, min, max int) int {
+	if goja.IsUndefined(v) {
+		return def
+	}
+	if !isNumber(v) {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "%s" argument must be of type number. Received %s`, name, v.String()))
+	}
+	f := v.ToFloat()
+	if f != math.Trunc(f) {
+		panic(a.rangeError("ERR_OUT_OF_RANGE", `The value of "%s" is out of range. It must be an integer. Received %s`, name, v.String()))
+	}
+	if f < float64(min) || f > float64(max) {
+		panic(a.rangeError("ERR_OUT_OF_RANGE", `The value of "%s" is out of range. It must be >= %d and <= %d. Received %s`,
+			name, min, max, v.String()))
+	}
+	return int(f)
+}
+
+// clamp converts an optional argument to an integer within [0, length]. Negative values count from the end
+// if relative is set, otherwise they are treated as 0.
+func clamp(v goja.Value, def, length int, relative bool) int {
+	if goja.IsUndefined(v) {
+		return def
+	}
+	f := v.ToFloat()
+	if math.IsNaN(f) {
+		return 0
+	}
+	if f < 0 && relative {
+		f += float64(length)
+	}
+	if f < 0 {
+		return 0
+	}
+	if f > float64(length) {
+		return length
+	}
+	return int(f)
+}
+
+func (a *api) construct(call goja.FunctionCall) goja.Value {
+	if isNumber(call.Argument(0)) {
+		return a.allocate(call.Argument(0))
+	}
+	return a.fromValue(call.Argument(0), call.Argument(1), call.Argument(2))
+}
+
+func (a *api) from(call goja.FunctionCall) goja.Value {
+	return a.fromValue(call.Argument(0), call.Argument(1), call.Argument(2))
+}
+
+func (a *api) fromValue(value, encodingOrOffset, length goja.Value) *goja.Object {
+	if isString(value) {
+		return a.newBuffer(encodeString(value.String(), a.encoding(encodingOrOffset)))
+	}
+	obj, ok := value.(*goja.Object)
+	if !ok {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", "The first argument must be of type string or an instance of Buffer, "+
+			"ArrayBuffer, or Array or an Array-like Object. Received %s", value.String()))
+	}
+	switch e := obj.Export().(type) {
+	case goja.ArrayBuffer:
+		size := len(e.Bytes())
+		if !goja.IsUndefined(encodingOrOffset) {
+			if f := encodingOrOffset.ToFloat(); f < 0 || f > float64(size) {
+				panic(a.rangeError("ERR_BUFFER_OUT_OF_BOUNDS", `"offset" is outside of buffer bounds`))
+			}
+		}
+		offset := clamp(encodingOrOffset, 0, size, false)
+		n := size - offset
+		if !goja.IsUndefined(length) {
+			if f := length.ToFloat(); f > float64(n) {
+				panic(a.rangeError("ERR_BUFFER_OUT_OF_BOUNDS", `"length" is outside of buffer bounds`))
+			}
+			n = clamp(length, 0, n, false)
+		}
+		return a.view(obj, offset, n)
+	case []byte:
+		return a.newBuffer(append([]byte(nil), e...))
+	}
+	if valueOf, ok := goja.AssertFunction(obj.Get("valueOf")); ok {
+		if v, err := valueOf(obj); err == nil && !isMissing(v) && !v.SameAs(obj) {
+			return a.fromValue(v, encodingOrOffset, length)
+		}
+	}
+	if t := obj.Get("type"); t != nil && t.String() == "Buffer" {
+		if data, ok := obj.Get("data").(*goja.Object); ok {
+			return a.fromArrayLike(data)
+		}
+	}
+	if l := obj.Get("length"); !isMissing(l) {
+		return a.fromArrayLike(obj)
+	}
+	panic(a.typeError("ERR_INVALID_ARG_TYPE", "The first argument must be of type string or an instance of Buffer, "+
+		"ArrayBuffer, or Array or an Array-like Object. Received %s", value.String()))
+}
+
+func (a *api) fromArrayLike(obj *goja.Object) *goja.Object {
+	n := clamp(obj.Get("length"), 0, kMaxLength, false)
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(obj.Get(strconv.Itoa(i)).ToInteger())
+	}
+	return a.newBuffer(b)
+}
+
+func (a *api) allocate(size goja.Value) *goja.Object {
+	if !isNumber(size) {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "size" argument must be of type number. Received %s`, size.String()))
+	}
+	if f := size.ToFloat(); math.IsNaN(f) || f < 0 || f > kMaxLength {
+		panic(a.rangeError("ERR_OUT_OF_RANGE", `The value of "size" is out of range. It must be >= 0 && <= %d. Received %s`,
+			kMaxLength, size.String()))
+	}
+	return a.newBuffer(make([]byte, int(size.ToInteger())))
+}
+
+func (a *api) alloc(call goja.FunctionCall) goja.Value {
+	buf := a.allocate(call.Argument(0))
+	if value := call.Argument(1); !goja.IsUndefined(value) {
+		b, _ := buf.Export().([]byte)
+		a.fillBytes(b, value, a.encoding(call.Argument(2)))
+	}
+	return buf
+}
+
+func (a *api) allocUnsafe(call goja.FunctionCall) goja.Value {
+	return a.allocate(call.Argument(0))
+}
+
+func (a *api) concat(call goja.FunctionCall) goja.Value {
+	list, ok := call.Argument(0).(*goja.Object)
+	if !ok || list.ClassName() != "Array" {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "list" argument must be an instance of Array. Received %s`,
+			call.Argument(0).String()))
+	}
+	n := int(list.Get("length").ToInteger())
+	parts := make([][]byte, n)
+	total := 0
+	for i := range parts {
+		parts[i] = a.bytes(list.Get(strconv.Itoa(i)), "list["+strconv.Itoa(i)+"]")
+		total += len(parts[i])
+	}
+	if v := call.Argument(1); !goja.IsUndefined(v) {
+		total = a.index(v, "length", 0, 0, kMaxLength)
+	}
+	b := make([]byte, total)
+	pos := 0
+	for _, p := range parts {
+		if pos >= total {
+			break
+		}
+		pos += copy(b[pos:], p)
+	}
+	return a.newBuffer(b)
+}
+
+func (a *api) byteLength(call goja.FunctionCall) goja.Value {
+	v := call.Argument(0)
+	if isString(v) {
+		return a.runtime.ToValue(len(encodeString(v.String(), a.encoding(call.Argument(1)))))
+	}
+	if b, ok := bytesOf(v); ok {
+		return a.runtime.ToValue(len(b))
+	}
+	panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "string" argument must be of type string or an instance of Buffer `+
+		"or ArrayBuffer. Received %s", v.String()))
+}
+
+func (a *api) compareStatic(call goja.FunctionCall) goja.Value {
+	b1 := a.bytes(call.Argument(0), "buf1")
+	b2 := a.bytes(call.Argument(1), "buf2")
+	return a.runtime.ToValue(bytes.Compare(b1, b2))
+}
+
+func (a *api) isBuffer(call goja.FunctionCall) goja.Value {
+	if obj, ok := call.Argument(0).(*goja.Object); ok {
+		for p := obj.Prototype(); p != nil; p = p.Prototype() {
+			if p.SameAs(a.proto) {
+				return a.runtime.ToValue(true)
+			}
+		}
+	}
+	return a.runtime.ToValue(false)
+}
+
+func (a *api) isEncoding(call goja.FunctionCall) goja.Value {
+	v := call.Argument(0)
+	if !isString(v) {
+		return a.runtime.ToValue(false)
+	}
+	_, ok := parseEncoding(v.String())
+	return a.runtime.ToValue(ok)
+}
+
+func (a *api) toString(call goja.FunctionCall) goja.Value {
+	b := a.bytes(call.This, "this")
+	enc := a.encoding(call.Argument(0))
+	start := clamp(call.Argument(1), 0, len(b), false)
+	end := clamp(call.Argument(2), len(b), len(b), false)
+	if end <= start {
+		return a.runtime.ToValue("")
+	}
+	return a.runtime.ToValue(decodeString(b[start:end], enc))
+}
+
+func (a *api) toJSON(call goja.FunctionCall) goja.Value {
+	b := a.bytes(call.This, "this")
+	data := make([]interface{}, len(b))
+	for i, c := range b {
+		data[i] = int(c)
+	}
+	obj := a.runtime.NewObject()
+	obj.Set("type", "Buffer")
+	obj.Set("data", a.runtime.NewArray(data...))
+	return obj
+}
+
+func (a *api) equals(call goja.FunctionCall) goja.Value {
+	return a.runtime.ToValue(bytes.Equal(a.bytes(call.This, "this"), a.bytes(call.Argument(0), "otherBuffer")))
+}
+
+func (a *api) compare(call goja.FunctionCall) goja.Value {
+	source := a.bytes(call.This, "this")
+	target := a.bytes(call.Argument(0), "target")
+	targetStart := a.index(call.Argument(1), "targetStart", 0, 0, len(target))
+	targetEnd := a.index(call.Argument(2), "targetEnd", len(target), 0, len(target))
+	sourceStart := a.index(call.Argument(3), "sourceStart", 0, 0, len(source))
+	sourceEnd := a.index(call.Argument(4), "sourceEnd", len(source), 0, len(source))
+	if targetEnd < targetStart {
+		targetEnd = targetStart
+	}
+	if sourceEnd < sourceStart {
+		sourceEnd = sourceStart
+	}
+	return a.runtime.ToValue(bytes.Compare(source[sourceStart:sourceEnd], target[targetStart:targetEnd]))
+}
+
+func (a *api) copy(call goja.FunctionCall) goja.Value {
+	source := a.bytes(call.This, "this")
+	target := a.bytes(call.Argument(0), "target")
+	targetStart := a.index(call.Argument(1), "targetStart", 0, 0, math.MaxInt32)
+	sourceStart := a.index(call.Argument(2), "sourceStart", 0, 0, len(source))
+	sourceEnd := clamp(call.Argument(3), len(source), len(source), false)
+	if targetStart >= len(target) || sourceStart >= sourceEnd {
+		return a.runtime.ToValue(0)
+	}
+	return a.runtime.ToValue(copy(target[targetStart:], source[sourceStart:sourceEnd]))
+}
+
+// subarray implements both slice() and subarray(): unlike Uint8Array.prototype.slice(), Buffer.prototype.slice()
+// doesn't copy the bytes.
+func (a *api) subarray(call goja.FunctionCall) goja.Value {
+	this := call.This.ToObject(a.runtime)
+	b := a.bytes(this, "this")
+	start := clamp(call.Argument(0), 0, len(b), true)
+	end := clamp(call.Argument(1), len(b), len(b), true)
+	if end < start {
+		end = start
+	}
+	offset := int(this.Get("byteOffset").ToInteger())
+	return a.view(this.Get("buffer"), offset+start, end-start)
+}
+
+func (a *api) write(call goja.FunctionCall) goja.Value {
+	b := a.bytes(call.This, "this")
+	s := call.Argument(0)
+	if !isString(s) {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "string" argument must be of type string. Received %s`, s.String()))
+	}
+	offsetArg, lengthArg, encArg := call.Argument(1), call.Argument(2), call.Argument(3)
+	if isString(offsetArg) {
+		offsetArg, lengthArg, encArg = goja.Undefined(), goja.Undefined(), offsetArg
+	} else if isString(lengthArg) {
+		lengthArg, encArg = goja.Undefined(), lengthArg
+	}
+	offset := a.index(offsetArg, "offset", 0, 0, len(b))
+	length := a.index(lengthArg, "length", len(b)-offset, 0, len(b))
+	if length > len(b)-offset {
+		length = len(b) - offset
+	}
+	enc := a.encoding(encArg)
+	data := encodeString(s.String(), enc)
+	if len(data) > length {
+		// Characters are not split
+		switch enc {
+		case encUTF8:
+			for length > 0 && !utf8.RuneStart(data[length]) {
+				length--
+			}
+		case encUTF16LE:
+			length &^= 1
+		}
+		data = data[:length]
+	}
+	return a.runtime.ToValue(copy(b[offset:], data))
+}
+
+func (a *api) fillBytes(b []byte, value goja.Value, enc int) {
+	var pattern []byte
+	if isString(value) {
+		s := value.String()
+		if s == "" {
+			pattern = []byte{0}
+		} else if pattern = encodeString(s, enc); len(pattern) == 0 {
+			panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'value' is invalid. Received '%s'", s))
+		}
+	} else if data, ok := bytesOf(value); ok && isObject(value) {
+		if len(data) == 0 {
+			panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'value' is invalid. Received %s", value.String()))
+		}
+		pattern = append([]byte(nil), data...)
+	} else {
+		pattern = []byte{byte(value.ToInteger())}
+	}
+	for i := 0; i < len(b); i += len(pattern) {
+		copy(b[i:], pattern)
+	}
+}
+
+func (a *api) fill(call goja.FunctionCall) goja.Value {
+	b := a.bytes(call.This, "this")
+	offsetArg, endArg, encArg := call.Argument(1), call.Argument(2), call.Argument(3)
+	if isString(offsetArg) {
+		offsetArg, endArg, encArg = goja.Undefined(), goja.Undefined(), offsetArg
+	} else if isString(endArg) {
+		endArg, encArg = goja.Undefined(), endArg
+	}
+	offset := a.index(offsetArg, "offset", 0, 0, len(b))
+	end := a.index(endArg, "end", len(b), 0, len(b))
+	if offset < end {
+		a.fillBytes(b[offset:end], call.Argument(0), a.encoding(encArg))
+	}
+	return call.This
+}
+
+func (a *api) search(call goja.FunctionCall, last bool) int {
+	b := a.bytes(call.This, "this")
+	value, offsetArg, encArg := call.Argument(0), call.Argument(1), call.Argument(2)
+	if isString(offsetArg) {
+		offsetArg, encArg = goja.Undefined(), offsetArg
+	}
+	offset := 0
+	if last {
+		offset = len(b)
+	}
+	if !goja.IsUndefined(offsetArg) {
+		if f := offsetArg.ToFloat(); !math.IsNaN(f) {
+			f = math.Trunc(f)
+			if f < 0 {
+				f += float64(len(b))
+			}
+			offset = int(math.Max(-1, math.Min(f, float64(len(b)))))
+		}
+	}
+
+	var needle []byte
+	if isString(value) {
+		needle = encodeString(value.String(), a.encoding(encArg))
+	} else if isNumber(value) {
+		needle = []byte{byte(value.ToInteger())}
+	} else if data, ok := bytesOf(value); ok {
+		needle = data
+	} else {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "value" argument must be one of type number or string `+
+			"or an instance of Buffer or Uint8Array. Received %s", value.String()))
+	}
+
+	if last {
+		if offset < 0 {
+			return -1
+		}
+		end := offset + len(needle)
+		if end > len(b) {
+			end = len(b)
+		}
+		return bytes.LastIndex(b[:end], needle)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if i := bytes.Index(b[offset:], needle); i >= 0 {
+		return offset + i
+	}
+	return -1
+}
+
+func (a *api) indexOf(call goja.FunctionCall) goja.Value {
+	return a.runtime.ToValue(a.search(call, false))
+}
+
+func (a *api) lastIndexOf(call goja.FunctionCall) goja.Value {
+	return a.runtime.ToValue(a.search(call, true))
+}
+
+func (a *api) includes(call goja.FunctionCall) goja.Value {
+	return a.runtime.ToValue(a.search(call, false) >= 0)
+}
+
+func (a *api) swap(size int) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		b := a.bytes(call.This, "this")
+		if len(b)%size != 0 {
+			panic(a.rangeError("ERR_INVALID_BUFFER_SIZE", "Buffer size must be a multiple of %d-bits", size*8))
+		}
+		for i := 0; i < len(b); i += size {
+			for j, k := i, i+size-1; j < k; j, k = j+1, k-1 {
+				b[j], b[k] = b[k], b[j]
+			}
+		}
+		return call.This
+	}
+}
+
+func (a *api) atob(call goja.FunctionCall) goja.Value {
+	s := call.Argument(0).String()
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/', c == '=',
+			c == ' ', c == '\t', c == '\n', c == '\f', c == '\r':
+		default:
+			panic(a.newError("Error", "ERR_INVALID_CHARACTER", "Invalid character"))
+		}
+	}
+	return a.runtime.ToValue(decodeString(decodeBase64(s), encLatin1))
+}
+
+func (a *api) btoa(call goja.FunctionCall) goja.Value {
+	s := call.Argument(0).String()
+	for _, c := range s {
+		if c > 0xff {
+			panic(a.newError("Error", "ERR_INVALID_CHARACTER", "Invalid character"))
+		}
+	}
+	return a.runtime.ToValue(decodeString(encodeString(s, encLatin1), encBase64))
+}
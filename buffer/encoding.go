@@ -0,0 +1,143 @@
+package buffer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	encUTF8 = iota
+	encHex
+	encBase64
+	encBase64URL
+	encLatin1
+	encASCII
+	encUTF16LE
+)
+
+// parseEncoding returns the encoding with the given name. The names are case-insensitive.
+func parseEncoding(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "utf8", "utf-8":
+		return encUTF8, true
+	case "hex":
+		return encHex, true
+	case "base64":
+		return encBase64, true
+	case "base64url":
+		return encBase64URL, true
+	case "latin1", "binary":
+		return encLatin1, true
+	case "ascii":
+		return encASCII, true
+	case "utf16le", "utf-16le", "ucs2", "ucs-2":
+		return encUTF16LE, true
+	}
+	return 0, false
+}
+
+// encodeString converts the string to bytes in the encoding. Like in Node, characters which can't be
+// decoded (e.g. invalid hex digits) terminate the hex input and are skipped in base64 input.
+func encodeString(s string, enc int) []byte {
+	switch enc {
+	case encHex:
+		n := len(s) / 2
+		b := make([]byte, 0, n)
+		for i := 0; i < n; i++ {
+			v, err := hex.DecodeString(s[i*2 : i*2+2])
+			if err != nil {
+				break
+			}
+			b = append(b, v[0])
+		}
+		return b
+	case encBase64, encBase64URL:
+		return decodeBase64(s)
+	case encLatin1, encASCII:
+		units := utf16.Encode([]rune(s))
+		b := make([]byte, len(units))
+		for i, u := range units {
+			b[i] = byte(u)
+		}
+		return b
+	case encUTF16LE:
+		units := utf16.Encode([]rune(s))
+		b := make([]byte, len(units)*2)
+		for i, u := range units {
+			b[i*2] = byte(u)
+			b[i*2+1] = byte(u >> 8)
+		}
+		return b
+	}
+	return []byte(s)
+}
+
+// decodeBase64 decodes both the standard and the URL alphabets, with or without padding. Whitespace and
+// other characters outside the alphabets are ignored and the input ends at the first '='.
+func decodeBase64(s string) []byte {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '+', c == '/':
+			sb.WriteByte(c)
+		case c == '-':
+			sb.WriteByte('+')
+		case c == '_':
+			sb.WriteByte('/')
+		case c == '=':
+			i = len(s)
+		}
+	}
+	clean := sb.String()
+	if len(clean)%4 == 1 {
+		clean = clean[:len(clean)-1]
+	}
+	b, _ := base64.RawStdEncoding.DecodeString(clean)
+	return b
+}
+
+// decodeString converts the bytes to a string. Invalid UTF-8 sequences are replaced with U+FFFD.
+func decodeString(b []byte, enc int) string {
+	switch enc {
+	case encHex:
+		return hex.EncodeToString(b)
+	case encBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case encBase64URL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	case encLatin1:
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return string(r)
+	case encASCII:
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c & 0x7f)
+		}
+		return string(r)
+	case encUTF16LE:
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = uint16(b[i*2]) | uint16(b[i*2+1])<<8
+		}
+		return string(utf16.Decode(units))
+	}
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	var sb strings.Builder
+	sb.Grow(len(b))
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		sb.WriteRune(r)
+		b = b[size:]
+	}
+	return sb.String()
+}
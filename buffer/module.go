@@ -0,0 +1,102 @@
+package buffer
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+)
+
+const ModuleName = "node:buffer"
+
+const (
+	kMaxLength       = 1 << 32
+	kStringMaxLength = 1<<29 - 24
+)
+
+var defaultModule = BufferModule{}
+
+// ErrNotBinary is returned by Bytes if the value is not a Buffer, TypedArray, DataView or ArrayBuffer.
+var ErrNotBinary = errors.New("value is not a Buffer, TypedArray, DataView or ArrayBuffer")
+
+// ErrUnknownEncoding is returned by Encode and Decode if the encoding is not supported by Buffer.
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// apiSym is the key of the per-runtime state stored in the global object.
+var apiSym = goja.NewSymbol("buffer")
+
+func getAPI(runtime *goja.Runtime) *api {
+	global := runtime.GlobalObject()
+	if v := global.GetSymbol(apiSym); v != nil {
+		return v.Export().(*api)
+	}
+	a := &api{runtime: runtime}
+	a.init()
+	global.DefineDataPropertySymbol(apiSym, runtime.ToValue(a), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return a
+}
+
+// NewBuffer creates a Buffer which uses data as its backing store, so the changes made by JS code are
+// visible in data and vice versa.
+func NewBuffer(runtime *goja.Runtime, data []byte) *goja.Object {
+	return getAPI(runtime).newBuffer(data)
+}
+
+// Bytes returns the bytes of a Buffer, TypedArray, DataView or ArrayBuffer. The returned slice shares the
+// memory with the value.
+func Bytes(v goja.Value) ([]byte, error) {
+	if b, ok := bytesOf(v); ok {
+		return b, nil
+	}
+	return nil, ErrNotBinary
+}
+
+// Encode converts the string to bytes in the named encoding (e.g. "utf8", "hex", "base64" or "latin1") the same
+// way as Buffer.from(s, encoding) does.
+func Encode(s, encoding string) ([]byte, error) {
+	enc, ok := parseEncoding(encoding)
+	if !ok {
+		return nil, ErrUnknownEncoding
+	}
+	return encodeString(s, enc), nil
+}
+
+// Decode converts the bytes to a string in the named encoding the same way as buf.toString(encoding) does.
+func Decode(b []byte, encoding string) (string, error) {
+	enc, ok := parseEncoding(encoding)
+	if !ok {
+		return "", ErrUnknownEncoding
+	}
+	return decodeString(b, enc), nil
+}
+
+type BufferModule struct {
+}
+
+// Enable defines the Buffer class as a global.
+func (m *BufferModule) Enable(runtime *goja.Runtime) {
+	runtime.Set("Buffer", getAPI(runtime).buffer)
+}
+
+// Globals returns the names of the globals set by Enable.
+func (m *BufferModule) Globals() []string {
+	return []string{"Buffer"}
+}
+
+func (m *BufferModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	a := getAPI(runtime)
+	exports := module.Get("exports").(*goja.Object)
+	exports.Set("Buffer", a.buffer)
+	exports.Set("kMaxLength", kMaxLength)
+	exports.Set("kStringMaxLength", kStringMaxLength)
+	exports.Set("INSPECT_MAX_BYTES", 50)
+	constants := runtime.NewObject()
+	constants.Set("MAX_LENGTH", kMaxLength)
+	constants.Set("MAX_STRING_LENGTH", kStringMaxLength)
+	exports.Set("constants", constants)
+	exports.Set("atob", a.atob)
+	exports.Set("btoa", a.btoa)
+}
+
+func Default() *BufferModule {
+	return &defaultModule
+}
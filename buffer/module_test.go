@@ -0,0 +1,216 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/require"
+)
+
+func newRuntime() *goja.Runtime {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+	Default().Enable(vm)
+	return vm
+}
+
+func runScript(t *testing.T, vm *goja.Runtime, script string) goja.Value {
+	res, err := vm.RunString(script)
+	if err != nil {
+		if ex, ok := err.(*goja.Exception); ok {
+			t.Fatal(ex.String())
+		}
+		t.Fatal(err)
+	}
+	return res
+}
+
+const assertScript = `
+function assert(cond, msg) {
+	if (!cond) throw new Error("assertion failed: " + msg);
+}
+function assertEq(actual, expected, msg) {
+	if (actual !== expected) throw new Error(msg + ": expected " + JSON.stringify(expected) + ", got " + JSON.stringify(actual));
+}
+function assertThrows(fn, code) {
+	try {
+		fn();
+	} catch (e) {
+		assertEq(e.code, code, "error code");
+		return;
+	}
+	throw new Error("expected " + code);
+}
+`
+
+func TestBufferClass(t *testing.T) {
+	vm := newRuntime()
+	runScript(t, vm, assertScript+`
+	const { Buffer: B, kMaxLength } = require("node:buffer");
+	assert(B === Buffer, "global Buffer");
+	assert(kMaxLength > 0, "kMaxLength");
+	const b = Buffer.from("hello");
+	assert(b instanceof Buffer && b instanceof Uint8Array, "instanceof");
+	assert(Buffer.isBuffer(b) && !Buffer.isBuffer(new Uint8Array(1)), "isBuffer");
+	assertEq(b.length, 5, "length");
+	assert(b.subarray(1) instanceof Buffer, "subarray");
+	assert(b.map(x => x) instanceof Buffer, "map");
+
+	const s = b.slice(1, 3);
+	s[0] = 0x61;
+	assertEq(b.toString(), "hallo", "slice shares memory");
+	assertEq(b.slice(-2).toString(), "lo", "negative slice");
+
+	const ab = new ArrayBuffer(8);
+	const v = Buffer.from(ab, 2, 4);
+	v[0] = 1;
+	assertEq(new Uint8Array(ab)[2], 1, "from ArrayBuffer shares memory");
+	assertEq(v.byteOffset, 2, "byteOffset");
+
+	assertEq(Buffer.from([1, 2, 256, -1]).join(), "1,2,0,255", "from array");
+	assertEq(Buffer.from(new Uint16Array([1, 258])).join(), "1,2", "from typed array");
+	assertEq(Buffer.from(JSON.parse(JSON.stringify(Buffer.from("ab")))).toString(), "ab", "toJSON");
+	assertEq(Buffer.from(new String("str")).toString(), "str", "from String object");
+	assertEq(Buffer.alloc(5, "ab").toString(), "ababa", "alloc with fill");
+	assertEq(Buffer.alloc(3, 1).join(), "1,1,1", "alloc with number");
+	assertEq(Buffer.allocUnsafe(3).length, 3, "allocUnsafe");
+	assertEq(new Buffer(2).length, 2, "constructor with size");
+	assertEq(new Buffer("abc").toString(), "abc", "constructor with string");
+	assertThrows(() => Buffer.alloc(-1), "ERR_OUT_OF_RANGE");
+	assertThrows(() => Buffer.from(1), "ERR_INVALID_ARG_TYPE");
+	assertThrows(() => Buffer.from("a", "nope"), "ERR_UNKNOWN_ENCODING");
+
+	assertEq(Buffer.concat([Buffer.from("ab"), new Uint8Array([99])]).toString(), "abc", "concat");
+	assertEq(Buffer.concat([Buffer.from("ab"), Buffer.from("cd")], 3).toString(), "abc", "concat with length");
+	assertEq(Buffer.compare(Buffer.from("a"), Buffer.from("b")), -1, "compare");
+	assert(Buffer.from("abc").equals(Buffer.from("abc")), "equals");
+	assertEq(Buffer.from("abc").compare(Buffer.from("xbc"), 1, 3, 1, 3), 0, "compare ranges");
+	assert(Buffer.isEncoding("UTF-8") && !Buffer.isEncoding("nope"), "isEncoding");
+	`)
+}
+
+func TestBufferEncodings(t *testing.T) {
+	vm := newRuntime()
+	runScript(t, vm, assertScript+`
+	const s = "héllo wörld €𝄞";
+	for (const enc of ["utf8", "utf-8", "utf16le", "ucs2"]) {
+		assertEq(Buffer.from(s, enc).toString(enc), s, enc);
+	}
+	for (const enc of ["hex", "base64", "base64url"]) {
+		assertEq(Buffer.from(Buffer.from(s).toString(enc), enc).toString(), s, enc);
+	}
+	assertEq(Buffer.from("hello").toString("hex"), "68656c6c6f", "hex");
+	assertEq(Buffer.from("68656c6c6fzz", "hex").toString(), "hello", "invalid hex");
+	assertEq(Buffer.from("hello?").toString("base64"), "aGVsbG8/", "base64");
+	assertEq(Buffer.from("hello?").toString("base64url"), "aGVsbG8_", "base64url");
+	assertEq(Buffer.from("aGVsbG8_", "base64").toString(), "hello?", "lenient base64");
+	assertEq(Buffer.from("aGV sbG8", "base64").toString(), "hello", "base64 without padding");
+	assertEq(Buffer.from("ÿé", "latin1").join(), "255,233", "latin1");
+	assertEq(Buffer.from([255, 233]).toString("binary"), "ÿé", "binary");
+	assertEq(Buffer.from([0xe9]).toString("ascii"), "i", "ascii");
+	assertEq(Buffer.from("€", "utf16le").join(), "172,32", "utf16le");
+	assertEq(Buffer.from([0x61, 0xff, 0x62]).toString(), "a�b", "invalid utf8");
+	assertEq(Buffer.from("hello").toString("utf8", 1, 3), "el", "toString range");
+
+	assertEq(Buffer.byteLength("€"), 3, "byteLength");
+	assertEq(Buffer.byteLength("aGVsbG8=", "base64"), 5, "byteLength base64");
+	assertEq(Buffer.byteLength(new ArrayBuffer(7)), 7, "byteLength ArrayBuffer");
+
+	const b = Buffer.alloc(4);
+	assertEq(b.write("a€"), 4, "write");
+	assertEq(b.write("€€", 1), 3, "write doesn't split characters");
+	assertEq(b.write("ffff", 2, "hex"), 2, "write hex");
+	assertEq(b.toString("hex"), "61e2ffff", "written");
+
+	const { atob, btoa } = require("node:buffer");
+	assertEq(btoa("hello"), "aGVsbG8=", "btoa");
+	assertEq(atob("aGVsbG8="), "hello", "atob");
+	`)
+}
+
+func TestBufferMethods(t *testing.T) {
+	vm := newRuntime()
+	runScript(t, vm, assertScript+`
+	const b = Buffer.from("abcabc");
+	assertEq(b.indexOf("c"), 2, "indexOf");
+	assertEq(b.indexOf("c", 3), 5, "indexOf offset");
+	assertEq(b.indexOf(98), 1, "indexOf number");
+	assertEq(b.indexOf(Buffer.from("ca")), 2, "indexOf buffer");
+	assertEq(b.lastIndexOf("ab"), 3, "lastIndexOf");
+	assertEq(b.lastIndexOf("ab", -4), 0, "lastIndexOf negative offset");
+	assert(b.includes("bca") && !b.includes("x"), "includes");
+
+	assertEq(Buffer.alloc(6).fill("xy", 1, 5).toString("hex"), "0078797879" + "00", "fill range");
+	assertEq(Buffer.alloc(3).fill("ff", "hex").join(), "255,255,255", "fill encoding");
+
+	const t = Buffer.alloc(4);
+	assertEq(b.copy(t, 1, 2), 3, "copy");
+	assertEq(t.toString("latin1", 1), "cab", "copied");
+
+	assertEq(Buffer.from([1, 2, 3, 4]).swap16().join(), "2,1,4,3", "swap16");
+	assertEq(Buffer.from([1, 2, 3, 4]).swap32().join(), "4,3,2,1", "swap32");
+	assertThrows(() => Buffer.from([1, 2, 3]).swap16(), "ERR_INVALID_BUFFER_SIZE");
+	`)
+}
+
+func TestBufferNumbers(t *testing.T) {
+	vm := newRuntime()
+	runScript(t, vm, assertScript+`
+	const b = Buffer.alloc(8);
+	assertEq(b.writeUInt16BE(0x1234, 0), 2, "writeUInt16BE");
+	assertEq(b.toString("hex", 0, 2), "1234", "big endian");
+	assertEq(b.readUInt16LE(0), 0x3412, "readUInt16LE");
+	assertEq(b.readUint16BE(0), 0x1234, "Uint alias");
+	b.writeInt32LE(-2, 4);
+	assertEq(b.readInt32LE(4), -2, "readInt32LE");
+	assertEq(b.readUInt32LE(4), 0xfffffffe, "readUInt32LE");
+	b.writeInt8(-128, 0);
+	assertEq(b.readInt8(0), -128, "readInt8");
+	assertEq(b.readUInt8(0), 128, "readUInt8");
+	b.writeUIntBE(0x123456789abc, 0, 6);
+	assertEq(b.readUIntBE(0, 6), 0x123456789abc, "readUIntBE");
+	b.writeIntLE(-123456, 1, 3);
+	assertEq(b.readIntLE(1, 3), -123456, "readIntLE");
+	b.writeDoubleLE(Math.PI);
+	assertEq(b.readDoubleLE(), Math.PI, "readDoubleLE");
+	b.writeFloatBE(1.5, 4);
+	assertEq(b.readFloatBE(4), 1.5, "readFloatBE");
+
+	assertThrows(() => b.writeUInt8(256), "ERR_OUT_OF_RANGE");
+	assertThrows(() => b.writeInt16LE(-40000), "ERR_OUT_OF_RANGE");
+	assertThrows(() => b.readUInt32LE(5), "ERR_OUT_OF_RANGE");
+	assertThrows(() => b.readUInt8(1.5), "ERR_OUT_OF_RANGE");
+	assertThrows(() => b.readUIntLE(0, 7), "ERR_OUT_OF_RANGE");
+	assertThrows(() => Buffer.alloc(1).readUInt16LE(), "ERR_BUFFER_OUT_OF_BOUNDS");
+	`)
+}
+
+func TestGoHelpers(t *testing.T) {
+	vm := newRuntime()
+	data := []byte("hello")
+	vm.Set("b", NewBuffer(vm, data))
+	runScript(t, vm, `
+	if (!Buffer.isBuffer(b) || b.toString() !== "hello") throw new Error("NewBuffer");
+	b[0] = 0x6a;
+	`)
+	if string(data) != "jello" {
+		t.Fatalf("Unexpected data: %s", data)
+	}
+
+	for _, script := range []string{`Buffer.from("jello")`, `new Uint8Array([106, 101, 108, 108, 111])`,
+		`Buffer.from("xjellox").subarray(1, 6)`, `new DataView(Buffer.from("jello").buffer)`} {
+		b, err := Bytes(runScript(t, vm, script))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, data) {
+			t.Fatalf("%s: unexpected bytes: %v", script, b)
+		}
+	}
+	if _, err := Bytes(vm.ToValue("str")); err != ErrNotBinary {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
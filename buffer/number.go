@@ -0,0 +1,173 @@
+package buffer
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+func getUint(b []byte, littleEndian bool) uint64 {
+	var v uint64
+	for i := range b {
+		idx := i
+		if littleEndian {
+			idx = len(b) - 1 - i
+		}
+		v = v<<8 | uint64(b[idx])
+	}
+	return v
+}
+
+func putUint(b []byte, v uint64, littleEndian bool) {
+	for i := range b {
+		idx := len(b) - 1 - i
+		if littleEndian {
+			idx = i
+		}
+		b[idx] = byte(v)
+		v >>= 8
+	}
+}
+
+// offset checks the offset argument of the read and write methods accessing size bytes.
+func (a *api) offset(b []byte, v goja.Value, size int) int {
+	if goja.IsUndefined(v) {
+		v = a.runtime.ToValue(0)
+	}
+	if isNumber(v) && len(b) < size {
+		panic(a.rangeError("ERR_BUFFER_OUT_OF_BOUNDS", "Attempt to access memory outside buffer bounds"))
+	}
+	return a.index(v, "offset", 0, 0, len(b)-size)
+}
+
+func (a *api) byteLengthArg(v goja.Value) int {
+	if goja.IsUndefined(v) {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "byteLength" argument must be of type number. Received undefined`))
+	}
+	return a.index(v, "byteLength", 0, 1, 6)
+}
+
+func (a *api) readInt(b []byte, offset goja.Value, size int, littleEndian, signed bool) goja.Value {
+	off := a.offset(b, offset, size)
+	v := getUint(b[off:off+size], littleEndian)
+	if signed {
+		shift := 64 - 8*size
+		return a.runtime.ToValue(int64(v<<shift) >> shift)
+	}
+	return a.runtime.ToValue(int64(v))
+}
+
+func (a *api) writeInt(b []byte, value, offset goja.Value, size int, littleEndian, signed bool) goja.Value {
+	off := a.offset(b, offset, size)
+	f := value.ToFloat()
+	min, max := 0.0, math.Pow(2, float64(8*size))-1
+	if signed {
+		min, max = -math.Pow(2, float64(8*size-1)), math.Pow(2, float64(8*size-1))-1
+	}
+	if f < min || f > max {
+		panic(a.rangeError("ERR_OUT_OF_RANGE", `The value of "value" is out of range. It must be >= %s and <= %s. Received %s`,
+			strconv.FormatFloat(min, 'f', -1, 64), strconv.FormatFloat(max, 'f', -1, 64), value.String()))
+	}
+	var v int64
+	if !math.IsNaN(f) {
+		v = int64(f)
+	}
+	putUint(b[off:off+size], uint64(v), littleEndian)
+	return a.runtime.ToValue(off + size)
+}
+
+func (a *api) readFloat(b []byte, offset goja.Value, size int, littleEndian bool) goja.Value {
+	off := a.offset(b, offset, size)
+	v := getUint(b[off:off+size], littleEndian)
+	if size == 4 {
+		return a.runtime.ToValue(float64(math.Float32frombits(uint32(v))))
+	}
+	return a.runtime.ToValue(math.Float64frombits(v))
+}
+
+func (a *api) writeFloat(b []byte, value, offset goja.Value, size int, littleEndian bool) goja.Value {
+	off := a.offset(b, offset, size)
+	f := value.ToFloat()
+	v := math.Float64bits(f)
+	if size == 4 {
+		v = uint64(math.Float32bits(float32(f)))
+	}
+	putUint(b[off:off+size], v, littleEndian)
+	return a.runtime.ToValue(off + size)
+}
+
+func (a *api) setMethod(fn func(goja.FunctionCall) goja.Value, names ...string) {
+	f := a.runtime.ToValue(fn)
+	for _, name := range names {
+		a.proto.Set(name, f)
+	}
+}
+
+// initNumberMethods defines the read and write methods for the integer and float types, e.g. readUInt16LE()
+// and writeDoubleBE(). Like in Node the unsigned methods are also available with the 'Uint' spelling.
+func (a *api) initNumberMethods() {
+	for _, size := range []int{1, 2, 4} {
+		orders := []string{"LE", "BE"}
+		if size == 1 {
+			orders = []string{""}
+		}
+		for _, order := range orders {
+			size, littleEndian, suffix := size, order != "BE", strconv.Itoa(size*8)+order
+			for _, signed := range []bool{false, true} {
+				signed := signed
+				names := []string{"Int" + suffix}
+				if !signed {
+					names = []string{"UInt" + suffix, "Uint" + suffix}
+				}
+				a.setMethod(func(call goja.FunctionCall) goja.Value {
+					return a.readInt(a.bytes(call.This, "this"), call.Argument(0), size, littleEndian, signed)
+				}, prefixed("read", names)...)
+				a.setMethod(func(call goja.FunctionCall) goja.Value {
+					return a.writeInt(a.bytes(call.This, "this"), call.Argument(0), call.Argument(1), size, littleEndian, signed)
+				}, prefixed("write", names)...)
+			}
+		}
+	}
+
+	for _, order := range []string{"LE", "BE"} {
+		littleEndian := order == "LE"
+		for _, signed := range []bool{false, true} {
+			signed := signed
+			names := []string{"Int" + order}
+			if !signed {
+				names = []string{"UInt" + order, "Uint" + order}
+			}
+			a.setMethod(func(call goja.FunctionCall) goja.Value {
+				b := a.bytes(call.This, "this")
+				return a.readInt(b, call.Argument(0), a.byteLengthArg(call.Argument(1)), littleEndian, signed)
+			}, prefixed("read", names)...)
+			a.setMethod(func(call goja.FunctionCall) goja.Value {
+				b := a.bytes(call.This, "this")
+				return a.writeInt(b, call.Argument(0), call.Argument(1), a.byteLengthArg(call.Argument(2)), littleEndian, signed)
+			}, prefixed("write", names)...)
+		}
+
+		for _, size := range []int{4, 8} {
+			size := size
+			name := "Float" + order
+			if size == 8 {
+				name = "Double" + order
+			}
+			a.setMethod(func(call goja.FunctionCall) goja.Value {
+				return a.readFloat(a.bytes(call.This, "this"), call.Argument(0), size, littleEndian)
+			}, "read"+name)
+			a.setMethod(func(call goja.FunctionCall) goja.Value {
+				return a.writeFloat(a.bytes(call.This, "this"), call.Argument(0), call.Argument(1), size, littleEndian)
+			}, "write"+name)
+		}
+	}
+}
+
+func prefixed(prefix string, names []string) []string {
+	ret := make([]string, len(names))
+	for i, name := range names {
+		ret[i] = prefix + name
+	}
+	return ret
+}
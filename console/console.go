@@ -0,0 +1,335 @@
+package console
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/util"
+)
+
+// now is replaced by the tests.
+var now = time.Now
+
+const defaultGroupIndentation = 2
+
+// Console implements the console object of a runtime. The counters, the timers and the group indentation
+// are kept per console.
+type Console struct {
+	runtime *goja.Runtime
+	printer Printer
+	indent  string
+	counts  map[string]int
+	timers  map[string]time.Time
+
+	// groupIndentation is added to indent by console.group().
+	groupIndentation string
+	// inspectOptions are the options of the Console constructor used when the values are inspected.
+	inspectOptions *goja.Object
+	colors         bool
+}
+
+func newConsole(runtime *goja.Runtime, printer Printer) *Console {
+	return &Console{
+		runtime:          runtime,
+		printer:          printer,
+		counts:           make(map[string]int),
+		timers:           make(map[string]time.Time),
+		groupIndentation: strings.Repeat(" ", defaultGroupIndentation),
+	}
+}
+
+func (c *Console) object() *goja.Object {
+	return c.define(c.runtime.NewObject())
+}
+
+// define sets the console methods on the object.
+func (c *Console) define(obj *goja.Object) *goja.Object {
+	obj.Set("log", c.log)
+	obj.Set("info", c.info)
+	obj.Set("debug", c.debug)
+	obj.Set("warn", c.warn)
+	obj.Set("error", c.error)
+	obj.Set("trace", c.trace)
+	obj.Set("assert", c.assert)
+	obj.Set("dir", c.dir)
+	obj.Set("dirxml", c.dirxml)
+	obj.Set("table", c.table)
+	obj.Set("group", c.group)
+	obj.Set("groupCollapsed", c.group)
+	obj.Set("groupEnd", c.groupEnd)
+	obj.Set("count", c.count)
+	obj.Set("countReset", c.countReset)
+	obj.Set("time", c.time)
+	obj.Set("timeLog", c.timeLog)
+	obj.Set("timeEnd", c.timeEnd)
+	return obj
+}
+
+// print sends the message of the console method called with the arguments to the printer. The lines of the
+// message are indented by the current group indentation unless the printer takes records.
+func (c *Console) print(level Level, method, s string, args []goja.Value) {
+	if p, ok := c.printer.(RecordPrinter); ok {
+		p.PrintRecord(c.record(level, method, s, args))
+		return
+	}
+	if c.indent != "" {
+		s = c.indent + strings.ReplaceAll(s, "\n", "\n"+c.indent)
+	}
+	if p, ok := c.printer.(LevelPrinter); ok {
+		p.Print(level, s)
+		return
+	}
+	switch level {
+	case LevelWarn:
+		c.printer.Warn(s)
+	case LevelError:
+		c.printer.Error(s)
+	default:
+		c.printer.Log(s)
+	}
+}
+
+// groups returns the nesting level of console.group() calls.
+func (c *Console) groups() int {
+	if c.groupIndentation == "" {
+		return 0
+	}
+	return len(c.indent) / len(c.groupIndentation)
+}
+
+func (c *Console) record(level Level, method, s string, args []goja.Value) Record {
+	record := Record{
+		Time:    now(),
+		Level:   level,
+		Method:  method,
+		Message: s,
+		Args:    args,
+		Group:   c.groups(),
+		Runtime: c.runtime,
+	}
+	var buf [4]goja.StackFrame
+	for _, frame := range c.runtime.CaptureCallStack(len(buf), buf[:0]) {
+		if frame.SrcName() != "<native>" {
+			pos := frame.Position()
+			record.File, record.Line, record.Column = pos.Filename, pos.Line, pos.Column
+			break
+		}
+	}
+	return record
+}
+
+// getInspectOptions returns the options with the inspectOptions of the Console constructor applied.
+func (c *Console) getInspectOptions(options util.InspectOptions) util.InspectOptions {
+	options = util.ParseInspectOptions(c.inspectOptions, options)
+	options.Colors = c.colors
+	return options
+}
+
+func (c *Console) format(args []goja.Value) string {
+	return util.FormatWithOptions(c.runtime, c.getInspectOptions(util.DefaultInspectOptions), args...)
+}
+
+func (c *Console) printArgs(level Level, method string, args []goja.Value) goja.Value {
+	c.print(level, method, c.format(args), args)
+	return goja.Undefined()
+}
+
+func (c *Console) log(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelLog, "log", call.Arguments)
+}
+
+func (c *Console) info(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelInfo, "info", call.Arguments)
+}
+
+func (c *Console) debug(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelDebug, "debug", call.Arguments)
+}
+
+func (c *Console) warn(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelWarn, "warn", call.Arguments)
+}
+
+func (c *Console) error(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelError, "error", call.Arguments)
+}
+
+// trace prints the message followed by the stack trace of the caller, like the stack of an Error named Trace.
+func (c *Console) trace(call goja.FunctionCall) goja.Value {
+	var b bytes.Buffer
+	b.WriteString("Trace")
+	if msg := c.format(call.Arguments); msg != "" {
+		b.WriteString(": " + msg)
+	}
+	frames := c.runtime.CaptureCallStack(0, nil)
+	// The first frame is console.trace() itself
+	for i := 1; i < len(frames); i++ {
+		b.WriteString("\n    at " + frameLocation(&frames[i]))
+	}
+	c.print(LevelError, "trace", b.String(), call.Arguments)
+	return goja.Undefined()
+}
+
+// frameLocation formats the stack frame like goja does for Error.stack, without the program counter.
+func frameLocation(frame *goja.StackFrame) string {
+	var location string
+	if frame.SrcName() == "<native>" {
+		location = "native"
+	} else {
+		pos := frame.Position()
+		filename := pos.Filename
+		if filename == "" {
+			filename = "<eval>"
+		}
+		location = fmt.Sprintf("%s:%d:%d", filename, pos.Line, pos.Column)
+	}
+	if name := frame.FuncName(); name != "<anonymous>" && name != "<native>" {
+		location = name + " (" + location + ")"
+	}
+	return location
+}
+
+func (c *Console) assert(call goja.FunctionCall) goja.Value {
+	if call.Argument(0).ToBoolean() {
+		return goja.Undefined()
+	}
+	args := call.Arguments
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		if _, isString := args[0].Export().(string); isString {
+			args = append([]goja.Value{c.runtime.ToValue("Assertion failed: " + args[0].String())}, args[1:]...)
+		} else {
+			args = append([]goja.Value{c.runtime.ToValue("Assertion failed")}, args...)
+		}
+	} else {
+		args = []goja.Value{c.runtime.ToValue("Assertion failed")}
+	}
+	return c.printArgs(LevelWarn, "assert", args)
+}
+
+// dir prints the value inspected without the custom inspect methods unless they're enabled by the options.
+func (c *Console) dir(call goja.FunctionCall) goja.Value {
+	options := util.DefaultInspectOptions
+	options.CustomInspect = false
+	options = util.ParseInspectOptions(call.Argument(1), c.getInspectOptions(options))
+	c.print(LevelLog, "dir", util.Inspect(c.runtime, call.Argument(0), options), call.Arguments)
+	return goja.Undefined()
+}
+
+func (c *Console) dirxml(call goja.FunctionCall) goja.Value {
+	return c.printArgs(LevelLog, "dirxml", call.Arguments)
+}
+
+func (c *Console) group(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) > 0 {
+		c.printArgs(LevelLog, "group", call.Arguments)
+	}
+	c.indent += c.groupIndentation
+	return goja.Undefined()
+}
+
+func (c *Console) groupEnd(call goja.FunctionCall) goja.Value {
+	c.indent = strings.TrimSuffix(c.indent, c.groupIndentation)
+	return goja.Undefined()
+}
+
+func label(v goja.Value) string {
+	if goja.IsUndefined(v) {
+		return "default"
+	}
+	return v.String()
+}
+
+func (c *Console) count(call goja.FunctionCall) goja.Value {
+	l := label(call.Argument(0))
+	c.counts[l]++
+	c.print(LevelLog, "count", l+": "+strconv.Itoa(c.counts[l]), call.Arguments)
+	return goja.Undefined()
+}
+
+func (c *Console) countReset(call goja.FunctionCall) goja.Value {
+	l := label(call.Argument(0))
+	if _, exists := c.counts[l]; !exists {
+		c.print(LevelWarn, "countReset", "Count for '"+l+"' does not exist", call.Arguments)
+		return goja.Undefined()
+	}
+	delete(c.counts, l)
+	return goja.Undefined()
+}
+
+func (c *Console) time(call goja.FunctionCall) goja.Value {
+	l := label(call.Argument(0))
+	if _, exists := c.timers[l]; exists {
+		c.print(LevelWarn, "time", "Warning: Label '"+l+"' already exists for console.time()", call.Arguments)
+		return goja.Undefined()
+	}
+	c.timers[l] = now()
+	return goja.Undefined()
+}
+
+// logTime prints the time elapsed since console.time() was called with the label followed by the data.
+func (c *Console) logTime(name string, call goja.FunctionCall, end bool) goja.Value {
+	l := label(call.Argument(0))
+	start, exists := c.timers[l]
+	if !exists {
+		c.print(LevelWarn, name, "Warning: No such label '"+l+"' for console."+name+"()", call.Arguments)
+		return goja.Undefined()
+	}
+	if end {
+		delete(c.timers, l)
+	}
+	args := []goja.Value{c.runtime.ToValue("%s: %s"), c.runtime.ToValue(l),
+		c.runtime.ToValue(formatTime(float64(now().Sub(start)) / float64(time.Millisecond)))}
+	if len(call.Arguments) > 1 {
+		args = append(args, call.Arguments[1:]...)
+	}
+	c.print(LevelLog, name, c.format(args), call.Arguments)
+	return goja.Undefined()
+}
+
+func (c *Console) timeLog(call goja.FunctionCall) goja.Value {
+	return c.logTime("timeLog", call, false)
+}
+
+func (c *Console) timeEnd(call goja.FunctionCall) goja.Value {
+	return c.logTime("timeEnd", call, true)
+}
+
+// formatTime formats the duration in milliseconds the way Node.js does for console.timeEnd().
+func formatTime(ms float64) string {
+	var hours, minutes int64
+	seconds := 0.0
+	if ms >= 1000 {
+		if ms >= 60000 {
+			if ms >= 3600000 {
+				hours = int64(ms / 3600000)
+				ms = math.Mod(ms, 3600000)
+			}
+			minutes = int64(ms / 60000)
+			ms = math.Mod(ms, 60000)
+		}
+		seconds = ms / 1000
+	}
+	if hours != 0 || minutes != 0 {
+		parts := strings.SplitN(strconv.FormatFloat(seconds, 'f', 3, 64), ".", 2)
+		if len(parts[0]) < 2 {
+			parts[0] = "0" + parts[0]
+		}
+		if hours != 0 {
+			return fmt.Sprintf("%d:%02d:%s.%s (h:mm:ss.mmm)", hours, minutes, parts[0], parts[1])
+		}
+		return fmt.Sprintf("%d:%s.%s (m:ss.mmm)", minutes, parts[0], parts[1])
+	}
+	if seconds != 0 {
+		return strconv.FormatFloat(seconds, 'f', 3, 64) + "s"
+	}
+	ms, _ = strconv.ParseFloat(strconv.FormatFloat(ms, 'f', 3, 64), 64)
+	return strconv.FormatFloat(ms, 'f', -1, 64) + "ms"
+}
@@ -2,9 +2,9 @@ package console
 
 import (
 	"log"
+	"time"
 
 	"github.com/dop251/goja"
-	"github.com/khanghh/goja-nodejs/util"
 )
 
 const ModuleName = "node:console"
@@ -13,12 +13,75 @@ var defaultModule = ConsoleModule{
 	printer: DefaultPrinter,
 }
 
+// Level is the severity of a message printed by the console.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelLog
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelLog:
+		return "log"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "unknown"
+}
+
 type Printer interface {
 	Log(string)
 	Warn(string)
 	Error(string)
 }
 
+// LevelPrinter is a Printer which receives the level of every message. The console calls Print instead of
+// the other methods if the printer implements it. Plain printers get the debug and info messages with Log.
+type LevelPrinter interface {
+	Printer
+	Print(level Level, s string)
+}
+
+// Record describes a message printed by a console method, see RecordPrinter.
+type Record struct {
+	Time  time.Time
+	Level Level
+	// Method is the name of the console method, e.g. "log" or "table".
+	Method string
+	// Message is the formatted message without the group indentation.
+	Message string
+	// Args are the arguments the console method was called with.
+	Args []goja.Value
+	// Group is the nesting level of console.group() calls.
+	Group int
+	// File, Line and Column locate the script code which called the console method. File is empty if the
+	// method was called from Go.
+	File   string
+	Line   int
+	Column int
+	// Runtime is the runtime of the console.
+	Runtime *goja.Runtime
+}
+
+// RecordPrinter is a Printer which receives the details of every console call. The console calls PrintRecord
+// instead of the other methods if the printer implements it. Args and Runtime may only be used by PrintRecord
+// itself because goja values are not safe for concurrent use.
+type RecordPrinter interface {
+	Printer
+	PrintRecord(record Record)
+}
+
 type PrinterFunc func(s string)
 
 func (print PrinterFunc) Log(s string) { print(s) }
@@ -29,39 +92,6 @@ func (print PrinterFunc) Error(s string) { print(s) }
 
 var DefaultPrinter Printer = PrinterFunc(func(s string) { log.Print(s) })
 
-type Console struct {
-	runtime *goja.Runtime
-	printer Printer
-}
-
-func (c *Console) formatPrinterOutput(call goja.FunctionCall) string {
-	var format string
-	if arg := call.Argument(0); !goja.IsUndefined(arg) {
-		format = arg.String()
-	}
-	var args []goja.Value
-	if len(call.Arguments) > 0 {
-		args = call.Arguments[1:]
-	}
-	out := util.Format(c.runtime, format, args...)
-	return out.String()
-}
-
-func (c *Console) log(call goja.FunctionCall) goja.Value {
-	c.printer.Log(c.formatPrinterOutput(call))
-	return goja.Undefined()
-}
-
-func (c *Console) warn(call goja.FunctionCall) goja.Value {
-	c.printer.Warn(c.formatPrinterOutput(call))
-	return goja.Undefined()
-}
-
-func (c *Console) error(call goja.FunctionCall) goja.Value {
-	c.printer.Error(c.formatPrinterOutput(call))
-	return goja.Undefined()
-}
-
 type Option func(*ConsoleModule)
 
 type ConsoleModule struct {
@@ -69,18 +99,25 @@ type ConsoleModule struct {
 }
 
 func (m *ConsoleModule) Enable(runtime *goja.Runtime) {
-	console := &Console{
-		runtime: runtime,
-		printer: m.printer,
-	}
-	obj := runtime.NewObject()
-	obj.Set("log", console.log)
-	obj.Set("warn", console.warn)
-	obj.Set("error", console.error)
-	runtime.Set("console", obj)
+	console := newConsole(runtime, m.printer).object()
+	console.Set("Console", m.constructor(runtime))
+	runtime.Set("console", console)
 }
 
+// Globals returns the names of the globals set by Enable.
+func (m *ConsoleModule) Globals() []string {
+	return []string{"console"}
+}
+
+// Export exports the global console like Node.js does, the Console class is available as a property. A console
+// using the printer of the module is created if the global console isn't set.
 func (m *ConsoleModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	console, ok := runtime.Get("console").(*goja.Object)
+	if !ok || console.Get("Console") == nil {
+		console = newConsole(runtime, m.printer).object()
+		console.Set("Console", m.constructor(runtime))
+	}
+	module.Set("exports", console)
 }
 
 func WithPrinter(printer Printer) Option {
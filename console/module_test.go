@@ -1,9 +1,13 @@
 package console
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/require"
 )
 
 func TestConsole(t *testing.T) {
@@ -60,3 +64,214 @@ func TestConsoleWithPrinter(t *testing.T) {
 		t.Fatal("lastPrint not 'warn'", lastPrint)
 	}
 }
+
+type levelPrinter []string
+
+func (p *levelPrinter) Log(s string)   { p.Print(LevelLog, s) }
+func (p *levelPrinter) Warn(s string)  { p.Print(LevelWarn, s) }
+func (p *levelPrinter) Error(s string) { p.Print(LevelError, s) }
+
+func (p *levelPrinter) Print(level Level, s string) {
+	*p = append(*p, level.String()+": "+s)
+}
+
+func TestConsoleMethods(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	elapsed := time.Duration(0)
+	now = func() time.Time {
+		return start.Add(elapsed)
+	}
+	defer func() {
+		now = time.Now
+	}()
+
+	vm := goja.New()
+	printer := &levelPrinter{}
+	New(WithPrinter(printer)).Enable(vm)
+	vm.Set("elapse", func(ms int) {
+		elapsed += time.Duration(ms) * time.Millisecond
+	})
+	_, err := vm.RunScript("test.js", `
+	console.info("info %d", 1);
+	console.debug("debug");
+	console.assert(true, "not printed");
+	console.assert(false, "failed %s", "x");
+	console.assert(false, 1);
+	console.assert(false);
+	console.group("group");
+	console.log("a\nb");
+	console.groupCollapsed();
+	console.dir({ a: [1, "s"], f() {} });
+	console.groupEnd();
+	console.groupEnd();
+	console.groupEnd();
+	console.dirxml("xml");
+	console.count();
+	console.count("x");
+	console.count();
+	console.countReset();
+	console.countReset("y");
+	console.count();
+	console.time();
+	console.time();
+	elapse(1.5);
+	console.timeLog(undefined, "data", 1);
+	elapse(1500);
+	console.timeEnd();
+	console.timeEnd();
+	console.time("long");
+	elapse(3723004);
+	console.timeEnd("long");
+	console.table([{ a: 1, b: "Y" }, { a: "Z", b: 2 }]);
+	console.table({ x: 1, y: { v: true } }, ["v"]);
+	console.table(["a", { b: 1 }]);
+	console.table(1);
+	function f() {
+		console.trace("here %d", 1);
+	}
+	[0].forEach(f);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"info: info 1",
+		"debug: debug",
+		"warn: Assertion failed: failed x",
+		"warn: Assertion failed 1",
+		"warn: Assertion failed",
+		"log: group",
+		"log:   a\n  b",
+		"log:     { a: [ 1, 's' ], f: [Function: f] }",
+		"log: xml",
+		"log: default: 1",
+		"log: x: 1",
+		"log: default: 2",
+		"warn: Count for 'y' does not exist",
+		"log: default: 1",
+		"warn: Warning: Label 'default' already exists for console.time()",
+		"log: default: 1ms data 1",
+		"log: default: 1.501s",
+		"warn: Warning: No such label 'default' for console.timeEnd()",
+		"log: long: 1:02:03.004 (h:mm:ss.mmm)",
+		`log: ┌─────────┬─────┬─────┐
+│ (index) │  a  │  b  │
+├─────────┼─────┼─────┤
+│    0    │  1  │ 'Y' │
+│    1    │ 'Z' │  2  │
+└─────────┴─────┴─────┘`,
+		`log: ┌─────────┬──────┐
+│ (index) │  v   │
+├─────────┼──────┤
+│    x    │      │
+│    y    │ true │
+└─────────┴──────┘`,
+		`log: ┌─────────┬───┬────────┐
+│ (index) │ b │ Values │
+├─────────┼───┼────────┤
+│    0    │   │  'a'   │
+│    1    │ 1 │        │
+└─────────┴───┴────────┘`,
+		"log: 1",
+		"error: Trace: here 1\n    at f (test.js:37:16)\n    at forEach (native)\n    at test.js:39:13",
+	}
+	if len(*printer) != len(expected) {
+		t.Fatalf("Unexpected output:\n%s", strings.Join(*printer, "\n"))
+	}
+	for i, s := range *printer {
+		if s != expected[i] {
+			t.Errorf("%d: expected:\n%s\ngot:\n%s", i, expected[i], s)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	for _, tc := range []struct {
+		ms       float64
+		expected string
+	}{
+		{0.1234, "0.123ms"},
+		{12, "12ms"},
+		{999.9999, "1000ms"},
+		{1000, "1.000s"},
+		{61500, "1:01.500 (m:ss.mmm)"},
+		{3600000, "1:00:00.000 (h:mm:ss.mmm)"},
+	} {
+		if s := formatTime(tc.ms); s != tc.expected {
+			t.Errorf("%v: got %q expected %q", tc.ms, s, tc.expected)
+		}
+	}
+}
+
+func TestConsoleClass(t *testing.T) {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, New(WithPrinter(PrinterFunc(func(s string) {
+		t.Errorf("Unexpected output of the global console: %q", s)
+	}))))
+	registry.Enable(vm)
+
+	var stdout, stderr bytes.Buffer
+	vm.Set("stdout", &stdout)
+	vm.Set("stderr", &stderr)
+	v, err := vm.RunString(`
+	const { Console } = require("node:console");
+	const logger = new Console(stdout, stderr);
+	logger.log("hello %s", "world");
+	logger.group("group");
+	logger.error("error");
+	logger.groupEnd();
+	logger.warn("warn");
+
+	const chunks = [];
+	const stream = { write(chunk) { chunks.push(chunk); } };
+	const custom = new Console({ stdout: stream, groupIndentation: 4, inspectOptions: { depth: 0 } });
+	custom.group();
+	custom.info("info");
+	custom.dir({ a: { b: 1 } });
+
+	const errors = [];
+	for (const options of [{}, { stdout: {} }, { stdout: stream, stderr: 1 }, { stdout: stream, colorMode: "x" },
+		{ stdout: stream, colorMode: true, inspectOptions: { colors: true } }, { stdout: stream, groupIndentation: 1001 }]) {
+		try {
+			new Console(options);
+		} catch (e) {
+			errors.push(e.name + " " + e.code + ": " + e.message);
+		}
+	}
+	const throwing = new Console({ stdout: { write() { throw new Error("write failed"); } } });
+	throwing.log("ignored");
+	try {
+		new Console({ stdout: { write() { throw new Error("write failed"); } }, ignoreErrors: false }).log("thrown");
+	} catch (e) {
+		errors.push(e.message);
+	}
+
+	[chunks.join(""), errors.join("\n"), require("node:console") === console].join("\n---\n");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stdout.String(); s != "hello world\ngroup\n" {
+		t.Fatalf("Unexpected stdout: %q", s)
+	}
+	if s := stderr.String(); s != "  error\nwarn\n" {
+		t.Fatalf("Unexpected stderr: %q", s)
+	}
+	const expected = `    info
+    { a: [Object] }
+
+---
+TypeError ERR_CONSOLE_WRITABLE_STREAM: Console expects a writable stream instance for stdout
+TypeError ERR_CONSOLE_WRITABLE_STREAM: Console expects a writable stream instance for stdout
+TypeError ERR_CONSOLE_WRITABLE_STREAM: Console expects a writable stream instance for stderr
+TypeError ERR_INVALID_ARG_VALUE: The argument 'colorMode' is invalid. Received 'x'
+TypeError ERR_INCOMPATIBLE_OPTION_PAIR: Option "options.inspectOptions.color" cannot be used in combination with option "colorMode"
+RangeError ERR_OUT_OF_RANGE: The value of "groupIndentation" is out of range. It must be >= 0 && <= 1000. Received 1001
+write failed
+---
+true`
+	if s := v.String(); s != expected {
+		t.Fatalf("Unexpected result:\n%s", s)
+	}
+}
@@ -0,0 +1,160 @@
+//go:build go1.21
+
+package console
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/util"
+)
+
+// SlogPrinter is a RecordPrinter which emits the console messages as structured records to a slog.Handler.
+// The record message is the formatted message and the attributes are:
+//   - "method": the name of the console method
+//   - "args": the arguments of the call exported to Go values
+//   - "group": the console.group() nesting level if it's not zero
+//   - slog.SourceKey: the script file and line which called the console method
+//   - "runtime": the runtime identifier if WithRuntimeID() is used
+type SlogPrinter struct {
+	handler   slog.Handler
+	runtimeID func(*goja.Runtime) string
+}
+
+type SlogOption func(*SlogPrinter)
+
+// WithRuntimeID sets the function which returns the identifier of the runtime added to every record.
+func WithRuntimeID(runtimeID func(*goja.Runtime) string) SlogOption {
+	return func(p *SlogPrinter) {
+		p.runtimeID = runtimeID
+	}
+}
+
+func NewSlogPrinter(handler slog.Handler, opts ...SlogOption) *SlogPrinter {
+	p := &SlogPrinter{
+		handler: handler,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SlogLevel converts the console level to the slog level. The log and info levels are both slog.LevelInfo.
+func SlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func (p *SlogPrinter) Log(s string) {
+	p.PrintRecord(Record{Time: now(), Level: LevelLog, Message: s})
+}
+
+func (p *SlogPrinter) Warn(s string) {
+	p.PrintRecord(Record{Time: now(), Level: LevelWarn, Message: s})
+}
+
+func (p *SlogPrinter) Error(s string) {
+	p.PrintRecord(Record{Time: now(), Level: LevelError, Message: s})
+}
+
+func (p *SlogPrinter) PrintRecord(record Record) {
+	ctx := context.Background()
+	level := SlogLevel(record.Level)
+	if !p.handler.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(record.Time, level, record.Message, 0)
+	if record.Method != "" {
+		r.AddAttrs(slog.String("method", record.Method))
+	}
+	if len(record.Args) > 0 {
+		args := make([]interface{}, len(record.Args))
+		for i, arg := range record.Args {
+			args[i] = exportArg(arg.Export())
+		}
+		r.AddAttrs(slog.Any("args", args))
+	}
+	if record.Group > 0 {
+		r.AddAttrs(slog.Int("group", record.Group))
+	}
+	if record.File != "" {
+		r.AddAttrs(slog.Any(slog.SourceKey, &slog.Source{File: record.File, Line: record.Line}))
+	}
+	if p.runtimeID != nil && record.Runtime != nil {
+		r.AddAttrs(slog.String("runtime", p.runtimeID(record.Runtime)))
+	}
+	p.handler.Handle(ctx, r)
+}
+
+// maxExportDepth is the nesting level of the exported arguments from which objects and arrays are replaced.
+const maxExportDepth = 10
+
+// exportArg replaces the functions in the exported value, which cannot be encoded by the slog handlers. Circular
+// references are replaced with [Circular] and the values nested deeper than maxExportDepth with [Object] or
+// [Array].
+func exportArg(v interface{}) interface{} {
+	return (&argExporter{seen: make(map[uintptr]bool)}).export(v, 0)
+}
+
+type argExporter struct {
+	// seen holds the maps and slices which are being exported
+	seen map[uintptr]bool
+}
+
+func (e *argExporter) enter(v interface{}) (uintptr, bool) {
+	ptr := reflect.ValueOf(v).Pointer()
+	if ptr == 0 {
+		return 0, true
+	}
+	if e.seen[ptr] {
+		return 0, false
+	}
+	e.seen[ptr] = true
+	return ptr, true
+}
+
+func (e *argExporter) export(v interface{}, depth int) interface{} {
+	switch v := v.(type) {
+	case func(goja.FunctionCall) goja.Value:
+		return "[Function]"
+	case map[string]interface{}:
+		if depth >= maxExportDepth {
+			return "[Object]"
+		}
+		ptr, ok := e.enter(v)
+		if !ok {
+			return util.CircularNotation
+		}
+		defer delete(e.seen, ptr)
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = e.export(value, depth+1)
+		}
+		return m
+	case []interface{}:
+		if depth >= maxExportDepth {
+			return "[Array]"
+		}
+		ptr, ok := e.enter(v)
+		if !ok {
+			return util.CircularNotation
+		}
+		defer delete(e.seen, ptr)
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = e.export(value, depth+1)
+		}
+		return s
+	}
+	return v
+}
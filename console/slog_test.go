@@ -0,0 +1,84 @@
+//go:build go1.21
+
+package console
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestSlogPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	vm := goja.New()
+	printer := NewSlogPrinter(handler, WithRuntimeID(func(*goja.Runtime) string {
+		return "vm1"
+	}))
+	New(WithPrinter(printer)).Enable(vm)
+	_, err := vm.RunScript("script.js", `
+	console.log("count: %d", 1, { a: [1, "x"], f() {} });
+	console.debug("hidden");
+	console.group();
+	console.warn("warning");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	printer.Error("from go")
+
+	expected := `{"level":"INFO","msg":"count: 1 { a: [ 1, 'x' ], f: [Function: f] }","method":"log","args":["count: %d",1,{"a":[1,"x"],"f":"[Function]"}],"source":{"file":"script.js","line":2},"runtime":"vm1"}
+{"level":"WARN","msg":"warning","method":"warn","args":["warning"],"group":1,"source":{"file":"script.js","line":5},"runtime":"vm1"}
+{"level":"ERROR","msg":"from go"}
+`
+	if s := buf.String(); s != expected {
+		t.Fatalf("Unexpected output:\n%s", strings.TrimSpace(s))
+	}
+}
+
+func TestSlogPrinterCircular(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	vm := goja.New()
+	New(WithPrinter(NewSlogPrinter(handler))).Enable(vm)
+	_, err := vm.RunString(`
+	const o = { shared: [1] };
+	o.self = o;
+	o.list = [o, o.shared];
+	console.log("x", o);
+	let deep = {};
+	for (let i = 0; i < 20; i++) deep = { d: deep };
+	console.log(deep);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected output:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[0], `"args":["x",{"list":["[Circular]",[1]],"self":"[Circular]","shared":[1]}]`) {
+		t.Fatalf("Unexpected record: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `{"d":"[Object]"}`) {
+		t.Fatalf("Unexpected record: %s", lines[1])
+	}
+}
@@ -0,0 +1,149 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/util"
+)
+
+// writerPrinter prints the log messages to stdout and the warnings and errors to stderr.
+type writerPrinter struct {
+	stdout, stderr func(s string)
+}
+
+func (p *writerPrinter) Log(s string) { p.stdout(s + "\n") }
+
+func (p *writerPrinter) Warn(s string) { p.stderr(s + "\n") }
+
+func (p *writerPrinter) Error(s string) { p.stderr(s + "\n") }
+
+// NewWriterPrinter returns a Printer which writes every message followed by a newline to stdout, warnings and
+// errors are written to stderr. Write errors are ignored.
+func NewWriterPrinter(stdout, stderr io.Writer) Printer {
+	return &writerPrinter{
+		stdout: func(s string) { io.WriteString(stdout, s) },
+		stderr: func(s string) { io.WriteString(stderr, s) },
+	}
+}
+
+func newError(runtime *goja.Runtime, ctor, code, format string, args ...interface{}) *goja.Object {
+	err, _ := runtime.New(runtime.GlobalObject().Get(ctor), runtime.ToValue(fmt.Sprintf(format, args...)))
+	err.Set("code", code)
+	return err
+}
+
+func writableStreamError(runtime *goja.Runtime, name string) *goja.Object {
+	return newError(runtime, "TypeError", "ERR_CONSOLE_WRITABLE_STREAM", "Console expects a writable stream instance for %s", name)
+}
+
+// isWriter reports whether the value is a Go io.Writer passed from the host or an object with a write method.
+func isWriter(v goja.Value) bool {
+	obj, ok := v.(*goja.Object)
+	if !ok || obj == nil {
+		return false
+	}
+	if _, ok := obj.Export().(io.Writer); ok {
+		return true
+	}
+	_, ok = goja.AssertFunction(obj.Get("write"))
+	return ok
+}
+
+// streamWriter returns a function writing to the stream, which is either a Go io.Writer or an object with a
+// write method like a Node.js writable stream. Errors are thrown unless they're ignored.
+func streamWriter(runtime *goja.Runtime, v goja.Value, name string, ignoreErrors bool) func(s string) {
+	if !isWriter(v) {
+		panic(writableStreamError(runtime, name))
+	}
+	obj := v.(*goja.Object)
+	if w, ok := obj.Export().(io.Writer); ok {
+		return func(s string) {
+			if _, err := io.WriteString(w, s); err != nil && !ignoreErrors {
+				panic(runtime.NewGoError(err))
+			}
+		}
+	}
+	return func(s string) {
+		// The write method is looked up on every call like Node.js does
+		write, ok := goja.AssertFunction(obj.Get("write"))
+		if !ok {
+			panic(writableStreamError(runtime, name))
+		}
+		if _, err := write(obj, runtime.ToValue(s)); err != nil && !ignoreErrors {
+			panic(err)
+		}
+	}
+}
+
+// constructor returns the Console class of node:console. new Console(stdout[, stderr][, ignoreErrors]) and
+// new Console(options) create a console writing to the streams, see streamWriter.
+func (m *ConsoleModule) constructor(runtime *goja.Runtime) *goja.Object {
+	return runtime.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		options, _ := call.Argument(0).(*goja.Object)
+		if options == nil || isWriter(options) {
+			options = runtime.NewObject()
+			options.Set("stdout", call.Argument(0))
+			options.Set("stderr", call.Argument(1))
+			options.Set("ignoreErrors", call.Argument(2))
+		}
+		ignoreErrors := true
+		if v := options.Get("ignoreErrors"); v != nil && !goja.IsUndefined(v) {
+			ignoreErrors = v.ToBoolean()
+		}
+		stdout := streamWriter(runtime, options.Get("stdout"), "stdout", ignoreErrors)
+		stderr := stdout
+		if v := options.Get("stderr"); v != nil && !goja.IsUndefined(v) {
+			stderr = streamWriter(runtime, v, "stderr", ignoreErrors)
+		}
+		c := newConsole(runtime, &writerPrinter{stdout: stdout, stderr: stderr})
+		c.configure(options)
+		return c.define(call.This)
+	}).(*goja.Object)
+}
+
+// configure applies the colorMode, inspectOptions and groupIndentation options of the Console constructor.
+func (c *Console) configure(options *goja.Object) {
+	runtime := c.runtime
+	colorMode := options.Get("colorMode")
+	switch {
+	case colorMode == nil || goja.IsUndefined(colorMode):
+		colorMode = runtime.ToValue("auto")
+	case colorMode.Export() == "auto":
+	default:
+		if _, isBool := colorMode.Export().(bool); !isBool {
+			panic(newError(runtime, "TypeError", "ERR_INVALID_ARG_VALUE", "The argument 'colorMode' is invalid. Received %s", util.Inspect(runtime, colorMode, util.DefaultInspectOptions)))
+		}
+		// The streams aren't terminals, so colors are only used when they're enabled explicitly
+		c.colors = colorMode.ToBoolean()
+	}
+
+	if v := options.Get("inspectOptions"); v != nil && !goja.IsUndefined(v) {
+		inspectOptions, ok := v.(*goja.Object)
+		if !ok || goja.IsNull(v) {
+			panic(newError(runtime, "TypeError", "ERR_INVALID_ARG_TYPE", `The "options.inspectOptions" property must be of type object. Received %s`, util.Inspect(runtime, v, util.DefaultInspectOptions)))
+		}
+		if colors := inspectOptions.Get("colors"); colors != nil && !goja.IsUndefined(colors) {
+			if colorMode.Export() != "auto" {
+				panic(newError(runtime, "TypeError", "ERR_INCOMPATIBLE_OPTION_PAIR", `Option "options.inspectOptions.color" cannot be used in combination with option "colorMode"`))
+			}
+			c.colors = colors.ToBoolean()
+		}
+		c.inspectOptions = inspectOptions
+	}
+
+	if v := options.Get("groupIndentation"); v != nil && !goja.IsUndefined(v) {
+		if _, isNumber := v.Export().(int64); !isNumber {
+			if _, isFloat := v.Export().(float64); !isFloat {
+				panic(newError(runtime, "TypeError", "ERR_INVALID_ARG_TYPE", `The "groupIndentation" argument must be of type number. Received %s`, util.Inspect(runtime, v, util.DefaultInspectOptions)))
+			}
+		}
+		n := v.ToFloat()
+		if n != float64(int64(n)) || n < 0 || n > 1000 {
+			panic(newError(runtime, "RangeError", "ERR_OUT_OF_RANGE", `The value of "groupIndentation" is out of range. It must be >= 0 && <= 1000. Received %s`, v.String()))
+		}
+		c.groupIndentation = strings.Repeat(" ", int(n))
+	}
+}
@@ -0,0 +1,156 @@
+package console
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/util"
+)
+
+const (
+	indexKey  = "(index)"
+	valuesKey = "Values"
+)
+
+// inspectCell returns the representation of the value in a cell of console.table(). Objects with more than
+// two keys are abbreviated.
+func (c *Console) inspectCell(v goja.Value) string {
+	options := util.DefaultInspectOptions
+	options.Depth = 0
+	if obj, ok := v.(*goja.Object); ok && obj.ClassName() != "Array" {
+		if _, isFunc := goja.AssertFunction(obj); !isFunc && len(obj.Keys()) > 2 {
+			options.Depth = -1
+		}
+	}
+	options.MaxArrayLength = 3
+	options.BreakLength = math.MaxInt32
+	return util.Inspect(c.runtime, v, c.getInspectOptions(options))
+}
+
+func (c *Console) table(call goja.FunctionCall) goja.Value {
+	data, ok := call.Argument(0).(*goja.Object)
+	if !ok {
+		return c.printArgs(LevelLog, "table", call.Arguments)
+	}
+	var properties []string
+	if props := call.Argument(1); !goja.IsUndefined(props) {
+		if err := c.runtime.ExportTo(props, &properties); err != nil {
+			panic(c.runtime.NewTypeError("The \"properties\" argument must be an array of strings"))
+		}
+	}
+
+	var (
+		keys          []string
+		columns       = make(map[string][]string)
+		values        []string
+		hasPrimitives bool
+	)
+	indexKeys := data.Keys()
+	for i, index := range indexKeys {
+		item := data.Get(index)
+		itemObj, isObject := item.(*goja.Object)
+		if properties == nil && !isObject {
+			hasPrimitives = true
+			values = setCell(values, i, c.inspectCell(item))
+			continue
+		}
+		itemKeys := properties
+		if itemKeys == nil {
+			itemKeys = itemObj.Keys()
+		}
+		for _, key := range itemKeys {
+			if _, exists := columns[key]; !exists {
+				keys = append(keys, key)
+				columns[key] = nil
+			}
+			if isObject && hasOwnProperty(itemObj, key) {
+				columns[key] = setCell(columns[key], i, c.inspectCell(itemObj.Get(key)))
+			}
+		}
+	}
+
+	head := append([]string{indexKey}, keys...)
+	cols := [][]string{indexKeys}
+	for _, key := range keys {
+		cols = append(cols, columns[key])
+	}
+	if hasPrimitives {
+		head = append(head, valuesKey)
+		cols = append(cols, values)
+	}
+	c.print(LevelLog, "table", renderTable(head, cols), call.Arguments)
+	return goja.Undefined()
+}
+
+func hasOwnProperty(obj *goja.Object, key string) bool {
+	for _, k := range obj.Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// setCell sets the cell of the column at the row index, the missing cells are empty.
+func setCell(column []string, i int, value string) []string {
+	for len(column) <= i {
+		column = append(column, "")
+	}
+	column[i] = value
+	return column
+}
+
+func renderRow(row []string, widths []int) string {
+	var b strings.Builder
+	b.WriteString("│ ")
+	for i, cell := range row {
+		needed := widths[i] - utf8.RuneCountInString(cell)
+		b.WriteString(strings.Repeat(" ", needed/2))
+		b.WriteString(cell)
+		b.WriteString(strings.Repeat(" ", needed-needed/2))
+		if i != len(row)-1 {
+			b.WriteString(" │ ")
+		}
+	}
+	b.WriteString(" │")
+	return b.String()
+}
+
+// renderTable renders the columns with the given heads like the cli_table of Node.js.
+func renderTable(head []string, columns [][]string) string {
+	widths := make([]int, len(head))
+	rowCount := 0
+	for i, h := range head {
+		widths[i] = utf8.RuneCountInString(h)
+		if len(columns[i]) > rowCount {
+			rowCount = len(columns[i])
+		}
+	}
+	rows := make([][]string, rowCount)
+	for j := range rows {
+		rows[j] = make([]string, len(head))
+		for i, column := range columns {
+			if j < len(column) {
+				rows[j][i] = column[j]
+			}
+			if w := utf8.RuneCountInString(rows[j][i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	divider := make([]string, len(widths))
+	for i, w := range widths {
+		divider[i] = strings.Repeat("─", w+2)
+	}
+	var b strings.Builder
+	b.WriteString("┌" + strings.Join(divider, "┬") + "┐\n")
+	b.WriteString(renderRow(head, widths) + "\n")
+	b.WriteString("├" + strings.Join(divider, "┼") + "┤\n")
+	for _, row := range rows {
+		b.WriteString(renderRow(row, widths) + "\n")
+	}
+	b.WriteString("└" + strings.Join(divider, "┴") + "┘")
+	return b.String()
+}
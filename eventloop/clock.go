@@ -0,0 +1,79 @@
+package eventloop
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a virtual clock for testing scripts which use timers. Time only moves when Advance is
+// called, which fires the due timeouts, intervals and immediates of the loops using the clock in order.
+// Timers of a loop using a FakeClock do not keep the loop running, so Run returns as soon as there is
+// nothing left to do other than waiting for the clock. The clock is also used as the time source of
+// the runtime (i.e. for Date).
+type FakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	loops []*EventLoop
+}
+
+// NewFakeClock creates a FakeClock which starts at the specified time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) attach(loop *EventLoop) {
+	c.mu.Lock()
+	c.loops = append(c.loops, loop)
+	c.mu.Unlock()
+}
+
+// next returns the loop with the earliest timer which is due at the target time.
+func (c *FakeClock) next(target time.Time) (*EventLoop, time.Time) {
+	var next *EventLoop
+	var when time.Time
+	for _, loop := range c.loops {
+		if len(loop.timers) == 0 {
+			continue
+		}
+		if w := loop.timers[0].when; !w.After(target) && (next == nil || w.Before(when)) {
+			next, when = loop, w
+		}
+	}
+	return next, when
+}
+
+// Advance moves the clock forward by d. The timers which become due run synchronously in the order of
+// their deadlines, with the clock set to the deadline of each timer, followed by the immediates scheduled
+// up to that point.
+// Advance runs jobs of the loops which use the clock, so it must be called either from the loop (i.e. from
+// Run() or RunOnLoop()) or while the loop is not running.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		loop, when := c.next(target)
+		if loop == nil {
+			break
+		}
+		if when.After(c.now) {
+			c.now = when
+		}
+		c.mu.Unlock()
+		loop.runTimers(when)
+		loop.runAux()
+		c.mu.Lock()
+	}
+	c.now = target
+	loops := c.loops
+	c.mu.Unlock()
+	for _, loop := range loops {
+		loop.runAux()
+	}
+}
@@ -0,0 +1,170 @@
+package eventloop
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dop251/goja"
+)
+
+// RejectionMode defines how unhandled promise rejections are treated, like the --unhandled-rejections
+// flag of Node.
+type RejectionMode int
+
+const (
+	// RejectionsThrow emits 'unhandledRejection' on process. If there is no listener the rejection is
+	// raised as an uncaught exception.
+	RejectionsThrow RejectionMode = iota
+	// RejectionsStrict raises the rejection as an uncaught exception. If the exception is handled,
+	// 'unhandledRejection' is emitted.
+	RejectionsStrict
+	// RejectionsWarn emits 'unhandledRejection' and always reports the rejection to the error handler
+	// without raising it.
+	RejectionsWarn
+	// RejectionsNone emits 'unhandledRejection' only.
+	RejectionsNone
+)
+
+// UnhandledRejectionError is reported for a promise which was rejected without a handler.
+type UnhandledRejectionError struct {
+	Promise *goja.Promise
+	Reason  goja.Value
+}
+
+func (e *UnhandledRejectionError) Error() string {
+	return fmt.Sprintf("unhandled promise rejection: %v", e.Reason)
+}
+
+// DefaultErrorHandler prints the error to os.Stderr.
+func DefaultErrorHandler(err error) {
+	fmt.Fprintln(os.Stderr, err)
+}
+
+// WithErrorHandler sets the function which is called with the errors thrown from callbacks run by the loop
+// (and with unhandled rejections, depending on the RejectionMode) when there are no 'uncaughtException'
+// listeners on process, or when a listener throws. The handler is called on the loop, so it may use
+// StopNoWait() to terminate the loop.
+func WithErrorHandler(handler func(err error)) Option {
+	return func(loop *EventLoop) {
+		loop.errorHandler = handler
+	}
+}
+
+// WithUnhandledRejections sets how unhandled promise rejections are treated. The default is RejectionsThrow.
+func WithUnhandledRejections(mode RejectionMode) Option {
+	return func(loop *EventLoop) {
+		loop.rejectionMode = mode
+	}
+}
+
+func (loop *EventLoop) trackRejection(p *goja.Promise, op goja.PromiseRejectionOperation) {
+	switch op {
+	case goja.PromiseRejectionReject:
+		loop.rejections = append(loop.rejections, p)
+	case goja.PromiseRejectionHandle:
+		for i, r := range loop.rejections {
+			if r == p {
+				loop.rejections = append(loop.rejections[:i], loop.rejections[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// emitProcess emits the event on the process object if it is enabled. It returns false if there are
+// no listeners.
+func (loop *EventLoop) emitProcess(event string, args ...goja.Value) (handled bool, err error) {
+	process, ok := loop.vm.GlobalObject().Get("process").(*goja.Object)
+	if !ok {
+		return false, nil
+	}
+	emit, ok := goja.AssertFunction(process.Get("emit"))
+	if !ok {
+		return false, nil
+	}
+	ret, err := emit(process, append([]goja.Value{loop.vm.ToValue(event)}, args...)...)
+	if err != nil {
+		return false, err
+	}
+	return ret.ToBoolean(), nil
+}
+
+// ReportError reports an exception thrown by a callback which the caller ran on the loop, the same way as the
+// ones thrown by timer callbacks: it is emitted as process 'uncaughtException' or passed to the error handler.
+// It must be called on the loop, e.g. from a function passed to RunOnLoop.
+func (loop *EventLoop) ReportError(err error) {
+	loop.handleError(err)
+}
+
+// handleError handles an exception thrown by a callback run by the loop.
+func (loop *EventLoop) handleError(err error) {
+	if _, ok := err.(*goja.InterruptedError); ok {
+		return
+	}
+	var value goja.Value
+	if ex, ok := err.(*goja.Exception); ok {
+		value = ex.Value()
+	} else if rejection, ok := err.(*UnhandledRejectionError); ok {
+		value = loop.rejectionError(rejection)
+	} else {
+		value = loop.vm.NewGoError(err)
+	}
+	loop.uncaughtException(err, value)
+}
+
+func (loop *EventLoop) uncaughtException(err error, value goja.Value) bool {
+	handled, emitErr := loop.emitProcess("uncaughtException", value, loop.vm.ToValue("uncaughtException"))
+	if emitErr != nil {
+		err, handled = emitErr, false
+	}
+	if !handled {
+		loop.errorHandler(err)
+	}
+	return handled
+}
+
+// rejectionError returns the value used as the uncaught exception of an unhandled rejection.
+func (loop *EventLoop) rejectionError(e *UnhandledRejectionError) goja.Value {
+	if obj, ok := e.Reason.(*goja.Object); ok && obj.ClassName() == "Error" {
+		return obj
+	}
+	ctor := loop.vm.Get("Error")
+	obj, _ := loop.vm.New(ctor, loop.vm.ToValue(fmt.Sprintf("This error originated either by throwing inside of an "+
+		"async function without a catch block, or by rejecting a promise which was not handled with .catch(). "+
+		"The promise rejected with the reason \"%v\".", e.Reason)))
+	obj.Set("code", "ERR_UNHANDLED_REJECTION")
+	return obj
+}
+
+// processRejections reports the promises which were rejected during the last job and are still not handled.
+func (loop *EventLoop) processRejections() {
+	for len(loop.rejections) > 0 {
+		p := loop.rejections[0]
+		loop.rejections = loop.rejections[1:]
+		e := &UnhandledRejectionError{Promise: p, Reason: p.Result()}
+		emit := func() bool {
+			handled, err := loop.emitProcess("unhandledRejection", e.Reason, loop.vm.ToValue(p))
+			if err != nil {
+				loop.handleError(err)
+				return true
+			}
+			return handled
+		}
+		switch loop.rejectionMode {
+		case RejectionsThrow:
+			if !emit() {
+				loop.uncaughtException(e, loop.rejectionError(e))
+			}
+		case RejectionsStrict:
+			if loop.uncaughtException(e, loop.rejectionError(e)) {
+				emit()
+			}
+		case RejectionsWarn:
+			emit()
+			loop.errorHandler(e)
+		case RejectionsNone:
+			emit()
+		}
+	}
+	loop.rejections = nil
+}
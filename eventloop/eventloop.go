@@ -1,40 +1,67 @@
 package eventloop
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/khanghh/goja-nodejs/console"
+	"github.com/khanghh/goja-nodejs/events"
 	"github.com/khanghh/goja-nodejs/require"
+	"github.com/khanghh/goja-nodejs/timers"
 )
 
 type job struct {
 	cancelled bool
+	unref     bool
+	virtual   bool
+	id        int64
 	fn        func()
 }
 
+// keepsAlive reports whether the job keeps the loop running while it is pending. Unref'd jobs and
+// timers of a FakeClock do not.
+func (j *job) keepsAlive() bool {
+	return !j.unref && !j.virtual
+}
+
 type Timer struct {
 	job
-	timer *time.Timer
+	entry *timerEntry
+	delay time.Duration
+	fired bool
 }
 
 type Interval struct {
 	job
-	ticker   *time.Ticker
-	stopChan chan struct{}
+	entry *timerEntry
+	delay time.Duration
 }
 
 type Immediate struct {
 	job
 }
 
+// CancelledError is returned by RunContext and Err when the loop was stopped because its context was done.
+// Any script running at that moment was interrupted and the pending timers were cancelled.
+type CancelledError struct {
+	Err     error // the error returned by ctx.Err()
+	Pending int   // the number of timers and immediates which were cancelled
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("event loop stopped: %v (%d pending jobs cancelled)", e.Err, e.Pending)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
 type EventLoop struct {
 	vm       *goja.Runtime
-	jobChan  chan func()
 	jobCount int32
 	canRun   int32
 
@@ -46,8 +73,31 @@ type EventLoop struct {
 	stopLock sync.Mutex
 	stopCond *sync.Cond
 	running  bool
+	finished bool
+
+	stopWatch func() error
+	err       error
 
 	registry *require.Registry
+
+	clock      *FakeClock
+	timers     timerQueue
+	timerSeq   uint64
+	immediates map[*Immediate]struct{}
+
+	errorHandler  func(error)
+	rejectionMode RejectionMode
+	rejections    []*goja.Promise
+
+	enableProcess bool
+	enableTimers  bool
+	runner        goja.Callable
+	ticks         []tick
+
+	timeoutProto, immediateProto *goja.Object
+	handleSym                    *goja.Symbol
+	nextTimerId                  int64
+	knownTimers                  map[int64]interface{}
 }
 
 func NewEventLoop(opts ...Option) *EventLoop {
@@ -55,24 +105,40 @@ func NewEventLoop(opts ...Option) *EventLoop {
 
 	loop := &EventLoop{
 		vm:         vm,
-		jobChan:    make(chan func()),
 		wakeupChan: make(chan struct{}, 1),
+		immediates: make(map[*Immediate]struct{}),
 	}
 	loop.stopCond = sync.NewCond(&loop.stopLock)
 
 	for _, opt := range opts {
 		opt(loop)
 	}
+	if loop.errorHandler == nil {
+		loop.errorHandler = DefaultErrorHandler
+	}
+	vm.SetPromiseRejectionTracker(loop.trackRejection)
 	if loop.registry == nil {
 		loop.registry = new(require.Registry)
 	}
-	loop.registry.Enable(vm)
+	var enableOpts []require.EnableOption
+	if loop.enableTimers {
+		events.Default().Enable(vm)
+		enableOpts = append(enableOpts,
+			require.WithNativeModule(timers.ModuleName, timers.Default()),
+			require.WithNativeModule(timers.PromisesModuleName, timers.Promises()))
+	}
+	loop.registry.Enable(vm, enableOpts...)
+	if loop.clock != nil {
+		vm.SetTimeSource(loop.clock.Now)
+	}
 	vm.Set("setTimeout", loop.setTimeout)
 	vm.Set("setInterval", loop.setInterval)
 	vm.Set("setImmediate", loop.setImmediate)
-	vm.Set("clearTimeout", loop.clearTimeout)
-	vm.Set("clearInterval", loop.clearInterval)
-	vm.Set("clearImmediate", loop.clearImmediate)
+	vm.Set("clearTimeout", loop.jsClearTimer)
+	vm.Set("clearInterval", loop.jsClearTimer)
+	vm.Set("clearImmediate", loop.jsClearImmediate)
+	loop.initTimerPrototypes()
+	loop.initTicks()
 
 	return loop
 }
@@ -85,6 +151,24 @@ func WithRegistry(registry *require.Registry) Option {
 	}
 }
 
+// WithClock makes the loop use the specified virtual clock for its timers instead of the real time.
+func WithClock(clock *FakeClock) Option {
+	return func(loop *EventLoop) {
+		loop.clock = clock
+		clock.attach(loop)
+	}
+}
+
+// EnableTimers makes the node:timers and node:timers/promises modules available to the loop's runtime. They
+// are added to its module resolver only, the registry passed to WithRegistry is not modified. The AbortController
+// and AbortSignal globals used by the signal option of node:timers/promises are defined as well (see the events
+// package).
+func EnableTimers() Option {
+	return func(loop *EventLoop) {
+		loop.enableTimers = true
+	}
+}
+
 func EnableConsole() Option {
 	return func(loop *EventLoop) {
 		console.Default().Enable(loop.vm)
@@ -95,6 +179,7 @@ func (loop *EventLoop) Runtime() *goja.Runtime {
 	return loop.vm
 }
 
+// JobCount returns the number of pending jobs which keep the loop running. Unref'd timers are not counted.
 func (loop *EventLoop) JobCount() int {
 	return int(loop.jobCount)
 }
@@ -112,14 +197,13 @@ func (loop *EventLoop) schedule(call goja.FunctionCall, repeating bool) goja.Val
 		}
 		f := func() {
 			if _, err := fn(nil, args...); err != nil {
-				fmt.Fprintln(os.Stderr, err)
+				loop.handleError(err)
 			}
 		}
-		loop.jobCount++
 		if repeating {
-			return loop.vm.ToValue(loop.addInterval(f, time.Duration(delay)*time.Millisecond))
+			return loop.newTimeoutObject(loop.addInterval(f, time.Duration(delay)*time.Millisecond))
 		} else {
-			return loop.vm.ToValue(loop.addTimeout(f, time.Duration(delay)*time.Millisecond))
+			return loop.newTimeoutObject(loop.addTimeout(f, time.Duration(delay)*time.Millisecond))
 		}
 	}
 	return nil
@@ -141,11 +225,10 @@ func (loop *EventLoop) setImmediate(call goja.FunctionCall) goja.Value {
 		}
 		f := func() {
 			if _, err := fn(nil, args...); err != nil {
-				fmt.Fprintln(os.Stderr, err)
+				loop.handleError(err)
 			}
 		}
-		loop.jobCount++
-		return loop.vm.ToValue(loop.addImmediate(f))
+		return loop.newImmediateObject(loop.addImmediate(f))
 	}
 	return nil
 }
@@ -157,9 +240,9 @@ func (loop *EventLoop) setImmediate(call goja.FunctionCall) goja.Value {
 // from it must not be used outside the function. SetTimeout is
 // safe to call inside or outside the loop.
 func (loop *EventLoop) SetTimeout(fn func(*goja.Runtime), timeout time.Duration) *Timer {
-	t := loop.addTimeout(func() { fn(loop.vm) }, timeout)
+	t := loop.newTimer(func() { fn(loop.vm) }, timeout)
 	loop.addAuxJob(func() {
-		loop.jobCount++
+		loop.startTimeout(t)
 	})
 	return t
 }
@@ -180,9 +263,9 @@ func (loop *EventLoop) ClearTimeout(t *Timer) {
 // the function. SetInterval is safe to call inside or outside the
 // loop.
 func (loop *EventLoop) SetInterval(fn func(*goja.Runtime), timeout time.Duration) *Interval {
-	i := loop.addInterval(func() { fn(loop.vm) }, timeout)
+	i := loop.newInterval(func() { fn(loop.vm) }, timeout)
 	loop.addAuxJob(func() {
-		loop.jobCount++
+		loop.startInterval(i)
 	})
 	return i
 }
@@ -195,14 +278,64 @@ func (loop *EventLoop) ClearInterval(i *Interval) {
 	})
 }
 
-func (loop *EventLoop) setRunning() {
+func (loop *EventLoop) setRunning(ctx context.Context) {
 	loop.stopLock.Lock()
 	defer loop.stopLock.Unlock()
 	if loop.running {
 		panic("Loop is already started")
 	}
 	loop.running = true
+	loop.finished = false
+	loop.err = nil
 	atomic.StoreInt32(&loop.canRun, 1)
+	loop.stopWatch = loop.watchContext(ctx)
+}
+
+// watchContext interrupts the runtime and stops the loop once ctx is done. The returned function stops
+// watching and returns the error if the context was done before.
+func (loop *EventLoop) watchContext(ctx context.Context) func() error {
+	if ctx.Done() == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	var err error
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			// The context may be done right after the loop has completed its work, which is not a cancellation
+			loop.stopLock.Lock()
+			if !loop.finished {
+				err = ctx.Err()
+				loop.vm.Interrupt(err)
+				atomic.StoreInt32(&loop.canRun, 0)
+				loop.wakeup()
+			}
+			loop.stopLock.Unlock()
+		case <-done:
+		}
+	}()
+	return func() error {
+		close(done)
+		<-exited
+		return err
+	}
+}
+
+// cancelJobs cancels all pending timers and immediates and returns their number.
+func (loop *EventLoop) cancelJobs() int {
+	n := len(loop.timers) + len(loop.immediates)
+	for _, e := range loop.timers {
+		e.index = -1
+		e.job.cancelled = true
+		loop.jobDone(e.job)
+	}
+	loop.timers = nil
+	for i := range loop.immediates {
+		loop.clearImmediate(i)
+	}
+	return n
 }
 
 // Run calls the specified function, starts the event loop and waits until there are no more delayed jobs to run
@@ -212,18 +345,46 @@ func (loop *EventLoop) setRunning() {
 // Do NOT use this function while the loop is already running. Use RunOnLoop() instead.
 // If the loop is already started it will panic.
 func (loop *EventLoop) Run(fn func(*goja.Runtime)) {
-	loop.setRunning()
-	fn(loop.vm)
+	loop.setRunning(context.Background())
+	loop.runMain(func() { fn(loop.vm) })
 	loop.run(false)
 }
 
+// RunContext is like Run, but stops the execution once ctx is done. In that case the running script is
+// interrupted, the loop is stopped, the pending timers are cancelled and a *CancelledError is returned.
+// If ctx is already done the function is not called.
+func (loop *EventLoop) RunContext(ctx context.Context, fn func(*goja.Runtime)) error {
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Err: err}
+	}
+	loop.setRunning(ctx)
+	loop.runMain(func() { fn(loop.vm) })
+	loop.run(false)
+	return loop.Err()
+}
+
 // Start the event loop in the background. The loop continues to run until Stop() is called.
 // If the loop is already started it will panic.
 func (loop *EventLoop) Start() {
-	loop.setRunning()
+	loop.setRunning(context.Background())
 	go loop.run(true)
 }
 
+// StartContext is like Start, but the loop is also stopped once ctx is done, interrupting the running
+// script and cancelling the pending timers. Err can be used to find out why the loop was stopped.
+func (loop *EventLoop) StartContext(ctx context.Context) {
+	loop.setRunning(ctx)
+	go loop.run(true)
+}
+
+// Err returns a *CancelledError if the last run of the loop was stopped because its context was done,
+// nil otherwise.
+func (loop *EventLoop) Err() error {
+	loop.stopLock.Lock()
+	defer loop.stopLock.Unlock()
+	return loop.err
+}
+
 // Stop the loop that was started with Start(). After this function returns there will be no more jobs executed
 // by the loop. It is possible to call Start() or Run() again after this to resume the execution.
 // Note, it does not cancel active timeouts.
@@ -262,13 +423,28 @@ func (loop *EventLoop) RunOnLoop(fn func(*goja.Runtime)) {
 	loop.addAuxJob(func() { fn(loop.vm) })
 }
 
+// RegisterCallback signals the start of an asynchronous operation (e.g. I/O performed in another goroutine)
+// and keeps the loop running until the operation is complete. The returned function must be called exactly
+// once when the operation is complete: it runs the specified function on the loop like RunOnLoop() does.
+// RegisterCallback must be called in the context of the loop, the returned function is safe to call from
+// any goroutine.
+func (loop *EventLoop) RegisterCallback() func(fn func(*goja.Runtime)) {
+	loop.jobCount++
+	return func(fn func(*goja.Runtime)) {
+		loop.RunOnLoop(func(vm *goja.Runtime) {
+			loop.jobCount--
+			fn(vm)
+		})
+	}
+}
+
 func (loop *EventLoop) runAux() {
 	loop.auxJobsLock.Lock()
 	jobs := loop.auxJobs
 	loop.auxJobs = loop.auxJobsSpare
 	loop.auxJobsLock.Unlock()
 	for i, job := range jobs {
-		job()
+		loop.runJob(job)
 		jobs[i] = nil
 	}
 	loop.auxJobsSpare = jobs[:0]
@@ -279,11 +455,24 @@ func (loop *EventLoop) run(inBackground bool) {
 	if inBackground {
 		loop.jobCount++
 	}
+	// a single timer is used to sleep until the earliest deadline
+	wait := time.NewTimer(time.Hour)
 LOOP:
-	for loop.jobCount > 0 {
+	for loop.jobCount > 0 && atomic.LoadInt32(&loop.canRun) != 0 {
+		var timerChan <-chan time.Time
+		if loop.clock == nil && len(loop.timers) > 0 {
+			if !wait.Stop() {
+				select {
+				case <-wait.C:
+				default:
+				}
+			}
+			wait.Reset(time.Until(loop.timers[0].when))
+			timerChan = wait.C
+		}
 		select {
-		case job := <-loop.jobChan:
-			job()
+		case <-timerChan:
+			loop.runTimers(time.Now())
 		case <-loop.wakeupChan:
 			loop.runAux()
 			if atomic.LoadInt32(&loop.canRun) == 0 {
@@ -291,16 +480,36 @@ LOOP:
 			}
 		}
 	}
+	wait.Stop()
 	if inBackground {
 		loop.jobCount--
 	}
+	loop.finish()
+
+	var err error
+	if loop.stopWatch != nil {
+		if ctxErr := loop.stopWatch(); ctxErr != nil {
+			loop.vm.ClearInterrupt()
+			err = &CancelledError{Err: ctxErr, Pending: loop.cancelJobs()}
+		}
+		loop.stopWatch = nil
+	}
 
 	loop.stopLock.Lock()
 	loop.running = false
+	loop.err = err
 	loop.stopLock.Unlock()
 	loop.stopCond.Broadcast()
 }
 
+// finish records that the loop doesn't run any more jobs, so the context being done from now on doesn't
+// cancel it.
+func (loop *EventLoop) finish() {
+	loop.stopLock.Lock()
+	loop.finished = true
+	loop.stopLock.Unlock()
+}
+
 func (loop *EventLoop) wakeup() {
 	select {
 	case loop.wakeupChan <- struct{}{}:
@@ -315,39 +524,75 @@ func (loop *EventLoop) addAuxJob(fn func()) {
 	loop.wakeup()
 }
 
-func (loop *EventLoop) addTimeout(f func(), timeout time.Duration) *Timer {
-	t := &Timer{
-		job: job{fn: f},
+func (loop *EventLoop) newTimer(f func(), timeout time.Duration) *Timer {
+	// https://nodejs.org/api/timers.html#settimeoutcallback-delay-args
+	if timeout <= 0 {
+		timeout = time.Millisecond
 	}
-	t.timer = time.AfterFunc(timeout, func() {
-		loop.jobChan <- func() {
-			loop.doTimeout(t)
-		}
-	})
+	return &Timer{
+		job:   job{fn: f, virtual: loop.clock != nil},
+		delay: timeout,
+	}
+}
 
+func (loop *EventLoop) addTimeout(f func(), timeout time.Duration) *Timer {
+	t := loop.newTimer(f, timeout)
+	loop.startTimeout(t)
 	return t
 }
 
-func (loop *EventLoop) addInterval(f func(), timeout time.Duration) *Interval {
+func (loop *EventLoop) startTimeout(t *Timer) {
+	loop.jobAdded(&t.job)
+	loop.armTimeout(t)
+}
+
+func (loop *EventLoop) now() time.Time {
+	if loop.clock != nil {
+		return loop.clock.Now()
+	}
+	return time.Now()
+}
+
+func (loop *EventLoop) armTimeout(t *Timer) {
+	t.entry = loop.addTimer(loop.now().Add(t.delay), &t.job, func() {
+		loop.doTimeout(t)
+	})
+}
+
+func (loop *EventLoop) newInterval(f func(), timeout time.Duration) *Interval {
 	// https://nodejs.org/api/timers.html#timers_setinterval_callback_delay_args
 	if timeout <= 0 {
 		timeout = time.Millisecond
 	}
-
-	i := &Interval{
-		job:      job{fn: f},
-		ticker:   time.NewTicker(timeout),
-		stopChan: make(chan struct{}),
+	return &Interval{
+		job:   job{fn: f, virtual: loop.clock != nil},
+		delay: timeout,
 	}
+}
 
-	go i.run(loop)
+func (loop *EventLoop) addInterval(f func(), timeout time.Duration) *Interval {
+	i := loop.newInterval(f, timeout)
+	loop.startInterval(i)
 	return i
 }
 
+func (loop *EventLoop) startInterval(i *Interval) {
+	loop.jobAdded(&i.job)
+	loop.armInterval(i)
+}
+
+func (loop *EventLoop) armInterval(i *Interval) {
+	i.entry = loop.addTimer(loop.now().Add(i.delay), &i.job, func() {
+		loop.doInterval(i)
+	})
+}
+
 func (loop *EventLoop) addImmediate(f func()) *Immediate {
 	i := &Immediate{
 		job: job{fn: f},
 	}
+	loop.jobAdded(&i.job)
+	loop.immediates[i] = struct{}{}
 	loop.addAuxJob(func() {
 		loop.doImmediate(i)
 	})
@@ -356,60 +601,99 @@ func (loop *EventLoop) addImmediate(f func()) *Immediate {
 
 func (loop *EventLoop) doTimeout(t *Timer) {
 	if !t.cancelled {
-		t.fn()
 		t.cancelled = true
-		loop.jobCount--
+		t.fired = true
+		loop.jobDone(&t.job)
+		t.fn()
 	}
 }
 
 func (loop *EventLoop) doInterval(i *Interval) {
 	if !i.cancelled {
+		loop.armInterval(i)
 		i.fn()
 	}
 }
 
 func (loop *EventLoop) doImmediate(i *Immediate) {
 	if !i.cancelled {
-		i.fn()
+		delete(loop.immediates, i)
 		i.cancelled = true
+		loop.jobDone(&i.job)
+		i.fn()
+	}
+}
+
+// jobAdded is called when a job is scheduled.
+func (loop *EventLoop) jobAdded(j *job) {
+	if j.keepsAlive() {
+		loop.jobCount++
+	}
+}
+
+// jobDone is called when a job is fired or cancelled.
+func (loop *EventLoop) jobDone(j *job) {
+	delete(loop.knownTimers, j.id)
+	if j.keepsAlive() {
 		loop.jobCount--
 	}
 }
 
+func (loop *EventLoop) setRef(j *job, ref bool) {
+	keptAlive := j.keepsAlive()
+	j.unref = !ref
+	if j.cancelled || keptAlive == j.keepsAlive() {
+		return
+	}
+	if keptAlive {
+		loop.jobCount--
+	} else {
+		loop.jobCount++
+	}
+}
+
 func (loop *EventLoop) clearTimeout(t *Timer) {
 	if t != nil && !t.cancelled {
-		t.timer.Stop()
+		loop.removeTimer(t.entry)
 		t.cancelled = true
-		loop.jobCount--
+		loop.jobDone(&t.job)
+	}
+}
+
+// refreshTimeout restarts the timer with its original delay. A timer which has already fired is
+// re-activated, a cleared one is left alone.
+func (loop *EventLoop) refreshTimeout(t *Timer) {
+	if t.cancelled {
+		if !t.fired {
+			return
+		}
+		t.cancelled = false
+		t.fired = false
+		loop.jobAdded(&t.job)
 	}
+	loop.removeTimer(t.entry)
+	loop.armTimeout(t)
 }
 
 func (loop *EventLoop) clearInterval(i *Interval) {
 	if i != nil && !i.cancelled {
 		i.cancelled = true
-		close(i.stopChan)
-		loop.jobCount--
+		loop.removeTimer(i.entry)
+		loop.jobDone(&i.job)
 	}
 }
 
-func (loop *EventLoop) clearImmediate(i *Immediate) {
-	if i != nil && !i.cancelled {
-		i.cancelled = true
-		loop.jobCount--
+func (loop *EventLoop) refreshInterval(i *Interval) {
+	if !i.cancelled {
+		loop.removeTimer(i.entry)
+		loop.armInterval(i)
 	}
 }
 
-func (i *Interval) run(loop *EventLoop) {
-L:
-	for {
-		select {
-		case <-i.stopChan:
-			i.ticker.Stop()
-			break L
-		case <-i.ticker.C:
-			loop.jobChan <- func() {
-				loop.doInterval(i)
-			}
-		}
+func (loop *EventLoop) clearImmediate(i *Immediate) {
+	if i != nil && !i.cancelled {
+		delete(loop.immediates, i)
+		i.cancelled = true
+		loop.jobDone(&i.job)
 	}
 }
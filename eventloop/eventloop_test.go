@@ -1,12 +1,17 @@
 package eventloop
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/process"
+	"github.com/khanghh/goja-nodejs/require"
 )
 
 func TestRun(t *testing.T) {
@@ -135,6 +140,32 @@ func TestRunNoConsole(t *testing.T) {
 	}
 }
 
+func TestEnableTimers(t *testing.T) {
+	t.Parallel()
+	registry := require.NewRegistry()
+	loop := NewEventLoop(WithRegistry(registry), EnableTimers())
+	var v goja.Value
+	var err error
+	loop.Run(func(vm *goja.Runtime) {
+		v, err = vm.RunString(`require("node:timers").setTimeout === setTimeout && typeof require("node:timers/promises").setTimeout + ":" + typeof AbortController`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.String(); s != "function:function" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// The modules are not added to the shared registry
+	loop = NewEventLoop(WithRegistry(registry))
+	loop.Run(func(vm *goja.Runtime) {
+		_, err = vm.RunString(`require("node:timers")`)
+	})
+	if err == nil {
+		t.Fatal("node:timers was registered without EnableTimers")
+	}
+}
+
 func TestClearIntervalRace(t *testing.T) {
 	t.Parallel()
 	const SCRIPT = `
@@ -177,6 +208,27 @@ func TestNativeTimeout(t *testing.T) {
 	}
 }
 
+func TestRegisterCallback(t *testing.T) {
+	t.Parallel()
+	loop := NewEventLoop()
+	var result string
+	loop.Run(func(vm *goja.Runtime) {
+		callback := loop.RegisterCallback()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			callback(func(vm *goja.Runtime) {
+				result = "done"
+			})
+		}()
+	})
+	if result != "done" {
+		t.Fatal("The loop did not wait for the callback")
+	}
+	if n := loop.JobCount(); n != 0 {
+		t.Fatalf("Unexpected job count: %d", n)
+	}
+}
+
 func TestNativeClearTimeout(t *testing.T) {
 	t.Parallel()
 	fired := false
@@ -414,3 +466,499 @@ func TestEventLoop_StopNoWait(t *testing.T) {
 		t.Fatal("ran != 0")
 	}
 }
+
+func TestTopLevelAwait(t *testing.T) {
+	t.Parallel()
+	registry := require.NewRegistry(require.WithLoader(func(p string) ([]byte, error) {
+		switch p {
+		case "main.js":
+			return []byte(`import("./m.mjs").then(m => { exports.result = m.value; });`), nil
+		case "m.mjs":
+			return []byte(`export const value = await new Promise(resolve => setTimeout(resolve, 100, "passed"));`), nil
+		}
+		return nil, require.ErrModuleNotExist
+	}))
+	loop := NewEventLoop(WithRegistry(registry))
+	var main goja.Value
+	var err error
+	loop.Run(func(vm *goja.Runtime) {
+		main, err = require.Require(vm, "./main.js")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop.Run(func(vm *goja.Runtime) {
+		if result := main.ToObject(vm).Get("result"); !result.SameAs(vm.ToValue("passed")) {
+			err = fmt.Errorf("unexpected result: %v", result)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailingModuleRejectionHandled(t *testing.T) {
+	t.Parallel()
+	registry := require.NewRegistry(require.WithLoader(func(p string) ([]byte, error) {
+		switch p {
+		case "main.js":
+			return []byte(`
+			exports.log = [];
+			try {
+				require("./fail.mjs");
+			} catch (e) {
+				exports.log.push("require:" + e.message);
+			}
+			import("./fail.mjs").catch(e => exports.log.push("import:" + e.message));
+			`), nil
+		case "fail.mjs":
+			return []byte(`throw new Error("failed");`), nil
+		}
+		return nil, require.ErrModuleNotExist
+	}))
+	var errs []error
+	loop := NewEventLoop(WithRegistry(registry), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	var res string
+	loop.Run(func(vm *goja.Runtime) {
+		main, err := require.Require(vm, "./main.js")
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm.Set("main", main)
+	})
+	loop.Run(func(vm *goja.Runtime) {
+		res = vm.Get("main").ToObject(vm).Get("log").String()
+	})
+	if res != "require:failed,import:failed" {
+		t.Fatalf("unexpected result: %s", res)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestTimeoutObject(t *testing.T) {
+	t.Parallel()
+	const SCRIPT = `
+	var log = [];
+	var unrefd = setTimeout(() => log.push("unref'd"), 5000).unref();
+	var refd = setTimeout(() => log.push("ref'd"), 10).unref().ref();
+	log.push(unrefd.hasRef(), refd.hasRef(), typeof unrefd.refresh, typeof (+refd));
+	var byId = setTimeout(() => log.push("cleared by id"), 10);
+	clearTimeout(+byId);
+	var interval = setInterval(() => log.push("interval"), 10);
+	clearTimeout(interval.close());
+	var refreshed = setTimeout(() => {
+		log.push("refreshed");
+		if (log.filter(v => v === "refreshed").length < 2) {
+			refreshed.refresh();
+		}
+	}, 50);
+	var imm = setImmediate(() => log.push("immediate"));
+	log.push(imm.hasRef(), imm.unref().hasRef());
+	`
+
+	loop := NewEventLoop()
+	prg, err := goja.Compile("main.js", SCRIPT, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var res string
+	loop.Run(func(vm *goja.Runtime) {
+		_, err = vm.RunProgram(prg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop.Run(func(vm *goja.Runtime) {
+		res = vm.Get("log").String()
+	})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("unref'd timeout kept the loop running for %v", elapsed)
+	}
+	if res != "false,true,function,number,true,false,immediate,ref'd,refreshed,refreshed" {
+		t.Fatalf("unexpected result: %s", res)
+	}
+	if c := loop.JobCount(); c != 0 {
+		t.Fatalf("jobCount: %d", c)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+	const SCRIPT = `
+	var log = [];
+	function logTime(name) {
+		return () => log.push(name + "@" + (Date.now() - start));
+	}
+	var start = Date.now();
+	setTimeout(logTime("b"), 200);
+	setTimeout(logTime("a"), 100);
+	setTimeout(logTime("a2"), 100);
+	var i = setInterval(() => {
+		logTime("interval")();
+		if (log.filter(v => v.startsWith("interval")).length === 3) {
+			clearInterval(i);
+		}
+	}, 60);
+	setTimeout(() => {
+		logTime("c")();
+		setImmediate(logTime("immediate"));
+		Promise.resolve().then(logTime("promise"));
+	}, 5 * 60 * 1000);
+	setImmediate(logTime("first immediate"));
+	`
+
+	clock := NewFakeClock(time.Unix(1000, 0))
+	loop := NewEventLoop(WithClock(clock))
+	prg, err := goja.Compile("main.js", SCRIPT, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop.Run(func(vm *goja.Runtime) {
+		_, err = vm.RunProgram(prg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := loop.JobCount(); c != 0 {
+		t.Fatalf("jobCount: %d", c)
+	}
+	clock.Advance(150 * time.Millisecond)
+	clock.Advance(5 * time.Minute)
+	if !clock.Now().Equal(time.Unix(1000, 0).Add(5*time.Minute + 150*time.Millisecond)) {
+		t.Fatalf("unexpected time: %v", clock.Now())
+	}
+	const expected = "first immediate@0,interval@60,a@100,a2@100,interval@120,interval@180,b@200,c@300000,promise@300000,immediate@300000"
+	if res := loop.vm.Get("log").String(); res != expected {
+		t.Fatalf("unexpected result: %s", res)
+	}
+}
+
+func TestTimerOrder(t *testing.T) {
+	t.Parallel()
+	const SCRIPT = `
+	var log = [];
+	for (var i = 0; i < 10000; i++) {
+		setTimeout(function(i) {
+			log.push(i);
+		}, 20 - i % 3, i);
+	}
+	var t = setTimeout(() => log.push("cleared"), 1);
+	clearTimeout(t);
+	`
+
+	loop := NewEventLoop()
+	prg, err := goja.Compile("main.js", SCRIPT, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log []interface{}
+	loop.Run(func(vm *goja.Runtime) {
+		_, err = vm.RunProgram(prg)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loop.Run(func(vm *goja.Runtime) {
+		log = vm.Get("log").Export().([]interface{})
+	})
+	if len(log) != 10000 || len(loop.timers) != 0 {
+		t.Fatalf("unexpected number of fired timers: %d, pending: %d", len(log), len(loop.timers))
+	}
+	// timers with equal deadlines fire in insertion order
+	prev := map[int64]int64{}
+	for _, v := range log {
+		i := v.(int64)
+		if p, ok := prev[i%3]; ok && p > i {
+			t.Fatalf("timer %d fired after %d", p, i)
+		}
+		prev[i%3] = i
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	t.Parallel()
+	loop := NewEventLoop()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var runErr error
+	err := loop.RunContext(ctx, func(vm *goja.Runtime) {
+		_, runErr = vm.RunString(`
+		setTimeout(() => {}, 60 * 1000);
+		setInterval(() => {}, 10);
+		setTimeout(() => { for (;;) {} }, 20);
+		`)
+	})
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cancelled.Pending != 2 || loop.JobCount() != 0 || len(loop.timers) != 0 {
+		t.Fatalf("unexpected pending jobs: %d, jobCount: %d, timers: %d", cancelled.Pending, loop.JobCount(), len(loop.timers))
+	}
+
+	// the runtime is usable again
+	err = loop.RunContext(context.Background(), func(vm *goja.Runtime) {
+		_, runErr = vm.RunString(`setTimeout(() => {}, 10)`)
+	})
+	if err != nil || runErr != nil {
+		t.Fatal(err, runErr)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := loop.RunContext(ctx, func(*goja.Runtime) { t.Fatal("should not run") }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunContextCancelAfterCompletion(t *testing.T) {
+	t.Parallel()
+	loop := NewEventLoop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loop.setRunning(ctx)
+	loop.runMain(func() {
+		if _, err := loop.vm.RunString(`var x = 1`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Cancel once the loop has completed its work, before it has stopped watching the context
+	loop.finish()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	loop.run(false)
+	if err := loop.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := loop.vm.RunString(`x`); err != nil {
+		t.Fatalf("the runtime was interrupted: %v", err)
+	}
+}
+
+func TestStartContext(t *testing.T) {
+	t.Parallel()
+	loop := NewEventLoop()
+	ctx, cancel := context.WithCancel(context.Background())
+	loop.StartContext(ctx)
+	started := make(chan struct{})
+	loop.RunOnLoop(func(vm *goja.Runtime) {
+		close(started)
+		vm.RunString(`for (;;) {}`)
+	})
+	<-started
+	cancel()
+	loop.Stop()
+	if err := loop.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+	var errs []error
+	loop := NewEventLoop(WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	loop.Run(func(vm *goja.Runtime) {
+		vm.RunString(`
+		setTimeout(() => { throw new Error("from timeout"); });
+		setImmediate(() => { throw new Error("from immediate"); });
+		Promise.reject(new Error("rejected"));
+		Promise.reject(new Error("handled later")).catch(() => {});
+		`)
+	})
+	if len(errs) != 3 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	var rejection *UnhandledRejectionError
+	if !errors.As(errs[0], &rejection) || !strings.Contains(rejection.Reason.String(), "rejected") {
+		t.Fatalf("unexpected error: %v", errs[0])
+	}
+	if !strings.Contains(errs[1].Error(), "from immediate") || !strings.Contains(errs[2].Error(), "from timeout") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestProcessErrorEvents(t *testing.T) {
+	t.Parallel()
+	const SCRIPT = `
+	var log = [];
+	process.on("uncaughtException", (err, origin) => log.push("exception:" + err.message + ":" + origin));
+	process.on("unhandledRejection", (reason, promise) => log.push("rejection:" + reason + ":" + (promise instanceof Promise)));
+	setTimeout(() => { throw new Error("thrown"); });
+	Promise.reject("reason");
+	`
+	for _, tc := range []struct {
+		mode     RejectionMode
+		expected string
+		errors   int
+	}{
+		{RejectionsThrow, "rejection:reason:true,exception:thrown:uncaughtException", 0},
+		{RejectionsStrict, "exception:This error originated either by throwing inside of an async function without a catch block, or by rejecting a promise which was not handled with .catch(). The promise rejected with the reason \"reason\".:uncaughtException,rejection:reason:true,exception:thrown:uncaughtException", 0},
+		{RejectionsWarn, "rejection:reason:true,exception:thrown:uncaughtException", 1},
+		{RejectionsNone, "rejection:reason:true,exception:thrown:uncaughtException", 0},
+	} {
+		var errs []error
+		loop := NewEventLoop(WithUnhandledRejections(tc.mode), WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		process.Default().Enable(loop.Runtime())
+		var res string
+		loop.Run(func(vm *goja.Runtime) {
+			if _, err := vm.RunString(SCRIPT); err != nil {
+				t.Fatal(err)
+			}
+		})
+		loop.Run(func(vm *goja.Runtime) {
+			res = vm.Get("log").String()
+		})
+		if res != tc.expected || len(errs) != tc.errors {
+			t.Fatalf("mode %d: unexpected result: %s, errors: %v", tc.mode, res, errs)
+		}
+	}
+}
+
+func TestNextTick(t *testing.T) {
+	t.Parallel()
+	const SCRIPT = `
+	var log = [];
+	Promise.resolve().then(() => log.push("main promise"));
+	process.nextTick(() => log.push("main tick"));
+	setTimeout(() => {
+		Promise.resolve().then(() => {
+			log.push("p1");
+			process.nextTick(() => log.push("t3"));
+		});
+		process.nextTick((a, b) => {
+			log.push("t1" + a + b);
+			Promise.resolve().then(() => log.push("p2"));
+			process.nextTick(() => log.push("t2"));
+		}, ":", "args");
+		queueMicrotask(() => log.push("m1"));
+		log.push("timeout");
+	});
+	setImmediate(() => {
+		process.nextTick(() => { throw new Error("from tick"); });
+		queueMicrotask(() => { throw new Error("from microtask"); });
+	});
+	log.push("main");
+	`
+
+	var errs []error
+	loop := NewEventLoop(EnableProcess(), WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	var res string
+	loop.Run(func(vm *goja.Runtime) {
+		if _, err := vm.RunString(SCRIPT); err != nil {
+			t.Fatal(err)
+		}
+	})
+	loop.Run(func(vm *goja.Runtime) {
+		res = vm.Get("log").String()
+	})
+	if res != "main,main tick,main promise,timeout,t1:args,t2,p1,m1,p2,t3" {
+		t.Fatalf("unexpected result: %s", res)
+	}
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "from tick") || !strings.Contains(errs[1].Error(), "from microtask") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestRunPromiseJobs(t *testing.T) {
+	t.Parallel()
+	loop := NewEventLoop(EnableProcess())
+	var res goja.Value
+	loop.Run(func(vm *goja.Runtime) {
+		if _, err := vm.RunString(`var x; Promise.resolve(1).then(v => { x = v })`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	loop.Run(func(vm *goja.Runtime) {
+		res = vm.Get("x")
+	})
+	if res.Export() != int64(1) {
+		t.Fatalf("unexpected x: %v", res)
+	}
+
+	// Promises resolved from Go are settled once the job returns
+	loop.Run(func(vm *goja.Runtime) {
+		p, resolve, _ := vm.NewPromise()
+		vm.Set("p", p)
+		if _, err := vm.RunString(`p.then(v => { x = v })`); err != nil {
+			t.Fatal(err)
+		}
+		resolve(2)
+	})
+	loop.Run(func(vm *goja.Runtime) {
+		res = vm.Get("x")
+	})
+	if res.Export() != int64(2) {
+		t.Fatalf("unexpected x: %v", res)
+	}
+}
+
+func TestRunPanic(t *testing.T) {
+	t.Parallel()
+	var handled []error
+	loop := NewEventLoop(WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	func() {
+		defer func() {
+			if x := recover(); x == nil {
+				t.Fatal("expected a panic from Run")
+			}
+		}()
+		loop.Run(func(vm *goja.Runtime) {
+			panic(vm.NewTypeError("boom"))
+		})
+	}()
+	if len(handled) != 0 {
+		t.Fatalf("unexpected handled errors: %v", handled)
+	}
+}
+
+func TestRunOnLoopErrors(t *testing.T) {
+	t.Parallel()
+	var handled []error
+	loop := NewEventLoop(EnableProcess(), WithErrorHandler(func(err error) {
+		handled = append(handled, err)
+	}))
+	loop.Start()
+	defer loop.Stop()
+	loop.RunOnLoop(func(vm *goja.Runtime) {
+		panic(vm.NewTypeError("thrown"))
+	})
+	loop.RunOnLoop(func(vm *goja.Runtime) {
+		var m map[string]int
+		m["x"]++
+	})
+	done := make(chan string)
+	loop.RunOnLoop(func(vm *goja.Runtime) {
+		vm.Set("done", func(msg string) {
+			done <- msg
+		})
+		if _, err := vm.RunString(`process.on("uncaughtException", err => done(err.message))`); err != nil {
+			t.Error(err)
+		}
+		cb := loop.RegisterCallback()
+		go cb(func(vm *goja.Runtime) {
+			panic(vm.NewTypeError("from callback"))
+		})
+	})
+	if msg := <-done; msg != "from callback" {
+		t.Fatalf("unexpected uncaught exception: %s", msg)
+	}
+	if len(handled) != 2 || !strings.Contains(handled[0].Error(), "thrown") || !strings.Contains(handled[1].Error(), "assignment to entry in nil map") {
+		t.Fatalf("unexpected handled errors: %v", handled)
+	}
+}
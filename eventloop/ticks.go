@@ -0,0 +1,153 @@
+package eventloop
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/process"
+)
+
+type tick struct {
+	fn   goja.Callable
+	args []goja.Value
+}
+
+// EnableProcess enables the process module in the loop's runtime with process.nextTick() using the
+// loop's nextTick queue.
+func EnableProcess() Option {
+	return func(loop *EventLoop) {
+		loop.enableProcess = true
+	}
+}
+
+func (loop *EventLoop) initTicks() {
+	// Promise jobs are run when the outermost call into the runtime returns. Running each job from a JS
+	// function makes the calls made by the job nested, so the nextTick queue can be drained before them.
+	runner, err := loop.vm.RunString(`(function(job) { job(); })`)
+	if err != nil {
+		panic(err)
+	}
+	loop.runner, _ = goja.AssertFunction(runner)
+	loop.vm.Set("queueMicrotask", loop.queueMicrotask)
+	if loop.enableProcess {
+		process.Default().Enable(loop.vm)
+		loop.vm.Get("process").(*goja.Object).Set("nextTick", loop.nextTick)
+	}
+}
+
+func (loop *EventLoop) nextTick(call goja.FunctionCall) goja.Value {
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(loop.vm.NewTypeError(`The "callback" argument must be of type function`))
+	}
+	var args []goja.Value
+	if len(call.Arguments) > 1 {
+		args = append(args, call.Arguments[1:]...)
+	}
+	loop.ticks = append(loop.ticks, tick{fn: fn, args: args})
+	return goja.Undefined()
+}
+
+func (loop *EventLoop) queueMicrotask(call goja.FunctionCall) goja.Value {
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(loop.vm.NewTypeError(`The "callback" argument must be of type function`))
+	}
+	p, resolve, _ := loop.vm.NewPromise()
+	resolve(goja.Undefined())
+	promise := loop.vm.ToValue(p).(*goja.Object)
+	then, _ := goja.AssertFunction(promise.Get("then"))
+	then(promise, loop.vm.ToValue(func(goja.FunctionCall) goja.Value {
+		if _, err := fn(goja.Undefined()); err != nil {
+			loop.handleError(err)
+		}
+		return goja.Undefined()
+	}))
+	return goja.Undefined()
+}
+
+func (loop *EventLoop) runTicks() {
+	for len(loop.ticks) > 0 {
+		t := loop.ticks[0]
+		loop.ticks[0] = tick{}
+		loop.ticks = loop.ticks[1:]
+		if _, err := t.fn(goja.Undefined(), t.args...); err != nil {
+			loop.handleError(err)
+		}
+	}
+	loop.ticks = nil
+}
+
+// runNested calls fn from a JS function, so the promise jobs queued by fn only run once it returns.
+func (loop *EventLoop) runNested(fn func()) {
+	_, err := loop.runner(goja.Undefined(), loop.vm.ToValue(func(goja.FunctionCall) goja.Value {
+		fn()
+		return goja.Undefined()
+	}))
+	if err != nil {
+		loop.handleError(err)
+	}
+}
+
+// throwPanics calls fn and turns a Go panic into a GoError thrown into the runtime, so it is reported like
+// an exception thrown by a callback instead of unwinding the loop.
+func (loop *EventLoop) throwPanics(fn func()) {
+	defer func() {
+		if x := recover(); x != nil {
+			switch x.(type) {
+			case goja.Value, *goja.Exception, *goja.InterruptedError:
+				panic(x)
+			}
+			err, ok := x.(error)
+			if !ok {
+				err = fmt.Errorf("%v", x)
+			}
+			panic(loop.vm.NewGoError(err))
+		}
+	}()
+	fn()
+}
+
+// drain runs the ticks queued by the promise jobs and reports the unhandled rejections.
+func (loop *EventLoop) drain() {
+	for {
+		for len(loop.ticks) > 0 {
+			loop.runNested(loop.runTicks)
+		}
+		if len(loop.rejections) == 0 {
+			break
+		}
+		loop.processRejections()
+	}
+}
+
+// runJob runs a macrotask followed by the nextTick queue, the promise jobs and the unhandled rejections
+// in the same order as Node. Exceptions thrown and panics raised by the job are handled by handleError.
+func (loop *EventLoop) runJob(fn func()) {
+	loop.runNested(func() {
+		loop.throwPanics(fn)
+		loop.runTicks()
+	})
+	loop.drain()
+}
+
+// runMain runs the function passed to Run like runJob, except that a panic raised by the function reaches
+// the caller.
+func (loop *EventLoop) runMain(fn func()) {
+	var x interface{}
+	panicked := true
+	loop.runNested(func() {
+		defer func() {
+			if panicked {
+				x = recover()
+			}
+		}()
+		fn()
+		panicked = false
+		loop.runTicks()
+	})
+	if panicked {
+		panic(x)
+	}
+	loop.drain()
+}
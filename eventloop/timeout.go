@@ -0,0 +1,129 @@
+package eventloop
+
+import (
+	"github.com/dop251/goja"
+)
+
+// initTimerPrototypes creates the prototypes of the Timeout and Immediate objects returned to JS code.
+// Both hold the underlying *Timer, *Interval or *Immediate in a non-enumerable symbol property.
+func (loop *EventLoop) initTimerPrototypes() {
+	vm := loop.vm
+	loop.handleSym = goja.NewSymbol("timer")
+	loop.knownTimers = make(map[int64]interface{})
+
+	refMethods := func(proto *goja.Object) {
+		proto.Set("ref", func(call goja.FunctionCall) goja.Value {
+			loop.setRef(loop.jobOf(call.This), true)
+			return call.This
+		})
+		proto.Set("unref", func(call goja.FunctionCall) goja.Value {
+			loop.setRef(loop.jobOf(call.This), false)
+			return call.This
+		})
+		proto.Set("hasRef", func(call goja.FunctionCall) goja.Value {
+			return vm.ToValue(!loop.jobOf(call.This).unref)
+		})
+	}
+
+	loop.timeoutProto = vm.NewObject()
+	refMethods(loop.timeoutProto)
+	loop.timeoutProto.Set("refresh", func(call goja.FunctionCall) goja.Value {
+		switch t := loop.handleOf(call.This).(type) {
+		case *Timer:
+			loop.refreshTimeout(t)
+		case *Interval:
+			loop.refreshInterval(t)
+		}
+		return call.This
+	})
+	loop.timeoutProto.Set("close", func(call goja.FunctionCall) goja.Value {
+		loop.clearTimer(loop.handleOf(call.This))
+		return call.This
+	})
+	toPrimitive := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		h := loop.handleOf(call.This)
+		j := loop.jobOf(call.This)
+		if !j.cancelled {
+			loop.knownTimers[j.id] = h
+		}
+		return vm.ToValue(j.id)
+	})
+	loop.timeoutProto.DefineDataPropertySymbol(goja.SymToPrimitive, toPrimitive, goja.FLAG_TRUE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	loop.timeoutProto.DefineDataPropertySymbol(goja.SymToStringTag, vm.ToValue("Timeout"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	loop.immediateProto = vm.NewObject()
+	refMethods(loop.immediateProto)
+	loop.immediateProto.DefineDataPropertySymbol(goja.SymToStringTag, vm.ToValue("Immediate"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
+
+func (loop *EventLoop) newHandleObject(proto *goja.Object, h interface{}) *goja.Object {
+	obj := loop.vm.NewObject()
+	obj.SetPrototype(proto)
+	obj.DefineDataPropertySymbol(loop.handleSym, loop.vm.ToValue(h), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return obj
+}
+
+func (loop *EventLoop) newTimeoutObject(h interface{}) *goja.Object {
+	loop.nextTimerId++
+	switch t := h.(type) {
+	case *Timer:
+		t.id = loop.nextTimerId
+	case *Interval:
+		t.id = loop.nextTimerId
+	}
+	return loop.newHandleObject(loop.timeoutProto, h)
+}
+
+func (loop *EventLoop) newImmediateObject(i *Immediate) *goja.Object {
+	return loop.newHandleObject(loop.immediateProto, i)
+}
+
+// handleOf returns the *Timer, *Interval or *Immediate held by a Timeout or Immediate object, or nil.
+func (loop *EventLoop) handleOf(v goja.Value) interface{} {
+	if obj, ok := v.(*goja.Object); ok {
+		if h := obj.GetSymbol(loop.handleSym); h != nil {
+			return h.Export()
+		}
+	}
+	return nil
+}
+
+func (loop *EventLoop) jobOf(v goja.Value) *job {
+	switch t := loop.handleOf(v).(type) {
+	case *Timer:
+		return &t.job
+	case *Interval:
+		return &t.job
+	case *Immediate:
+		return &t.job
+	}
+	panic(loop.vm.NewTypeError("Value is not a Timeout or Immediate object"))
+}
+
+func (loop *EventLoop) clearTimer(h interface{}) {
+	switch t := h.(type) {
+	case *Timer:
+		loop.clearTimeout(t)
+	case *Interval:
+		loop.clearInterval(t)
+	}
+}
+
+// jsClearTimer implements clearTimeout() and clearInterval() which, like in Node, accept both
+// Timeout objects and their primitive ids.
+func (loop *EventLoop) jsClearTimer(call goja.FunctionCall) goja.Value {
+	arg := call.Argument(0)
+	if _, ok := arg.(*goja.Object); ok {
+		loop.clearTimer(loop.handleOf(arg))
+	} else if !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		loop.clearTimer(loop.knownTimers[arg.ToInteger()])
+	}
+	return goja.Undefined()
+}
+
+func (loop *EventLoop) jsClearImmediate(call goja.FunctionCall) goja.Value {
+	if i, ok := loop.handleOf(call.Argument(0)).(*Immediate); ok {
+		loop.clearImmediate(i)
+	}
+	return goja.Undefined()
+}
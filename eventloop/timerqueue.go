@@ -0,0 +1,71 @@
+package eventloop
+
+import (
+	"container/heap"
+	"time"
+)
+
+type timerEntry struct {
+	when  time.Time
+	seq   uint64
+	index int
+	job   *job
+	fn    func()
+}
+
+// timerQueue is a min-heap of timers ordered by deadline. Timers with equal deadlines are ordered
+// by insertion.
+type timerQueue []*timerEntry
+
+func (q timerQueue) Len() int {
+	return len(q)
+}
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].when.Equal(q[j].when) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].when.Before(q[j].when)
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	e := x.(*timerEntry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
+
+func (loop *EventLoop) addTimer(when time.Time, j *job, fn func()) *timerEntry {
+	loop.timerSeq++
+	e := &timerEntry{when: when, seq: loop.timerSeq, job: j, fn: fn}
+	heap.Push(&loop.timers, e)
+	return e
+}
+
+func (loop *EventLoop) removeTimer(e *timerEntry) {
+	if e != nil && e.index >= 0 {
+		heap.Remove(&loop.timers, e.index)
+	}
+}
+
+// runTimers runs all timers which are due at the given time in order.
+func (loop *EventLoop) runTimers(now time.Time) {
+	for len(loop.timers) > 0 && !loop.timers[0].when.After(now) {
+		loop.runJob(heap.Pop(&loop.timers).(*timerEntry).fn)
+	}
+}
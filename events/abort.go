@@ -0,0 +1,183 @@
+package events
+
+import (
+	"math"
+
+	"github.com/dop251/goja"
+)
+
+// abortSignal is the state of an AbortSignal, which is an EventTarget dispatching the 'abort' event.
+type abortSignal struct {
+	eventTarget
+	aborted bool
+	reason  goja.Value
+	onabort goja.Value
+}
+
+func (a *api) initAbort() {
+	r := a.runtime
+
+	a.abortSignal = r.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		panic(r.NewTypeError("Illegal constructor"))
+	}).(*goja.Object)
+	a.abortSignalProto = a.abortSignal.Get("prototype").(*goja.Object)
+	a.abortSignalProto.SetPrototype(a.eventTargetProto)
+	a.abortSignal.SetPrototype(a.eventTarget)
+	proto := a.abortSignalProto
+	proto.DefineAccessorProperty("aborted", r.ToValue(func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(a.signalOf(call.This).aborted)
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	proto.DefineAccessorProperty("reason", r.ToValue(func(call goja.FunctionCall) goja.Value {
+		return a.signalOf(call.This).reason
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	proto.DefineAccessorProperty("onabort", r.ToValue(func(call goja.FunctionCall) goja.Value {
+		return a.signalOf(call.This).onabort
+	}), r.ToValue(func(call goja.FunctionCall) goja.Value {
+		s := a.signalOf(call.This)
+		if _, ok := goja.AssertFunction(call.Argument(0)); ok {
+			s.onabort = call.Argument(0)
+		} else {
+			s.onabort = goja.Null()
+		}
+		return goja.Undefined()
+	}), goja.FLAG_FALSE, goja.FLAG_TRUE)
+	proto.Set("throwIfAborted", func(call goja.FunctionCall) goja.Value {
+		if s := a.signalOf(call.This); s.aborted {
+			panic(s.reason)
+		}
+		return goja.Undefined()
+	})
+	proto.DefineDataPropertySymbol(goja.SymToStringTag, r.ToValue("AbortSignal"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	a.abortSignal.Set("abort", func(call goja.FunctionCall) goja.Value {
+		signal := a.newAbortSignal()
+		a.abort(signal, call.Argument(0))
+		return signal
+	})
+	a.abortSignal.Set("timeout", a.abortTimeout)
+	a.abortSignal.Set("any", a.abortAny)
+
+	a.abortController = r.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		call.This.DefineDataProperty("signal", a.newAbortSignal(), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		return nil
+	}).(*goja.Object)
+	proto = a.abortController.Get("prototype").(*goja.Object)
+	proto.Set("abort", func(call goja.FunctionCall) goja.Value {
+		signal, ok := call.This.ToObject(r).Get("signal").(*goja.Object)
+		if !ok {
+			panic(r.NewTypeError(`Value of "this" must be of type AbortController`))
+		}
+		a.abort(signal, call.Argument(0))
+		return goja.Undefined()
+	})
+	proto.DefineDataPropertySymbol(goja.SymToStringTag, r.ToValue("AbortController"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
+
+func (a *api) newAbortSignal() *goja.Object {
+	s := &abortSignal{
+		eventTarget: eventTarget{listeners: make(map[string][]*targetListener), maxListeners: defaultMaxListeners},
+		reason:      goja.Undefined(),
+		onabort:     goja.Null(),
+	}
+	obj := a.runtime.NewObject()
+	obj.SetPrototype(a.abortSignalProto)
+	obj.DefineDataPropertySymbol(a.stateSym, a.runtime.ToValue(s), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return obj
+}
+
+func (a *api) signalOf(v goja.Value) *abortSignal {
+	if s, ok := a.stateOf(v).(*abortSignal); ok {
+		return s
+	}
+	panic(a.runtime.NewTypeError(`Value of "this" must be of type AbortSignal`))
+}
+
+// domException returns an error like the DOMException with the given name created by Node, goja has no
+// DOMException class.
+func (a *api) domException(name, message string, code int) goja.Value {
+	err, _ := a.runtime.New(a.runtime.Get("Error"), a.runtime.ToValue(message))
+	err.Set("name", name)
+	err.Set("code", code)
+	return err
+}
+
+// abort aborts the signal with the reason, or an AbortError if the reason is undefined, and dispatches the
+// 'abort' event. Aborting a signal which is already aborted does nothing.
+func (a *api) abort(signal *goja.Object, reason goja.Value) {
+	s := a.signalOf(signal)
+	if s.aborted {
+		return
+	}
+	if goja.IsUndefined(reason) {
+		reason = a.domException("AbortError", "This operation was aborted", 20)
+	}
+	s.aborted = true
+	s.reason = reason
+	ev, err := a.runtime.New(a.event, a.runtime.ToValue("abort"))
+	if err != nil {
+		panic(err)
+	}
+	if fn, ok := goja.AssertFunction(s.onabort); ok {
+		if _, err := fn(signal, ev); err != nil {
+			if err = a.reportError(err); err != nil {
+				panic(err)
+			}
+		}
+	}
+	a.dispatchEvent(goja.FunctionCall{This: signal, Arguments: []goja.Value{ev}})
+}
+
+// abortTimeout implements AbortSignal.timeout(delay), which returns a signal aborted with a TimeoutError after
+// the delay. The timer is unref'd, so it doesn't keep the event loop running.
+func (a *api) abortTimeout(call goja.FunctionCall) goja.Value {
+	r := a.runtime
+	delay := call.Argument(0)
+	if n := delay.ToFloat(); goja.IsUndefined(delay) || n < 0 || math.IsNaN(n) {
+		panic(r.NewTypeError(`The "delay" argument must be a non-negative number`))
+	}
+	setTimeout, ok := goja.AssertFunction(r.GlobalObject().Get("setTimeout"))
+	if !ok {
+		panic(r.NewTypeError("setTimeout is not defined, AbortSignal.timeout() requires an event loop"))
+	}
+	signal := a.newAbortSignal()
+	timer, err := setTimeout(goja.Undefined(), r.ToValue(func(goja.FunctionCall) goja.Value {
+		a.abort(signal, a.domException("TimeoutError", "The operation was aborted due to timeout", 23))
+		return goja.Undefined()
+	}), delay)
+	if err != nil {
+		panic(err)
+	}
+	if obj, ok := timer.(*goja.Object); ok && a.hasMethod(obj, "unref") {
+		a.callMethod(obj, "unref")
+	}
+	return signal
+}
+
+// abortAny implements AbortSignal.any(signals), which returns a signal aborted as soon as one of the signals is.
+func (a *api) abortAny(call goja.FunctionCall) goja.Value {
+	r := a.runtime
+	var values []goja.Value
+	if err := r.ExportTo(call.Argument(0), &values); err != nil {
+		panic(r.NewTypeError(`The "signals" argument must be an instance of AbortSignal[]`))
+	}
+	signals := make([]*goja.Object, len(values))
+	for i, v := range values {
+		if _, ok := a.stateOf(v).(*abortSignal); !ok {
+			panic(r.NewTypeError(`The "signals" argument must be an instance of AbortSignal[]`))
+		}
+		signals[i] = v.(*goja.Object)
+	}
+	signal := a.newAbortSignal()
+	for _, s := range signals {
+		if state := a.signalOf(s); state.aborted {
+			a.abort(signal, state.reason)
+			return signal
+		}
+	}
+	for _, s := range signals {
+		s := s
+		a.onAbort(s, func() {
+			a.abort(signal, a.signalOf(s).reason)
+		})
+	}
+	return signal
+}
@@ -0,0 +1,424 @@
+package events
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// The listeners are stored in the _events object of the emitter, the same way as Node does: each property
+// is either a single listener or an array of listeners. Listeners added with once() are wrapped by a function
+// with the original listener in its 'listener' property. Some packages rely on this layout.
+
+func (a *api) initEventEmitter() {
+	r := a.runtime
+	ctor, err := r.RunString(`(function(init) {
+		function EventEmitter(opts) {
+			EventEmitter.init.call(this, opts);
+		}
+		EventEmitter.init = init;
+		return EventEmitter;
+	})`)
+	if err != nil {
+		panic(err)
+	}
+	wrap, _ := goja.AssertFunction(ctor)
+	emitter, err := wrap(goja.Undefined(), r.ToValue(func(call goja.FunctionCall) goja.Value {
+		a.init(call.This.ToObject(r))
+		return goja.Undefined()
+	}))
+	if err != nil {
+		panic(err)
+	}
+	a.emitter = emitter.(*goja.Object)
+	a.emitterProto = a.emitter.Get("prototype").(*goja.Object)
+
+	proto := a.emitterProto
+	proto.Set("_events", goja.Undefined())
+	proto.Set("_eventsCount", 0)
+	proto.Set("_maxListeners", goja.Undefined())
+	proto.Set("on", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.addListener(this, call.Argument(0), call.Argument(1), false)
+		return this
+	}))
+	proto.Set("addListener", proto.Get("on"))
+	proto.Set("prependListener", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.addListener(this, call.Argument(0), call.Argument(1), true)
+		return this
+	}))
+	proto.Set("once", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.addListener(this, call.Argument(0), a.onceWrapper(this, call.Argument(0), call.Argument(1)), false)
+		return this
+	}))
+	proto.Set("prependOnceListener", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.addListener(this, call.Argument(0), a.onceWrapper(this, call.Argument(0), call.Argument(1)), true)
+		return this
+	}))
+	proto.Set("off", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.removeListener(this, call.Argument(0), call.Argument(1))
+		return this
+	}))
+	proto.Set("removeListener", proto.Get("off"))
+	proto.Set("removeAllListeners", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		a.removeAllListeners(this, call.Argument(0))
+		return this
+	}))
+	proto.Set("emit", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		var args []goja.Value
+		if len(call.Arguments) > 1 {
+			args = call.Arguments[1:]
+		}
+		return r.ToValue(a.emit(this, call.Argument(0), args))
+	}))
+	proto.Set("listeners", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		list := a.list(this, call.Argument(0))
+		ret := make([]interface{}, len(list))
+		for i, l := range list {
+			ret[i] = unwrapListener(l)
+		}
+		return r.NewArray(ret...)
+	}))
+	proto.Set("rawListeners", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		return r.NewArray(valuesToInterfaces(a.list(this, call.Argument(0)))...)
+	}))
+	proto.Set("listenerCount", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		list := a.list(this, call.Argument(0))
+		if listener := call.Argument(1); !goja.IsUndefined(listener) {
+			n := 0
+			for _, l := range list {
+				if l.SameAs(listener) || unwrapListener(l).SameAs(listener) {
+					n++
+				}
+			}
+			return r.ToValue(n)
+		}
+		return r.ToValue(len(list))
+	}))
+	proto.Set("eventNames", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		var names []interface{}
+		if events, ok := this.Get("_events").(*goja.Object); ok {
+			for _, key := range events.Keys() {
+				names = append(names, key)
+			}
+			for _, sym := range events.Symbols() {
+				names = append(names, sym)
+			}
+		}
+		return r.NewArray(names...)
+	}))
+	proto.Set("setMaxListeners", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		this.Set("_maxListeners", a.validateMaxListeners(call.Argument(0)))
+		return this
+	}))
+	proto.Set("getMaxListeners", a.method(func(this *goja.Object, call goja.FunctionCall) goja.Value {
+		return r.ToValue(a.maxListeners(this))
+	}))
+
+	a.emitter.Set("EventEmitter", a.emitter)
+	a.emitter.Set("defaultMaxListeners", defaultMaxListeners)
+	a.emitter.Set("errorMonitor", a.errorMonitor)
+	a.emitter.Set("usingDomains", false)
+	a.emitter.Set("once", a.once)
+	a.emitter.Set("listenerCount", a.listenerCountStatic)
+	a.emitter.Set("getEventListeners", a.getEventListeners)
+	a.emitter.Set("setMaxListeners", a.setMaxListeners)
+}
+
+func (a *api) method(fn func(this *goja.Object, call goja.FunctionCall) goja.Value) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		return fn(call.This.ToObject(a.runtime), call)
+	}
+}
+
+// init implements EventEmitter.init() which is called by the constructor.
+func (a *api) init(this *goja.Object) {
+	events := this.Get("_events")
+	if proto := this.Prototype(); goja.IsUndefined(events) || events == nil || (proto != nil && events.SameAs(proto.Get("_events"))) {
+		this.Set("_events", a.newEvents())
+		this.Set("_eventsCount", 0)
+	}
+	if max := this.Get("_maxListeners"); max == nil {
+		this.Set("_maxListeners", goja.Undefined())
+	}
+}
+
+func (a *api) newEvents() *goja.Object {
+	events := a.runtime.NewObject()
+	events.SetPrototype(nil)
+	return events
+}
+
+func (a *api) events(this *goja.Object) *goja.Object {
+	if events, ok := this.Get("_events").(*goja.Object); ok {
+		return events
+	}
+	events := a.newEvents()
+	this.Set("_events", events)
+	this.Set("_eventsCount", 0)
+	return events
+}
+
+func getKey(obj *goja.Object, key goja.Value) goja.Value {
+	if sym, ok := key.(*goja.Symbol); ok {
+		return obj.GetSymbol(sym)
+	}
+	return obj.Get(key.String())
+}
+
+func setKey(obj *goja.Object, key goja.Value, value goja.Value) {
+	if sym, ok := key.(*goja.Symbol); ok {
+		obj.SetSymbol(sym, value)
+	} else {
+		obj.Set(key.String(), value)
+	}
+}
+
+func deleteKey(obj *goja.Object, key goja.Value) {
+	if sym, ok := key.(*goja.Symbol); ok {
+		obj.DeleteSymbol(sym)
+	} else {
+		obj.Delete(key.String())
+	}
+}
+
+func isArray(v goja.Value) (*goja.Object, bool) {
+	obj, ok := v.(*goja.Object)
+	return obj, ok && obj.ClassName() == "Array"
+}
+
+// list returns a copy of the listeners of the event.
+func (a *api) list(this *goja.Object, key goja.Value) []goja.Value {
+	events, ok := this.Get("_events").(*goja.Object)
+	if !ok {
+		return nil
+	}
+	v := getKey(events, key)
+	if v == nil || goja.IsUndefined(v) {
+		return nil
+	}
+	if arr, ok := isArray(v); ok {
+		n := int(arr.Get("length").ToInteger())
+		list := make([]goja.Value, n)
+		for i := range list {
+			list[i] = arr.Get(strconv.Itoa(i))
+		}
+		return list
+	}
+	return []goja.Value{v}
+}
+
+func (a *api) setList(this *goja.Object, key goja.Value, list []goja.Value) {
+	events := a.events(this)
+	old := getKey(events, key)
+	existed := old != nil && !goja.IsUndefined(old)
+	switch len(list) {
+	case 0:
+		deleteKey(events, key)
+		if existed {
+			this.Set("_eventsCount", this.Get("_eventsCount").ToInteger()-1)
+		}
+		return
+	case 1:
+		setKey(events, key, list[0])
+	default:
+		arr := a.runtime.NewArray(valuesToInterfaces(list)...)
+		if prev, ok := isArray(old); ok {
+			if warned := prev.Get("warned"); warned != nil {
+				arr.Set("warned", warned)
+			}
+		}
+		setKey(events, key, arr)
+	}
+	if !existed {
+		this.Set("_eventsCount", this.Get("_eventsCount").ToInteger()+1)
+	}
+}
+
+func unwrapListener(l goja.Value) goja.Value {
+	if obj, ok := l.(*goja.Object); ok {
+		if orig := obj.Get("listener"); orig != nil {
+			if _, ok := goja.AssertFunction(orig); ok {
+				return orig
+			}
+		}
+	}
+	return l
+}
+
+func (a *api) checkListener(listener goja.Value) {
+	if _, ok := goja.AssertFunction(listener); !ok {
+		panic(a.runtime.NewTypeError(`The "listener" argument must be of type function. Received %s`, listener.String()))
+	}
+}
+
+func (a *api) validateMaxListeners(v goja.Value) int64 {
+	n := v.ToFloat()
+	if math.IsNaN(n) || n < 0 {
+		panic(a.runtime.NewTypeError(`The value of "n" is out of range. It must be a non-negative number. Received %s`, v.String()))
+	}
+	if math.IsInf(n, 1) {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+func (a *api) maxListeners(this *goja.Object) int64 {
+	v := this.Get("_maxListeners")
+	if v == nil || goja.IsUndefined(v) {
+		v = a.emitter.Get("defaultMaxListeners")
+	}
+	if math.IsInf(v.ToFloat(), 1) {
+		return math.MaxInt64
+	}
+	return v.ToInteger()
+}
+
+func (a *api) addListener(this *goja.Object, key, listener goja.Value, prepend bool) {
+	a.checkListener(unwrapListener(listener))
+	if len(a.list(this, a.runtime.ToValue("newListener"))) > 0 {
+		a.emit(this, a.runtime.ToValue("newListener"), []goja.Value{key, unwrapListener(listener)})
+	}
+	list := a.list(this, key)
+	if prepend {
+		list = append([]goja.Value{listener}, list...)
+	} else {
+		list = append(list, listener)
+	}
+	a.setList(this, key, list)
+
+	if max := a.maxListeners(this); max > 0 && int64(len(list)) > max {
+		arr, _ := isArray(getKey(a.events(this), key))
+		if arr != nil && !boolOption(arr, "warned") {
+			arr.Set("warned", true)
+			a.warn("MaxListenersExceededWarning", fmt.Sprintf("Possible EventEmitter memory leak detected. "+
+				"%d %s listeners added. Use emitter.setMaxListeners() to increase limit", len(list), key.String()))
+		}
+	}
+}
+
+// warn emits the warning with process.emitWarning() if it exists, otherwise it is printed to os.Stderr.
+func (a *api) warn(name, message string) {
+	if process, ok := a.runtime.GlobalObject().Get("process").(*goja.Object); ok {
+		if emitWarning, ok := goja.AssertFunction(process.Get("emitWarning")); ok {
+			if _, err := emitWarning(process, a.runtime.ToValue(message), a.runtime.ToValue(name)); err != nil {
+				panic(err)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", name, message)
+}
+
+func (a *api) onceWrapper(this *goja.Object, key, listener goja.Value) goja.Value {
+	a.checkListener(listener)
+	fn, _ := goja.AssertFunction(listener)
+	fired := false
+	var wrapper *goja.Object
+	wrapper = a.runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+		if fired {
+			return goja.Undefined()
+		}
+		fired = true
+		a.removeListener(this, key, wrapper)
+		ret, err := fn(call.This, call.Arguments...)
+		if err != nil {
+			panic(err)
+		}
+		return ret
+	}).(*goja.Object)
+	wrapper.Set("listener", listener)
+	return wrapper
+}
+
+func (a *api) removeListener(this *goja.Object, key, listener goja.Value) {
+	a.checkListener(listener)
+	list := a.list(this, key)
+	for i := len(list) - 1; i >= 0; i-- {
+		if l := list[i]; l.SameAs(listener) || unwrapListener(l).SameAs(listener) {
+			a.setList(this, key, append(list[:i:i], list[i+1:]...))
+			if len(a.list(this, a.runtime.ToValue("removeListener"))) > 0 {
+				a.emit(this, a.runtime.ToValue("removeListener"), []goja.Value{key, unwrapListener(l)})
+			}
+			return
+		}
+	}
+}
+
+func (a *api) removeAllListeners(this *goja.Object, key goja.Value) {
+	events, ok := this.Get("_events").(*goja.Object)
+	if !ok {
+		return
+	}
+	removeListener := a.runtime.ToValue("removeListener")
+	if len(a.list(this, removeListener)) == 0 {
+		if goja.IsUndefined(key) {
+			this.Set("_events", a.newEvents())
+			this.Set("_eventsCount", 0)
+		} else {
+			a.setList(this, key, nil)
+		}
+		return
+	}
+	if goja.IsUndefined(key) {
+		for _, name := range events.Keys() {
+			if name != "removeListener" {
+				a.removeAllListeners(this, a.runtime.ToValue(name))
+			}
+		}
+		for _, sym := range events.Symbols() {
+			a.removeAllListeners(this, sym)
+		}
+		a.removeAllListeners(this, removeListener)
+		this.Set("_events", a.newEvents())
+		this.Set("_eventsCount", 0)
+		return
+	}
+	list := a.list(this, key)
+	for i := len(list) - 1; i >= 0; i-- {
+		a.removeListener(this, key, list[i])
+	}
+}
+
+func (a *api) emit(this *goja.Object, key goja.Value, args []goja.Value) bool {
+	if _, isSym := key.(*goja.Symbol); !isSym && key.String() == "error" {
+		if len(a.list(this, a.errorMonitor)) > 0 {
+			a.emit(this, a.errorMonitor, args)
+		}
+		if len(a.list(this, key)) == 0 {
+			a.throwUnhandled(args)
+		}
+	}
+	list := a.list(this, key)
+	for _, l := range list {
+		fn, ok := goja.AssertFunction(l)
+		if !ok {
+			continue
+		}
+		if _, err := fn(this, args...); err != nil {
+			panic(err)
+		}
+	}
+	return len(list) > 0
+}
+
+// throwUnhandled throws the argument of an 'error' event which has no listeners.
+func (a *api) throwUnhandled(args []goja.Value) {
+	var er goja.Value = goja.Undefined()
+	if len(args) > 0 {
+		er = args[0]
+	}
+	if obj, ok := er.(*goja.Object); ok && obj.ClassName() == "Error" {
+		panic(obj)
+	}
+	desc := er.String()
+	if _, ok := er.Export().(string); ok {
+		desc = "'" + desc + "'"
+	}
+	err, _ := a.runtime.New(a.runtime.Get("Error"), a.runtime.ToValue("Unhandled error. ("+desc+")"))
+	err.Set("code", "ERR_UNHANDLED_ERROR")
+	err.Set("context", er)
+	panic(err)
+}
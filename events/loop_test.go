@@ -0,0 +1,32 @@
+package events_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/eventloop"
+	"github.com/khanghh/goja-nodejs/events"
+)
+
+func TestEmitOnLoopThrowingListener(t *testing.T) {
+	errs := make(chan error, 1)
+	loop := eventloop.NewEventLoop(eventloop.WithErrorHandler(func(err error) {
+		errs <- err
+	}))
+	var emitter *goja.Object
+	loop.Run(func(vm *goja.Runtime) {
+		emitter = events.NewEventEmitter(vm)
+		vm.Set("emitter", emitter)
+		if _, err := vm.RunString(`emitter.on("data", () => { throw new Error("from listener") })`); err != nil {
+			t.Fatal(err)
+		}
+	})
+	loop.Start()
+	events.EmitOnLoop(loop, emitter, "data")
+	err := <-errs
+	loop.Stop()
+	if !strings.Contains(err.Error(), "from listener") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
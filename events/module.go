@@ -0,0 +1,289 @@
+package events
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+)
+
+const ModuleName = "node:events"
+
+const defaultMaxListeners = 10
+
+var defaultModule = EventsModule{}
+
+// apiSym is the key of the per-runtime state stored in the global object.
+var apiSym = goja.NewSymbol("events")
+
+// api holds the classes of the module created for a runtime.
+type api struct {
+	runtime *goja.Runtime
+
+	emitter      *goja.Object
+	emitterProto *goja.Object
+	errorMonitor *goja.Symbol
+
+	eventTarget, event           *goja.Object
+	eventTargetProto, eventProto *goja.Object
+	stateSym                     *goja.Symbol
+
+	abortController, abortSignal, abortSignalProto *goja.Object
+}
+
+func getAPI(runtime *goja.Runtime) *api {
+	global := runtime.GlobalObject()
+	if v := global.GetSymbol(apiSym); v != nil {
+		return v.Export().(*api)
+	}
+	a := &api{
+		runtime:      runtime,
+		errorMonitor: goja.NewSymbol("events.errorMonitor"),
+		stateSym:     goja.NewSymbol("state"),
+	}
+	a.initEventEmitter()
+	a.initEventTarget()
+	a.initAbort()
+	global.DefineDataPropertySymbol(apiSym, runtime.ToValue(a), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return a
+}
+
+func (a *api) abortError(signal *goja.Object) goja.Value {
+	err, _ := a.runtime.New(a.runtime.Get("Error"), a.runtime.ToValue("The operation was aborted"))
+	err.Set("name", "AbortError")
+	err.Set("code", "ABORT_ERR")
+	err.Set("cause", signal.Get("reason"))
+	return err
+}
+
+func (a *api) callMethod(obj *goja.Object, name string, args ...goja.Value) goja.Value {
+	fn, ok := goja.AssertFunction(obj.Get(name))
+	if !ok {
+		panic(a.runtime.NewTypeError("%s is not a function", name))
+	}
+	ret, err := fn(obj, args...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func (a *api) hasMethod(obj *goja.Object, name string) bool {
+	_, ok := goja.AssertFunction(obj.Get(name))
+	return ok
+}
+
+// onAbort calls fn once the signal is aborted. The returned function removes the listener.
+func (a *api) onAbort(signal *goja.Object, fn func()) func() {
+	listener := a.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		fn()
+		return goja.Undefined()
+	})
+	options := a.runtime.NewObject()
+	options.Set("once", true)
+	a.callMethod(signal, "addEventListener", a.runtime.ToValue("abort"), listener, options)
+	return func() {
+		a.callMethod(signal, "removeEventListener", a.runtime.ToValue("abort"), listener)
+	}
+}
+
+func (a *api) signalOption(options goja.Value) *goja.Object {
+	obj, ok := options.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	signal := obj.Get("signal")
+	if signal == nil || goja.IsUndefined(signal) {
+		return nil
+	}
+	s, ok := signal.(*goja.Object)
+	if !ok || s.Get("aborted") == nil {
+		panic(a.runtime.NewTypeError(`The "options.signal" property must be an instance of AbortSignal`))
+	}
+	return s
+}
+
+// once implements events.once(emitter, name, options) which returns a promise fulfilled with the array of
+// arguments of the next emitted event. For EventEmitters the promise is rejected if 'error' is emitted first.
+func (a *api) once(call goja.FunctionCall) goja.Value {
+	r := a.runtime
+	emitter := call.Argument(0).ToObject(r)
+	name := call.Argument(1)
+	signal := a.signalOption(call.Argument(2))
+	p, resolve, reject := r.NewPromise()
+	if signal != nil && signal.Get("aborted").ToBoolean() {
+		reject(a.abortError(signal))
+		return r.ToValue(p)
+	}
+
+	var removeAbort func()
+	cleanup := func() {
+		if removeAbort != nil {
+			removeAbort()
+		}
+	}
+	var remove func()
+	if a.hasMethod(emitter, "on") {
+		var errorListener goja.Value
+		resolver := r.ToValue(func(call goja.FunctionCall) goja.Value {
+			if errorListener != nil {
+				a.callMethod(emitter, "removeListener", r.ToValue("error"), errorListener)
+			}
+			cleanup()
+			resolve(r.NewArray(valuesToInterfaces(call.Arguments)...))
+			return goja.Undefined()
+		})
+		a.callMethod(emitter, "once", name, resolver)
+		if _, isSym := name.(*goja.Symbol); isSym || name.String() != "error" {
+			errorListener = r.ToValue(func(call goja.FunctionCall) goja.Value {
+				a.callMethod(emitter, "removeListener", name, resolver)
+				cleanup()
+				reject(call.Argument(0))
+				return goja.Undefined()
+			})
+			a.callMethod(emitter, "once", r.ToValue("error"), errorListener)
+		}
+		remove = func() {
+			a.callMethod(emitter, "removeListener", name, resolver)
+			if errorListener != nil {
+				a.callMethod(emitter, "removeListener", r.ToValue("error"), errorListener)
+			}
+		}
+	} else if a.hasMethod(emitter, "addEventListener") {
+		listener := r.ToValue(func(call goja.FunctionCall) goja.Value {
+			cleanup()
+			resolve(r.NewArray(valuesToInterfaces(call.Arguments)...))
+			return goja.Undefined()
+		})
+		options := r.NewObject()
+		options.Set("once", true)
+		a.callMethod(emitter, "addEventListener", name, listener, options)
+		remove = func() {
+			a.callMethod(emitter, "removeEventListener", name, listener)
+		}
+	} else {
+		panic(r.NewTypeError(`The "emitter" argument must be an instance of EventEmitter or EventTarget`))
+	}
+	if signal != nil {
+		removeAbort = a.onAbort(signal, func() {
+			remove()
+			reject(a.abortError(signal))
+		})
+	}
+	return r.ToValue(p)
+}
+
+func (a *api) getEventListeners(call goja.FunctionCall) goja.Value {
+	emitter := call.Argument(0).ToObject(a.runtime)
+	if t := a.targetOf(emitter); t != nil {
+		var ret []interface{}
+		for _, l := range t.listeners[call.Argument(1).String()] {
+			ret = append(ret, l.callback)
+		}
+		return a.runtime.NewArray(ret...)
+	}
+	return a.callMethod(emitter, "listeners", call.Argument(1))
+}
+
+func (a *api) setMaxListeners(call goja.FunctionCall) goja.Value {
+	n := a.validateMaxListeners(call.Argument(0))
+	if len(call.Arguments) < 2 {
+		a.emitter.Set("defaultMaxListeners", n)
+		return goja.Undefined()
+	}
+	for _, target := range call.Arguments[1:] {
+		obj := target.ToObject(a.runtime)
+		if t := a.targetOf(obj); t != nil {
+			t.maxListeners = n
+		} else {
+			a.callMethod(obj, "setMaxListeners", a.runtime.ToValue(n))
+		}
+	}
+	return goja.Undefined()
+}
+
+func (a *api) listenerCountStatic(call goja.FunctionCall) goja.Value {
+	return a.callMethod(call.Argument(0).ToObject(a.runtime), "listenerCount", call.Argument(1))
+}
+
+func valuesToInterfaces(values []goja.Value) []interface{} {
+	ret := make([]interface{}, len(values))
+	for i, v := range values {
+		ret[i] = v
+	}
+	return ret
+}
+
+// NewEventEmitter creates a new EventEmitter in the runtime. It can be used by native modules to create
+// objects which emit events to JS code.
+func NewEventEmitter(runtime *goja.Runtime) *goja.Object {
+	a := getAPI(runtime)
+	obj := runtime.NewObject()
+	obj.SetPrototype(a.emitterProto)
+	a.init(obj)
+	return obj
+}
+
+// Emit calls the emit() method of the emitter with the arguments converted by runtime.ToValue().
+// It returns true if the event had listeners and the exception thrown by a listener, if any.
+// It must be called from the goroutine which runs the runtime (e.g. from a function passed to RunOnLoop).
+func Emit(runtime *goja.Runtime, emitter *goja.Object, event string, args ...interface{}) (bool, error) {
+	emit, ok := goja.AssertFunction(emitter.Get("emit"))
+	if !ok {
+		return false, errors.New("emit is not a function")
+	}
+	values := make([]goja.Value, 0, len(args)+1)
+	values = append(values, runtime.ToValue(event))
+	for _, arg := range args {
+		values = append(values, runtime.ToValue(arg))
+	}
+	ret, err := emit(emitter, values...)
+	if err != nil {
+		return false, err
+	}
+	return ret.ToBoolean(), nil
+}
+
+// Loop is the part of *eventloop.EventLoop used by EmitOnLoop.
+type Loop interface {
+	RunOnLoop(fn func(*goja.Runtime))
+}
+
+// EmitOnLoop schedules the event to be emitted on the loop. It is safe to call from any goroutine.
+// Exceptions thrown by listeners are rethrown from the job, which *eventloop.EventLoop reports like the ones
+// thrown by timer callbacks: to process 'uncaughtException' or to its error handler.
+func EmitOnLoop(loop Loop, emitter *goja.Object, event string, args ...interface{}) {
+	loop.RunOnLoop(func(runtime *goja.Runtime) {
+		_, err := Emit(runtime, emitter, event, args...)
+		if ex, ok := err.(*goja.Exception); ok {
+			panic(ex)
+		}
+		if err != nil {
+			panic(runtime.NewGoError(err))
+		}
+	})
+}
+
+type EventsModule struct {
+}
+
+// Enable defines the EventTarget, Event, AbortController and AbortSignal classes as globals.
+func (m *EventsModule) Enable(runtime *goja.Runtime) {
+	a := getAPI(runtime)
+	runtime.Set("EventTarget", a.eventTarget)
+	runtime.Set("Event", a.event)
+	runtime.Set("AbortController", a.abortController)
+	runtime.Set("AbortSignal", a.abortSignal)
+}
+
+// Globals returns the names of the globals set by Enable.
+func (m *EventsModule) Globals() []string {
+	return []string{"EventTarget", "Event", "AbortController", "AbortSignal"}
+}
+
+func (m *EventsModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	module.Set("exports", getAPI(runtime).emitter)
+}
+
+func Default() *EventsModule {
+	return &defaultModule
+}
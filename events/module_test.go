@@ -0,0 +1,260 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/require"
+)
+
+func newRuntime(t *testing.T) *goja.Runtime {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+	Default().Enable(vm)
+	return vm
+}
+
+func runScript(t *testing.T, vm *goja.Runtime, script string) goja.Value {
+	res, err := vm.RunString(script)
+	if err != nil {
+		if ex, ok := err.(*goja.Exception); ok {
+			t.Fatal(ex.String())
+		}
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestEventEmitter(t *testing.T) {
+	vm := newRuntime(t)
+	res := runScript(t, vm, `
+	const EventEmitter = require("node:events");
+	if (EventEmitter.EventEmitter !== EventEmitter) throw new Error("EventEmitter.EventEmitter");
+	var log = [];
+	const e = new EventEmitter();
+	function a(v) { log.push("a" + v); }
+	e.on("newListener", name => log.push("new:" + name));
+	e.on("test", a).once("test", v => log.push("once" + v)).prependListener("test", v => log.push("first" + v));
+	e.removeAllListeners("newListener");
+	var emitted = e.emit("test", 1);
+	e.off("test", a);
+	e.emit("test", 2);
+	log.push(emitted, e.emit("other"), e.listenerCount("test"), e.eventNames().length);
+	log.join();
+	`)
+	if s := res.String(); s != "new:test,new:test,new:test,first1,a1,once1,first2,true,false,1,1" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestEventEmitterInheritance(t *testing.T) {
+	vm := newRuntime(t)
+	runScript(t, vm, `
+	const EventEmitter = require("node:events");
+	class A extends EventEmitter {
+		constructor() {
+			super();
+			this.name = "a";
+		}
+	}
+	function B() {
+		EventEmitter.call(this);
+	}
+	Object.setPrototypeOf(B.prototype, EventEmitter.prototype);
+
+	for (const obj of [new A(), new B()]) {
+		if (!(obj instanceof EventEmitter)) throw new Error("instanceof");
+		let v;
+		obj.on("x", function(arg) { v = [this, arg]; });
+		obj.emit("x", 42);
+		if (v[0] !== obj || v[1] !== 42) throw new Error("emit: " + v);
+	}
+	`)
+}
+
+func TestEventEmitterErrors(t *testing.T) {
+	vm := newRuntime(t)
+	runScript(t, vm, `
+	const EventEmitter = require("node:events");
+	const e = new EventEmitter();
+	const err = new Error("boom");
+	try {
+		e.emit("error", err);
+		throw new Error("not thrown");
+	} catch (ex) {
+		if (ex !== err) throw ex;
+	}
+	try {
+		e.emit("error", "str");
+		throw new Error("not thrown");
+	} catch (ex) {
+		if (ex.code !== "ERR_UNHANDLED_ERROR") throw ex;
+	}
+	let monitored, handled;
+	e.on(EventEmitter.errorMonitor, v => monitored = v);
+	e.on("error", v => handled = v);
+	e.emit("error", err);
+	if (monitored !== err || handled !== err) throw new Error("error listeners");
+	try {
+		e.on("x", 1);
+		throw new Error("not thrown");
+	} catch (ex) {
+		if (!(ex instanceof TypeError)) throw ex;
+	}
+	`)
+}
+
+func TestEventEmitterSymbolsAndLimits(t *testing.T) {
+	vm := newRuntime(t)
+	var warnings []string
+	process := vm.NewObject()
+	process.Set("emitWarning", func(call goja.FunctionCall) goja.Value {
+		warnings = append(warnings, call.Argument(0).String())
+		return goja.Undefined()
+	})
+	vm.Set("process", process)
+	runScript(t, vm, `
+	const EventEmitter = require("node:events");
+	const e = new EventEmitter();
+	const sym = Symbol("s");
+	const f = () => {};
+	e.on(sym, f);
+	e.on("a", f);
+	const names = e.eventNames();
+	if (names.length !== 2 || names.indexOf(sym) < 0) throw new Error("eventNames");
+	if (e.listenerCount(sym) !== 1 || e.listeners(sym)[0] !== f) throw new Error("listeners");
+	e.once("b", f);
+	if (e.rawListeners("b")[0] === f || e.rawListeners("b")[0].listener !== f) throw new Error("rawListeners");
+	e.setMaxListeners(2);
+	if (e.getMaxListeners() !== 2) throw new Error("getMaxListeners");
+	for (let i = 0; i < 4; i++) {
+		e.on("c", () => {});
+	}
+	`)
+	if len(warnings) != 1 {
+		t.Fatalf("Unexpected warnings: %v", warnings)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	vm := newRuntime(t)
+	res := runScript(t, vm, `
+	const EventEmitter = require("node:events");
+	const { once } = EventEmitter;
+	var log = [];
+	const e = new EventEmitter();
+	once(e, "ready").then(args => log.push("ready:" + args.join("+")));
+	once(e, "ready").catch(err => log.push("rejected:" + err.message));
+	e.emit("ready", 1, 2);
+	const p = once(e, "never");
+	p.catch(err => log.push("error:" + err.message));
+	e.emit("error", new Error("boom"));
+	if (e.listenerCount("never") !== 0 || e.listenerCount("error") !== 0) throw new Error("listeners left");
+
+	const target = new EventTarget();
+	once(target, "foo").then(([ev]) => log.push("target:" + ev.type));
+	target.dispatchEvent(new Event("foo"));
+	log;
+	`)
+	if s := res.String(); s != "ready:1+2,error:boom,target:foo" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestEventTarget(t *testing.T) {
+	vm := newRuntime(t)
+	res := runScript(t, vm, `
+	var log = [];
+	class Target extends EventTarget {}
+	const target = new Target();
+	function a(e) { log.push("a:" + e.type + ":" + (e.target === target) + ":" + e.eventPhase); }
+	target.addEventListener("foo", a);
+	target.addEventListener("foo", a);
+	target.addEventListener("foo", () => log.push("once"), { once: true });
+	target.addEventListener("foo", { handleEvent(e) { log.push("handle:" + (this !== target)); } });
+	let ev = new Event("foo");
+	let ret = target.dispatchEvent(ev);
+	log.push(ret, ev.currentTarget, ev.eventPhase);
+	target.dispatchEvent(new Event("foo"));
+	target.removeEventListener("foo", a);
+
+	target.addEventListener("bar", e => { e.preventDefault(); e.stopImmediatePropagation(); });
+	target.addEventListener("bar", () => log.push("not called"));
+	ev = new Event("bar", { cancelable: true });
+	log.push(target.dispatchEvent(ev), ev.defaultPrevented);
+
+	target.addEventListener("baz", e => e.preventDefault(), { passive: true });
+	log.push(target.dispatchEvent(new Event("baz", { cancelable: true })));
+	log.join();
+	`)
+	if s := res.String(); s != "a:foo:true:2,once,handle:true,true,,0,a:foo:true:2,handle:true,false,true,true" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestAbortController(t *testing.T) {
+	vm := newRuntime(t)
+	res := runScript(t, vm, `
+	const log = [];
+	const c = new AbortController();
+	const s = c.signal;
+	log.push(s instanceof EventTarget, s instanceof AbortSignal, String(s), s.aborted, s.reason);
+	s.onabort = e => log.push("onabort:" + e.type);
+	s.addEventListener("abort", e => log.push("listener:" + (e.target === s)));
+	c.abort();
+	c.abort("again");
+	log.push(s.aborted, s.reason.name, s.reason.message, s.reason.code);
+	try { s.throwIfAborted(); } catch (e) { log.push("thrown:" + e.name); }
+	try { new AbortSignal(); } catch (e) { log.push(e.constructor.name + ":" + e.message); }
+	const a = AbortSignal.abort("why");
+	log.push(a.aborted, a.reason);
+	const c2 = new AbortController();
+	const any = AbortSignal.any([c2.signal, new AbortController().signal]);
+	c2.abort("first");
+	log.push(any.aborted, any.reason);
+	log.join();
+	`)
+	if s := res.String(); s != "true,true,[object AbortSignal],false,,onabort:abort,listener:true,true,AbortError,This operation was aborted,20,"+
+		"thrown:AbortError,TypeError:Illegal constructor,true,why,true,first" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+type testLoop struct {
+	vm    *goja.Runtime
+	queue []func(*goja.Runtime)
+}
+
+func (l *testLoop) RunOnLoop(fn func(*goja.Runtime)) {
+	l.queue = append(l.queue, fn)
+}
+
+func TestEmitOnLoop(t *testing.T) {
+	vm := newRuntime(t)
+	emitter := NewEventEmitter(vm)
+	vm.Set("emitter", emitter)
+	runScript(t, vm, `
+	var received = [];
+	emitter.on("data", (s, n) => received.push(s, n));
+	`)
+	loop := &testLoop{vm: vm}
+	EmitOnLoop(loop, emitter, "data", "hello", 42)
+	if len(loop.queue) != 1 {
+		t.Fatal("Event was not scheduled")
+	}
+	loop.queue[0](vm)
+	if s := runScript(t, vm, "received.join()").String(); s != "hello,42" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	ok, err := Emit(vm, emitter, "other")
+	if ok || err != nil {
+		t.Fatalf("Unexpected result: %v, %v", ok, err)
+	}
+	if _, err = Emit(vm, emitter, "error", errors.New("boom")); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
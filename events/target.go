@@ -0,0 +1,338 @@
+package events
+
+import (
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	phaseNone = iota
+	phaseCapturing
+	phaseAtTarget
+	phaseBubbling
+)
+
+type targetListener struct {
+	callback goja.Value
+	once     bool
+	capture  bool
+	passive  bool
+	removed  bool
+	cleanup  func()
+}
+
+type eventTarget struct {
+	listeners    map[string][]*targetListener
+	maxListeners int64
+}
+
+type event struct {
+	typ              string
+	bubbles          bool
+	cancelable       bool
+	composed         bool
+	defaultPrevented bool
+	stop             bool
+	stopImmediate    bool
+	dispatching      bool
+	inPassive        bool
+	phase            int
+	timeStamp        float64
+	target           goja.Value
+	currentTarget    goja.Value
+}
+
+func (a *api) initEventTarget() {
+	r := a.runtime
+	start := time.Now()
+
+	a.eventTarget = r.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		t := &eventTarget{listeners: make(map[string][]*targetListener), maxListeners: defaultMaxListeners}
+		call.This.DefineDataPropertySymbol(a.stateSym, r.ToValue(t), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+		return nil
+	}).(*goja.Object)
+	a.eventTargetProto = a.eventTarget.Get("prototype").(*goja.Object)
+	a.eventTargetProto.Set("addEventListener", a.addEventListener)
+	a.eventTargetProto.Set("removeEventListener", a.removeEventListener)
+	a.eventTargetProto.Set("dispatchEvent", a.dispatchEvent)
+	a.eventTargetProto.DefineDataPropertySymbol(goja.SymToStringTag, r.ToValue("EventTarget"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+
+	a.event = r.ToValue(func(call goja.ConstructorCall) *goja.Object {
+		if len(call.Arguments) == 0 {
+			panic(r.NewTypeError(`The "type" argument must be specified`))
+		}
+		e := &event{
+			typ:           call.Argument(0).String(),
+			timeStamp:     float64(time.Since(start)) / float64(time.Millisecond),
+			target:        goja.Null(),
+			currentTarget: goja.Null(),
+		}
+		if init, ok := call.Argument(1).(*goja.Object); ok {
+			e.bubbles = boolOption(init, "bubbles")
+			e.cancelable = boolOption(init, "cancelable")
+			e.composed = boolOption(init, "composed")
+		} else if !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+			panic(r.NewTypeError(`The "options" argument must be of type object`))
+		}
+		call.This.DefineDataPropertySymbol(a.stateSym, r.ToValue(e), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+		return nil
+	}).(*goja.Object)
+	a.eventProto = a.event.Get("prototype").(*goja.Object)
+	proto := a.eventProto
+	getter := func(name string, fn func(e *event) interface{}) {
+		get := r.ToValue(func(call goja.FunctionCall) goja.Value {
+			return r.ToValue(fn(a.eventOf(call.This)))
+		})
+		proto.DefineAccessorProperty(name, get, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+	getter("type", func(e *event) interface{} { return e.typ })
+	getter("bubbles", func(e *event) interface{} { return e.bubbles })
+	getter("cancelable", func(e *event) interface{} { return e.cancelable })
+	getter("composed", func(e *event) interface{} { return e.composed })
+	getter("defaultPrevented", func(e *event) interface{} { return e.cancelable && e.defaultPrevented })
+	getter("returnValue", func(e *event) interface{} { return !e.cancelable || !e.defaultPrevented })
+	getter("eventPhase", func(e *event) interface{} { return e.phase })
+	getter("isTrusted", func(e *event) interface{} { return false })
+	getter("timeStamp", func(e *event) interface{} { return e.timeStamp })
+	getter("target", func(e *event) interface{} { return e.target })
+	getter("srcElement", func(e *event) interface{} { return e.target })
+	getter("currentTarget", func(e *event) interface{} { return e.currentTarget })
+	cancelBubble := r.ToValue(func(call goja.FunctionCall) goja.Value {
+		e := a.eventOf(call.This)
+		if len(call.Arguments) > 0 {
+			if call.Argument(0).ToBoolean() {
+				e.stop = true
+			}
+			return goja.Undefined()
+		}
+		return r.ToValue(e.stop)
+	})
+	proto.DefineAccessorProperty("cancelBubble", cancelBubble, cancelBubble, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	proto.Set("preventDefault", func(call goja.FunctionCall) goja.Value {
+		if e := a.eventOf(call.This); !e.inPassive {
+			e.defaultPrevented = true
+		}
+		return goja.Undefined()
+	})
+	proto.Set("stopPropagation", func(call goja.FunctionCall) goja.Value {
+		a.eventOf(call.This).stop = true
+		return goja.Undefined()
+	})
+	proto.Set("stopImmediatePropagation", func(call goja.FunctionCall) goja.Value {
+		e := a.eventOf(call.This)
+		e.stop = true
+		e.stopImmediate = true
+		return goja.Undefined()
+	})
+	proto.Set("composedPath", func(call goja.FunctionCall) goja.Value {
+		e := a.eventOf(call.This)
+		if !e.dispatching {
+			return r.NewArray()
+		}
+		return r.NewArray(e.currentTarget)
+	})
+	proto.DefineDataPropertySymbol(goja.SymToStringTag, r.ToValue("Event"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	for i, name := range []string{"NONE", "CAPTURING_PHASE", "AT_TARGET", "BUBBLING_PHASE"} {
+		a.event.DefineDataProperty(name, r.ToValue(i), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		proto.DefineDataProperty(name, r.ToValue(i), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+
+	a.emitter.Set("EventTarget", a.eventTarget)
+	a.emitter.Set("Event", a.event)
+}
+
+func (a *api) stateOf(v goja.Value) interface{} {
+	if obj, ok := v.(*goja.Object); ok {
+		if s := obj.GetSymbol(a.stateSym); s != nil {
+			return s.Export()
+		}
+	}
+	return nil
+}
+
+// targetOf returns the state of an EventTarget, or nil if the value is not an EventTarget.
+func (a *api) targetOf(v goja.Value) *eventTarget {
+	switch t := a.stateOf(v).(type) {
+	case *eventTarget:
+		return t
+	case *abortSignal:
+		return &t.eventTarget
+	}
+	return nil
+}
+
+func (a *api) mustTarget(v goja.Value) *eventTarget {
+	if t := a.targetOf(v); t != nil {
+		return t
+	}
+	panic(a.runtime.NewTypeError(`Value of "this" must be of type EventTarget`))
+}
+
+func (a *api) eventOf(v goja.Value) *event {
+	if e, ok := a.stateOf(v).(*event); ok {
+		return e
+	}
+	panic(a.runtime.NewTypeError(`Value of "this" must be of type Event`))
+}
+
+func boolOption(obj *goja.Object, name string) bool {
+	v := obj.Get(name)
+	return v != nil && v.ToBoolean()
+}
+
+func (a *api) listenerOptions(v goja.Value) (once, capture, passive bool, signal *goja.Object) {
+	if obj, ok := v.(*goja.Object); ok {
+		once = boolOption(obj, "once")
+		capture = boolOption(obj, "capture")
+		passive = boolOption(obj, "passive")
+		signal = a.signalOption(obj)
+	} else {
+		capture = v.ToBoolean()
+	}
+	return
+}
+
+func (t *eventTarget) find(typ string, callback goja.Value, capture bool) int {
+	for i, l := range t.listeners[typ] {
+		if l.callback.SameAs(callback) && l.capture == capture {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *eventTarget) remove(typ string, i int) {
+	list := t.listeners[typ]
+	l := list[i]
+	l.removed = true
+	if l.cleanup != nil {
+		l.cleanup()
+	}
+	list = append(list[:i:i], list[i+1:]...)
+	if len(list) == 0 {
+		delete(t.listeners, typ)
+	} else {
+		t.listeners[typ] = list
+	}
+}
+
+func (a *api) addEventListener(call goja.FunctionCall) goja.Value {
+	t := a.mustTarget(call.This)
+	typ := call.Argument(0).String()
+	callback := call.Argument(1)
+	if goja.IsUndefined(callback) || goja.IsNull(callback) {
+		return goja.Undefined()
+	}
+	if _, ok := callback.(*goja.Object); !ok {
+		panic(a.runtime.NewTypeError(`The "listener" argument must be an instance of EventListener`))
+	}
+	once, capture, passive, signal := a.listenerOptions(call.Argument(2))
+	if signal != nil && signal.Get("aborted").ToBoolean() {
+		return goja.Undefined()
+	}
+	if t.find(typ, callback, capture) >= 0 {
+		return goja.Undefined()
+	}
+	l := &targetListener{callback: callback, once: once, capture: capture, passive: passive}
+	t.listeners[typ] = append(t.listeners[typ], l)
+	if n := int64(len(t.listeners[typ])); t.maxListeners > 0 && n == t.maxListeners+1 {
+		a.warn("MaxListenersExceededWarning", "Possible EventTarget memory leak detected. "+
+			call.Argument(0).String()+" listeners added to EventTarget. Use events.setMaxListeners() to increase limit")
+	}
+	if signal != nil {
+		l.cleanup = a.onAbort(signal, func() {
+			l.cleanup = nil
+			if i := t.find(typ, callback, capture); i >= 0 {
+				t.remove(typ, i)
+			}
+		})
+	}
+	return goja.Undefined()
+}
+
+func (a *api) removeEventListener(call goja.FunctionCall) goja.Value {
+	t := a.mustTarget(call.This)
+	typ := call.Argument(0).String()
+	_, capture, _, _ := a.listenerOptions(call.Argument(2))
+	if i := t.find(typ, call.Argument(1), capture); i >= 0 {
+		t.remove(typ, i)
+	}
+	return goja.Undefined()
+}
+
+// reportError reports an exception thrown by an event listener without interrupting the dispatch. It is
+// re-thrown from a microtask if queueMicrotask() is available, so an event loop handles it as an uncaught
+// exception. Otherwise it is returned to be thrown once the dispatch is complete.
+func (a *api) reportError(err error) error {
+	queueMicrotask, ok := goja.AssertFunction(a.runtime.GlobalObject().Get("queueMicrotask"))
+	if !ok {
+		return err
+	}
+	_, qerr := queueMicrotask(goja.Undefined(), a.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		panic(err)
+	}))
+	if qerr != nil {
+		return qerr
+	}
+	return nil
+}
+
+func (a *api) dispatchEvent(call goja.FunctionCall) goja.Value {
+	t := a.mustTarget(call.This)
+	arg := call.Argument(0)
+	e, ok := a.stateOf(arg).(*event)
+	if !ok {
+		panic(a.runtime.NewTypeError(`The "event" argument must be an instance of Event`))
+	}
+	if e.dispatching {
+		err, _ := a.runtime.New(a.runtime.Get("Error"), a.runtime.ToValue("The event \""+e.typ+"\" is already being dispatched"))
+		err.Set("code", "ERR_EVENT_RECURSION")
+		panic(err)
+	}
+	e.dispatching = true
+	e.target = call.This
+	e.currentTarget = call.This
+	e.phase = phaseAtTarget
+
+	var thrown error
+	list := append([]*targetListener(nil), t.listeners[e.typ]...)
+	for _, l := range list {
+		if l.removed {
+			continue
+		}
+		if l.once {
+			if i := t.find(e.typ, l.callback, l.capture); i >= 0 {
+				t.remove(e.typ, i)
+			}
+		}
+		this := call.This
+		fn, ok := goja.AssertFunction(l.callback)
+		if !ok {
+			obj := l.callback.(*goja.Object)
+			if fn, ok = goja.AssertFunction(obj.Get("handleEvent")); !ok {
+				continue
+			}
+			this = obj
+		}
+		e.inPassive = l.passive
+		_, err := fn(this, arg)
+		e.inPassive = false
+		if err != nil {
+			if err = a.reportError(err); err != nil && thrown == nil {
+				thrown = err
+			}
+		}
+		if e.stopImmediate {
+			break
+		}
+	}
+
+	e.dispatching = false
+	e.currentTarget = goja.Null()
+	e.phase = phaseNone
+	if thrown != nil {
+		panic(thrown)
+	}
+	return a.runtime.ToValue(!e.cancelable || !e.defaultPrevented)
+}
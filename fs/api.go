@@ -0,0 +1,603 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/buffer"
+)
+
+// File type bits of the mode of fs.Stats
+const (
+	sIFMT   = 0170000
+	sIFSOCK = 0140000
+	sIFLNK  = 0120000
+	sIFREG  = 0100000
+	sIFBLK  = 0060000
+	sIFDIR  = 0040000
+	sIFCHR  = 0020000
+	sIFIFO  = 0010000
+)
+
+var fileTypes = []struct {
+	method string
+	bits   int64
+}{
+	{"isFile", sIFREG},
+	{"isDirectory", sIFDIR},
+	{"isSymbolicLink", sIFLNK},
+	{"isFIFO", sIFIFO},
+	{"isSocket", sIFSOCK},
+	{"isBlockDevice", sIFBLK},
+	{"isCharacterDevice", sIFCHR},
+}
+
+// errRmDirectory is returned by rm() for a directory if the recursive option is not set.
+var errRmDirectory = errors.New("path is a directory")
+
+// task is a file system operation. The arguments are parsed by the function creating the task, then run is
+// called, in a separate goroutine for the asynchronous variants, so it must not use the runtime.
+// Finally result converts the value returned by run to a JS value.
+type task struct {
+	syscall string
+	paths   []string
+	run     func() (interface{}, error)
+	result  func(v interface{}) goja.Value
+}
+
+type api struct {
+	runtime *goja.Runtime
+	fsys    FS
+	loop    Loop
+
+	fs, promises            *goja.Object
+	statsProto, direntProto *goja.Object
+	typeSym                 *goja.Symbol
+}
+
+func (a *api) init() {
+	r := a.runtime
+	a.fs = r.NewObject()
+	a.promises = r.NewObject()
+	a.typeSym = goja.NewSymbol("type")
+	a.initStats()
+
+	ops := []struct {
+		name  string
+		build func(args []goja.Value) *task
+	}{
+		{"readFile", a.readFile},
+		{"writeFile", a.writeFile},
+		{"stat", a.stat},
+		{"readdir", a.readdir},
+		{"mkdir", a.mkdir},
+		{"rm", a.rm},
+		{"rename", a.rename},
+	}
+	for _, op := range ops {
+		build := op.build
+		a.fs.Set(op.name+"Sync", func(call goja.FunctionCall) goja.Value {
+			return a.sync(build(call.Arguments))
+		})
+		a.fs.Set(op.name, func(call goja.FunctionCall) goja.Value {
+			a.callback(build, call.Arguments)
+			return goja.Undefined()
+		})
+		a.promises.Set(op.name, func(call goja.FunctionCall) goja.Value {
+			return a.promise(build, call.Arguments)
+		})
+	}
+	a.fs.Set("existsSync", a.existsSync)
+	a.fs.Set("promises", a.promises)
+}
+
+func (a *api) initStats() {
+	r := a.runtime
+	a.statsProto = r.NewObject()
+	a.direntProto = r.NewObject()
+	for _, t := range fileTypes {
+		bits := t.bits
+		a.statsProto.Set(t.method, func(call goja.FunctionCall) goja.Value {
+			mode := call.This.ToObject(r).Get("mode")
+			return r.ToValue(mode != nil && mode.ToInteger()&sIFMT == bits)
+		})
+		a.direntProto.Set(t.method, func(call goja.FunctionCall) goja.Value {
+			mode := call.This.ToObject(r).GetSymbol(a.typeSym)
+			return r.ToValue(mode != nil && mode.ToInteger()&sIFMT == bits)
+		})
+	}
+}
+
+// sync runs the task in the current goroutine and throws the error if it fails.
+func (a *api) sync(t *task) goja.Value {
+	v, err := t.run()
+	if err != nil {
+		panic(a.fsError(err, t.syscall, t.paths...))
+	}
+	return t.result(v)
+}
+
+// async runs the task in a separate goroutine and calls done on the loop. Without a loop the task is run
+// synchronously and done is called from a promise job.
+func (a *api) async(t *task, done func(err, result goja.Value)) {
+	complete := func(v interface{}, err error) {
+		if err != nil {
+			done(a.fsError(err, t.syscall, t.paths...), nil)
+		} else {
+			done(nil, t.result(v))
+		}
+	}
+	if a.loop == nil {
+		v, err := t.run()
+		a.enqueueJob(func() {
+			complete(v, err)
+		})
+		return
+	}
+	callback := a.loop.RegisterCallback()
+	go func() {
+		v, err := t.run()
+		callback(func(*goja.Runtime) {
+			complete(v, err)
+		})
+	}()
+}
+
+func (a *api) enqueueJob(fn func()) {
+	r := a.runtime
+	p, resolve, _ := r.NewPromise()
+	resolve(goja.Undefined())
+	promise := r.ToValue(p).(*goja.Object)
+	then, _ := goja.AssertFunction(promise.Get("then"))
+	then(promise, r.ToValue(func(goja.FunctionCall) goja.Value {
+		fn()
+		return goja.Undefined()
+	}))
+}
+
+func (a *api) callback(build func([]goja.Value) *task, args []goja.Value) {
+	var cb goja.Callable
+	var ok bool
+	if len(args) > 0 {
+		cb, ok = goja.AssertFunction(args[len(args)-1])
+	}
+	if !ok {
+		received := "undefined"
+		if len(args) > 0 {
+			received = args[len(args)-1].String()
+		}
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "cb" argument must be of type function. Received %s`, received))
+	}
+	t := build(args[:len(args)-1])
+	a.async(t, func(err, result goja.Value) {
+		var cbErr error
+		if err != nil {
+			_, cbErr = cb(goja.Undefined(), err)
+		} else {
+			_, cbErr = cb(goja.Undefined(), goja.Null(), result)
+		}
+		if cbErr == nil {
+			return
+		}
+		if a.loop != nil {
+			a.loop.ReportError(cbErr)
+		} else {
+			panic(cbErr)
+		}
+	})
+}
+
+func (a *api) promise(build func([]goja.Value) *task, args []goja.Value) goja.Value {
+	p, resolve, reject := a.runtime.NewPromise()
+	var t *task
+	if ex := a.runtime.Try(func() { t = build(args) }); ex != nil {
+		reject(ex.Value())
+		return a.runtime.ToValue(p)
+	}
+	a.async(t, func(err, result goja.Value) {
+		if err != nil {
+			reject(err)
+		} else {
+			resolve(result)
+		}
+	})
+	return a.runtime.ToValue(p)
+}
+
+func arg(args []goja.Value, i int) goja.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return goja.Undefined()
+}
+
+func isString(v goja.Value) bool {
+	if _, ok := v.(*goja.Object); ok {
+		return false
+	}
+	_, ok := v.Export().(string)
+	return ok
+}
+
+func isMissing(v goja.Value) bool {
+	return v == nil || goja.IsUndefined(v) || goja.IsNull(v)
+}
+
+// resolvePath converts the path used by JS code to a name of the FS.
+func resolvePath(p string) string {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// path returns the path given as a string, a Buffer or a file: URL and the corresponding name of the FS.
+func (a *api) path(v goja.Value, name string) (string, string) {
+	var p string
+	if isString(v) {
+		p = v.String()
+	} else if b, err := buffer.Bytes(v); err == nil && v.(*goja.Object).Get("BYTES_PER_ELEMENT") != nil {
+		p = string(b)
+	} else if obj, ok := v.(*goja.Object); ok && obj.Get("href") != nil && obj.Get("pathname") != nil {
+		if obj.Get("protocol").String() != "file:" {
+			panic(a.typeError("ERR_INVALID_URL_SCHEME", "The URL must be of scheme file"))
+		}
+		var err error
+		if p, err = url.PathUnescape(obj.Get("pathname").String()); err != nil {
+			panic(a.typeError("ERR_INVALID_FILE_URL_PATH", "File URL path %s", err.Error()))
+		}
+	} else {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "%s" argument must be of type string or an instance of Buffer or URL. Received %s`,
+			name, v.String()))
+	}
+	if strings.IndexByte(p, 0) >= 0 {
+		panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument '%s' must be a string, Uint8Array, or URL without null bytes. Received %q",
+			name, p))
+	}
+	return p, resolvePath(p)
+}
+
+// options returns the options argument as an object. A string is the encoding option.
+func (a *api) options(v goja.Value) *goja.Object {
+	if isMissing(v) {
+		return a.runtime.NewObject()
+	}
+	if isString(v) {
+		obj := a.runtime.NewObject()
+		obj.Set("encoding", v)
+		return obj
+	}
+	if obj, ok := v.(*goja.Object); ok {
+		return obj
+	}
+	panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "options" argument must be one of type string or object. Received %s`, v.String()))
+}
+
+func option(options *goja.Object, name string) goja.Value {
+	if v := options.Get(name); v != nil {
+		return v
+	}
+	return goja.Undefined()
+}
+
+func boolOption(options *goja.Object, name string, def bool) bool {
+	if v := option(options, name); !goja.IsUndefined(v) {
+		return v.ToBoolean()
+	}
+	return def
+}
+
+// encodingOption returns the encoding option, or "" if the data should be returned as a Buffer.
+func (a *api) encodingOption(options *goja.Object, def string) string {
+	v := option(options, "encoding")
+	if isMissing(v) {
+		return def
+	}
+	enc := v.String()
+	if enc == "buffer" {
+		return ""
+	}
+	if _, err := buffer.Encode("", enc); err != nil {
+		panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'encoding' is invalid encoding. Received '%s'", enc))
+	}
+	return enc
+}
+
+func (a *api) modeOption(v goja.Value, def iofs.FileMode) iofs.FileMode {
+	if goja.IsUndefined(v) {
+		return def
+	}
+	var mode int64
+	if isString(v) {
+		m, err := strconv.ParseInt(v.String(), 8, 32)
+		if err != nil {
+			panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'mode' must be a 32-bit unsigned integer or an octal string. Received '%s'",
+				v.String()))
+		}
+		mode = m
+	} else {
+		f := v.ToFloat()
+		if f != math.Trunc(f) || f < 0 || f > math.MaxUint32 {
+			panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'mode' must be a 32-bit unsigned integer or an octal string. Received %s",
+				v.String()))
+		}
+		mode = int64(f)
+	}
+	return iofs.FileMode(mode).Perm()
+}
+
+func (a *api) bytesValue(data []byte, encoding string) goja.Value {
+	if encoding == "" {
+		return buffer.NewBuffer(a.runtime, data)
+	}
+	s, _ := buffer.Decode(data, encoding)
+	return a.runtime.ToValue(s)
+}
+
+func (a *api) readFile(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	encoding := a.encodingOption(a.options(arg(args, 1)), "")
+	return &task{
+		syscall: "open",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			return a.fsys.ReadFile(name)
+		},
+		result: func(v interface{}) goja.Value {
+			return a.bytesValue(v.([]byte), encoding)
+		},
+	}
+}
+
+func (a *api) writeFile(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	options := a.options(arg(args, 2))
+	encoding := a.encodingOption(options, "utf8")
+	mode := a.modeOption(option(options, "mode"), 0666)
+	flag := "w"
+	if v := option(options, "flag"); !isMissing(v) {
+		flag = v.String()
+	}
+	appendData := strings.ContainsRune(flag, 'a')
+	exclusive := strings.ContainsRune(flag, 'x')
+	if strings.Trim(flag, "wax+s") != "" || !appendData && !strings.ContainsRune(flag, 'w') {
+		panic(a.typeError("ERR_INVALID_ARG_VALUE", "The argument 'flags' is invalid. Received '%s'", flag))
+	}
+
+	var data []byte
+	if v := arg(args, 1); isString(v) {
+		if encoding == "" {
+			encoding = "utf8"
+		}
+		data, _ = buffer.Encode(v.String(), encoding)
+	} else if b, err := buffer.Bytes(v); err == nil {
+		data = append([]byte(nil), b...)
+	} else {
+		panic(a.typeError("ERR_INVALID_ARG_TYPE", `The "data" argument must be of type string or an instance of Buffer, TypedArray, or DataView. Received %s`,
+			v.String()))
+	}
+
+	return &task{
+		syscall: "open",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			if exclusive {
+				if _, err := a.fsys.Stat(name); err == nil {
+					return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrExist}
+				}
+			}
+			if appendData {
+				old, err := a.fsys.ReadFile(name)
+				if err == nil {
+					data = append(old, data...)
+				} else if !errors.Is(err, iofs.ErrNotExist) {
+					return nil, err
+				}
+			}
+			return nil, a.fsys.WriteFile(name, data, mode)
+		},
+		result: func(interface{}) goja.Value {
+			return goja.Undefined()
+		},
+	}
+}
+
+func fileTypeBits(mode iofs.FileMode) int64 {
+	switch {
+	case mode.IsDir():
+		return sIFDIR
+	case mode&iofs.ModeSymlink != 0:
+		return sIFLNK
+	case mode&iofs.ModeNamedPipe != 0:
+		return sIFIFO
+	case mode&iofs.ModeSocket != 0:
+		return sIFSOCK
+	case mode&iofs.ModeCharDevice != 0:
+		return sIFCHR
+	case mode&iofs.ModeDevice != 0:
+		return sIFBLK
+	}
+	return sIFREG
+}
+
+func (a *api) newDate(ms float64) goja.Value {
+	d, err := a.runtime.New(a.runtime.GlobalObject().Get("Date"), a.runtime.ToValue(ms))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (a *api) newStats(fi iofs.FileInfo) *goja.Object {
+	stats := a.runtime.NewObject()
+	stats.SetPrototype(a.statsProto)
+	size := fi.Size()
+	stats.Set("dev", 0)
+	stats.Set("mode", fileTypeBits(fi.Mode())|int64(fi.Mode().Perm()))
+	stats.Set("nlink", 1)
+	stats.Set("uid", 0)
+	stats.Set("gid", 0)
+	stats.Set("rdev", 0)
+	stats.Set("blksize", 4096)
+	stats.Set("ino", 0)
+	stats.Set("size", size)
+	stats.Set("blocks", (size+511)/512)
+	ms := float64(fi.ModTime().UnixNano()) / 1e6
+	for _, name := range []string{"atime", "mtime", "ctime", "birthtime"} {
+		stats.Set(name+"Ms", ms)
+	}
+	for _, name := range []string{"atime", "mtime", "ctime", "birthtime"} {
+		stats.Set(name, a.newDate(ms))
+	}
+	return stats
+}
+
+func (a *api) stat(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	throwIfNoEntry := boolOption(a.options(arg(args, 1)), "throwIfNoEntry", true)
+	return &task{
+		syscall: "stat",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			fi, err := a.fsys.Stat(name)
+			if err != nil {
+				if !throwIfNoEntry && errorCodeOf(err) == codeENOENT {
+					return nil, nil
+				}
+				return nil, err
+			}
+			return fi, nil
+		},
+		result: func(v interface{}) goja.Value {
+			if v == nil {
+				return goja.Undefined()
+			}
+			return a.newStats(v.(iofs.FileInfo))
+		},
+	}
+}
+
+func (a *api) readdir(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	options := a.options(arg(args, 1))
+	encoding := a.encodingOption(options, "utf8")
+	withFileTypes := boolOption(options, "withFileTypes", false)
+	return &task{
+		syscall: "scandir",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			return a.fsys.ReadDir(name)
+		},
+		result: func(v interface{}) goja.Value {
+			entries := v.([]iofs.DirEntry)
+			ret := make([]interface{}, len(entries))
+			for i, e := range entries {
+				name := a.bytesValue([]byte(e.Name()), encoding)
+				if !withFileTypes {
+					ret[i] = name
+					continue
+				}
+				dirent := a.runtime.NewObject()
+				dirent.SetPrototype(a.direntProto)
+				dirent.Set("name", name)
+				dirent.Set("parentPath", p)
+				dirent.Set("path", p)
+				dirent.DefineDataPropertySymbol(a.typeSym, a.runtime.ToValue(fileTypeBits(e.Type())),
+					goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+				ret[i] = dirent
+			}
+			return a.runtime.NewArray(ret...)
+		},
+	}
+}
+
+func (a *api) mkdir(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	var recursive bool
+	mode := iofs.FileMode(0777)
+	if v := arg(args, 1); !isMissing(v) {
+		if options, ok := v.(*goja.Object); ok {
+			recursive = boolOption(options, "recursive", false)
+			mode = a.modeOption(option(options, "mode"), mode)
+		} else {
+			mode = a.modeOption(v, mode)
+		}
+	}
+	return &task{
+		syscall: "mkdir",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			if recursive {
+				return mkdirAll(a.fsys, name, mode)
+			}
+			return "", a.fsys.Mkdir(name, mode)
+		},
+		result: func(v interface{}) goja.Value {
+			if first := v.(string); first != "" {
+				return a.runtime.ToValue("/" + first)
+			}
+			return goja.Undefined()
+		},
+	}
+}
+
+func (a *api) rm(args []goja.Value) *task {
+	p, name := a.path(arg(args, 0), "path")
+	options := a.options(arg(args, 1))
+	recursive := boolOption(options, "recursive", false)
+	force := boolOption(options, "force", false)
+	return &task{
+		syscall: "rm",
+		paths:   []string{p},
+		run: func() (interface{}, error) {
+			fi, err := a.fsys.Stat(name)
+			if err != nil {
+				if force && errorCodeOf(err) == codeENOENT {
+					return nil, nil
+				}
+				return nil, err
+			}
+			if fi.IsDir() {
+				if !recursive {
+					return nil, errRmDirectory
+				}
+				return nil, removeAll(a.fsys, name)
+			}
+			return nil, a.fsys.Remove(name)
+		},
+		result: func(interface{}) goja.Value {
+			return goja.Undefined()
+		},
+	}
+}
+
+func (a *api) rename(args []goja.Value) *task {
+	oldPath, oldName := a.path(arg(args, 0), "oldPath")
+	newPath, newName := a.path(arg(args, 1), "newPath")
+	return &task{
+		syscall: "rename",
+		paths:   []string{oldPath, newPath},
+		run: func() (interface{}, error) {
+			return nil, a.fsys.Rename(oldName, newName)
+		},
+		result: func(interface{}) goja.Value {
+			return goja.Undefined()
+		},
+	}
+}
+
+func (a *api) existsSync(call goja.FunctionCall) goja.Value {
+	var name string
+	if ex := a.runtime.Try(func() { _, name = a.path(call.Argument(0), "path") }); ex != nil {
+		return a.runtime.ToValue(false)
+	}
+	_, err := a.fsys.Stat(name)
+	return a.runtime.ToValue(err == nil)
+}
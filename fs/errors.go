@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"syscall"
+
+	"github.com/dop251/goja"
+)
+
+type errorCode struct {
+	code        string
+	errno       int
+	description string
+}
+
+var (
+	codeENOENT    = errorCode{"ENOENT", -2, "no such file or directory"}
+	codeEEXIST    = errorCode{"EEXIST", -17, "file already exists"}
+	codeENOTDIR   = errorCode{"ENOTDIR", -20, "not a directory"}
+	codeEISDIR    = errorCode{"EISDIR", -21, "illegal operation on a directory"}
+	codeENOTEMPTY = errorCode{"ENOTEMPTY", -39, "directory not empty"}
+	codeEACCES    = errorCode{"EACCES", -13, "permission denied"}
+	codeEPERM     = errorCode{"EPERM", -1, "operation not permitted"}
+	codeEINVAL    = errorCode{"EINVAL", -22, "invalid argument"}
+	codeEIO       = errorCode{"EIO", -5, "i/o error"}
+)
+
+var errnoCodes = map[syscall.Errno]errorCode{
+	syscall.ENOENT:    codeENOENT,
+	syscall.EEXIST:    codeEEXIST,
+	syscall.ENOTDIR:   codeENOTDIR,
+	syscall.EISDIR:    codeEISDIR,
+	syscall.ENOTEMPTY: codeENOTEMPTY,
+	syscall.EACCES:    codeEACCES,
+	syscall.EPERM:     codeEPERM,
+	syscall.EINVAL:    codeEINVAL,
+}
+
+func errorCodeOf(err error) errorCode {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if c, ok := errnoCodes[errno]; ok {
+			return c
+		}
+	}
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		return codeENOENT
+	case errors.Is(err, iofs.ErrExist):
+		return codeEEXIST
+	case errors.Is(err, iofs.ErrPermission):
+		return codeEPERM
+	case errors.Is(err, iofs.ErrInvalid):
+		return codeEINVAL
+	}
+	return codeEIO
+}
+
+// fsError converts an error returned by the FS into a Node system error, e.g.
+// "ENOENT: no such file or directory, open '/a.txt'" with the code, errno, syscall and path properties.
+func (a *api) fsError(err error, syscall string, paths ...string) goja.Value {
+	if err == errRmDirectory {
+		e := a.newError("Error", fmt.Sprintf("Path is a directory: %s returned EISDIR (is a directory) %s", syscall, paths[0]))
+		e.Set("code", "ERR_FS_EISDIR")
+		e.Set("errno", 21)
+		e.Set("syscall", syscall)
+		e.Set("path", paths[0])
+		return e
+	}
+	c := errorCodeOf(err)
+	msg := fmt.Sprintf("%s: %s, %s", c.code, c.description, syscall)
+	if len(paths) > 0 {
+		msg += fmt.Sprintf(" '%s'", paths[0])
+	}
+	if len(paths) > 1 {
+		msg += fmt.Sprintf(" -> '%s'", paths[1])
+	}
+	e := a.newError("Error", msg)
+	e.Set("errno", c.errno)
+	e.Set("code", c.code)
+	e.Set("syscall", syscall)
+	if len(paths) > 0 {
+		e.Set("path", paths[0])
+	}
+	if len(paths) > 1 {
+		e.Set("dest", paths[1])
+	}
+	return e
+}
+
+func (a *api) newError(ctor, msg string) *goja.Object {
+	err, _ := a.runtime.New(a.runtime.GlobalObject().Get(ctor), a.runtime.ToValue(msg))
+	return err
+}
+
+func (a *api) typeError(code, format string, args ...interface{}) *goja.Object {
+	err := a.newError("TypeError", fmt.Sprintf(format, args...))
+	err.Set("code", code)
+	return err
+}
@@ -0,0 +1,216 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// FS is a writable file system used by the module. Like in io/fs, the names are unrooted slash-separated
+// paths (see io/fs.ValidPath) and the root directory is ".". The methods return *io/fs.PathError errors
+// which wrap io/fs.ErrNotExist, io/fs.ErrExist and io/fs.ErrPermission or a syscall.Errno such as
+// syscall.ENOTDIR and syscall.ENOTEMPTY, which are converted to the errors codes of Node.
+// The implementations must be safe for concurrent use, as asynchronous operations run in separate goroutines.
+type FS interface {
+	iofs.StatFS
+	iofs.ReadDirFS
+	iofs.ReadFileFS
+
+	// WriteFile writes data to the named file, creating it with the permissions perm if necessary.
+	WriteFile(name string, data []byte, perm iofs.FileMode) error
+
+	// Mkdir creates a directory. The parent directory must exist.
+	Mkdir(name string, perm iofs.FileMode) error
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// Rename moves oldname to newname, replacing newname if it is a file.
+	Rename(oldname, newname string) error
+}
+
+// RemoveAllFS is implemented by the file systems which remove a directory with its contents by themselves.
+// Otherwise the entries are removed one by one.
+type RemoveAllFS interface {
+	FS
+
+	// RemoveAll removes the named file or directory with its contents.
+	RemoveAll(name string) error
+}
+
+type dirFS string
+
+// DirFS returns a file system rooted at the directory dir of the host's file system. Like os.DirFS, it
+// does not prevent the symbolic links inside dir from referring to files outside of it.
+func DirFS(dir string) FS {
+	return dirFS(dir)
+}
+
+func (dir dirFS) join(op, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	// Like os.DirFS, reject the names which Windows would read as separators or volumes, such as `..\x` or `c:x`.
+	if runtime.GOOS == "windows" && strings.ContainsAny(name, `\:`) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	return filepath.Join(string(dir), filepath.FromSlash(name)), nil
+}
+
+// pathError replaces the host's path in the errors returned by the os package with name.
+func pathError(err error, name string) error {
+	var pe *iofs.PathError
+	if errors.As(err, &pe) {
+		return &iofs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &iofs.PathError{Op: le.Op, Path: name, Err: le.Err}
+	}
+	return err
+}
+
+func (dir dirFS) Open(name string) (iofs.File, error) {
+	fullname, err := dir.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fullname)
+	if err != nil {
+		return nil, pathError(err, name)
+	}
+	return f, nil
+}
+
+func (dir dirFS) Stat(name string) (iofs.FileInfo, error) {
+	fullname, err := dir.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(fullname)
+	return fi, pathError(err, name)
+}
+
+func (dir dirFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	fullname, err := dir.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(fullname)
+	return entries, pathError(err, name)
+}
+
+func (dir dirFS) ReadFile(name string) ([]byte, error) {
+	fullname, err := dir.join("read", name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fullname)
+	return data, pathError(err, name)
+}
+
+func (dir dirFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	fullname, err := dir.join("write", name)
+	if err != nil {
+		return err
+	}
+	return pathError(os.WriteFile(fullname, data, perm), name)
+}
+
+func (dir dirFS) Mkdir(name string, perm iofs.FileMode) error {
+	fullname, err := dir.join("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return pathError(os.Mkdir(fullname, perm), name)
+}
+
+func (dir dirFS) Remove(name string) error {
+	fullname, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrPermission}
+	}
+	return pathError(os.Remove(fullname), name)
+}
+
+func (dir dirFS) RemoveAll(name string) error {
+	fullname, err := dir.join("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrPermission}
+	}
+	return pathError(os.RemoveAll(fullname), name)
+}
+
+func (dir dirFS) Rename(oldname, newname string) error {
+	oldpath, err := dir.join("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := dir.join("rename", newname)
+	if err != nil {
+		return err
+	}
+	return pathError(os.Rename(oldpath, newpath), oldname)
+}
+
+// mkdirAll creates the directory and its missing parents. It returns the first directory created, or ""
+// if the directory already existed.
+func mkdirAll(fsys FS, name string, perm iofs.FileMode) (string, error) {
+	fi, err := fsys.Stat(name)
+	if err == nil {
+		if fi.IsDir() {
+			return "", nil
+		}
+		return "", &iofs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	var first string
+	if parent := path.Dir(name); parent != name {
+		if first, err = mkdirAll(fsys, parent, perm); err != nil {
+			return "", err
+		}
+	}
+	if err = fsys.Mkdir(name, perm); err != nil {
+		if fi, serr := fsys.Stat(name); serr == nil && fi.IsDir() {
+			return first, nil
+		}
+		return "", err
+	}
+	if first == "" {
+		first = name
+	}
+	return first, nil
+}
+
+// removeAll removes the file or the directory with its contents.
+func removeAll(fsys FS, name string) error {
+	if ra, ok := fsys.(RemoveAllFS); ok {
+		return ra.RemoveAll(name)
+	}
+	fi, err := fsys.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err = removeAll(fsys, path.Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return fsys.Remove(name)
+}
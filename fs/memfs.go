@@ -0,0 +1,321 @@
+package fs
+
+import (
+	"io"
+	iofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type memNode struct {
+	name    string
+	data    []byte
+	mode    iofs.FileMode
+	modTime time.Time
+}
+
+func (n *memNode) info() *memFileInfo {
+	return &memFileInfo{name: n.name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    iofs.FileMode
+	modTime time.Time
+}
+
+func (fi *memFileInfo) Name() string                 { return fi.name }
+func (fi *memFileInfo) Size() int64                  { return fi.size }
+func (fi *memFileInfo) Mode() iofs.FileMode          { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time           { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool                  { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}             { return nil }
+func (fi *memFileInfo) Type() iofs.FileMode          { return fi.mode.Type() }
+func (fi *memFileInfo) Info() (iofs.FileInfo, error) { return fi, nil }
+
+// MemFS is a file system which keeps the files in memory. The zero value is not usable, use NewMemFS().
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+// NewMemFS returns an empty in-memory file system.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: map[string]*memNode{
+			".": {name: ".", mode: iofs.ModeDir | 0777, modTime: time.Now()},
+		},
+	}
+}
+
+// lookup returns the node with the given name. It checks that all parents are directories.
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	if n, ok := m.nodes[name]; ok {
+		return n, nil
+	}
+	if name != "." {
+		if parent, err := m.lookup(op, path.Dir(name)); err != nil {
+			return nil, &iofs.PathError{Op: op, Path: name, Err: err.(*iofs.PathError).Err}
+		} else if !parent.mode.IsDir() {
+			return nil, &iofs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrNotExist}
+}
+
+// parent returns the directory which will contain a new node with the given name.
+func (m *MemFS) parent(op, name string) (*memNode, error) {
+	if !iofs.ValidPath(name) || name == "." {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	dir, err := m.lookup(op, path.Dir(name))
+	if err != nil {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: err.(*iofs.PathError).Err}
+	}
+	if !dir.mode.IsDir() {
+		return nil, &iofs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return dir, nil
+}
+
+func (m *MemFS) children(name string) []string {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	var ret []string
+	for k := range m.nodes {
+		if k != "." && strings.HasPrefix(k, prefix) {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (m *MemFS) Open(name string) (iofs.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f := &memFile{info: n.info()}
+	if n.mode.IsDir() {
+		entries, _ := m.readDir(name)
+		f.entries = entries
+	} else {
+		f.data = append([]byte(nil), n.data...)
+	}
+	return f, nil
+}
+
+func (m *MemFS) Stat(name string) (iofs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(), nil
+}
+
+func (m *MemFS) readDir(name string) ([]iofs.DirEntry, error) {
+	n, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	var entries []iofs.DirEntry
+	for _, k := range m.children(name) {
+		if path.Dir(k) == name {
+			entries = append(entries, m.nodes[k].info())
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readDir(name)
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, ok := m.nodes[name]; ok {
+		if n.mode.IsDir() {
+			return &iofs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		n.data = append([]byte(nil), data...)
+		n.modTime = time.Now()
+		return nil
+	}
+	if _, err := m.parent("open", name); err != nil {
+		return err
+	}
+	m.nodes[name] = &memNode{name: path.Base(name), data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Mkdir(name string, perm iofs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.nodes[name]; ok {
+		return &iofs.PathError{Op: "mkdir", Path: name, Err: iofs.ErrExist}
+	}
+	if _, err := m.parent("mkdir", name); err != nil {
+		return err
+	}
+	m.nodes[name] = &memNode{name: path.Base(name), mode: iofs.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrPermission}
+	}
+	if n.mode.IsDir() && len(m.children(name)) > 0 {
+		return &iofs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, err := m.lookup("rename", oldname)
+	if err != nil {
+		return err
+	}
+	if oldname == "." {
+		return &iofs.PathError{Op: "rename", Path: oldname, Err: iofs.ErrPermission}
+	}
+	if _, err = m.parent("rename", newname); err != nil {
+		return err
+	}
+	if oldname == newname {
+		return nil
+	}
+	if n.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &iofs.PathError{Op: "rename", Path: oldname, Err: iofs.ErrInvalid}
+	}
+	if target, ok := m.nodes[newname]; ok {
+		switch {
+		case target.mode.IsDir() && !n.mode.IsDir():
+			return &iofs.PathError{Op: "rename", Path: oldname, Err: syscall.EISDIR}
+		case !target.mode.IsDir() && n.mode.IsDir():
+			return &iofs.PathError{Op: "rename", Path: oldname, Err: syscall.ENOTDIR}
+		case target.mode.IsDir() && len(m.children(newname)) > 0:
+			return &iofs.PathError{Op: "rename", Path: oldname, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if n.mode.IsDir() {
+		for _, k := range m.children(oldname) {
+			m.nodes[newname+strings.TrimPrefix(k, oldname)] = m.nodes[k]
+			delete(m.nodes, k)
+		}
+	}
+	delete(m.nodes, oldname)
+	n.name = path.Base(newname)
+	m.nodes[newname] = n
+	return nil
+}
+
+// RemoveAll removes the named file or directory with its contents.
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.lookup("remove", name); err != nil {
+		if pe, ok := err.(*iofs.PathError); ok && pe.Err == iofs.ErrNotExist {
+			return nil
+		}
+		return err
+	}
+	if name == "." {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrPermission}
+	}
+	for _, k := range m.children(name) {
+		delete(m.nodes, k)
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+type memFile struct {
+	info    *memFileInfo
+	data    []byte
+	offset  int
+	entries []iofs.DirEntry
+}
+
+func (f *memFile) Stat() (iofs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &iofs.PathError{Op: "read", Path: f.info.name, Err: syscall.EISDIR}
+	}
+	if f.offset >= len(f.data) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *memFile) ReadDir(count int) ([]iofs.DirEntry, error) {
+	if !f.info.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: f.info.name, Err: syscall.ENOTDIR}
+	}
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.entries) {
+		count = len(f.entries)
+	}
+	entries := f.entries[:count]
+	f.entries = f.entries[count:]
+	return entries, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"github.com/dop251/goja"
+)
+
+const (
+	ModuleName         = "node:fs"
+	PromisesModuleName = "node:fs/promises"
+)
+
+// Loop is the part of *eventloop.EventLoop used to complete the asynchronous operations.
+type Loop interface {
+	RegisterCallback() func(fn func(*goja.Runtime))
+	// ReportError reports the exceptions thrown by the callbacks.
+	ReportError(err error)
+}
+
+type Option func(*FSModule)
+
+// WithLoop sets the event loop used by the asynchronous functions. They run the operations in separate
+// goroutines and call the callbacks or settle the promises on the loop, which keeps running until the
+// operations are complete. The module can only be used by the runtime of the loop.
+// Without a loop the operations are performed synchronously and the results are delivered as promise jobs,
+// the exceptions thrown by the callbacks then reject the promises of the jobs.
+func WithLoop(loop Loop) Option {
+	return func(m *FSModule) {
+		m.loop = loop
+	}
+}
+
+// FSModule implements the node:fs module on top of an FS. The paths used by JS code are resolved relative
+// to the root of the FS, which is also the current directory: "/a/b", "a/b" and "../a/b" all refer to the
+// file "a/b" of the FS, so the scripts can't access the files outside of it.
+type FSModule struct {
+	fsys   FS
+	loop   Loop
+	apiSym *goja.Symbol
+}
+
+// New creates a module which gives access to the files of fsys, see DirFS and NewMemFS.
+func New(fsys FS, opts ...Option) *FSModule {
+	m := &FSModule{
+		fsys:   fsys,
+		apiSym: goja.NewSymbol("fs"),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *FSModule) getAPI(runtime *goja.Runtime) *api {
+	global := runtime.GlobalObject()
+	if v := global.GetSymbol(m.apiSym); v != nil {
+		return v.Export().(*api)
+	}
+	a := &api{runtime: runtime, fsys: m.fsys, loop: m.loop}
+	a.init()
+	global.DefineDataPropertySymbol(m.apiSym, runtime.ToValue(a), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return a
+}
+
+func (m *FSModule) Enable(runtime *goja.Runtime) {
+}
+
+func (m *FSModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	module.Set("exports", m.getAPI(runtime).fs)
+}
+
+// Promises returns the node:fs/promises module which shares the FS with m.
+func (m *FSModule) Promises() *PromisesModule {
+	return &PromisesModule{m: m}
+}
+
+// PromisesModule implements the node:fs/promises module, its exports are the same object as fs.promises.
+type PromisesModule struct {
+	m *FSModule
+}
+
+func (p *PromisesModule) Enable(runtime *goja.Runtime) {
+}
+
+func (p *PromisesModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	module.Set("exports", p.m.getAPI(runtime).promises)
+}
@@ -0,0 +1,309 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/buffer"
+	"github.com/khanghh/goja-nodejs/eventloop"
+	"github.com/khanghh/goja-nodejs/require"
+)
+
+const assertScript = `
+function assertEq(actual, expected, msg) {
+	if (actual !== expected) throw new Error(msg + ": expected " + JSON.stringify(expected) + ", got " + JSON.stringify(actual));
+}
+function assertThrows(fn, code) {
+	try {
+		fn();
+	} catch (e) {
+		assertEq(e.code, code, "error code of " + e.message);
+		return e;
+	}
+	throw new Error("expected " + code);
+}
+`
+
+func newRuntime(fsys FS, opts ...Option) *goja.Runtime {
+	vm := goja.New()
+	m := New(fsys, opts...)
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(buffer.ModuleName, buffer.Default())
+	registry.RegisterNativeModule(ModuleName, m)
+	registry.RegisterNativeModule(PromisesModuleName, m.Promises())
+	registry.Enable(vm)
+	buffer.Default().Enable(vm)
+	return vm
+}
+
+func runScript(t *testing.T, vm *goja.Runtime, script string) goja.Value {
+	res, err := vm.RunString(script)
+	if err != nil {
+		if ex, ok := err.(*goja.Exception); ok {
+			t.Fatal(ex.String())
+		}
+		t.Fatal(err)
+	}
+	return res
+}
+
+const syncScript = assertScript + `
+const fs = require("node:fs");
+assertEq(fs.promises, require("node:fs/promises"), "fs.promises");
+
+fs.mkdirSync("/dir");
+assertEq(fs.mkdirSync("/dir/a/b", { recursive: true }), "/dir/a", "mkdir recursive");
+assertEq(fs.mkdirSync("/dir/a/b", { recursive: true }), undefined, "mkdir existing");
+assertThrows(() => fs.mkdirSync("/dir"), "EEXIST");
+assertThrows(() => fs.mkdirSync("/missing/x"), "ENOENT");
+
+fs.writeFileSync("/dir/hello.txt", "héllo");
+assertEq(fs.readFileSync("/dir/hello.txt", "utf8"), "héllo", "readFile utf8");
+assertEq(fs.readFileSync("dir/hello.txt", { encoding: "hex" }), "68c3a96c6c6f", "readFile hex");
+const buf = fs.readFileSync("/dir/hello.txt");
+assertEq(Buffer.isBuffer(buf) && buf.length, 6, "readFile Buffer");
+fs.writeFileSync("/dir/hello.txt", Buffer.from("!"), { flag: "a" });
+assertEq(fs.readFileSync("/dir/hello.txt", "latin1"), "hÃ©llo!", "append");
+assertThrows(() => fs.writeFileSync("/dir/hello.txt", "x", { flag: "wx" }), "EEXIST");
+fs.writeFileSync("/dir/b64", "aGk=", "base64");
+assertEq(fs.readFileSync("/dir/b64", "utf8"), "hi", "writeFile encoding");
+
+const e = assertThrows(() => fs.readFileSync("/nope.txt"), "ENOENT");
+assertEq(e.message, "ENOENT: no such file or directory, open '/nope.txt'", "error message");
+assertEq(e.path, "/nope.txt", "error path");
+assertEq(e.syscall, "open", "error syscall");
+assertThrows(() => fs.readFileSync("/dir/hello.txt/x"), "ENOTDIR");
+
+const st = fs.statSync("/dir/hello.txt");
+assertEq(st.isFile() && !st.isDirectory() && st.size, 7, "stat file");
+assertEq(st.mtime instanceof Date && st.mtimeMs > 0, true, "mtime");
+assertEq(fs.statSync("/dir").isDirectory(), true, "stat dir");
+assertEq(fs.statSync("/nope", { throwIfNoEntry: false }), undefined, "throwIfNoEntry");
+assertEq(fs.existsSync("/dir/b64") && !fs.existsSync("/dir/nope"), true, "existsSync");
+
+assertEq(fs.readdirSync("/dir").join(), "a,b64,hello.txt", "readdir");
+const entries = fs.readdirSync("/dir", { withFileTypes: true });
+assertEq(entries.map(d => d.name + ":" + d.isDirectory()).join(), "a:true,b64:false,hello.txt:false", "readdir withFileTypes");
+assertEq(entries[0].parentPath, "/dir", "parentPath");
+
+fs.renameSync("/dir/b64", "/dir/a/moved");
+assertEq(fs.readFileSync("/dir/a/moved", "utf8"), "hi", "rename");
+assertThrows(() => fs.renameSync("/dir/b64", "/x"), "ENOENT");
+
+assertThrows(() => fs.rmSync("/dir"), "ERR_FS_EISDIR");
+fs.rmSync("/dir/hello.txt");
+assertThrows(() => fs.rmSync("/dir/hello.txt"), "ENOENT");
+fs.rmSync("/dir/hello.txt", { force: true });
+fs.rmSync("/dir", { recursive: true });
+assertEq(fs.readdirSync("/").length, 0, "rm recursive");
+
+// Paths can't escape the root
+fs.mkdirSync("../../etc");
+fs.writeFileSync("../../etc/passwd", "sandboxed");
+assertEq(fs.readdirSync("/").join(), "etc", "sandbox");
+assertEq(fs.readFileSync("/etc/passwd", "utf8"), "sandboxed", "sandboxed file");
+assertThrows(() => fs.readFileSync(42), "ERR_INVALID_ARG_TYPE");
+assertThrows(() => fs.readFileSync("/a\0b"), "ERR_INVALID_ARG_VALUE");
+assertThrows(() => fs.readFileSync("/etc/passwd", "nope"), "ERR_INVALID_ARG_VALUE");
+`
+
+func TestSyncMemFS(t *testing.T) {
+	vm := newRuntime(NewMemFS())
+	runScript(t, vm, syncScript)
+}
+
+func TestSyncDirFS(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0777); err != nil {
+		t.Fatal(err)
+	}
+	vm := newRuntime(DirFS(root))
+	runScript(t, vm, syncScript)
+	if _, err := os.Stat(filepath.Join(root, "etc", "passwd")); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("Files were written outside of the root: %v", entries)
+	}
+}
+
+func TestDirFSWindowsNames(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0777); err != nil {
+		t.Fatal(err)
+	}
+	fsys := DirFS(root)
+	for _, name := range []string{`..\escaped`, `c:escaped`} {
+		err := fsys.WriteFile(name, []byte("x"), 0666)
+		if runtime.GOOS == "windows" {
+			if !errors.Is(err, iofs.ErrInvalid) {
+				t.Fatalf("Unexpected error writing %s: %v", name, err)
+			}
+			continue
+		}
+		// Elsewhere they are plain file names inside the root
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("Files were written outside of the root: %v", entries)
+	}
+}
+
+const asyncScript = `
+const fs = require("node:fs");
+const fsp = require("node:fs/promises");
+var log = [];
+fs.writeFile("/a.txt", "data", err => {
+	log.push("write:" + err);
+	fs.readFile("/a.txt", "utf8", (err, data) => {
+		log.push("read:" + err + ":" + data);
+		fs.stat("/nope", err => log.push("stat:" + err.code));
+	});
+});
+(async () => {
+	await fsp.mkdir("/p/q", { recursive: true });
+	await fsp.writeFile("/p/q/b.txt", Buffer.from([1, 2, 3]));
+	const data = await fsp.readFile("/p/q/b.txt");
+	log.push("promise:" + data.join("+"));
+	log.push("readdir:" + (await fsp.readdir("/p/q")).join());
+	await fsp.rename("/p/q/b.txt", "/p/c.txt");
+	log.push("stat:" + (await fsp.stat("/p/c.txt")).size);
+	await fsp.rm("/p", { recursive: true });
+	try {
+		await fsp.readFile("/p/c.txt");
+	} catch (e) {
+		log.push("rejected:" + e.code);
+	}
+	try {
+		await fsp.readFile(null);
+	} catch (e) {
+		log.push("invalid:" + e.code);
+	}
+})();
+`
+
+func checkAsyncLog(t *testing.T, vm *goja.Runtime) {
+	res := vm.Get("log").Export().([]interface{})
+	var write, read, stat, promise bool
+	for _, v := range res {
+		switch v {
+		case "write:null":
+			write = true
+		case "read:null:data":
+			read = true
+		case "stat:ENOENT":
+			stat = true
+		case "invalid:ERR_INVALID_ARG_TYPE":
+			promise = true
+		}
+	}
+	if !write || !read || !stat || !promise {
+		t.Fatalf("Unexpected log: %v", res)
+	}
+	for i, s := range []string{"promise:1+2+3", "readdir:b.txt", "stat:3", "rejected:ENOENT", "invalid:ERR_INVALID_ARG_TYPE"} {
+		found := false
+		for _, v := range res {
+			if v == s {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%d: %q not found in %v", i, s, res)
+		}
+	}
+}
+
+func TestAsyncOnLoop(t *testing.T) {
+	registry := require.NewRegistry()
+	loop := eventloop.NewEventLoop(eventloop.WithRegistry(registry))
+	m := New(NewMemFS(), WithLoop(loop))
+	registry.RegisterNativeModule(buffer.ModuleName, buffer.Default())
+	registry.RegisterNativeModule(ModuleName, m)
+	registry.RegisterNativeModule(PromisesModuleName, m.Promises())
+	var vm *goja.Runtime
+	loop.Run(func(runtime *goja.Runtime) {
+		vm = runtime
+		buffer.Default().Enable(vm)
+		if _, err := vm.RunString(asyncScript); err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkAsyncLog(t, vm)
+}
+
+func TestAsyncCallbackError(t *testing.T) {
+	registry := require.NewRegistry()
+	var errs []error
+	loop := eventloop.NewEventLoop(eventloop.WithRegistry(registry), eventloop.WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	registry.RegisterNativeModule(ModuleName, New(NewMemFS(), WithLoop(loop)))
+	loop.Run(func(vm *goja.Runtime) {
+		_, err := vm.RunString(`
+		var after = false;
+		require("node:fs").readFile("/missing", () => { throw new Error("from callback") });
+		setTimeout(() => { after = true }, 10);
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "from callback") {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	loop.Run(func(vm *goja.Runtime) {
+		if !vm.Get("after").ToBoolean() {
+			t.Fatal("The loop stopped after the error")
+		}
+	})
+}
+
+func TestAsyncWithoutLoop(t *testing.T) {
+	vm := newRuntime(NewMemFS())
+	runScript(t, vm, asyncScript)
+	checkAsyncLog(t, vm)
+}
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	if _, err := mkdirAll(m, "a/b/c", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a/x.txt", "a/b/y.txt", "z.txt"} {
+		if err := m.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fstest.TestFS(m, "a/x.txt", "a/b/y.txt", "a/b/c", "z.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Rename("a/b", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(m, "a/x.txt", "b/y.txt", "b/c", "z.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("b"); errorCodeOf(err) != codeENOTEMPTY {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := removeAll(m, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("b/c"); errorCodeOf(err) != codeENOENT {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
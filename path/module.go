@@ -0,0 +1,226 @@
+package path
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+const ModuleName = "node:path"
+
+var defaultModule = PathModule{}
+
+// PathModule implements the node:path module. The module exports path.posix on all platforms, path.win32 is
+// available as a property. process.cwd() is used by resolve() and relative() if it's defined, otherwise they
+// resolve against "/". The working directory of the Go process is never exposed to scripts.
+type PathModule struct {
+}
+
+type api struct {
+	runtime *goja.Runtime
+}
+
+func (a *api) typeError(format string, args ...interface{}) *goja.Object {
+	err := a.runtime.NewTypeError(fmt.Sprintf(format, args...))
+	err.Set("code", "ERR_INVALID_ARG_TYPE")
+	return err
+}
+
+// received describes the invalid argument in the way of Node's ERR_INVALID_ARG_TYPE messages.
+func received(v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return "undefined"
+	case goja.IsNull(v):
+		return "null"
+	}
+	if obj, ok := v.(*goja.Object); ok {
+		if _, ok := goja.AssertFunction(obj); ok {
+			return "function " + obj.Get("name").String()
+		}
+		if ctor, ok := obj.Get("constructor").(*goja.Object); ok {
+			if name := ctor.Get("name"); name != nil && name.String() != "" {
+				return "an instance of " + name.String()
+			}
+		}
+		return v.String()
+	}
+	s := v.String()
+	typ := "number"
+	switch v.Export().(type) {
+	case string:
+		typ = "string"
+		s = "'" + s + "'"
+	case bool:
+		typ = "boolean"
+	case *goja.Symbol:
+		typ = "symbol"
+	}
+	if len(s) > 28 {
+		s = s[:25] + "..."
+	}
+	return "type " + typ + " (" + s + ")"
+}
+
+func (a *api) str(v goja.Value, name string) string {
+	if v != nil {
+		if s, ok := v.Export().(string); ok {
+			if _, ok := v.(*goja.Object); !ok {
+				return s
+			}
+		}
+	}
+	panic(a.typeError(`The "%s" argument must be of type string. Received %s`, name, received(v)))
+}
+
+func (a *api) cwd() string {
+	if process, ok := a.runtime.Get("process").(*goja.Object); ok {
+		if cwd, ok := goja.AssertFunction(process.Get("cwd")); ok {
+			v, err := cwd(process)
+			if err != nil {
+				panic(err)
+			}
+			return v.String()
+		}
+	}
+	return "/"
+}
+
+func (a *api) env(key string) (string, bool) {
+	if process, ok := a.runtime.Get("process").(*goja.Object); ok {
+		if env, ok := process.Get("env").(*goja.Object); ok {
+			if v := env.Get(key); v != nil && !goja.IsUndefined(v) {
+				return v.String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// format implements path.format() which is the opposite of path.parse(): the name and ext properties are
+// only used when there is no base, the root is only used when there is no dir.
+func (a *api) format(f flavor, pathObject goja.Value) string {
+	obj, ok := pathObject.(*goja.Object)
+	if _, isFunc := goja.AssertFunction(pathObject); !ok || isFunc || obj.ClassName() == "Array" {
+		panic(a.typeError(`The "pathObject" argument must be of type object. Received %s`, received(pathObject)))
+	}
+	prop := func(name string) string {
+		if v := obj.Get(name); v != nil && v.ToBoolean() {
+			return v.String()
+		}
+		return ""
+	}
+	root := prop("root")
+	dir := prop("dir")
+	if dir == "" {
+		dir = root
+	}
+	base := prop("base")
+	if base == "" {
+		base = prop("name")
+		if ext := prop("ext"); ext != "" {
+			if ext[0] != '.' {
+				base += "."
+			}
+			base += ext
+		}
+	}
+	if dir == "" {
+		return base
+	}
+	if dir == root {
+		return dir + base
+	}
+	return dir + f.sep() + base
+}
+
+func (a *api) newPath(f flavor) *goja.Object {
+	r := a.runtime
+	o := r.NewObject()
+	o.Set("sep", f.sep())
+	o.Set("delimiter", f.delimiter())
+	o.Set("resolve", func(call goja.FunctionCall) goja.Value {
+		paths := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			paths[i] = a.str(arg, "paths["+strconv.Itoa(i)+"]")
+		}
+		return r.ToValue(f.resolve(paths))
+	})
+	o.Set("normalize", func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(f.normalize(a.str(call.Argument(0), "path")))
+	})
+	o.Set("isAbsolute", func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(f.isAbsolute(a.str(call.Argument(0), "path")))
+	})
+	o.Set("join", func(call goja.FunctionCall) goja.Value {
+		paths := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			paths[i] = a.str(arg, "path")
+		}
+		return r.ToValue(f.join(paths))
+	})
+	o.Set("relative", func(call goja.FunctionCall) goja.Value {
+		from := a.str(call.Argument(0), "from")
+		to := a.str(call.Argument(1), "to")
+		return r.ToValue(f.relative(from, to))
+	})
+	toNamespacedPath := func(call goja.FunctionCall) goja.Value {
+		// Non-string values are returned as is
+		p := call.Argument(0)
+		if s, ok := p.Export().(string); ok {
+			if _, ok := p.(*goja.Object); !ok {
+				return r.ToValue(f.toNamespacedPath(s))
+			}
+		}
+		return p
+	}
+	o.Set("toNamespacedPath", toNamespacedPath)
+	o.Set("_makeLong", toNamespacedPath)
+	o.Set("dirname", func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(f.dirname(a.str(call.Argument(0), "path")))
+	})
+	o.Set("basename", func(call goja.FunctionCall) goja.Value {
+		p := a.str(call.Argument(0), "path")
+		var suffix string
+		if v := call.Argument(1); !goja.IsUndefined(v) {
+			suffix = a.str(v, "suffix")
+		}
+		return r.ToValue(f.basename(p, suffix))
+	})
+	o.Set("extname", func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(f.extname(a.str(call.Argument(0), "path")))
+	})
+	o.Set("format", func(call goja.FunctionCall) goja.Value {
+		return r.ToValue(a.format(f, call.Argument(0)))
+	})
+	o.Set("parse", func(call goja.FunctionCall) goja.Value {
+		p := f.parse(a.str(call.Argument(0), "path"))
+		ret := r.NewObject()
+		ret.Set("root", p.root)
+		ret.Set("dir", p.dir)
+		ret.Set("base", p.base)
+		ret.Set("ext", p.ext)
+		ret.Set("name", p.name)
+		return ret
+	})
+	return o
+}
+
+func (m *PathModule) Enable(runtime *goja.Runtime) {
+}
+
+func (m *PathModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	a := &api{runtime: runtime}
+	posixPath := a.newPath(posix{cwd: a.cwd})
+	win32Path := a.newPath(win32{cwd: a.cwd, env: a.env})
+	for _, o := range []*goja.Object{posixPath, win32Path} {
+		o.Set("posix", posixPath)
+		o.Set("win32", win32Path)
+	}
+	module.Set("exports", posixPath)
+}
+
+func Default() *PathModule {
+	return &defaultModule
+}
@@ -0,0 +1,99 @@
+package path
+
+import (
+	_ "embed"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/require"
+)
+
+//go:embed testdata/path_test.js
+var pathTest string
+
+func newRuntime() *goja.Runtime {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+	return vm
+}
+
+func TestJs(t *testing.T) {
+	vm := newRuntime()
+	if _, err := vm.RunString(`var process = { cwd() { return "/home/user/project"; }, env: {} };`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Script will throw an error on failed validation
+	_, err := vm.RunScript("testdata/path_test.js", pathTest)
+	if err != nil {
+		if ex, ok := err.(*goja.Exception); ok {
+			t.Fatal(ex.String())
+		}
+		t.Fatal("Failed to process path script.", err)
+	}
+}
+
+func TestCwd(t *testing.T) {
+	vm := newRuntime()
+	// Without process.cwd() paths are resolved against the root, not the working directory of the Go process
+	v, err := vm.RunString(`[require("node:path").resolve("a"), require("node:path").win32.resolve("a")].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.String(); s != "/a,\\a" {
+		t.Fatalf("Unexpected path: %s", s)
+	}
+
+	// Drive-specific current directories are taken from the environment
+	_, err = vm.RunString(`var process = { cwd() { return "/home/user"; }, env: { "=D:": "D:\\work" } };`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = vm.RunString(`
+	const path = require("node:path");
+	[path.win32.resolve("D:a"), path.win32.resolve("C:a"), path.win32.resolve("a"), path.posix.resolve("a")].join(",")
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.String(); s != `D:\work\a,C:\home\user\a,\home\user\a,/home/user/a` {
+		t.Fatalf("Unexpected paths: %s", s)
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	vm := newRuntime()
+	v, err := vm.RunString(`
+	const path = require("node:path");
+	const messages = [];
+	for (const arg of [undefined, null, 1, "string", true, {}, [], function f() {}]) {
+		try {
+			path.format(arg);
+		} catch (e) {
+			messages.push(e.message);
+		}
+	}
+	try {
+		path.resolve("a", 1);
+	} catch (e) {
+		messages.push(e.message);
+	}
+	messages.join("\n");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `The "pathObject" argument must be of type object. Received undefined
+The "pathObject" argument must be of type object. Received null
+The "pathObject" argument must be of type object. Received type number (1)
+The "pathObject" argument must be of type object. Received type string ('string')
+The "pathObject" argument must be of type object. Received type boolean (true)
+The "pathObject" argument must be of type object. Received an instance of Array
+The "pathObject" argument must be of type object. Received function f
+The "paths[1]" argument must be of type string. Received type number (1)`
+	if s := v.String(); s != expected {
+		t.Fatalf("Unexpected messages:\n%s", s)
+	}
+}
@@ -0,0 +1,453 @@
+package path
+
+import "strings"
+
+// parsedPath is the result of path.parse().
+type parsedPath struct {
+	root, dir, base, ext, name string
+}
+
+// flavor is implemented by the posix and win32 variants of the path functions. All functions follow the
+// algorithms of Node's lib/path.js, so the results match Node's even for the unusual inputs.
+type flavor interface {
+	sep() string
+	delimiter() string
+	resolve(paths []string) string
+	normalize(path string) string
+	isAbsolute(path string) bool
+	join(paths []string) string
+	relative(from, to string) string
+	toNamespacedPath(path string) string
+	dirname(path string) string
+	basename(path, suffix string) string
+	extname(path string) string
+	parse(path string) parsedPath
+}
+
+func isPosixPathSeparator(c byte) bool {
+	return c == '/'
+}
+
+// normalizeString resolves the "." and ".." elements of path ignoring the empty ones. The ".." elements
+// which would go above the root are kept if allowAboveRoot is true and dropped otherwise.
+func normalizeString(path string, allowAboveRoot bool, separator byte, isPathSeparator func(byte) bool) string {
+	res := ""
+	lastSegmentLength := 0
+	lastSlash := -1
+	dots := 0
+	var code byte
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) {
+			code = path[i]
+		} else if isPathSeparator(code) {
+			break
+		} else {
+			code = '/'
+		}
+
+		if isPathSeparator(code) {
+			if lastSlash == i-1 || dots == 1 {
+				// NOOP
+			} else if dots == 2 {
+				if len(res) < 2 || lastSegmentLength != 2 || res[len(res)-1] != '.' || res[len(res)-2] != '.' {
+					if len(res) > 2 {
+						lastSlashIndex := strings.LastIndexByte(res, separator)
+						if lastSlashIndex == -1 {
+							res = ""
+							lastSegmentLength = 0
+						} else {
+							res = res[:lastSlashIndex]
+							lastSegmentLength = len(res) - 1 - strings.LastIndexByte(res, separator)
+						}
+						lastSlash = i
+						dots = 0
+						continue
+					} else if len(res) != 0 {
+						res = ""
+						lastSegmentLength = 0
+						lastSlash = i
+						dots = 0
+						continue
+					}
+				}
+				if allowAboveRoot {
+					if len(res) > 0 {
+						res += string(separator) + ".."
+					} else {
+						res = ".."
+					}
+					lastSegmentLength = 2
+				}
+			} else {
+				if len(res) > 0 {
+					res += string(separator) + path[lastSlash+1:i]
+				} else {
+					res = path[lastSlash+1 : i]
+				}
+				lastSegmentLength = i - lastSlash - 1
+			}
+			lastSlash = i
+			dots = 0
+		} else if code == '.' && dots != -1 {
+			dots++
+		} else {
+			dots = -1
+		}
+	}
+	return res
+}
+
+// extension finds the extension of path[start:] the same way for extname() and parse(). It returns the index
+// of the dot (-1 if there is no extension), the start and the end of the last element (end is -1 if there
+// are only separators).
+func extension(path string, start int, isPathSeparator func(byte) bool) (startDot, startPart, end int) {
+	startDot, startPart, end = -1, start, -1
+	matchedSlash := true
+	// Track the state of characters (if any) we see before our first dot and after any path separator we find
+	preDotState := 0
+	for i := len(path) - 1; i >= start; i-- {
+		code := path[i]
+		if isPathSeparator(code) {
+			// If we reached a path separator that was not part of a set of path separators at the end of
+			// the string, stop now
+			if !matchedSlash {
+				startPart = i + 1
+				break
+			}
+			continue
+		}
+		if end == -1 {
+			// We saw the first non-path separator, mark this as the end of our extension
+			matchedSlash = false
+			end = i + 1
+		}
+		if code == '.' {
+			// If this is our first dot, mark it as the start of our extension
+			if startDot == -1 {
+				startDot = i
+			} else if preDotState != 1 {
+				preDotState = 1
+			}
+		} else if startDot != -1 {
+			// We saw a non-dot and non-path separator before our dot, so we should have an extension
+			preDotState = -1
+		}
+	}
+	if startDot == -1 || end == -1 ||
+		// We saw a non-dot character immediately before the dot
+		preDotState == 0 ||
+		// The (right-most) trimmed path component is exactly '..'
+		(preDotState == 1 && startDot == end-1 && startDot == startPart+1) {
+		startDot = -1
+	}
+	return
+}
+
+// basename returns path[start:] without the trailing separators and the suffix.
+func basename(path, suffix string, start int, isPathSeparator func(byte) bool) string {
+	end := -1
+	matchedSlash := true
+	if len(suffix) > 0 && len(suffix) <= len(path) {
+		if suffix == path {
+			return ""
+		}
+		extIdx := len(suffix) - 1
+		firstNonSlashEnd := -1
+		for i := len(path) - 1; i >= start; i-- {
+			code := path[i]
+			if isPathSeparator(code) {
+				if !matchedSlash {
+					start = i + 1
+					break
+				}
+			} else {
+				if firstNonSlashEnd == -1 {
+					// We saw the first non-path separator, remember this index in case we need it if the
+					// extension ends up not matching
+					matchedSlash = false
+					firstNonSlashEnd = i + 1
+				}
+				if extIdx >= 0 {
+					// Try to match the explicit extension
+					if code == suffix[extIdx] {
+						if extIdx--; extIdx == -1 {
+							// We matched the extension, so mark this as the end of our path component
+							end = i
+						}
+					} else {
+						// Extension does not match, so our result is the entire path component
+						extIdx = -1
+						end = firstNonSlashEnd
+					}
+				}
+			}
+		}
+		if start == end {
+			end = firstNonSlashEnd
+		} else if end == -1 {
+			end = len(path)
+		}
+		if end < start {
+			return ""
+		}
+		return path[start:end]
+	}
+
+	for i := len(path) - 1; i >= start; i-- {
+		if isPathSeparator(path[i]) {
+			// If we reached a path separator that was not part of a set of path separators at the end of
+			// the string, stop now
+			if !matchedSlash {
+				start = i + 1
+				break
+			}
+		} else if end == -1 {
+			// We saw the first non-path separator, mark this as the end of our path component
+			matchedSlash = false
+			end = i + 1
+		}
+	}
+	if end == -1 {
+		return ""
+	}
+	return path[start:end]
+}
+
+type posix struct {
+	cwd func() string
+}
+
+func (posix) sep() string {
+	return "/"
+}
+
+func (posix) delimiter() string {
+	return ":"
+}
+
+func (p posix) resolve(paths []string) string {
+	resolvedPath := ""
+	resolvedAbsolute := false
+	for i := len(paths) - 1; i >= -1 && !resolvedAbsolute; i-- {
+		var path string
+		if i >= 0 {
+			path = paths[i]
+		} else {
+			path = p.cwd()
+		}
+		// Skip empty entries
+		if len(path) == 0 {
+			continue
+		}
+		resolvedPath = path + "/" + resolvedPath
+		resolvedAbsolute = path[0] == '/'
+	}
+
+	// At this point the path should be resolved to a full absolute path, but handle relative paths to be
+	// safe (might happen when process.cwd() fails)
+	resolvedPath = normalizeString(resolvedPath, !resolvedAbsolute, '/', isPosixPathSeparator)
+	if resolvedAbsolute {
+		return "/" + resolvedPath
+	}
+	if len(resolvedPath) > 0 {
+		return resolvedPath
+	}
+	return "."
+}
+
+func (posix) normalize(path string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	isAbsolute := path[0] == '/'
+	trailingSeparator := path[len(path)-1] == '/'
+
+	path = normalizeString(path, !isAbsolute, '/', isPosixPathSeparator)
+	if len(path) == 0 {
+		if isAbsolute {
+			return "/"
+		}
+		if trailingSeparator {
+			return "./"
+		}
+		return "."
+	}
+	if trailingSeparator {
+		path += "/"
+	}
+	if isAbsolute {
+		return "/" + path
+	}
+	return path
+}
+
+func (posix) isAbsolute(path string) bool {
+	return len(path) > 0 && path[0] == '/'
+}
+
+func (p posix) join(paths []string) string {
+	var joined []string
+	for _, path := range paths {
+		if len(path) > 0 {
+			joined = append(joined, path)
+		}
+	}
+	if len(joined) == 0 {
+		return "."
+	}
+	return p.normalize(strings.Join(joined, "/"))
+}
+
+func (p posix) relative(from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	// Trim leading forward slashes
+	from = p.resolve([]string{from})
+	to = p.resolve([]string{to})
+	if from == to {
+		return ""
+	}
+
+	fromStart := 1
+	fromEnd := len(from)
+	fromLen := fromEnd - fromStart
+	toStart := 1
+	toLen := len(to) - toStart
+
+	// Compare paths to find the longest common path from root
+	length := fromLen
+	if toLen < length {
+		length = toLen
+	}
+	lastCommonSep := -1
+	i := 0
+	for ; i < length; i++ {
+		fromCode := from[fromStart+i]
+		if fromCode != to[toStart+i] {
+			break
+		} else if fromCode == '/' {
+			lastCommonSep = i
+		}
+	}
+	if i == length {
+		if toLen > length {
+			if to[toStart+i] == '/' {
+				// We get here if `from` is the exact base path for `to`, e.g. from='/foo/bar'; to='/foo/bar/baz'
+				return to[toStart+i+1:]
+			}
+			if i == 0 {
+				// We get here if `from` is the root, e.g. from='/'; to='/foo'
+				return to[toStart+i:]
+			}
+		} else if fromLen > length {
+			if from[fromStart+i] == '/' {
+				// We get here if `to` is the exact base path for `from`, e.g. from='/foo/bar/baz'; to='/foo/bar'
+				lastCommonSep = i
+			} else if i == 0 {
+				// We get here if `to` is the root, e.g. from='/foo/bar'; to='/'
+				lastCommonSep = 0
+			}
+		}
+	}
+
+	// Generate the relative path based on the path difference between `to` and `from`
+	out := ""
+	for i = fromStart + lastCommonSep + 1; i <= fromEnd; i++ {
+		if i == fromEnd || from[i] == '/' {
+			if len(out) == 0 {
+				out += ".."
+			} else {
+				out += "/.."
+			}
+		}
+	}
+
+	// Lastly, append the rest of the destination (`to`) path that comes after the common path parts
+	return out + to[toStart+lastCommonSep:]
+}
+
+func (posix) toNamespacedPath(path string) string {
+	// Non-op on posix systems
+	return path
+}
+
+func (posix) dirname(path string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	hasRoot := path[0] == '/'
+	end := -1
+	matchedSlash := true
+	for i := len(path) - 1; i >= 1; i-- {
+		if path[i] == '/' {
+			if !matchedSlash {
+				end = i
+				break
+			}
+		} else {
+			// We saw the first non-path separator
+			matchedSlash = false
+		}
+	}
+
+	if end == -1 {
+		if hasRoot {
+			return "/"
+		}
+		return "."
+	}
+	if hasRoot && end == 1 {
+		return "//"
+	}
+	return path[:end]
+}
+
+func (posix) basename(path, suffix string) string {
+	return basename(path, suffix, 0, isPosixPathSeparator)
+}
+
+func (posix) extname(path string) string {
+	startDot, _, end := extension(path, 0, isPosixPathSeparator)
+	if startDot == -1 {
+		return ""
+	}
+	return path[startDot:end]
+}
+
+func (posix) parse(path string) (ret parsedPath) {
+	if len(path) == 0 {
+		return
+	}
+	isAbsolute := path[0] == '/'
+	start := 0
+	if isAbsolute {
+		ret.root = "/"
+		start = 1
+	}
+	startDot, startPart, end := extension(path, start, isPosixPathSeparator)
+	if startPart == start {
+		startPart = 0
+	}
+	if end != -1 {
+		start := startPart
+		if startPart == 0 && isAbsolute {
+			start = 1
+		}
+		if startDot == -1 {
+			ret.name = path[start:end]
+			ret.base = ret.name
+		} else {
+			ret.name = path[start:startDot]
+			ret.base = path[start:end]
+			ret.ext = path[startDot:end]
+		}
+	}
+	if startPart > 0 {
+		ret.dir = path[:startPart-1]
+	} else if isAbsolute {
+		ret.dir = "/"
+	}
+	return
+}
@@ -0,0 +1,907 @@
+'use strict';
+
+// The tests are ported from Node's test/parallel/test-path-*.js.
+
+const assert = require("../../assert.js");
+const path = require("node:path");
+
+const slashRE = /\//g;
+const backslashRE = /\\/g;
+
+function strictEqual(actual, expected, message) {
+  assert.sameValue(actual, expected, message);
+}
+
+function deepStrictEqual(actual, expected, message) {
+  strictEqual(JSON.stringify(actual), JSON.stringify(expected), message);
+}
+
+function throwsCode(f, code, message) {
+  try {
+    f();
+  } catch (e) {
+    strictEqual(e.name, "TypeError", "name of " + e.message);
+    strictEqual(e.code, code, "code of " + e.message);
+    if (message !== undefined) {
+      strictEqual(e.message, message);
+    }
+    return;
+  }
+  throw new Error("No exception was thrown");
+}
+
+function invalidArgTypeHelper(input) {
+  if (input == null) {
+    return ` Received ${input}`;
+  }
+  if (typeof input === 'function') {
+    return ` Received function ${input.name}`;
+  }
+  if (typeof input === 'object') {
+    return ` Received an instance of ${input.constructor.name}`;
+  }
+  let inspected = typeof input === 'string' ? `'${input}'` : String(input);
+  if (inspected.length > 28) {
+    inspected = `${inspected.slice(0, 25)}...`;
+  }
+  return ` Received type ${typeof input} (${inspected})`;
+}
+
+function checkFailures(failures) {
+  if (failures.length > 0) {
+    throw new Error(failures.join(''));
+  }
+}
+
+// test-path.js
+{
+  const typeErrorTests = [true, false, 7, null, {}, undefined, [], NaN];
+
+  function fail(fn) {
+    const args = Array.from(arguments).slice(1);
+    throwsCode(() => {
+      fn.apply(null, args);
+    }, 'ERR_INVALID_ARG_TYPE');
+  }
+
+  for (const test of typeErrorTests) {
+    for (const namespace of [path.posix, path.win32]) {
+      fail(namespace.join, test);
+      fail(namespace.resolve, test);
+      fail(namespace.normalize, test);
+      fail(namespace.isAbsolute, test);
+      fail(namespace.relative, test, 'foo');
+      fail(namespace.relative, 'foo', test);
+      fail(namespace.parse, test);
+      fail(namespace.dirname, test);
+      fail(namespace.basename, test);
+      fail(namespace.extname, test);
+
+      // Undefined is a valid value as the second argument to basename
+      if (test !== undefined) {
+        fail(namespace.basename, 'foo', test);
+      }
+    }
+  }
+
+  strictEqual(path.win32.sep, '\\');
+  strictEqual(path.posix.sep, '/');
+  strictEqual(path.win32.delimiter, ';');
+  strictEqual(path.posix.delimiter, ':');
+
+  strictEqual(path, path.posix);
+  strictEqual(path.posix.posix, path.posix);
+  strictEqual(path.posix.win32, path.win32);
+  strictEqual(path.win32.posix, path.posix);
+  strictEqual(path.win32.win32, path.win32);
+}
+
+// test-path-basename.js
+strictEqual(path.basename('/test/parallel/test-path-basename.js'), 'test-path-basename.js');
+strictEqual(path.basename('/test/parallel/test-path-basename.js', '.js'), 'test-path-basename');
+strictEqual(path.basename('.js', '.js'), '');
+strictEqual(path.basename('js', '.js'), 'js');
+strictEqual(path.basename('file.js', '.ts'), 'file.js');
+strictEqual(path.basename('file', '.js'), 'file');
+strictEqual(path.basename('file.js.old', '.js.old'), 'file');
+strictEqual(path.basename(''), '');
+strictEqual(path.basename('/dir/basename.ext'), 'basename.ext');
+strictEqual(path.basename('/basename.ext'), 'basename.ext');
+strictEqual(path.basename('basename.ext'), 'basename.ext');
+strictEqual(path.basename('basename.ext/'), 'basename.ext');
+strictEqual(path.basename('basename.ext//'), 'basename.ext');
+strictEqual(path.basename('aaa/bbb', '/bbb'), 'bbb');
+strictEqual(path.basename('aaa/bbb', 'a/bbb'), 'bbb');
+strictEqual(path.basename('aaa/bbb', 'bbb'), 'bbb');
+strictEqual(path.basename('aaa/bbb//', 'bbb'), 'bbb');
+strictEqual(path.basename('aaa/bbb', 'bb'), 'b');
+strictEqual(path.basename('aaa/bbb', 'b'), 'bb');
+strictEqual(path.basename('/aaa/bbb', '/bbb'), 'bbb');
+strictEqual(path.basename('/aaa/bbb', 'a/bbb'), 'bbb');
+strictEqual(path.basename('/aaa/bbb', 'bbb'), 'bbb');
+strictEqual(path.basename('/aaa/bbb//', 'bbb'), 'bbb');
+strictEqual(path.basename('/aaa/bbb', 'bb'), 'b');
+strictEqual(path.basename('/aaa/bbb', 'b'), 'bb');
+strictEqual(path.basename('/aaa/bbb'), 'bbb');
+strictEqual(path.basename('/aaa/'), 'aaa');
+strictEqual(path.basename('/aaa/b'), 'b');
+strictEqual(path.basename('/a/b'), 'b');
+strictEqual(path.basename('//a'), 'a');
+strictEqual(path.basename('a', 'a'), '');
+
+// On Windows a backslash acts as a path separator.
+strictEqual(path.win32.basename('\\dir\\basename.ext'), 'basename.ext');
+strictEqual(path.win32.basename('\\basename.ext'), 'basename.ext');
+strictEqual(path.win32.basename('basename.ext'), 'basename.ext');
+strictEqual(path.win32.basename('basename.ext\\'), 'basename.ext');
+strictEqual(path.win32.basename('basename.ext\\\\'), 'basename.ext');
+strictEqual(path.win32.basename('foo'), 'foo');
+strictEqual(path.win32.basename('aaa\\bbb', '\\bbb'), 'bbb');
+strictEqual(path.win32.basename('aaa\\bbb', 'a\\bbb'), 'bbb');
+strictEqual(path.win32.basename('aaa\\bbb', 'bbb'), 'bbb');
+strictEqual(path.win32.basename('aaa\\bbb\\\\\\\\', 'bbb'), 'bbb');
+strictEqual(path.win32.basename('aaa\\bbb', 'bb'), 'b');
+strictEqual(path.win32.basename('aaa\\bbb', 'b'), 'bb');
+strictEqual(path.win32.basename('C:'), '');
+strictEqual(path.win32.basename('C:.'), '.');
+strictEqual(path.win32.basename('C:\\'), '');
+strictEqual(path.win32.basename('C:\\dir\\base.ext'), 'base.ext');
+strictEqual(path.win32.basename('C:\\basename.ext'), 'basename.ext');
+strictEqual(path.win32.basename('C:basename.ext'), 'basename.ext');
+strictEqual(path.win32.basename('C:basename.ext\\'), 'basename.ext');
+strictEqual(path.win32.basename('C:basename.ext\\\\'), 'basename.ext');
+strictEqual(path.win32.basename('C:foo'), 'foo');
+strictEqual(path.win32.basename('file:stream'), 'file:stream');
+strictEqual(path.win32.basename('a', 'a'), '');
+
+// On unix a backslash is just treated as any other character.
+strictEqual(path.posix.basename('\\dir\\basename.ext'), '\\dir\\basename.ext');
+strictEqual(path.posix.basename('\\basename.ext'), '\\basename.ext');
+strictEqual(path.posix.basename('basename.ext'), 'basename.ext');
+strictEqual(path.posix.basename('basename.ext\\'), 'basename.ext\\');
+strictEqual(path.posix.basename('basename.ext\\\\'), 'basename.ext\\\\');
+strictEqual(path.posix.basename('foo'), 'foo');
+
+// POSIX filenames may include control characters
+{
+  const controlCharFilename = `Icon${String.fromCharCode(13)}`;
+  strictEqual(path.posix.basename(`/a/b/${controlCharFilename}`), controlCharFilename);
+}
+
+// test-path-dirname.js
+strictEqual(path.dirname('/test/parallel/test-path-dirname.js').slice(-13), 'test/parallel');
+
+strictEqual(path.posix.dirname('/a/b/'), '/a');
+strictEqual(path.posix.dirname('/a/b'), '/a');
+strictEqual(path.posix.dirname('/a'), '/');
+strictEqual(path.posix.dirname(''), '.');
+strictEqual(path.posix.dirname('/'), '/');
+strictEqual(path.posix.dirname('////'), '/');
+strictEqual(path.posix.dirname('//a'), '//');
+strictEqual(path.posix.dirname('foo'), '.');
+
+strictEqual(path.win32.dirname('c:\\'), 'c:\\');
+strictEqual(path.win32.dirname('c:\\foo'), 'c:\\');
+strictEqual(path.win32.dirname('c:\\foo\\'), 'c:\\');
+strictEqual(path.win32.dirname('c:\\foo\\bar'), 'c:\\foo');
+strictEqual(path.win32.dirname('c:\\foo\\bar\\'), 'c:\\foo');
+strictEqual(path.win32.dirname('c:\\foo\\bar\\baz'), 'c:\\foo\\bar');
+strictEqual(path.win32.dirname('c:\\foo bar\\baz'), 'c:\\foo bar');
+strictEqual(path.win32.dirname('\\'), '\\');
+strictEqual(path.win32.dirname('\\foo'), '\\');
+strictEqual(path.win32.dirname('\\foo\\'), '\\');
+strictEqual(path.win32.dirname('\\foo\\bar'), '\\foo');
+strictEqual(path.win32.dirname('\\foo\\bar\\'), '\\foo');
+strictEqual(path.win32.dirname('\\foo\\bar\\baz'), '\\foo\\bar');
+strictEqual(path.win32.dirname('\\foo bar\\baz'), '\\foo bar');
+strictEqual(path.win32.dirname('c:'), 'c:');
+strictEqual(path.win32.dirname('c:foo'), 'c:');
+strictEqual(path.win32.dirname('c:foo\\'), 'c:');
+strictEqual(path.win32.dirname('c:foo\\bar'), 'c:foo');
+strictEqual(path.win32.dirname('c:foo\\bar\\'), 'c:foo');
+strictEqual(path.win32.dirname('c:foo\\bar\\baz'), 'c:foo\\bar');
+strictEqual(path.win32.dirname('c:foo bar\\baz'), 'c:foo bar');
+strictEqual(path.win32.dirname('file:stream'), '.');
+strictEqual(path.win32.dirname('dir\\file:stream'), 'dir');
+strictEqual(path.win32.dirname('\\\\unc\\share'), '\\\\unc\\share');
+strictEqual(path.win32.dirname('\\\\unc\\share\\foo'), '\\\\unc\\share\\');
+strictEqual(path.win32.dirname('\\\\unc\\share\\foo\\'), '\\\\unc\\share\\');
+strictEqual(path.win32.dirname('\\\\unc\\share\\foo\\bar'), '\\\\unc\\share\\foo');
+strictEqual(path.win32.dirname('\\\\unc\\share\\foo\\bar\\'), '\\\\unc\\share\\foo');
+strictEqual(path.win32.dirname('\\\\unc\\share\\foo\\bar\\baz'), '\\\\unc\\share\\foo\\bar');
+strictEqual(path.win32.dirname('/a/b/'), '/a');
+strictEqual(path.win32.dirname('/a/b'), '/a');
+strictEqual(path.win32.dirname('/a'), '/');
+strictEqual(path.win32.dirname(''), '.');
+strictEqual(path.win32.dirname('/'), '/');
+strictEqual(path.win32.dirname('////'), '/');
+strictEqual(path.win32.dirname('foo'), '.');
+
+// test-path-extname.js
+{
+  const failures = [];
+  const testPaths = [
+    ['/test/parallel/test-path-extname.js', '.js'],
+    ['', ''],
+    ['/path/to/file', ''],
+    ['/path/to/file.ext', '.ext'],
+    ['/path.to/file.ext', '.ext'],
+    ['/path.to/file', ''],
+    ['/path.to/.file', ''],
+    ['/path.to/.file.ext', '.ext'],
+    ['/path/to/f.ext', '.ext'],
+    ['/path/to/..ext', '.ext'],
+    ['/path/to/..', ''],
+    ['file', ''],
+    ['file.ext', '.ext'],
+    ['.file', ''],
+    ['.file.ext', '.ext'],
+    ['/file', ''],
+    ['/file.ext', '.ext'],
+    ['/.file', ''],
+    ['/.file.ext', '.ext'],
+    ['.path/file.ext', '.ext'],
+    ['file.ext.ext', '.ext'],
+    ['file.', '.'],
+    ['.', ''],
+    ['./', ''],
+    ['.file.ext', '.ext'],
+    ['.file', ''],
+    ['.file.', '.'],
+    ['.file..', '.'],
+    ['..', ''],
+    ['../', ''],
+    ['..file.ext', '.ext'],
+    ['..file', '.file'],
+    ['..file.', '.'],
+    ['..file..', '.'],
+    ['...', '.'],
+    ['...ext', '.ext'],
+    ['....', '.'],
+    ['file.ext/', '.ext'],
+    ['file.ext//', '.ext'],
+    ['file/', ''],
+    ['file//', ''],
+    ['file./', '.'],
+    ['file.//', '.'],
+  ];
+
+  for (const testPath of testPaths) {
+    const expected = testPath[1];
+    const extNames = [path.posix.extname, path.win32.extname];
+    for (const extname of extNames) {
+      let input = testPath[0];
+      let os;
+      if (extname === path.win32.extname) {
+        input = input.replace(slashRE, '\\');
+        os = 'win32';
+      } else {
+        os = 'posix';
+      }
+      const actual = extname(input);
+      const message = `path.${os}.extname(${JSON.stringify(input)})\n  expect=${
+        JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+      if (actual !== expected)
+        failures.push(`\n${message}`);
+    }
+    {
+      const input = `C:${testPath[0].replace(slashRE, '\\')}`;
+      const actual = path.win32.extname(input);
+      const message = `path.win32.extname(${JSON.stringify(input)})\n  expect=${
+        JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+      if (actual !== expected)
+        failures.push(`\n${message}`);
+    }
+  }
+  checkFailures(failures);
+
+  // On Windows, backslash is a path separator.
+  strictEqual(path.win32.extname('.\\'), '');
+  strictEqual(path.win32.extname('..\\'), '');
+  strictEqual(path.win32.extname('file.ext\\'), '.ext');
+  strictEqual(path.win32.extname('file.ext\\\\'), '.ext');
+  strictEqual(path.win32.extname('file\\'), '');
+  strictEqual(path.win32.extname('file\\\\'), '');
+  strictEqual(path.win32.extname('file.\\'), '.');
+  strictEqual(path.win32.extname('file.\\\\'), '.');
+
+  // On *nix, backslash is a valid name component like any other character.
+  strictEqual(path.posix.extname('.\\'), '');
+  strictEqual(path.posix.extname('..\\'), '.\\');
+  strictEqual(path.posix.extname('file.ext\\'), '.ext\\');
+  strictEqual(path.posix.extname('file.ext\\\\'), '.ext\\\\');
+  strictEqual(path.posix.extname('file\\'), '');
+  strictEqual(path.posix.extname('file\\\\'), '');
+  strictEqual(path.posix.extname('file.\\'), '.\\');
+  strictEqual(path.posix.extname('file.\\\\'), '.\\\\');
+}
+
+// test-path-isabsolute.js
+strictEqual(path.win32.isAbsolute('/'), true);
+strictEqual(path.win32.isAbsolute('//'), true);
+strictEqual(path.win32.isAbsolute('//server'), true);
+strictEqual(path.win32.isAbsolute('//server/file'), true);
+strictEqual(path.win32.isAbsolute('\\\\server\\file'), true);
+strictEqual(path.win32.isAbsolute('\\\\server'), true);
+strictEqual(path.win32.isAbsolute('\\\\'), true);
+strictEqual(path.win32.isAbsolute('c'), false);
+strictEqual(path.win32.isAbsolute('c:'), false);
+strictEqual(path.win32.isAbsolute('c:\\'), true);
+strictEqual(path.win32.isAbsolute('c:/'), true);
+strictEqual(path.win32.isAbsolute('c://'), true);
+strictEqual(path.win32.isAbsolute('C:/Users/'), true);
+strictEqual(path.win32.isAbsolute('C:\\Users\\'), true);
+strictEqual(path.win32.isAbsolute('C:cwd/another'), false);
+strictEqual(path.win32.isAbsolute('C:cwd\\another'), false);
+strictEqual(path.win32.isAbsolute('directory/directory'), false);
+strictEqual(path.win32.isAbsolute('directory\\directory'), false);
+
+strictEqual(path.posix.isAbsolute('/home/foo'), true);
+strictEqual(path.posix.isAbsolute('/home/foo/..'), true);
+strictEqual(path.posix.isAbsolute('bar/'), false);
+strictEqual(path.posix.isAbsolute('./baz'), false);
+
+// test-path-join.js
+{
+  const failures = [];
+  const joinTests = [
+    [ [path.posix.join, path.win32.join],
+      // Arguments                     result
+      [[['.', 'x/b', '..', '/b/c.js'], 'x/b/c.js'],
+       [[], '.'],
+       [['/.', 'x/b', '..', '/b/c.js'], '/x/b/c.js'],
+       [['/foo', '../../../bar'], '/bar'],
+       [['foo', '../../../bar'], '../../bar'],
+       [['foo/', '../../../bar'], '../../bar'],
+       [['foo/x', '../../../bar'], '../bar'],
+       [['foo/x', './bar'], 'foo/x/bar'],
+       [['foo/x/', './bar'], 'foo/x/bar'],
+       [['foo/x/', '.', 'bar'], 'foo/x/bar'],
+       [['./'], './'],
+       [['.', './'], './'],
+       [['.', '.', '.'], '.'],
+       [['.', './', '.'], '.'],
+       [['.', '/./', '.'], '.'],
+       [['.', '/////./', '.'], '.'],
+       [['.'], '.'],
+       [['', '.'], '.'],
+       [['', 'foo'], 'foo'],
+       [['foo', '/bar'], 'foo/bar'],
+       [['', '/foo'], '/foo'],
+       [['', '', '/foo'], '/foo'],
+       [['', '', 'foo'], 'foo'],
+       [['foo', ''], 'foo'],
+       [['foo/', ''], 'foo/'],
+       [['foo', '', '/bar'], 'foo/bar'],
+       [['./', '..', '/foo'], '../foo'],
+       [['./', '..', '..', '/foo'], '../../foo'],
+       [['.', '..', '..', '/foo'], '../../foo'],
+       [['', '..', '..', '/foo'], '../../foo'],
+       [['/'], '/'],
+       [['/', '.'], '/'],
+       [['/', '..'], '/'],
+       [['/', '..', '..'], '/'],
+       [[''], '.'],
+       [['', ''], '.'],
+       [[' /foo'], ' /foo'],
+       [[' ', 'foo'], ' /foo'],
+       [[' ', '.'], ' '],
+       [[' ', '/'], ' /'],
+       [[' ', ''], ' '],
+       [['/', 'foo'], '/foo'],
+       [['/', '/foo'], '/foo'],
+       [['/', '//foo'], '/foo'],
+       [['/', '', '/foo'], '/foo'],
+       [['', '/', 'foo'], '/foo'],
+       [['', '/', '/foo'], '/foo'],
+      ],
+    ],
+  ];
+
+  // Windows-specific join tests
+  joinTests.push([
+    path.win32.join,
+    joinTests[0][1].slice(0).concat(
+      [// Arguments                     result
+        // UNC path expected
+        [['//foo/bar'], '\\\\foo\\bar\\'],
+        [['\\/foo/bar'], '\\\\foo\\bar\\'],
+        [['\\\\foo/bar'], '\\\\foo\\bar\\'],
+        // UNC path expected - server and share separate
+        [['//foo', 'bar'], '\\\\foo\\bar\\'],
+        [['//foo/', 'bar'], '\\\\foo\\bar\\'],
+        [['//foo', '/bar'], '\\\\foo\\bar\\'],
+        // UNC path expected - questionable
+        [['//foo', '', 'bar'], '\\\\foo\\bar\\'],
+        [['//foo/', '', 'bar'], '\\\\foo\\bar\\'],
+        [['//foo/', '', '/bar'], '\\\\foo\\bar\\'],
+        // UNC path expected - even more questionable
+        [['', '//foo', 'bar'], '\\\\foo\\bar\\'],
+        [['', '//foo/', 'bar'], '\\\\foo\\bar\\'],
+        [['', '//foo/', '/bar'], '\\\\foo\\bar\\'],
+        // No UNC path expected (no double slash in first component)
+        [['\\', 'foo/bar'], '\\foo\\bar'],
+        [['\\', '/foo/bar'], '\\foo\\bar'],
+        [['', '/', '/foo/bar'], '\\foo\\bar'],
+        // No UNC path expected (no non-slashes in first component -
+        // questionable)
+        [['//', 'foo/bar'], '\\foo\\bar'],
+        [['//', '/foo/bar'], '\\foo\\bar'],
+        [['\\\\', '/', '/foo/bar'], '\\foo\\bar'],
+        [['//'], '\\'],
+        // No UNC path expected (share name missing - questionable).
+        [['//foo'], '\\foo'],
+        [['//foo/'], '\\foo\\'],
+        [['//foo', '/'], '\\foo\\'],
+        [['//foo', '', '/'], '\\foo\\'],
+        // No UNC path expected (too many leading slashes - questionable)
+        [['///foo/bar'], '\\foo\\bar'],
+        [['////foo', 'bar'], '\\foo\\bar'],
+        [['\\\\\\/foo/bar'], '\\foo\\bar'],
+        // Drive-relative vs drive-absolute paths. This merely describes the
+        // status quo, rather than being obviously right
+        [['c:'], 'c:.'],
+        [['c:.'], 'c:.'],
+        [['c:', ''], 'c:.'],
+        [['', 'c:'], 'c:.'],
+        [['c:.', '/'], 'c:.\\'],
+        [['c:.', 'file'], 'c:file'],
+        [['c:', '/'], 'c:\\'],
+        [['c:', 'file'], 'c:\\file'],
+      ]
+    ),
+  ]);
+  joinTests.forEach((test) => {
+    if (!Array.isArray(test[0]))
+      test[0] = [test[0]];
+    test[0].forEach((join) => {
+      test[1].forEach((test) => {
+        const actual = join.apply(null, test[0]);
+        const expected = test[1];
+        // For non-Windows specific tests with the Windows join(), we need to try
+        // replacing the slashes since the non-Windows specific tests' `expected`
+        // use forward slashes
+        let actualAlt;
+        let os;
+        if (join === path.win32.join) {
+          actualAlt = actual.replace(backslashRE, '/');
+          os = 'win32';
+        } else {
+          os = 'posix';
+        }
+        if (actual !== expected && actualAlt !== expected) {
+          const delimiter = test[0].map(JSON.stringify).join(',');
+          const message = `path.${os}.join(${delimiter})\n  expect=${
+            JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+          failures.push(`\n${message}`);
+        }
+      });
+    });
+  });
+  checkFailures(failures);
+}
+
+// test-path-normalize.js
+strictEqual(path.win32.normalize('./fixtures///b/../b/c.js'), 'fixtures\\b\\c.js');
+strictEqual(path.win32.normalize('/foo/../../../bar'), '\\bar');
+strictEqual(path.win32.normalize('a//b//../b'), 'a\\b');
+strictEqual(path.win32.normalize('a//b//./c'), 'a\\b\\c');
+strictEqual(path.win32.normalize('a//b//.'), 'a\\b');
+strictEqual(path.win32.normalize('//server/share/dir/file.ext'), '\\\\server\\share\\dir\\file.ext');
+strictEqual(path.win32.normalize('/a/b/c/../../../x/y/z'), '\\x\\y\\z');
+strictEqual(path.win32.normalize('C:'), 'C:.');
+strictEqual(path.win32.normalize('C:..\\abc'), 'C:..\\abc');
+strictEqual(path.win32.normalize('C:..\\..\\abc\\..\\def'), 'C:..\\..\\def');
+strictEqual(path.win32.normalize('C:\\.'), 'C:\\');
+strictEqual(path.win32.normalize('file:stream'), 'file:stream');
+strictEqual(path.win32.normalize('bar\\foo..\\..\\'), 'bar\\');
+strictEqual(path.win32.normalize('bar\\foo..\\..'), 'bar');
+strictEqual(path.win32.normalize('bar\\foo..\\..\\baz'), 'bar\\baz');
+strictEqual(path.win32.normalize('bar\\foo..\\'), 'bar\\foo..\\');
+strictEqual(path.win32.normalize('bar\\foo..'), 'bar\\foo..');
+strictEqual(path.win32.normalize('..\\foo..\\..\\..\\bar'), '..\\..\\bar');
+strictEqual(path.win32.normalize('..\\...\\..\\.\\...\\..\\..\\bar'), '..\\..\\bar');
+strictEqual(path.win32.normalize('../../../foo/../../../bar'), '..\\..\\..\\..\\..\\bar');
+strictEqual(path.win32.normalize('../../../foo/../../../bar/../../'), '..\\..\\..\\..\\..\\..\\');
+strictEqual(path.win32.normalize('../foobar/barfoo/foo/../../../bar/../../'), '..\\..\\');
+strictEqual(path.win32.normalize('../.../../foobar/../../../bar/../../baz'), '..\\..\\..\\..\\baz');
+strictEqual(path.win32.normalize('foo/bar\\baz'), 'foo\\bar\\baz');
+
+strictEqual(path.posix.normalize('./fixtures///b/../b/c.js'), 'fixtures/b/c.js');
+strictEqual(path.posix.normalize('/foo/../../../bar'), '/bar');
+strictEqual(path.posix.normalize('a//b//../b'), 'a/b');
+strictEqual(path.posix.normalize('a//b//./c'), 'a/b/c');
+strictEqual(path.posix.normalize('a//b//.'), 'a/b');
+strictEqual(path.posix.normalize('/a/b/c/../../../x/y/z'), '/x/y/z');
+strictEqual(path.posix.normalize('///..//./foo/.//bar'), '/foo/bar');
+strictEqual(path.posix.normalize('bar/foo../../'), 'bar/');
+strictEqual(path.posix.normalize('bar/foo../..'), 'bar');
+strictEqual(path.posix.normalize('bar/foo../../baz'), 'bar/baz');
+strictEqual(path.posix.normalize('bar/foo../'), 'bar/foo../');
+strictEqual(path.posix.normalize('bar/foo..'), 'bar/foo..');
+strictEqual(path.posix.normalize('../foo../../../bar'), '../../bar');
+strictEqual(path.posix.normalize('../.../.././.../../../bar'), '../../bar');
+strictEqual(path.posix.normalize('../../../foo/../../../bar'), '../../../../../bar');
+strictEqual(path.posix.normalize('../../../foo/../../../bar/../../'), '../../../../../../');
+strictEqual(path.posix.normalize('../foobar/barfoo/foo/../../../bar/../../'), '../../');
+strictEqual(path.posix.normalize('../.../../foobar/../../../bar/../../baz'), '../../../../baz');
+strictEqual(path.posix.normalize('foo/bar\\baz'), 'foo/bar\\baz');
+
+// test-path-parse-format.js
+{
+  const winPaths = [
+    // [path, root]
+    ['C:\\path\\dir\\index.html', 'C:\\'],
+    ['C:\\another_path\\DIR\\1\\2\\33\\\\index', 'C:\\'],
+    ['another_path\\DIR with spaces\\1\\2\\33\\index', ''],
+    ['\\', '\\'],
+    ['\\foo\\C:', '\\'],
+    ['file', ''],
+    ['file:stream', ''],
+    ['.\\file', ''],
+    ['C:', 'C:'],
+    ['C:.', 'C:'],
+    ['C:..', 'C:'],
+    ['C:abc', 'C:'],
+    ['C:\\', 'C:\\'],
+    ['C:\\abc', 'C:\\' ],
+    ['', ''],
+
+    // unc
+    ['\\\\server\\share\\file_path', '\\\\server\\share\\'],
+    ['\\\\server two\\shared folder\\file path.zip',
+     '\\\\server two\\shared folder\\'],
+    ['\\\\teela\\admin$\\system32', '\\\\teela\\admin$\\'],
+    ['\\\\?\\UNC\\server\\share', '\\\\?\\UNC\\'],
+  ];
+
+  const winSpecialCaseParseTests = [
+    ['t', { base: 't', name: 't', root: '', dir: '', ext: '' }],
+    ['/foo/bar', { root: '/', dir: '/foo', base: 'bar', ext: '', name: 'bar' }],
+  ];
+
+  const winSpecialCaseFormatTests = [
+    [{ dir: 'some\\dir' }, 'some\\dir\\'],
+    [{ base: 'index.html' }, 'index.html'],
+    [{ root: 'C:\\' }, 'C:\\'],
+    [{ name: 'index', ext: '.html' }, 'index.html'],
+    [{ dir: 'some\\dir', name: 'index', ext: '.html' }, 'some\\dir\\index.html'],
+    [{ root: 'C:\\', name: 'index', ext: '.html' }, 'C:\\index.html'],
+    [{}, ''],
+  ];
+
+  const unixPaths = [
+    // [path, root]
+    ['/home/user/dir/file.txt', '/'],
+    ['/home/user/a dir/another File.zip', '/'],
+    ['/home/user/a dir//another&File.', '/'],
+    ['/home/user/a$$$dir//another File.zip', '/'],
+    ['user/dir/another File.zip', ''],
+    ['file', ''],
+    ['.\\file', ''],
+    ['./file', ''],
+    ['C:\\foo', ''],
+    ['/', '/'],
+    ['', ''],
+    ['.', ''],
+    ['..', ''],
+    ['/foo', '/'],
+    ['/foo.', '/'],
+    ['/foo.bar', '/'],
+    ['/.', '/'],
+    ['/.foo', '/'],
+    ['/.foo.bar', '/'],
+    ['/foo/bar.baz', '/'],
+  ];
+
+  const unixSpecialCaseFormatTests = [
+    [{ dir: 'some/dir' }, 'some/dir/'],
+    [{ base: 'index.html' }, 'index.html'],
+    [{ root: '/' }, '/'],
+    [{ name: 'index', ext: '.html' }, 'index.html'],
+    [{ dir: 'some/dir', name: 'index', ext: '.html' }, 'some/dir/index.html'],
+    [{ root: '/', name: 'index', ext: '.html' }, '/index.html'],
+    [{}, ''],
+  ];
+
+  const errors = [
+    { method: 'parse', input: [null] },
+    { method: 'parse', input: [{}] },
+    { method: 'parse', input: [true] },
+    { method: 'parse', input: [1] },
+    { method: 'parse', input: [] },
+    { method: 'format', input: [null] },
+    { method: 'format', input: [''] },
+    { method: 'format', input: [true] },
+    { method: 'format', input: [1] },
+  ];
+
+  function checkErrors(path) {
+    errors.forEach(({ method, input }) => {
+      throwsCode(() => {
+        path[method].apply(path, input);
+      }, 'ERR_INVALID_ARG_TYPE');
+    });
+  }
+
+  function checkParseFormat(path, paths) {
+    paths.forEach(([element, root]) => {
+      const output = path.parse(element);
+      strictEqual(typeof output.root, 'string');
+      strictEqual(typeof output.dir, 'string');
+      strictEqual(typeof output.base, 'string');
+      strictEqual(typeof output.ext, 'string');
+      strictEqual(typeof output.name, 'string');
+      strictEqual(path.format(output), element);
+      strictEqual(output.root, root);
+      strictEqual(output.dir.startsWith(output.root), true);
+      strictEqual(output.dir, output.dir ? path.dirname(element) : '');
+      strictEqual(output.base, path.basename(element));
+      strictEqual(output.ext, path.extname(element));
+    });
+  }
+
+  function checkSpecialCaseParseFormat(path, testCases) {
+    testCases.forEach(([element, expect]) => {
+      const actual = path.parse(element);
+      for (const key of Object.keys(expect)) {
+        strictEqual(actual[key], expect[key], `${key} of ${element}`);
+      }
+    });
+  }
+
+  function checkFormat(path, testCases) {
+    testCases.forEach(([element, expect]) => {
+      strictEqual(path.format(element), expect);
+    });
+
+    [null, undefined, 1, true, false, 'string'].forEach((pathObject) => {
+      throwsCode(() => {
+        path.format(pathObject);
+      }, 'ERR_INVALID_ARG_TYPE', 'The "pathObject" argument must be of type object.' + invalidArgTypeHelper(pathObject));
+    });
+  }
+
+  checkParseFormat(path.win32, winPaths);
+  checkParseFormat(path.posix, unixPaths);
+  checkSpecialCaseParseFormat(path.win32, winSpecialCaseParseTests);
+  checkErrors(path.win32);
+  checkErrors(path.posix);
+  checkFormat(path.win32, winSpecialCaseFormatTests);
+  checkFormat(path.posix, unixSpecialCaseFormatTests);
+
+  // Test removal of trailing path separators
+  const trailingTests = [
+    [ path.win32.parse,
+      [['.\\', { root: '', dir: '', base: '.', ext: '', name: '.' }],
+       ['\\\\', { root: '\\', dir: '\\', base: '', ext: '', name: '' }],
+       ['\\\\', { root: '\\', dir: '\\', base: '', ext: '', name: '' }],
+       ['c:\\foo\\\\\\',
+        { root: 'c:\\', dir: 'c:\\', base: 'foo', ext: '', name: 'foo' }],
+       ['D:\\foo\\\\\\bar.baz',
+        { root: 'D:\\',
+          dir: 'D:\\foo\\\\',
+          base: 'bar.baz',
+          ext: '.baz',
+          name: 'bar' },
+       ],
+      ],
+    ],
+    [ path.posix.parse,
+      [['./', { root: '', dir: '', base: '.', ext: '', name: '.' }],
+       ['//', { root: '/', dir: '/', base: '', ext: '', name: '' }],
+       ['///', { root: '/', dir: '/', base: '', ext: '', name: '' }],
+       ['/foo///', { root: '/', dir: '/', base: 'foo', ext: '', name: 'foo' }],
+       ['/foo///bar.baz',
+        { root: '/', dir: '/foo//', base: 'bar.baz', ext: '.baz', name: 'bar' },
+       ],
+      ],
+    ],
+  ];
+  const failures = [];
+  trailingTests.forEach((test) => {
+    const parse = test[0];
+    const os = parse === path.win32.parse ? 'win32' : 'posix';
+    test[1].forEach((test) => {
+      const actual = parse(test[0]);
+      const expected = test[1];
+      const message = `path.${os}.parse(${JSON.stringify(test[0])})\n  expect=${
+        JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+      const actualKeys = Object.keys(actual);
+      const expectedKeys = Object.keys(expected);
+      let failed = (actualKeys.length !== expectedKeys.length);
+      if (!failed) {
+        for (let i = 0; i < actualKeys.length; ++i) {
+          const key = actualKeys[i];
+          if (!expectedKeys.includes(key) || actual[key] !== expected[key]) {
+            failed = true;
+            break;
+          }
+        }
+      }
+      if (failed)
+        failures.push(`\n${message}`);
+    });
+  });
+  checkFailures(failures);
+
+  // See https://github.com/nodejs/node/issues/44343
+  strictEqual(path.format({ name: 'x', ext: 'png' }), 'x.png');
+  strictEqual(path.format({ name: 'x', ext: '.png' }), 'x.png');
+}
+
+// test-path-relative.js
+{
+  const failures = [];
+  const relativeTests = [
+    [ path.win32.relative,
+      // Arguments                     result
+      [['c:/blah\\blah', 'd:/games', 'd:\\games'],
+       ['c:/aaaa/bbbb', 'c:/aaaa', '..'],
+       ['c:/aaaa/bbbb', 'c:/cccc', '..\\..\\cccc'],
+       ['c:/aaaa/bbbb', 'c:/aaaa/bbbb', ''],
+       ['c:/aaaa/bbbb', 'c:/aaaa/cccc', '..\\cccc'],
+       ['c:/aaaa/', 'c:/aaaa/cccc', 'cccc'],
+       ['c:/', 'c:\\aaaa\\bbbb', 'aaaa\\bbbb'],
+       ['c:/aaaa/bbbb', 'd:\\', 'd:\\'],
+       ['c:/AaAa/bbbb', 'c:/aaaa/bbbb', ''],
+       ['c:/aaaaa/', 'c:/aaaa/cccc', '..\\aaaa\\cccc'],
+       ['C:\\foo\\bar\\baz\\quux', 'C:\\', '..\\..\\..\\..'],
+       ['C:\\foo\\test', 'C:\\foo\\test\\bar\\package.json', 'bar\\package.json'],
+       ['C:\\foo\\bar\\baz-quux', 'C:\\foo\\bar\\baz', '..\\baz'],
+       ['C:\\foo\\bar\\baz', 'C:\\foo\\bar\\baz-quux', '..\\baz-quux'],
+       ['\\\\foo\\bar', '\\\\foo\\bar\\baz', 'baz'],
+       ['\\\\foo\\bar\\baz', '\\\\foo\\bar', '..'],
+       ['\\\\foo\\bar\\baz-quux', '\\\\foo\\bar\\baz', '..\\baz'],
+       ['\\\\foo\\bar\\baz', '\\\\foo\\bar\\baz-quux', '..\\baz-quux'],
+       ['C:\\baz-quux', 'C:\\baz', '..\\baz'],
+       ['C:\\baz', 'C:\\baz-quux', '..\\baz-quux'],
+       ['\\\\foo\\baz-quux', '\\\\foo\\baz', '..\\baz'],
+       ['\\\\foo\\baz', '\\\\foo\\baz-quux', '..\\baz-quux'],
+       ['C:\\baz', '\\\\foo\\bar\\baz', '\\\\foo\\bar\\baz'],
+       ['\\\\foo\\bar\\baz', 'C:\\baz', 'C:\\baz'],
+      ],
+    ],
+    [ path.posix.relative,
+      // Arguments          result
+      [['/var/lib', '/var', '..'],
+       ['/var/lib', '/bin', '../../bin'],
+       ['/var/lib', '/var/lib', ''],
+       ['/var/lib', '/var/apache', '../apache'],
+       ['/var/', '/var/lib', 'lib'],
+       ['/', '/var/lib', 'var/lib'],
+       ['/foo/test', '/foo/test/bar/package.json', 'bar/package.json'],
+       ['/Users/a/web/b/test/mails', '/Users/a/web/b', '../..'],
+       ['/foo/bar/baz-quux', '/foo/bar/baz', '../baz'],
+       ['/foo/bar/baz', '/foo/bar/baz-quux', '../baz-quux'],
+       ['/baz-quux', '/baz', '../baz'],
+       ['/baz', '/baz-quux', '../baz-quux'],
+       ['/page1/page2/foo', '/', '../../..'],
+      ],
+    ],
+  ];
+  relativeTests.forEach((test) => {
+    const relative = test[0];
+    test[1].forEach((test) => {
+      const actual = relative(test[0], test[1]);
+      const expected = test[2];
+      if (actual !== expected) {
+        const os = relative === path.win32.relative ? 'win32' : 'posix';
+        const message = `path.${os}.relative(${
+          test.slice(0, 2).map(JSON.stringify).join(',')})\n  expect=${
+          JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+        failures.push(`\n${message}`);
+      }
+    });
+  });
+  checkFailures(failures);
+}
+
+// test-path-resolve.js
+{
+  const failures = [];
+  const posixyCwd = process.cwd();
+
+  const resolveTests = [
+    [ path.win32.resolve,
+      // Arguments                               result
+      [[['c:/blah\\blah', 'd:/games', 'c:../a'], 'c:\\blah\\a'],
+       [['c:/ignore', 'd:\\a/b\\c/d', '\\e.exe'], 'd:\\e.exe'],
+       [['c:/ignore', 'c:/some/file'], 'c:\\some\\file'],
+       [['d:/ignore', 'd:some/dir//'], 'd:\\ignore\\some\\dir'],
+       [['.'], process.cwd()],
+       [['//server/share', '..', 'relative\\'], '\\\\server\\share\\relative'],
+       [['c:/', '//'], 'c:\\'],
+       [['c:/', '//dir'], 'c:\\dir'],
+       [['c:/', '//server/share'], '\\\\server\\share\\'],
+       [['c:/', '//server//share'], '\\\\server\\share\\'],
+       [['c:/', '///some//dir'], 'c:\\some\\dir'],
+       [['C:\\foo\\tmp.3\\', '..\\tmp.3\\cycles\\root.js'],
+        'C:\\foo\\tmp.3\\cycles\\root.js'],
+      ],
+    ],
+    [ path.posix.resolve,
+      // Arguments                    result
+      [[['/var/lib', '../', 'file/'], '/var/file'],
+       [['/var/lib', '/../', 'file/'], '/file'],
+       [['a/b/c/', '../../..'], posixyCwd],
+       [['.'], posixyCwd],
+       [['/some/dir', '.', '/absolute/'], '/absolute'],
+       [['/foo/tmp.3/', '../tmp.3/cycles/root.js'], '/foo/tmp.3/cycles/root.js'],
+      ],
+    ],
+  ];
+  resolveTests.forEach(([resolve, tests]) => {
+    tests.forEach(([test, expected]) => {
+      const actual = resolve.apply(null, test);
+      let actualAlt;
+      const os = resolve === path.win32.resolve ? 'win32' : 'posix';
+      if (resolve === path.win32.resolve)
+        actualAlt = actual.replace(backslashRE, '/');
+
+      const message =
+        `path.${os}.resolve(${test.map(JSON.stringify).join(',')})\n  expect=${
+          JSON.stringify(expected)}\n  actual=${JSON.stringify(actual)}`;
+      if (actual !== expected && actualAlt !== expected)
+        failures.push(message);
+    });
+  });
+  checkFailures(failures);
+}
+
+// test-path-makelong.js
+{
+  const emptyObj = {};
+  strictEqual(path.win32.toNamespacedPath('C:\\foo'), '\\\\?\\C:\\foo');
+  strictEqual(path.win32.toNamespacedPath('C:/foo'), '\\\\?\\C:\\foo');
+  strictEqual(path.win32.toNamespacedPath('\\\\foo\\bar'), '\\\\?\\UNC\\foo\\bar\\');
+  strictEqual(path.win32.toNamespacedPath('//foo//bar'), '\\\\?\\UNC\\foo\\bar\\');
+  strictEqual(path.win32.toNamespacedPath('\\\\?\\foo'), '\\\\?\\foo');
+  strictEqual(path.win32.toNamespacedPath(null), null);
+  strictEqual(path.win32.toNamespacedPath(true), true);
+  strictEqual(path.win32.toNamespacedPath(1), 1);
+  strictEqual(path.win32.toNamespacedPath(), undefined);
+  strictEqual(path.win32.toNamespacedPath(emptyObj), emptyObj);
+  strictEqual(path.win32._makeLong('C:\\foo'), '\\\\?\\C:\\foo');
+
+  strictEqual(path.posix.toNamespacedPath('/foo/bar'), '/foo/bar');
+  strictEqual(path.posix.toNamespacedPath('foo/bar'), 'foo/bar');
+  strictEqual(path.posix.toNamespacedPath(null), null);
+  strictEqual(path.posix.toNamespacedPath(true), true);
+  strictEqual(path.posix.toNamespacedPath(1), 1);
+  strictEqual(path.posix.toNamespacedPath(), undefined);
+  strictEqual(path.posix.toNamespacedPath(emptyObj), emptyObj);
+}
+
+// test-path-zero-length-strings.js
+{
+  const pwd = process.cwd();
+
+  // Join will internally ignore all the zero-length strings and it will return
+  // '.' if the joined string is a zero-length string.
+  strictEqual(path.posix.join(''), '.');
+  strictEqual(path.posix.join('', ''), '.');
+  strictEqual(path.win32.join(''), '.');
+  strictEqual(path.win32.join('', ''), '.');
+  strictEqual(path.join(pwd), pwd);
+  strictEqual(path.join(pwd, ''), pwd);
+
+  // Normalize will return '.' if the input is a zero-length string
+  strictEqual(path.posix.normalize(''), '.');
+  strictEqual(path.win32.normalize(''), '.');
+  strictEqual(path.normalize(pwd), pwd);
+
+  // Since '' is not a valid path in any of the common environments, return false
+  strictEqual(path.posix.isAbsolute(''), false);
+  strictEqual(path.win32.isAbsolute(''), false);
+
+  // Resolve, internally ignores all the zero-length strings and returns the
+  // current working directory
+  strictEqual(path.resolve(''), pwd);
+  strictEqual(path.resolve('', ''), pwd);
+
+  // Relative, internally calls resolve. So, '' is actually the current directory
+  strictEqual(path.relative('', pwd), '');
+  strictEqual(path.relative(pwd, ''), '');
+  strictEqual(path.relative(pwd, pwd), '');
+}
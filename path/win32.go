@@ -0,0 +1,646 @@
+package path
+
+import "strings"
+
+func isPathSeparator(c byte) bool {
+	return c == '/' || c == '\\'
+}
+
+func isWindowsDeviceRoot(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// hasDevice reports whether path starts with a drive letter followed by a colon, e.g. "C:".
+func hasDevice(path string) bool {
+	return len(path) >= 2 && isWindowsDeviceRoot(path[0]) && path[1] == ':'
+}
+
+// skip skips the path separators (or the other characters if sep is false) starting at j and returns
+// the index of the next character.
+func skip(path string, j int, sep bool) int {
+	for j < len(path) && isPathSeparator(path[j]) == sep {
+		j++
+	}
+	return j
+}
+
+type win32 struct {
+	cwd func() string
+	// env returns the value of the environment variable, it is used to find the current directory of
+	// the drives ("=C:").
+	env func(key string) (string, bool)
+}
+
+func (win32) sep() string {
+	return "\\"
+}
+
+func (win32) delimiter() string {
+	return ";"
+}
+
+func (w win32) resolve(paths []string) string {
+	resolvedDevice := ""
+	resolvedTail := ""
+	resolvedAbsolute := false
+
+	for i := len(paths) - 1; i >= -1; i-- {
+		var path string
+		if i >= 0 {
+			path = paths[i]
+		} else if len(resolvedDevice) == 0 {
+			path = w.cwd()
+		} else {
+			// Windows has the concept of drive-specific current working directories. If we've resolved a
+			// drive letter but not yet an absolute path, get cwd for that drive, or the process cwd if the
+			// drive cwd is not available. We're sure the device is not a UNC path at this points, because
+			// UNC paths are always absolute.
+			var ok bool
+			if path, ok = w.env("=" + resolvedDevice); !ok {
+				path = w.cwd()
+			}
+			// Verify that a cwd was found and that it actually points to our drive. If not, default to
+			// the drive's root.
+			if len(path) > 2 && strings.ToLower(path[:2]) != strings.ToLower(resolvedDevice) && path[2] == '\\' {
+				path = resolvedDevice + "\\"
+			}
+		}
+		// Skip empty entries
+		if len(path) == 0 {
+			continue
+		}
+
+		rootEnd := 0
+		device := ""
+		isAbsolute := false
+		code := path[0]
+
+		// Try to match a root
+		if len(path) == 1 {
+			if isPathSeparator(code) {
+				// `path` contains just a path separator
+				rootEnd = 1
+				isAbsolute = true
+			}
+		} else if isPathSeparator(code) {
+			// Possible UNC root
+
+			// If we started with a separator, we know we at least have an absolute path of some kind
+			// (UNC or otherwise)
+			isAbsolute = true
+
+			if isPathSeparator(path[1]) {
+				// Matched double path separator at beginning
+				j := skip(path, 2, false)
+				if j < len(path) && j != 2 {
+					firstPart := path[2:j]
+					// Matched!
+					last := j
+					// Match 1 or more path separators
+					j = skip(path, j, true)
+					if j < len(path) && j != last {
+						// Matched!
+						last = j
+						// Match 1 or more non-path separators
+						j = skip(path, j, false)
+						if j == len(path) || j != last {
+							// We matched a UNC root
+							device = "\\\\" + firstPart + "\\" + path[last:j]
+							rootEnd = j
+						}
+					}
+				}
+			} else {
+				rootEnd = 1
+			}
+		} else if hasDevice(path) {
+			// Possible device root
+			device = path[:2]
+			rootEnd = 2
+			if len(path) > 2 && isPathSeparator(path[2]) {
+				// Treat separator following drive name as an absolute path indicator
+				isAbsolute = true
+				rootEnd = 3
+			}
+		}
+
+		if len(device) > 0 {
+			if len(resolvedDevice) > 0 {
+				if strings.ToLower(device) != strings.ToLower(resolvedDevice) {
+					// This path points to another device so it is not applicable
+					continue
+				}
+			} else {
+				resolvedDevice = device
+			}
+		}
+
+		if resolvedAbsolute {
+			if len(resolvedDevice) > 0 {
+				break
+			}
+		} else {
+			resolvedTail = path[rootEnd:] + "\\" + resolvedTail
+			resolvedAbsolute = isAbsolute
+			if isAbsolute && len(resolvedDevice) > 0 {
+				break
+			}
+		}
+	}
+
+	// At this point the path should be resolved to a full absolute path, but handle relative paths to be
+	// safe (might happen when process.cwd() fails)
+
+	// Normalize the tail path
+	resolvedTail = normalizeString(resolvedTail, !resolvedAbsolute, '\\', isPathSeparator)
+
+	if resolvedAbsolute {
+		return resolvedDevice + "\\" + resolvedTail
+	}
+	if res := resolvedDevice + resolvedTail; len(res) > 0 {
+		return res
+	}
+	return "."
+}
+
+func (win32) normalize(path string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	rootEnd := 0
+	device := ""
+	isAbsolute := false
+	code := path[0]
+
+	// Try to match a root
+	if len(path) == 1 {
+		// `path` contains just a single char, exit early to avoid unnecessary work
+		if isPosixPathSeparator(code) {
+			return "\\"
+		}
+		return path
+	}
+	if isPathSeparator(code) {
+		// Possible UNC root
+
+		// If we started with a separator, we know we at least have an absolute path of some kind
+		// (UNC or otherwise)
+		isAbsolute = true
+
+		if isPathSeparator(path[1]) {
+			// Matched double path separator at beginning
+			j := skip(path, 2, false)
+			if j < len(path) && j != 2 {
+				firstPart := path[2:j]
+				// Matched!
+				last := j
+				// Match 1 or more path separators
+				j = skip(path, j, true)
+				if j < len(path) && j != last {
+					// Matched!
+					last = j
+					// Match 1 or more non-path separators
+					j = skip(path, j, false)
+					if j == len(path) {
+						// We matched a UNC root only. Return the normalized version of the UNC root since
+						// there is nothing left to process
+						return "\\\\" + firstPart + "\\" + path[last:] + "\\"
+					}
+					if j != last {
+						// We matched a UNC root with leftovers
+						device = "\\\\" + firstPart + "\\" + path[last:j]
+						rootEnd = j
+					}
+				}
+			}
+		} else {
+			rootEnd = 1
+		}
+	} else if hasDevice(path) {
+		// Possible device root
+		device = path[:2]
+		rootEnd = 2
+		if len(path) > 2 && isPathSeparator(path[2]) {
+			// Treat separator following drive name as an absolute path indicator
+			isAbsolute = true
+			rootEnd = 3
+		}
+	}
+
+	tail := ""
+	if rootEnd < len(path) {
+		tail = normalizeString(path[rootEnd:], !isAbsolute, '\\', isPathSeparator)
+	}
+	if len(tail) == 0 && !isAbsolute {
+		tail = "."
+	}
+	if len(tail) > 0 && isPathSeparator(path[len(path)-1]) {
+		tail += "\\"
+	}
+	if isAbsolute {
+		return device + "\\" + tail
+	}
+	return device + tail
+}
+
+func (win32) isAbsolute(path string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	return isPathSeparator(path[0]) ||
+		// Possible device root
+		len(path) > 2 && hasDevice(path) && isPathSeparator(path[2])
+}
+
+func (w win32) join(paths []string) string {
+	joined := ""
+	firstPart := ""
+	for _, arg := range paths {
+		if len(arg) > 0 {
+			if len(joined) == 0 {
+				joined = arg
+				firstPart = arg
+			} else {
+				joined += "\\" + arg
+			}
+		}
+	}
+	if len(joined) == 0 {
+		return "."
+	}
+
+	// Make sure that the joined path doesn't start with two slashes, because normalize() will mistake it
+	// for a UNC path then.
+	//
+	// This step is skipped when it is very clear that the user actually intended to point at a UNC path.
+	// This is assumed when the first non-empty string arguments starts with exactly two slashes followed
+	// by at least one more non-slash character.
+	//
+	// Note that for normalize() to treat a path as a UNC path it needs to have at least 2 components, so
+	// we don't filter for that here. This means that the user can use join to construct UNC paths from
+	// a server name and a share name; for example:
+	//   path.join('//server', 'share') -> '\\\\server\\share\\')
+	needsReplace := true
+	slashCount := 0
+	if isPathSeparator(firstPart[0]) {
+		slashCount++
+		if len(firstPart) > 1 && isPathSeparator(firstPart[1]) {
+			slashCount++
+			if len(firstPart) > 2 {
+				if isPathSeparator(firstPart[2]) {
+					slashCount++
+				} else {
+					// We matched a UNC path in the first part
+					needsReplace = false
+				}
+			}
+		}
+	}
+	if needsReplace {
+		// Find any more consecutive slashes we need to replace
+		for slashCount < len(joined) && isPathSeparator(joined[slashCount]) {
+			slashCount++
+		}
+		// Replace the slashes if needed
+		if slashCount >= 2 {
+			joined = "\\" + joined[slashCount:]
+		}
+	}
+	return w.normalize(joined)
+}
+
+// relative returns the relative path from `from` to `to`. It compares the paths case-insensitively
+// as Windows does, e.g. from='C:\\orandea\\test\\aaa' and to='C:\\orandea\\impl\\bbb' gives '..\\..\\impl\\bbb'.
+func (w win32) relative(from, to string) string {
+	if from == to {
+		return ""
+	}
+
+	fromOrig := w.resolve([]string{from})
+	toOrig := w.resolve([]string{to})
+	if fromOrig == toOrig {
+		return ""
+	}
+
+	from = strings.ToLower(fromOrig)
+	to = strings.ToLower(toOrig)
+	if from == to {
+		return ""
+	}
+
+	// Trim any leading backslashes
+	fromStart := 0
+	for fromStart < len(from) && from[fromStart] == '\\' {
+		fromStart++
+	}
+	// Trim trailing backslashes (applicable to UNC paths only)
+	fromEnd := len(from)
+	for fromEnd-1 > fromStart && from[fromEnd-1] == '\\' {
+		fromEnd--
+	}
+	fromLen := fromEnd - fromStart
+
+	// Trim any leading backslashes
+	toStart := 0
+	for toStart < len(to) && to[toStart] == '\\' {
+		toStart++
+	}
+	// Trim trailing backslashes (applicable to UNC paths only)
+	toEnd := len(to)
+	for toEnd-1 > toStart && to[toEnd-1] == '\\' {
+		toEnd--
+	}
+	toLen := toEnd - toStart
+
+	// Compare paths to find the longest common path from root
+	length := fromLen
+	if toLen < length {
+		length = toLen
+	}
+	lastCommonSep := -1
+	i := 0
+	for ; i < length; i++ {
+		fromCode := from[fromStart+i]
+		if fromCode != to[toStart+i] {
+			break
+		} else if fromCode == '\\' {
+			lastCommonSep = i
+		}
+	}
+
+	// We found a mismatch before the first common path separator was seen, so return the original `to`.
+	if i != length {
+		if lastCommonSep == -1 {
+			return toOrig
+		}
+	} else {
+		if toLen > length {
+			if to[toStart+i] == '\\' {
+				// We get here if `from` is the exact base path for `to`, e.g. from='C:\\foo\\bar'; to='C:\\foo\\bar\\baz'
+				return toOrig[toStart+i+1:]
+			}
+			if i == 2 {
+				// We get here if `from` is the device root, e.g. from='C:\\'; to='C:\\foo'
+				return toOrig[toStart+i:]
+			}
+		}
+		if fromLen > length {
+			if from[fromStart+i] == '\\' {
+				// We get here if `to` is the exact base path for `from`, e.g. from='C:\\foo\\bar'; to='C:\\foo'
+				lastCommonSep = i
+			} else if i == 2 {
+				// We get here if `to` is the device root, e.g. from='C:\\foo\\bar'; to='C:\\'
+				lastCommonSep = 3
+			}
+		}
+		if lastCommonSep == -1 {
+			lastCommonSep = 0
+		}
+	}
+
+	out := ""
+	// Generate the relative path based on the path difference between `to` and `from`
+	for i = fromStart + lastCommonSep + 1; i <= fromEnd; i++ {
+		if i == fromEnd || from[i] == '\\' {
+			if len(out) == 0 {
+				out += ".."
+			} else {
+				out += "\\.."
+			}
+		}
+	}
+
+	toStart += lastCommonSep
+
+	// Lastly, append the rest of the destination (`to`) path that comes after the common path parts
+	if len(out) > 0 {
+		return out + toOrig[toStart:toEnd]
+	}
+	if toStart < len(toOrig) && toOrig[toStart] == '\\' {
+		toStart++
+	}
+	return toOrig[toStart:toEnd]
+}
+
+func (w win32) toNamespacedPath(path string) string {
+	// Note: this will *probably* throw somewhere.
+	if len(path) == 0 {
+		return path
+	}
+
+	resolvedPath := w.resolve([]string{path})
+	if len(resolvedPath) <= 2 {
+		return path
+	}
+
+	if resolvedPath[0] == '\\' {
+		// Possible UNC root
+		if resolvedPath[1] == '\\' {
+			if code := resolvedPath[2]; code != '?' && code != '.' {
+				// Matched non-long UNC root, convert the path to a long UNC path
+				return "\\\\?\\UNC\\" + resolvedPath[2:]
+			}
+		}
+	} else if hasDevice(resolvedPath) && resolvedPath[2] == '\\' {
+		// Matched device root, convert the path to a long UNC path
+		return "\\\\?\\" + resolvedPath
+	}
+	return path
+}
+
+func (win32) dirname(path string) string {
+	if len(path) == 0 {
+		return "."
+	}
+	rootEnd := -1
+	offset := 0
+	code := path[0]
+
+	if len(path) == 1 {
+		// `path` contains just a path separator, exit early to avoid unnecessary work or a dot
+		if isPathSeparator(code) {
+			return path
+		}
+		return "."
+	}
+
+	// Try to match a root
+	if isPathSeparator(code) {
+		// Possible UNC root
+		rootEnd = 1
+		offset = 1
+
+		if isPathSeparator(path[1]) {
+			// Matched double path separator at beginning
+			j := skip(path, 2, false)
+			if j < len(path) && j != 2 {
+				// Matched!
+				last := j
+				// Match 1 or more path separators
+				j = skip(path, j, true)
+				if j < len(path) && j != last {
+					// Matched!
+					last = j
+					// Match 1 or more non-path separators
+					j = skip(path, j, false)
+					if j == len(path) {
+						// We matched a UNC root only
+						return path
+					}
+					if j != last {
+						// We matched a UNC root with leftovers
+
+						// Offset by 1 to include the separator after the UNC root to treat it as a
+						// "normal root" on top of a (UNC) root
+						rootEnd = j + 1
+						offset = j + 1
+					}
+				}
+			}
+		}
+	} else if hasDevice(path) {
+		// Possible device root
+		rootEnd = 2
+		if len(path) > 2 && isPathSeparator(path[2]) {
+			rootEnd = 3
+		}
+		offset = rootEnd
+	}
+
+	end := -1
+	matchedSlash := true
+	for i := len(path) - 1; i >= offset; i-- {
+		if isPathSeparator(path[i]) {
+			if !matchedSlash {
+				end = i
+				break
+			}
+		} else {
+			// We saw the first non-path separator
+			matchedSlash = false
+		}
+	}
+
+	if end == -1 {
+		if rootEnd == -1 {
+			return "."
+		}
+		end = rootEnd
+	}
+	return path[:end]
+}
+
+func (win32) basename(path, suffix string) string {
+	start := 0
+	// Check for a drive letter prefix so as not to mistake the following path separator as an extra
+	// separator at the end of the path that can be disregarded
+	if hasDevice(path) {
+		start = 2
+	}
+	return basename(path, suffix, start, isPathSeparator)
+}
+
+func (win32) extname(path string) string {
+	start := 0
+	// Check for a drive letter prefix so as not to mistake the following path separator as an extra
+	// separator at the end of the path that can be disregarded
+	if hasDevice(path) {
+		start = 2
+	}
+	startDot, _, end := extension(path, start, isPathSeparator)
+	if startDot == -1 {
+		return ""
+	}
+	return path[startDot:end]
+}
+
+func (win32) parse(path string) (ret parsedPath) {
+	if len(path) == 0 {
+		return
+	}
+
+	rootEnd := 0
+	code := path[0]
+
+	if len(path) == 1 {
+		if isPathSeparator(code) {
+			// `path` contains just a path separator, exit early to avoid unnecessary work
+			ret.root = path
+			ret.dir = path
+			return
+		}
+		ret.base = path
+		ret.name = path
+		return
+	}
+	// Try to match a root
+	if isPathSeparator(code) {
+		// Possible UNC root
+		rootEnd = 1
+		if isPathSeparator(path[1]) {
+			// Matched double path separator at beginning
+			j := skip(path, 2, false)
+			if j < len(path) && j != 2 {
+				// Matched!
+				last := j
+				// Match 1 or more path separators
+				j = skip(path, j, true)
+				if j < len(path) && j != last {
+					// Matched!
+					last = j
+					// Match 1 or more non-path separators
+					j = skip(path, j, false)
+					if j == len(path) {
+						// We matched a UNC root only
+						rootEnd = j
+					} else if j != last {
+						// We matched a UNC root with leftovers
+						rootEnd = j + 1
+					}
+				}
+			}
+		}
+	} else if hasDevice(path) {
+		// Possible device root
+		if len(path) <= 2 {
+			// `path` contains just a drive root, exit early to avoid unnecessary work
+			ret.root = path
+			ret.dir = path
+			return
+		}
+		rootEnd = 2
+		if isPathSeparator(path[2]) {
+			if len(path) == 3 {
+				// `path` contains just a drive root, exit early to avoid unnecessary work
+				ret.root = path
+				ret.dir = path
+				return
+			}
+			rootEnd = 3
+		}
+	}
+	if rootEnd > 0 {
+		ret.root = path[:rootEnd]
+	}
+
+	startDot, startPart, end := extension(path, rootEnd, isPathSeparator)
+	if end != -1 {
+		if startDot == -1 {
+			ret.name = path[startPart:end]
+			ret.base = ret.name
+		} else {
+			ret.name = path[startPart:startDot]
+			ret.base = path[startPart:end]
+			ret.ext = path[startDot:end]
+		}
+	}
+
+	// If the directory is the root, use the entire root as the `dir` including the trailing slash if any
+	// (`C:\abc` -> `C:\`). Otherwise, strip out the trailing slash (`C:\abc\def` -> `C:\abc`).
+	if startPart > 0 && startPart != rootEnd {
+		ret.dir = path[:startPart-1]
+	} else {
+		ret.dir = ret.root
+	}
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/events"
 )
 
 const ModuleName = "node:process"
@@ -29,11 +30,43 @@ func (m *ProcessModule) Export(runtime *goja.Runtime, module *goja.Object) {
 }
 
 func (m *ProcessModule) Enable(runtime *goja.Runtime) {
-	process := runtime.NewObject()
+	process := events.NewEventEmitter(runtime)
 	process.Set("env", loadProcessEnv())
+	process.Set("nextTick", nextTick(runtime))
 	runtime.Set("process", process)
 }
 
+// Globals returns the names of the globals set by Enable.
+func (m *ProcessModule) Globals() []string {
+	return []string{"process"}
+}
+
+// nextTick returns the default implementation of process.nextTick() which runs the callback as a promise
+// job. Event loops replace it with their own nextTick queue which is drained before the promise jobs.
+func nextTick(runtime *goja.Runtime) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(runtime.NewTypeError(`The "callback" argument must be of type function`))
+		}
+		var args []goja.Value
+		if len(call.Arguments) > 1 {
+			args = append(args, call.Arguments[1:]...)
+		}
+		p, resolve, _ := runtime.NewPromise()
+		resolve(goja.Undefined())
+		promise := runtime.ToValue(p).(*goja.Object)
+		then, _ := goja.AssertFunction(promise.Get("then"))
+		then(promise, runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			if _, err := fn(goja.Undefined(), args...); err != nil {
+				panic(err)
+			}
+			return goja.Undefined()
+		}))
+		return goja.Undefined()
+	}
+}
+
 func Default() *ProcessModule {
 	return &defaultModule
 }
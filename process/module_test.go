@@ -59,3 +59,43 @@ func TestProcessEnvValuesBrackets(t *testing.T) {
 		}
 	}
 }
+
+func TestProcessEvents(t *testing.T) {
+	vm := goja.New()
+	Default().Enable(vm)
+
+	res, err := vm.RunString(`
+	var log = [];
+	function a(v) { log.push("a" + v); }
+	process.on("test", a).once("test", v => log.push("once" + v)).prependListener("test", v => log.push("first" + v));
+	var emitted = process.emit("test", 1);
+	process.off("test", a);
+	process.emit("test", 2);
+	log.push(emitted, process.emit("other"), process.listenerCount("test"));
+	log.join();
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "first1,a1,once1,first2,true,false,1" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestProcessNextTick(t *testing.T) {
+	vm := goja.New()
+	Default().Enable(vm)
+
+	res, err := vm.RunString(`
+	var log = [];
+	process.nextTick((a, b) => log.push(a + b), 1, 2);
+	log.push("sync");
+	log;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "sync,3" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
@@ -0,0 +1,141 @@
+package require
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// compileCacheMagic starts every cache entry. It includes the format version, so the entries written by an
+// incompatible version of the package are discarded.
+const compileCacheMagic = "goja-nodejs compile cache 1\n"
+
+// compileCache stores the wrapped module sources, i.e. after the extension handler's transformation and
+// the ES module transformation, in a directory. The entries are keyed by the hash of the module name and
+// the original source, so a changed file never uses a stale entry. goja programs cannot be serialized, so
+// the cached source still needs to be compiled, but it is known to be valid.
+type compileCache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+}
+
+// WithCompileCache enables the persistent cache of transformed module sources in the given directory, which
+// is created if it doesn't exist. Only the transformation is cached: the ES module transformation and the one
+// of the files loaded by a Transform extension handler (see WithExtensionHandler()), which helps when it is
+// expensive, e.g. a TypeScript compiler. goja programs cannot be serialized, so every module, cached or not,
+// is still parsed and compiled on every load, and the modules which aren't transformed bypass the cache.
+// Entries are validated with a checksum, the corrupted ones are ignored and removed. If maxSize is positive,
+// the least recently used entries are removed when the total size of the cache exceeds maxSize bytes.
+// The cache is keyed by the module source and not by the transformation, so the directory has to be cleared
+// when an extension handler changes the way it transforms files.
+func WithCompileCache(dir string, maxSize int64) Option {
+	return func(r *Registry) {
+		r.compileCache = &compileCache{dir: dir, maxSize: maxSize}
+	}
+}
+
+func compileCacheKey(name, code string, esm bool) string {
+	h := sha256.New()
+	h.Write([]byte(compileCacheMagic))
+	h.Write([]byte(strconv.Quote(name)))
+	h.Write([]byte(strconv.FormatBool(esm)))
+	h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *compileCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := filepath.Join(c.dir, key)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", false
+	}
+	if len(data) < len(compileCacheMagic)+sha256.Size || string(data[:len(compileCacheMagic)]) != compileCacheMagic {
+		os.Remove(p)
+		return "", false
+	}
+	data = data[len(compileCacheMagic):]
+	sum, source := data[:sha256.Size], data[sha256.Size:]
+	if checksum := sha256.Sum256(source); !bytes.Equal(checksum[:], sum) {
+		os.Remove(p)
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(p, now, now)
+	return string(source), true
+}
+
+// put stores the entry ignoring the errors: a failure to write the cache only makes the next start slower.
+func (c *compileCache) put(key, source string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256([]byte(source))
+	_, err = f.WriteString(compileCacheMagic)
+	if err == nil {
+		_, err = f.Write(sum[:])
+	}
+	if err == nil {
+		_, err = f.WriteString(source)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	c.trim()
+}
+
+// trim removes the least recently used entries until the cache fits in maxSize.
+func (c *compileCache) trim() {
+	if c.maxSize <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var (
+		infos []os.FileInfo
+		size  int64
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || len(entry.Name()) != hex.EncodedLen(sha256.Size) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+			size += info.Size()
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if size <= c.maxSize {
+			break
+		}
+		if os.Remove(filepath.Join(c.dir, info.Name())) == nil {
+			size -= info.Size()
+		}
+	}
+}
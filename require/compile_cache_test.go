@@ -0,0 +1,110 @@
+package require
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestCompileCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	files := map[string]string{
+		"/app/a.up":   `module.exports = "a"`,
+		"/app/b.up":   `module.exports = "b"`,
+		"/app/c.mjs":  `import a from "./a.up"; export default a + "c";`,
+		"/app/bad.up": `module.exports = `,
+		"/app/d.js":   `module.exports = "d"`,
+	}
+	transforms := 0
+	newRegistry := func(maxSize int64) *Registry {
+		return NewRegistry(WithLoader(mapFileSystemSourceLoader(files)), WithCompileCache(dir, maxSize),
+			WithExtensionHandler(".up", ExtensionHandler{
+				Transform: func(filename string, src []byte) (string, error) {
+					transforms++
+					return string(src) + ".toUpperCase();", nil
+				},
+			}))
+	}
+	run := func(r *Registry, script string) string {
+		t.Helper()
+		vm := goja.New()
+		rr := r.Enable(vm)
+		v, err := vm.RunScript("/app/test.js", script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := v.String(); s != "ok" {
+			return s
+		}
+		p := rr.Import("/app/c.mjs")
+		if p.State() != goja.PromiseStateFulfilled {
+			t.Fatalf("Unexpected promise state: %v", p.State())
+		}
+		return p.Result().ToObject(vm).Get("default").String()
+	}
+	entries := func() []os.DirEntry {
+		t.Helper()
+		list, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	if s := run(newRegistry(0), `[require("./a.up"), require("./b.up")].join()`); s != "A,B" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if s := run(newRegistry(0), `"ok"`); s != "Ac" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if transforms != 2 || len(entries()) != 3 {
+		t.Fatalf("Unexpected transforms: %d, entries: %d", transforms, len(entries()))
+	}
+
+	// The modules which aren't transformed are not cached
+	if s := run(newRegistry(0), `require("./d.js")`); s != "d" || len(entries()) != 3 {
+		t.Fatalf("Unexpected result: %s, entries: %d", s, len(entries()))
+	}
+
+	// The cached sources are used by a new registry
+	transforms = 0
+	if s := run(newRegistry(0), `[require("./a.up"), require("./b.up")].join()`); s != "A,B" || transforms != 0 {
+		t.Fatalf("Unexpected result: %s, transforms: %d", s, transforms)
+	}
+
+	// Invalid sources are not cached
+	vm := goja.New()
+	newRegistry(0).Enable(vm)
+	if _, err := vm.RunScript("/app/test.js", `require("./bad.up")`); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(entries()) != 3 {
+		t.Fatalf("Unexpected entries: %d", len(entries()))
+	}
+
+	// Changed and corrupted entries are not used
+	files["/app/a.up"] = `module.exports = "a2"`
+	for _, entry := range entries() {
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), []byte(compileCacheMagic+"corrupted"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transforms = 0
+	if s := run(newRegistry(0), `[require("./a.up"), require("./b.up")].join()`); s != "A2,B" || transforms != 2 {
+		t.Fatalf("Unexpected result: %s, transforms: %d", s, transforms)
+	}
+
+	// The cache is trimmed to the maximum size
+	if s := run(newRegistry(1), `require("./b.up")`); s != "B" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	files["/app/b.up"] = `module.exports = "b2"`
+	if s := run(newRegistry(1), `require("./b.up")`); s != "B2" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if n := len(entries()); n != 0 {
+		t.Fatalf("Unexpected entries: %d", n)
+	}
+}
@@ -0,0 +1,141 @@
+package require
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// GlobalsProvider can be implemented by a NativeModule to report the names of the global properties set by
+// its Enable method, which allows enabling the module lazily (see WithLazyGlobals()).
+type GlobalsProvider interface {
+	Globals() []string
+}
+
+// EnableOption configures the ModuleResolver created by Registry.Enable() for a single runtime.
+type EnableOption func(*ModuleResolver)
+
+// WithLazyGlobals defers the Enable call of the native modules implementing GlobalsProvider until one of their
+// globals is accessed or the module is required, so the modules which are never used don't cost anything.
+// Assigning a global before it's accessed replaces it without enabling the module.
+func WithLazyGlobals() EnableOption {
+	return func(r *ModuleResolver) {
+		r.lazy = make(map[string]*lazyModule)
+	}
+}
+
+// WithAllowedModules restricts the modules which can be loaded in the runtime to the given ones, require() and
+// import of any other module fail with ErrModuleNotAllowed. The native modules are matched by the registered name,
+// e.g. "node:url", the module files by the resolved file name. A name can be a pattern of path.Match, e.g.
+// "/app/lib/*.js", and a name ending with a slash matches all files in the directory and its subdirectories.
+// The native modules which are not allowed are not enabled, so their globals are not available either.
+func WithAllowedModules(names ...string) EnableOption {
+	return func(r *ModuleResolver) {
+		if r.allowList == nil {
+			r.allowList = []string{}
+		}
+		r.allowList = append(r.allowList, names...)
+	}
+}
+
+// WithDeniedModules prevents loading the given modules in the runtime, the names are matched in the same way as
+// by WithAllowedModules(). A denied module cannot be loaded even if it's allowed.
+func WithDeniedModules(names ...string) EnableOption {
+	return func(r *ModuleResolver) {
+		r.denyList = append(r.denyList, names...)
+	}
+}
+
+// WithNativeModule adds a native module to the runtime only, without registering it in the registry shared with
+// the other runtimes. It takes precedence over a module registered in the registry under the same name.
+func WithNativeModule(name string, module NativeModule) EnableOption {
+	return func(r *ModuleResolver) {
+		r.natives[path.Clean(name)] = module
+	}
+}
+
+func matchModule(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == name || strings.HasSuffix(pattern, "/") && strings.HasPrefix(name, pattern) {
+			return true
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowed reports whether the native module or the module file can be loaded in the runtime.
+func (r *ModuleResolver) isAllowed(name string) bool {
+	if matchModule(r.denyList, name) {
+		return false
+	}
+	return r.allowList == nil || matchModule(r.allowList, name)
+}
+
+func (r *ModuleResolver) checkAllowed(name string) error {
+	if !r.isAllowed(name) {
+		return fmt.Errorf("%w: %s", ErrModuleNotAllowed, name)
+	}
+	return nil
+}
+
+// lazyModule is a native module whose globals are replaced by accessors until it's enabled.
+type lazyModule struct {
+	module   NativeModule
+	globals  []string
+	replaced map[string]goja.Value
+}
+
+// enableNative calls the Enable method of the native module, or defers it if the globals are enabled lazily.
+func (r *ModuleResolver) enableNative(name string, module NativeModule) {
+	provider, ok := module.(GlobalsProvider)
+	if r.lazy == nil || !ok {
+		module.Enable(r.runtime)
+		return
+	}
+	m := &lazyModule{
+		module:   module,
+		globals:  provider.Globals(),
+		replaced: make(map[string]goja.Value),
+	}
+	r.lazy[name] = m
+	global := r.runtime.GlobalObject()
+	for _, g := range m.globals {
+		g := g
+		getter := r.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			r.enableLazy(name)
+			return global.Get(g)
+		})
+		setter := r.runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+			m.replaced[g] = call.Argument(0)
+			global.Delete(g)
+			global.Set(g, call.Argument(0))
+			return goja.Undefined()
+		})
+		global.DefineAccessorProperty(g, getter, setter, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	}
+}
+
+// enableLazy enables the native module if it was deferred by enableNative(). The globals replaced by the scripts
+// are kept.
+func (r *ModuleResolver) enableLazy(name string) {
+	m := r.lazy[name]
+	if m == nil {
+		return
+	}
+	delete(r.lazy, name)
+	global := r.runtime.GlobalObject()
+	for _, g := range m.globals {
+		if _, replaced := m.replaced[g]; !replaced {
+			global.Delete(g)
+		}
+	}
+	m.module.Enable(r.runtime)
+	for g, value := range m.replaced {
+		global.Set(g, value)
+	}
+}
@@ -0,0 +1,221 @@
+package require
+
+import (
+	"path"
+	"sort"
+
+	"github.com/dop251/goja"
+)
+
+// esModule holds the namespace object of a module imported by an ES module or by import(). For ES modules
+// the promise is the result of the module evaluation; it is nil for CommonJS and native modules and while
+// the module body is being evaluated synchronously.
+type esModule struct {
+	namespace *goja.Object
+	promise   *goja.Promise
+}
+
+func (m *esModule) pending() bool {
+	return m.promise != nil && m.promise.State() == goja.PromiseStatePending
+}
+
+func (r *ModuleResolver) isESModule(p string) bool {
+	switch path.Ext(p) {
+	case ".mjs":
+		return true
+	case ".js":
+		return r.packageType(path.Dir(p)) == "module"
+	}
+	return false
+}
+
+func (r *ModuleResolver) newNamespace() *goja.Object {
+	ns := r.runtime.NewObject()
+	ns.SetPrototype(nil)
+	ns.DefineDataPropertySymbol(goja.SymToStringTag, r.runtime.ToValue("Module"), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	return ns
+}
+
+// getESModule returns the namespace record of a loaded module. CommonJS and native modules are exposed with
+// module.exports as the default export and its own enumerable properties as named exports.
+func (r *ModuleResolver) getESModule(module *goja.Object) *esModule {
+	if m := r.esModules[module]; m != nil {
+		return m
+	}
+	ns := r.newNamespace()
+	exports := module.Get("exports")
+	if obj, ok := exports.(*goja.Object); ok {
+		for _, key := range obj.Keys() {
+			if key != "default" {
+				ns.DefineDataProperty(key, obj.Get(key), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+			}
+		}
+	}
+	ns.DefineDataProperty("default", exports, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	m := &esModule{namespace: ns}
+	r.esModules[module] = m
+	return m
+}
+
+func (r *ModuleResolver) importModule(spec, base string, importer *goja.Object) (*esModule, error) {
+	module, err := r.resolveFrom(spec, base, true, nil)
+	if err != nil {
+		return nil, withParent(err, importer)
+	}
+	r.addDependent(module, importer)
+	return r.getESModule(module), nil
+}
+
+// evaluated returns a promise which is fulfilled with the module namespace once the module is evaluated.
+func (r *ModuleResolver) evaluated(m *esModule) (goja.Value, error) {
+	if m.promise == nil {
+		p, resolve, _ := r.runtime.NewPromise()
+		resolve(m.namespace)
+		return r.runtime.ToValue(p), nil
+	}
+	promise := r.runtime.ToValue(m.promise)
+	then, _ := goja.AssertFunction(promise.ToObject(r.runtime).Get("then"))
+	return then(promise, r.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		return m.namespace
+	}))
+}
+
+func (r *ModuleResolver) errorValue(err error) goja.Value {
+	if ex, ok := err.(*goja.Exception); ok {
+		return ex.Value()
+	}
+	return r.runtime.NewGoError(err)
+}
+
+func (r *ModuleResolver) dynamicImport(spec, base string, importer *goja.Object) *goja.Promise {
+	p, resolve, reject := r.runtime.NewPromise()
+	m, err := r.importModule(spec, base, importer)
+	if err == nil {
+		var ret goja.Value
+		if ret, err = r.evaluated(m); err == nil {
+			resolve(ret)
+		}
+	}
+	if err != nil {
+		reject(r.errorValue(err))
+	}
+	return p
+}
+
+func (r *ModuleResolver) throw(err error) {
+	if _, ok := err.(*goja.Exception); !ok {
+		panic(r.runtime.NewGoError(err))
+	}
+	panic(err)
+}
+
+// newModuleHelper creates the object passed to a module wrapper which implements import declarations,
+// export declarations, import() and import.meta of the module at the given path. m is nil for CommonJS
+// modules which can only use import().
+func (r *ModuleResolver) newModuleHelper(filename string, module *goja.Object, m *esModule) *goja.Object {
+	base := path.Dir(filename)
+	helper := r.runtime.NewObject()
+	helper.Set("import", func(call goja.FunctionCall) goja.Value {
+		return r.runtime.ToValue(r.dynamicImport(call.Argument(0).String(), base, module))
+	})
+	if m == nil {
+		return helper
+	}
+
+	helper.Set("load", func(call goja.FunctionCall) goja.Value {
+		dep, err := r.importModule(call.Argument(0).String(), base, module)
+		if err != nil {
+			r.throw(err)
+		}
+		if dep.pending() {
+			return goja.Null()
+		}
+		if dep.promise != nil && dep.promise.State() == goja.PromiseStateRejected {
+			panic(dep.promise.Result())
+		}
+		return dep.namespace
+	})
+	helper.Set("wait", func(call goja.FunctionCall) goja.Value {
+		dep, err := r.importModule(call.Argument(0).String(), base, module)
+		if err != nil {
+			r.throw(err)
+		}
+		ret, err := r.evaluated(dep)
+		if err != nil {
+			r.throw(err)
+		}
+		return ret
+	})
+	helper.Set("export", func(call goja.FunctionCall) goja.Value {
+		getters := call.Argument(0).ToObject(r.runtime)
+		for _, key := range getters.Keys() {
+			m.namespace.DefineAccessorProperty(key, getters.Get(key), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		}
+		return goja.Undefined()
+	})
+	helper.Set("star", func(call goja.FunctionCall) goja.Value {
+		dep := call.Argument(0).ToObject(r.runtime)
+		exported := make(map[string]bool)
+		for _, key := range m.namespace.Keys() {
+			exported[key] = true
+		}
+		keys := dep.Keys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			if key == "default" || exported[key] {
+				continue
+			}
+			key := key
+			getter := r.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+				return dep.Get(key)
+			})
+			m.namespace.DefineAccessorProperty(key, getter, nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		}
+		return goja.Undefined()
+	})
+
+	meta := r.runtime.NewObject()
+	url := filename
+	if path.IsAbs(filename) {
+		url = "file://" + filename
+	}
+	meta.Set("url", url)
+	meta.Set("filename", filename)
+	meta.Set("dirname", base)
+	helper.Set("meta", meta)
+	return helper
+}
+
+func (r *ModuleResolver) evaluateESModule(filename string, wrapper goja.Callable, module *goja.Object) error {
+	m := &esModule{namespace: r.newNamespace()}
+	module.Set("exports", m.namespace)
+	r.esModules[module] = m
+	ret, err := wrapper(goja.Undefined(), r.newModuleHelper(filename, module, m))
+	if err == nil {
+		m.promise = ret.Export().(*goja.Promise)
+		if m.promise.State() != goja.PromiseStateRejected {
+			return nil
+		}
+		// The rejection is turned into the error of require() or import, so it must not be reported as
+		// unhandled
+		promise := r.runtime.ToValue(m.promise)
+		then, _ := goja.AssertFunction(promise.ToObject(r.runtime).Get("then"))
+		_, err = then(promise, goja.Undefined(), r.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+			return goja.Undefined()
+		}))
+		if err == nil {
+			reason := m.promise.Result()
+			err = r.runtime.Try(func() {
+				panic(reason)
+			})
+		}
+	}
+	delete(r.esModules, module)
+	return err
+}
+
+// Import can be used to import modules from Go source, similar to the import() expression. The returned
+// promise is fulfilled with the module namespace once the module and its dependencies are evaluated.
+func (r *ModuleResolver) Import(p string) *goja.Promise {
+	return r.dynamicImport(p, r.getCurrentModulePath(), nil)
+}
@@ -0,0 +1,310 @@
+package require
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type esmTokenKind int
+
+const (
+	esmTokenIdent esmTokenKind = iota
+	esmTokenPunct
+	esmTokenString
+	esmTokenNumber
+	esmTokenTemplate
+	esmTokenRegexp
+)
+
+// esmToken is a lexical token of a module source. Only as much of the ECMAScript grammar is recognized as
+// needed to locate import and export declarations and to skip strings, comments, templates and regular
+// expressions safely.
+type esmToken struct {
+	kind       esmTokenKind
+	value      string
+	start, end int
+	depth      int  // bracket nesting level at which the token starts
+	nlBefore   bool // a line terminator precedes the token
+}
+
+func (t *esmToken) is(kind esmTokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+var esmPunctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=",
+	"/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+}
+
+// Keywords after which a slash starts a regular expression rather than a division.
+var esmRegexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+type esmScanner struct {
+	src    string
+	pos    int
+	stack  []byte // open brackets, '`' marks a template substitution
+	tokens []esmToken
+	nl     bool
+}
+
+func isIdentStart(r rune) bool {
+	return r == '$' || r == '_' || r == '\\' || r == '#' || unicode.IsLetter(r)
+}
+
+func isIdentPart(r rune) bool {
+	return r == '$' || r == '_' || r == '\\' || r == '\u200c' || r == '\u200d' ||
+		unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+func isLineTerminator(r rune) bool {
+	return r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029'
+}
+
+// tokenizeModule splits src into tokens. It never fails: malformed input produces a best-effort token stream
+// and the error is reported later by the parser.
+func tokenizeModule(src string) []esmToken {
+	s := &esmScanner{src: src}
+	if strings.HasPrefix(src, "#!") {
+		s.skipLine()
+	}
+	for s.scan() {
+	}
+	return s.tokens
+}
+
+func (s *esmScanner) peek(offset int) byte {
+	if s.pos+offset < len(s.src) {
+		return s.src[s.pos+offset]
+	}
+	return 0
+}
+
+func (s *esmScanner) skipLine() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if isLineTerminator(r) {
+			return
+		}
+		s.pos += size
+	}
+}
+
+func (s *esmScanner) skipSpace() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		switch {
+		case isLineTerminator(r):
+			s.nl = true
+			s.pos += size
+		case r == ' ' || r == '\t' || r == '\v' || r == '\f' || r == '\ufeff' || unicode.IsSpace(r):
+			s.pos += size
+		case r == '/' && s.peek(1) == '/':
+			s.skipLine()
+		case r == '/' && s.peek(1) == '*':
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end < 0 {
+				end = len(s.src) - s.pos - 2
+			}
+			comment := s.src[s.pos : s.pos+2+end]
+			if strings.ContainsAny(comment, "\n\r\u2028\u2029") {
+				s.nl = true
+			}
+			s.pos += 2 + end + 2
+			if s.pos > len(s.src) {
+				s.pos = len(s.src)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *esmScanner) regexpAllowed() bool {
+	if len(s.tokens) == 0 {
+		return true
+	}
+	prev := &s.tokens[len(s.tokens)-1]
+	switch prev.kind {
+	case esmTokenIdent:
+		return esmRegexpKeywords[prev.value]
+	case esmTokenPunct:
+		switch prev.value {
+		case ")", "]", "++", "--":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (s *esmScanner) emit(kind esmTokenKind, start int) {
+	s.tokens = append(s.tokens, esmToken{
+		kind:     kind,
+		value:    s.src[start:s.pos],
+		start:    start,
+		end:      s.pos,
+		depth:    len(s.stack),
+		nlBefore: s.nl,
+	})
+	s.nl = false
+}
+
+func (s *esmScanner) scan() bool {
+	s.skipSpace()
+	if s.pos >= len(s.src) {
+		return false
+	}
+	start := s.pos
+	r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+	switch {
+	case isIdentStart(r):
+		s.pos += size
+		for s.pos < len(s.src) {
+			r, size = utf8.DecodeRuneInString(s.src[s.pos:])
+			if !isIdentPart(r) {
+				break
+			}
+			s.pos += size
+		}
+		s.emit(esmTokenIdent, start)
+	case r >= '0' && r <= '9' || r == '.' && s.peek(1) >= '0' && s.peek(1) <= '9':
+		s.scanNumber()
+		s.emit(esmTokenNumber, start)
+	case r == '\'' || r == '"':
+		s.scanString(s.src[s.pos])
+		s.emit(esmTokenString, start)
+	case r == '`':
+		s.pos++
+		s.scanTemplate(start)
+	case r == '/' && s.regexpAllowed():
+		s.scanRegexp()
+		s.emit(esmTokenRegexp, start)
+	case r == '{' || r == '(' || r == '[':
+		s.pos++
+		s.emit(esmTokenPunct, start)
+		s.stack = append(s.stack, byte(r))
+	case r == '}' || r == ')' || r == ']':
+		if len(s.stack) > 0 {
+			top := s.stack[len(s.stack)-1]
+			s.stack = s.stack[:len(s.stack)-1]
+			if top == '`' && r == '}' {
+				s.pos++
+				s.scanTemplate(start)
+				return true
+			}
+		}
+		s.pos++
+		s.emit(esmTokenPunct, start)
+	default:
+		for _, p := range esmPunctuators {
+			if strings.HasPrefix(s.src[s.pos:], p) {
+				s.pos += len(p)
+				s.emit(esmTokenPunct, start)
+				return true
+			}
+		}
+		s.pos += size
+		s.emit(esmTokenPunct, start)
+	}
+	return true
+}
+
+func (s *esmScanner) scanNumber() {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '.' {
+			s.pos++
+			if (c == 'e' || c == 'E') && (s.peek(0) == '+' || s.peek(0) == '-') {
+				s.pos++
+			}
+			continue
+		}
+		break
+	}
+}
+
+func (s *esmScanner) scanString(quote byte) {
+	s.pos++
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+			continue
+		case quote:
+			s.pos++
+			return
+		case '\n', '\r':
+			return
+		}
+		s.pos++
+	}
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+}
+
+// scanTemplate scans a template literal part starting right after the backtick or the closing brace of a
+// substitution. If the part ends with a substitution the template is remembered on the bracket stack.
+func (s *esmScanner) scanTemplate(start int) {
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+			continue
+		case '`':
+			s.pos++
+			s.emit(esmTokenTemplate, start)
+			return
+		case '$':
+			if s.peek(1) == '{' {
+				s.pos += 2
+				s.emit(esmTokenTemplate, start)
+				s.stack = append(s.stack, '`')
+				return
+			}
+		}
+		s.pos++
+	}
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+	s.emit(esmTokenTemplate, start)
+}
+
+func (s *esmScanner) scanRegexp() {
+	s.pos++
+	inClass := false
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\\':
+			s.pos += 2
+			continue
+		case c == '\n' || c == '\r':
+			return
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			s.pos++
+			for s.pos < len(s.src) {
+				r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+				if !isIdentPart(r) {
+					break
+				}
+				s.pos += size
+			}
+			return
+		}
+		s.pos++
+	}
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+}
@@ -0,0 +1,471 @@
+package require
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+const esmBodyPrefix = "(async function() {"
+
+// esmScope is a scope of the module body holding the names which shadow the imported bindings.
+type esmScope struct {
+	parent *esmScope
+	names  map[string]bool
+}
+
+func (s *esmScope) declare(names ...string) {
+	for _, name := range names {
+		s.names[name] = true
+	}
+}
+
+func (s *esmScope) shadows(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type esmEdit struct {
+	start, end int
+	text       string
+}
+
+// esmBindings rewrites the references to the imported bindings in the module body into reads of the module
+// namespace objects, which keeps the bindings live and allows circular imports to be used once the imported
+// module is evaluated.
+type esmBindings struct {
+	src     string
+	imports map[string]string
+	edits   []esmEdit
+}
+
+// rewriteImports returns the body with the references to the imported names replaced by their access
+// expressions. The body is returned unchanged if it cannot be parsed; the syntax error is then reported
+// when the module is compiled.
+func rewriteImports(body string, imports map[string]string) string {
+	if len(imports) == 0 {
+		return body
+	}
+	src := esmBodyPrefix + body + "\n})"
+	prg, err := parser.ParseFile(nil, "", src, 0)
+	if err != nil || len(prg.Body) != 1 {
+		return body
+	}
+	stmt, ok := prg.Body[0].(*ast.ExpressionStatement)
+	if !ok {
+		return body
+	}
+	fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		return body
+	}
+	b := &esmBindings{src: src, imports: imports}
+	b.function(fn, nil)
+	if len(b.edits) == 0 {
+		return body
+	}
+	sort.Slice(b.edits, func(i, j int) bool { return b.edits[i].start < b.edits[j].start })
+	var sb strings.Builder
+	last := 0
+	for _, e := range b.edits {
+		sb.WriteString(body[last:e.start])
+		sb.WriteString(e.text)
+		last = e.end
+	}
+	sb.WriteString(body[last:])
+	return sb.String()
+}
+
+// access returns the access expression of the identifier if it refers to an imported binding. The second
+// return value is the offset of the identifier in the body.
+func (b *esmBindings) access(id *ast.Identifier, scope *esmScope) (string, int, bool) {
+	name := id.Name.String()
+	access, exists := b.imports[name]
+	if !exists || scope.shadows(name) {
+		return "", 0, false
+	}
+	// The file indexes are 1-based
+	start := int(id.Idx) - 1 - len(esmBodyPrefix)
+	// Identifiers written with escape sequences are left alone
+	if pos := int(id.Idx) - 1; start < 0 || pos+len(name) > len(b.src) || b.src[pos:pos+len(name)] != name {
+		return "", 0, false
+	}
+	return access, start, true
+}
+
+func (b *esmBindings) replace(id *ast.Identifier, scope *esmScope, format func(access string) string) {
+	if access, start, ok := b.access(id, scope); ok {
+		b.edits = append(b.edits, esmEdit{start: start, end: start + len(id.Name.String()), text: format(access)})
+	}
+}
+
+func newScope(parent *esmScope) *esmScope {
+	return &esmScope{parent: parent, names: make(map[string]bool)}
+}
+
+func (b *esmBindings) function(fn *ast.FunctionLiteral, parent *esmScope) {
+	scope := newScope(parent)
+	if fn.Name != nil {
+		scope.declare(fn.Name.Name.String())
+	}
+	b.params(fn.ParameterList, fn.DeclarationList, scope)
+	if fn.Body != nil {
+		b.statements(fn.Body.List, scope)
+	}
+}
+
+func (b *esmBindings) params(params *ast.ParameterList, vars []*ast.VariableDeclaration, scope *esmScope) {
+	for _, decl := range vars {
+		for _, binding := range decl.List {
+			scope.declare(bindingNames(binding.Target, nil)...)
+		}
+	}
+	if params == nil {
+		return
+	}
+	for _, binding := range params.List {
+		scope.declare(bindingNames(binding.Target, nil)...)
+	}
+	if params.Rest != nil {
+		scope.declare(bindingNames(params.Rest, nil)...)
+	}
+	for _, binding := range params.List {
+		b.binding(binding, scope)
+	}
+	if params.Rest != nil {
+		b.target(params.Rest, scope)
+	}
+}
+
+// bindingNames appends the names bound by the binding target.
+func bindingNames(target ast.Expression, names []string) []string {
+	switch target := target.(type) {
+	case *ast.Identifier:
+		return append(names, target.Name.String())
+	case *ast.AssignExpression:
+		return bindingNames(target.Left, names)
+	case *ast.ObjectPattern:
+		for _, prop := range target.Properties {
+			switch prop := prop.(type) {
+			case *ast.PropertyShort:
+				names = append(names, prop.Name.Name.String())
+			case *ast.PropertyKeyed:
+				names = bindingNames(prop.Value, names)
+			}
+		}
+		return bindingNames(target.Rest, names)
+	case *ast.ArrayPattern:
+		for _, element := range target.Elements {
+			names = bindingNames(element, names)
+		}
+		return bindingNames(target.Rest, names)
+	}
+	return names
+}
+
+// lexicalNames returns the names declared by the let, const, class and function declarations of the
+// statement list.
+func lexicalNames(list []ast.Statement) []string {
+	var names []string
+	for _, stmt := range list {
+		switch stmt := stmt.(type) {
+		case *ast.LexicalDeclaration:
+			for _, binding := range stmt.List {
+				names = bindingNames(binding.Target, names)
+			}
+		case *ast.FunctionDeclaration:
+			if stmt.Function.Name != nil {
+				names = append(names, stmt.Function.Name.Name.String())
+			}
+		case *ast.ClassDeclaration:
+			if stmt.Class.Name != nil {
+				names = append(names, stmt.Class.Name.Name.String())
+			}
+		}
+	}
+	return names
+}
+
+func (b *esmBindings) statements(list []ast.Statement, scope *esmScope) {
+	scope.declare(lexicalNames(list)...)
+	for _, stmt := range list {
+		b.statement(stmt, scope)
+	}
+}
+
+func (b *esmBindings) statement(stmt ast.Statement, scope *esmScope) {
+	switch stmt := stmt.(type) {
+	case *ast.BlockStatement:
+		b.statements(stmt.List, newScope(scope))
+	case *ast.ExpressionStatement:
+		b.expression(stmt.Expression, scope)
+	case *ast.IfStatement:
+		b.expression(stmt.Test, scope)
+		b.statement(stmt.Consequent, scope)
+		b.statement(stmt.Alternate, scope)
+	case *ast.DoWhileStatement:
+		b.statement(stmt.Body, scope)
+		b.expression(stmt.Test, scope)
+	case *ast.WhileStatement:
+		b.expression(stmt.Test, scope)
+		b.statement(stmt.Body, scope)
+	case *ast.WithStatement:
+		b.expression(stmt.Object, scope)
+		b.statement(stmt.Body, scope)
+	case *ast.ReturnStatement:
+		b.expression(stmt.Argument, scope)
+	case *ast.ThrowStatement:
+		b.expression(stmt.Argument, scope)
+	case *ast.LabelledStatement:
+		b.statement(stmt.Statement, scope)
+	case *ast.SwitchStatement:
+		b.expression(stmt.Discriminant, scope)
+		inner := newScope(scope)
+		for _, c := range stmt.Body {
+			inner.declare(lexicalNames(c.Consequent)...)
+		}
+		for _, c := range stmt.Body {
+			b.expression(c.Test, inner)
+			for _, s := range c.Consequent {
+				b.statement(s, inner)
+			}
+		}
+	case *ast.TryStatement:
+		b.statement(stmt.Body, scope)
+		if stmt.Catch != nil {
+			inner := newScope(scope)
+			inner.declare(bindingNames(stmt.Catch.Parameter, nil)...)
+			b.target(stmt.Catch.Parameter, inner)
+			b.statement(stmt.Catch.Body, inner)
+		}
+		if stmt.Finally != nil {
+			b.statement(stmt.Finally, scope)
+		}
+	case *ast.VariableStatement:
+		for _, binding := range stmt.List {
+			b.binding(binding, scope)
+		}
+	case *ast.LexicalDeclaration:
+		for _, binding := range stmt.List {
+			b.binding(binding, scope)
+		}
+	case *ast.FunctionDeclaration:
+		b.function(stmt.Function, scope)
+	case *ast.ClassDeclaration:
+		b.class(stmt.Class, scope)
+	case *ast.ForStatement:
+		inner := newScope(scope)
+		switch init := stmt.Initializer.(type) {
+		case *ast.ForLoopInitializerExpression:
+			b.expression(init.Expression, inner)
+		case *ast.ForLoopInitializerVarDeclList:
+			for _, binding := range init.List {
+				b.binding(binding, inner)
+			}
+		case *ast.ForLoopInitializerLexicalDecl:
+			for _, binding := range init.LexicalDeclaration.List {
+				inner.declare(bindingNames(binding.Target, nil)...)
+			}
+			for _, binding := range init.LexicalDeclaration.List {
+				b.binding(binding, inner)
+			}
+		}
+		b.expression(stmt.Test, inner)
+		b.expression(stmt.Update, inner)
+		b.statement(stmt.Body, inner)
+	case *ast.ForInStatement:
+		b.forInto(stmt.Into, stmt.Source, stmt.Body, scope)
+	case *ast.ForOfStatement:
+		b.forInto(stmt.Into, stmt.Source, stmt.Body, scope)
+	}
+}
+
+func (b *esmBindings) forInto(into ast.ForInto, source ast.Expression, body ast.Statement, scope *esmScope) {
+	inner := newScope(scope)
+	switch into := into.(type) {
+	case *ast.ForIntoVar:
+		b.binding(into.Binding, inner)
+	case *ast.ForDeclaration:
+		inner.declare(bindingNames(into.Target, nil)...)
+		b.target(into.Target, inner)
+	case *ast.ForIntoExpression:
+		b.expression(into.Expression, inner)
+	}
+	b.expression(source, inner)
+	b.statement(body, inner)
+}
+
+func (b *esmBindings) binding(binding *ast.Binding, scope *esmScope) {
+	b.target(binding.Target, scope)
+	b.expression(binding.Initializer, scope)
+}
+
+// target visits the default values and the computed keys of a binding pattern.
+func (b *esmBindings) target(target ast.Expression, scope *esmScope) {
+	switch target := target.(type) {
+	case *ast.AssignExpression:
+		b.target(target.Left, scope)
+		b.expression(target.Right, scope)
+	case *ast.ObjectPattern:
+		for _, prop := range target.Properties {
+			switch prop := prop.(type) {
+			case *ast.PropertyShort:
+				b.expression(prop.Initializer, scope)
+			case *ast.PropertyKeyed:
+				if prop.Computed {
+					b.expression(prop.Key, scope)
+				}
+				b.target(prop.Value, scope)
+			}
+		}
+		b.target(target.Rest, scope)
+	case *ast.ArrayPattern:
+		for _, element := range target.Elements {
+			b.target(element, scope)
+		}
+		b.target(target.Rest, scope)
+	}
+}
+
+func (b *esmBindings) class(class *ast.ClassLiteral, parent *esmScope) {
+	scope := newScope(parent)
+	if class.Name != nil {
+		scope.declare(class.Name.Name.String())
+	}
+	b.expression(class.SuperClass, scope)
+	for _, element := range class.Body {
+		switch element := element.(type) {
+		case *ast.FieldDefinition:
+			if element.Computed {
+				b.expression(element.Key, scope)
+			}
+			b.expression(element.Initializer, scope)
+		case *ast.MethodDefinition:
+			if element.Computed {
+				b.expression(element.Key, scope)
+			}
+			b.function(element.Body, scope)
+		case *ast.ClassStaticBlock:
+			inner := newScope(scope)
+			b.params(nil, element.DeclarationList, inner)
+			b.statements(element.Block.List, inner)
+		}
+	}
+}
+
+// callee visits the callee of a call, an imported function is called with an undefined this value.
+func (b *esmBindings) callee(callee ast.Expression, scope *esmScope) {
+	if id, ok := callee.(*ast.Identifier); ok {
+		b.replace(id, scope, func(access string) string { return "(0, " + access + ")" })
+		return
+	}
+	b.expression(callee, scope)
+}
+
+func (b *esmBindings) expression(expr ast.Expression, scope *esmScope) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		b.replace(expr, scope, func(access string) string { return access })
+	case *ast.ArrayLiteral:
+		for _, v := range expr.Value {
+			b.expression(v, scope)
+		}
+	case *ast.ArrayPattern:
+		for _, v := range expr.Elements {
+			b.expression(v, scope)
+		}
+		b.expression(expr.Rest, scope)
+	case *ast.ObjectLiteral:
+		b.properties(expr.Value, scope)
+	case *ast.ObjectPattern:
+		b.properties(expr.Properties, scope)
+		b.expression(expr.Rest, scope)
+	case *ast.AssignExpression:
+		b.expression(expr.Left, scope)
+		b.expression(expr.Right, scope)
+	case *ast.BinaryExpression:
+		b.expression(expr.Left, scope)
+		b.expression(expr.Right, scope)
+	case *ast.ConditionalExpression:
+		b.expression(expr.Test, scope)
+		b.expression(expr.Consequent, scope)
+		b.expression(expr.Alternate, scope)
+	case *ast.SequenceExpression:
+		for _, e := range expr.Sequence {
+			b.expression(e, scope)
+		}
+	case *ast.UnaryExpression:
+		b.expression(expr.Operand, scope)
+	case *ast.AwaitExpression:
+		b.expression(expr.Argument, scope)
+	case *ast.YieldExpression:
+		b.expression(expr.Argument, scope)
+	case *ast.SpreadElement:
+		b.expression(expr.Expression, scope)
+	case *ast.Optional:
+		b.expression(expr.Expression, scope)
+	case *ast.OptionalChain:
+		b.expression(expr.Expression, scope)
+	case *ast.DotExpression:
+		b.expression(expr.Left, scope)
+	case *ast.PrivateDotExpression:
+		b.expression(expr.Left, scope)
+	case *ast.BracketExpression:
+		b.expression(expr.Left, scope)
+		b.expression(expr.Member, scope)
+	case *ast.CallExpression:
+		b.callee(expr.Callee, scope)
+		for _, arg := range expr.ArgumentList {
+			b.expression(arg, scope)
+		}
+	case *ast.NewExpression:
+		b.expression(expr.Callee, scope)
+		for _, arg := range expr.ArgumentList {
+			b.expression(arg, scope)
+		}
+	case *ast.TemplateLiteral:
+		if expr.Tag != nil {
+			b.callee(expr.Tag, scope)
+		}
+		for _, e := range expr.Expressions {
+			b.expression(e, scope)
+		}
+	case *ast.FunctionLiteral:
+		b.function(expr, scope)
+	case *ast.ArrowFunctionLiteral:
+		inner := newScope(scope)
+		b.params(expr.ParameterList, expr.DeclarationList, inner)
+		switch body := expr.Body.(type) {
+		case *ast.BlockStatement:
+			b.statements(body.List, inner)
+		case *ast.ExpressionBody:
+			b.expression(body.Expression, inner)
+		}
+	case *ast.ClassLiteral:
+		b.class(expr, scope)
+	}
+}
+
+func (b *esmBindings) properties(props []ast.Property, scope *esmScope) {
+	for _, prop := range props {
+		switch prop := prop.(type) {
+		case *ast.PropertyShort:
+			name := &prop.Name
+			b.replace(name, scope, func(access string) string { return name.Name.String() + ": " + access })
+			b.expression(prop.Initializer, scope)
+		case *ast.PropertyKeyed:
+			if prop.Computed {
+				b.expression(prop.Key, scope)
+			}
+			b.expression(prop.Value, scope)
+		case *ast.SpreadElement:
+			b.expression(prop.Expression, scope)
+		}
+	}
+}
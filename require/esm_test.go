@@ -0,0 +1,229 @@
+package require
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestESModuleImportExport(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"main.mjs": `
+		import def, { a, b as bee, "c-d" as cd } from "./lib.mjs";
+		import * as lib from "./lib.mjs";
+		import cjs, { name } from "./cjs.js";
+		export { reexported } from "./lib.mjs";
+		export * from "./more.mjs";
+		export * as more from "./more.mjs";
+
+		export const sum = a + bee + cd;
+		export let counter = 0, { x, y: [why] } = { x: 1, y: [2] };
+		export function inc() { counter++; }
+		export default class {
+			get value() { return def(); }
+		}
+		export const ns = lib;
+		export const cjsName = cjs.name + name;
+		`,
+		"lib.mjs": `
+		const a = 1, b = 2, cd = 3;
+		export { a, b, cd as "c-d", a as reexported };
+		export default function() { return "default"; }
+		`,
+		"more.mjs": `export var extra = "extra"; export default "ignored";`,
+		"cjs.js":   `exports.name = "cjs";`,
+	})))
+	rr := r.Enable(vm)
+	v, err := rr.Require("./main.mjs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.Set("m", v)
+	res, err := vm.RunString(`
+	m.inc();
+	[
+		m.sum, m.counter, m.x, m.why, new m.default().value, m.reexported, m.extra, m.more.extra,
+		m.ns.a, m.cjsName, Object.prototype.toString.call(m), Object.keys(m).sort().join()
+	].join("|");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "6|1|1|2|default|1|extra|extra|1|cjscjs|[object Module]|cjsName,counter,default,extra,inc,more,ns,reexported,sum,why,x"
+	if s := res.String(); s != expected {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestESModuleLiveBindings(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"main.mjs": `
+		import { count, inc, self } from "./counter.mjs";
+		import * as counter from "./counter.mjs";
+		import fn from "./fn.mjs";
+		import Cls from "./cls.mjs";
+		export { count };
+		inc();
+		function shadowed(count) { return count; }
+		const obj = { count, inc };
+		obj.inc();
+		export const result = [
+			count, counter.count, shadowed(5), obj.count, self(), fn.name, Cls.name, fn(),
+			(() => { let inc = 1; return inc; })(), typeof count, ` + "`${count}`" + `
+		].join();
+		`,
+		"counter.mjs": `
+		export let count = 0;
+		export function inc() { count++; }
+		export function self() { return this === undefined; }
+		`,
+		"fn.mjs":  `export default function () { return "fn"; }`,
+		"cls.mjs": `export default class {}`,
+	})))
+	rr := r.Enable(vm)
+	v, err := rr.Require("./main.mjs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(*goja.Object)
+	if s := m.Get("result").String(); s != "2,2,5,1,true,default,default,fn,1,number,2" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if c := m.Get("count").Export(); c != int64(2) {
+		t.Fatalf("Unexpected re-exported count: %v", c)
+	}
+}
+
+func TestESModuleCircularImports(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"a.mjs": `
+		import { getX } from "./b.mjs";
+		export const x = "x from a";
+		export const result = getX();
+		`,
+		"b.mjs": `
+		import { x } from "./a.mjs";
+		export function getX() { return x; }
+		`,
+	})))
+	rr := r.Enable(vm)
+	v, err := rr.Require("./a.mjs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.(*goja.Object).Get("result").String(); s != "x from a" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestESModulePackageType(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"/app/package.json":              `{"type": "module"}`,
+		"/app/main.js":                   `import { name } from "pkg"; export const value = name;`,
+		"/app/node_modules/pkg/index.js": `exports.name = "commonjs package";`,
+	})))
+	rr := r.Enable(vm)
+	v, err := rr.Require("/app/main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.ToObject(vm).Get("value").String(); s != "commonjs package" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestESModuleDynamicImport(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"dir/m.mjs": `export const url = import.meta.url; export const obj = { import() { return "method"; } };`,
+		"main.js": `
+		exports.result = [];
+		import("./dir/m.mjs").then(m => exports.result.push(m.url, m.obj.import()));
+		import("./missing.mjs").catch(e => exports.result.push("rejected"));
+		`,
+	})))
+	rr := r.Enable(vm)
+	v, err := rr.Require("./main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.ToObject(vm).Get("result").String(); s != "rejected,dir/m.mjs,method" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestESModuleTopLevelAwait(t *testing.T) {
+	vm := goja.New()
+	var resolve func(interface{})
+	vm.Set("later", func() *goja.Promise {
+		var p *goja.Promise
+		p, resolve, _ = vm.NewPromise()
+		return p
+	})
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"tla.mjs":  `export const value = await later();`,
+		"main.mjs": `import { value } from "./tla.mjs"; export const result = "got " + value;`,
+	})))
+	rr := r.Enable(vm)
+
+	if _, err := rr.Require("./tla.mjs"); !errors.Is(err, ErrAsyncModule) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p := rr.Import("./main.mjs")
+	if p.State() != goja.PromiseStatePending {
+		t.Fatalf("Unexpected state: %v", p.State())
+	}
+	resolve("passed")
+	if _, err := vm.RunString(""); err != nil { // drain the job queue
+		t.Fatal(err)
+	}
+	if p.State() != goja.PromiseStateFulfilled {
+		t.Fatalf("Unexpected state: %v", p.State())
+	}
+	if s := p.Result().ToObject(vm).Get("result").String(); s != "got passed" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestESModuleErrors(t *testing.T) {
+	vm := goja.New()
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(map[string]string{
+		"throws.mjs":  "\n\nthrow new Error('test passed');",
+		"invalid.mjs": "\nexport garbage;",
+	})))
+	rr := r.Enable(vm)
+	_, err := rr.Require("./throws.mjs")
+	if ex, ok := err.(*goja.Exception); !ok || !strings.Contains(ex.Error(), "test passed") ||
+		!strings.Contains(ex.String(), "throws.mjs:3:") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = rr.Require("./invalid.mjs")
+	if err == nil || !strings.Contains(err.Error(), "invalid.mjs: Line 2:8") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestTransformModule(t *testing.T) {
+	for i, tc := range []struct {
+		src, expected string
+	}{
+		{"const re = /import('x')/; `${import('y')}`", "const re = /import('x')/; `${__goja_esm.import('y')}`"},
+		{"// import('x')\nobj.import('y')", "// import('x')\nobj.import('y')"},
+		{"class A { import(x) { return x; } }", "class A { import(x) { return x; } }"},
+		{"a = b / import('c') / d", "a = b / __goja_esm.import('c') / d"},
+	} {
+		res, err := transformModule("test.js", tc.src, false)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if res != tc.expected {
+			t.Errorf("%d: got %q, expected %q", i, res, tc.expected)
+		}
+	}
+}
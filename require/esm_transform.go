@@ -0,0 +1,552 @@
+package require
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	esmHelperName  = "__goja_esm"
+	esmModuleVar   = "__goja_esm_m"
+	esmDefaultName = "__goja_esm_default"
+)
+
+// esmTransformer rewrites an ES module into the body of an async function which is evaluated by the
+// ModuleResolver. Import declarations are hoisted into a prologue which loads the dependencies before the
+// module body runs, and export declarations are replaced by accessors on the module namespace object, so
+// exported bindings stay live. The references to imported bindings are replaced by reads of the namespace
+// objects of the imported modules, so imported bindings are live too. Line numbers of the original source
+// are preserved.
+type esmTransformer struct {
+	name   string
+	src    string
+	toks   []esmToken
+	module bool
+
+	body     strings.Builder
+	last     int
+	exports  map[string]string
+	imports  map[string]string
+	prologue strings.Builder
+	modVars  map[string]string
+}
+
+var esmEOF = &esmToken{kind: esmTokenPunct}
+
+// transformModule rewrites the source of an ES module (module == true) or the dynamic import() expressions of
+// a CommonJS module so that the result can be compiled by goja.
+func transformModule(name, src string, module bool) (string, error) {
+	if !needsModuleTransform(src, module) {
+		return src, nil
+	}
+	t := &esmTransformer{
+		name:    name,
+		src:     src,
+		toks:    tokenizeModule(src),
+		module:  module,
+		exports: make(map[string]string),
+		imports: make(map[string]string),
+		modVars: make(map[string]string),
+	}
+	if err := t.transform(); err != nil {
+		return "", err
+	}
+	return t.String(), nil
+}
+
+// needsModuleTransform reports whether transformModule changes the source. CommonJS modules are only
+// transformed if they may contain import() expressions.
+func needsModuleTransform(src string, module bool) bool {
+	return module || strings.Contains(src, "import")
+}
+
+func (t *esmTransformer) at(i int) *esmToken {
+	if i < len(t.toks) {
+		return &t.toks[i]
+	}
+	return esmEOF
+}
+
+func (t *esmTransformer) errorAt(tok *esmToken, msg string) error {
+	offset := tok.start
+	if tok == esmEOF {
+		offset = len(t.src)
+	}
+	line := strings.Count(t.src[:offset], "\n") + 1
+	col := offset - strings.LastIndex(t.src[:offset], "\n")
+	return fmt.Errorf("%s: Line %d:%d %s", t.name, line, col, msg)
+}
+
+func (t *esmTransformer) replace(start, end int, text string) {
+	t.body.WriteString(t.src[t.last:start])
+	t.body.WriteString(text)
+	t.last = end
+}
+
+// blank removes the source between start and end keeping the line terminators.
+func (t *esmTransformer) blank(start, end int) {
+	t.replace(start, end, strings.Repeat("\n", strings.Count(t.src[start:end], "\n")))
+}
+
+func (t *esmTransformer) String() string {
+	if !t.module {
+		t.body.WriteString(t.src[t.last:])
+		return t.body.String()
+	}
+	var b strings.Builder
+	b.WriteString(`"use strict";`)
+	if len(t.exports) > 0 {
+		names := make([]string, 0, len(t.exports))
+		for name := range t.exports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString(esmHelperName + ".export({")
+		for i, name := range names {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			local := t.exports[name]
+			if access, imported := t.imports[local]; imported {
+				local = access
+			}
+			b.WriteString(strconv.Quote(name) + ": () => " + local)
+		}
+		b.WriteString("});")
+	}
+	b.WriteString(t.prologue.String())
+	b.WriteString(rewriteImports(t.body.String()+t.src[t.last:], t.imports))
+	return b.String()
+}
+
+func (t *esmTransformer) transform() error {
+	if t.module && strings.HasPrefix(t.src, "#!") {
+		end := strings.IndexAny(t.src, "\r\n")
+		if end < 0 {
+			end = len(t.src)
+		}
+		t.blank(0, end)
+	}
+	for i := 0; i < len(t.toks); {
+		tok := &t.toks[i]
+		if tok.kind != esmTokenIdent || (tok.value != "import" && tok.value != "export") ||
+			i > 0 && (t.toks[i-1].is(esmTokenPunct, ".") || t.toks[i-1].is(esmTokenPunct, "?.")) {
+			i++
+			continue
+		}
+		next := t.at(i + 1)
+		var err error
+		switch {
+		case tok.value == "import" && next.is(esmTokenPunct, "("):
+			// A method named import is followed by its body.
+			if !t.at(t.matching(i+1)+1).is(esmTokenPunct, "{") {
+				t.replace(tok.start, tok.end, esmHelperName+".import")
+			}
+			i++
+		case tok.value == "import" && next.is(esmTokenPunct, "."):
+			if t.module && t.at(i+2).is(esmTokenIdent, "meta") {
+				t.replace(tok.start, t.at(i+2).end, esmHelperName+".meta")
+			}
+			i += 2
+		case !t.module || tok.depth != 0:
+			i++
+		case tok.value == "import":
+			i, err = t.parseImport(i)
+		default:
+			i, err = t.parseExport(i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matching returns the index of the bracket closing the one at index i.
+func (t *esmTransformer) matching(i int) int {
+	depth := t.toks[i].depth
+	for i++; i < len(t.toks); i++ {
+		if t.toks[i].depth == depth && t.toks[i].kind == esmTokenPunct {
+			switch t.toks[i].value {
+			case "}", ")", "]":
+				return i
+			}
+		}
+	}
+	return i
+}
+
+func (t *esmTransformer) moduleVar(spec string) string {
+	if v, exists := t.modVars[spec]; exists {
+		return v
+	}
+	v := esmModuleVar + strconv.Itoa(len(t.modVars))
+	t.modVars[spec] = v
+	fmt.Fprintf(&t.prologue, "const %s = %s.load(%s) ?? await %s.wait(%s);", v, esmHelperName, spec, esmHelperName, spec)
+	return v
+}
+
+func (t *esmTransformer) export(name, local string) {
+	t.exports[name] = local
+}
+
+func exportedName(tok *esmToken) string {
+	if tok.kind == esmTokenString {
+		if s, err := strconv.Unquote(`"` + tok.value[1:len(tok.value)-1] + `"`); err == nil {
+			return s
+		}
+		return tok.value[1 : len(tok.value)-1]
+	}
+	return tok.value
+}
+
+func memberAccess(object string, tok *esmToken) string {
+	if tok.kind == esmTokenString {
+		return object + "[" + tok.value + "]"
+	}
+	return object + "." + tok.value
+}
+
+// parseFrom parses the module specifier following the from keyword and the optional import attributes.
+// It returns the index of the token after the declaration.
+func (t *esmTransformer) parseFrom(j int, spec **esmToken) (int, error) {
+	if !t.at(j).is(esmTokenIdent, "from") {
+		return j, t.errorAt(t.at(j), "Unexpected token, expected from")
+	}
+	return t.parseSpecifier(j+1, spec)
+}
+
+func (t *esmTransformer) parseSpecifier(j int, spec **esmToken) (int, error) {
+	if t.at(j).kind != esmTokenString {
+		return j, t.errorAt(t.at(j), "Unexpected token, expected a module specifier")
+	}
+	*spec = t.at(j)
+	j++
+	if tok := t.at(j); (tok.is(esmTokenIdent, "with") || tok.is(esmTokenIdent, "assert")) &&
+		!tok.nlBefore && t.at(j+1).is(esmTokenPunct, "{") {
+		j = t.matching(j+1) + 1
+	}
+	if t.at(j).is(esmTokenPunct, ";") {
+		j++
+	}
+	return j, nil
+}
+
+// parseSpecifierList parses a brace-enclosed list of import or export specifiers, each being a name
+// optionally followed by an alias.
+func (t *esmTransformer) parseSpecifierList(j int, fn func(name, alias *esmToken)) (int, error) {
+	for j++; !t.at(j).is(esmTokenPunct, "}"); {
+		name := t.at(j)
+		if name.kind != esmTokenIdent && name.kind != esmTokenString {
+			return j, t.errorAt(name, "Unexpected token "+name.value)
+		}
+		alias := name
+		j++
+		if t.at(j).is(esmTokenIdent, "as") {
+			alias = t.at(j + 1)
+			if alias.kind != esmTokenIdent && alias.kind != esmTokenString {
+				return j, t.errorAt(alias, "Unexpected token "+alias.value)
+			}
+			j += 2
+		}
+		fn(name, alias)
+		if t.at(j).is(esmTokenPunct, ",") {
+			j++
+		} else if !t.at(j).is(esmTokenPunct, "}") {
+			return j, t.errorAt(t.at(j), "Unexpected token "+t.at(j).value)
+		}
+	}
+	return j + 1, nil
+}
+
+func (t *esmTransformer) parseImport(i int) (int, error) {
+	var (
+		spec     *esmToken
+		bindings [][2]string
+		err      error
+	)
+	j := i + 1
+	if t.at(j).kind == esmTokenString {
+		j, err = t.parseSpecifier(j, &spec)
+	} else {
+		if tok := t.at(j); tok.kind == esmTokenIdent {
+			bindings = append(bindings, [2]string{tok.value, ".default"})
+			j++
+			if t.at(j).is(esmTokenPunct, ",") {
+				j++
+			}
+		}
+		switch tok := t.at(j); {
+		case tok.is(esmTokenPunct, "*"):
+			local := t.at(j + 2)
+			if !t.at(j+1).is(esmTokenIdent, "as") || local.kind != esmTokenIdent {
+				return j, t.errorAt(t.at(j+1), "Unexpected token, expected as")
+			}
+			bindings = append(bindings, [2]string{local.value, ""})
+			j += 3
+		case tok.is(esmTokenPunct, "{"):
+			j, err = t.parseSpecifierList(j, func(name, alias *esmToken) {
+				bindings = append(bindings, [2]string{alias.value, memberAccess("", name)})
+			})
+			if err != nil {
+				return j, err
+			}
+		}
+		j, err = t.parseFrom(j, &spec)
+	}
+	if err != nil {
+		return j, err
+	}
+	v := t.moduleVar(spec.value)
+	for _, binding := range bindings {
+		t.imports[binding[0]] = v + binding[1]
+	}
+	t.blank(t.toks[i].start, t.toks[j-1].end)
+	return j, nil
+}
+
+func (t *esmTransformer) parseExport(i int) (int, error) {
+	var (
+		spec *esmToken
+		err  error
+	)
+	start := t.toks[i].start
+	j := i + 1
+	switch tok := t.at(j); {
+	case tok.is(esmTokenPunct, "*"):
+		var alias *esmToken
+		j++
+		if t.at(j).is(esmTokenIdent, "as") {
+			alias = t.at(j + 1)
+			j += 2
+		}
+		if j, err = t.parseFrom(j, &spec); err != nil {
+			return j, err
+		}
+		v := t.moduleVar(spec.value)
+		if alias != nil {
+			t.export(exportedName(alias), v)
+		} else {
+			t.prologue.WriteString(esmHelperName + ".star(" + v + ");")
+		}
+		t.blank(start, t.toks[j-1].end)
+		return j, nil
+	case tok.is(esmTokenPunct, "{"):
+		var specifiers [][2]*esmToken
+		j, err = t.parseSpecifierList(j, func(name, alias *esmToken) {
+			specifiers = append(specifiers, [2]*esmToken{name, alias})
+		})
+		if err != nil {
+			return j, err
+		}
+		if t.at(j).is(esmTokenIdent, "from") {
+			if j, err = t.parseFrom(j, &spec); err != nil {
+				return j, err
+			}
+			v := t.moduleVar(spec.value)
+			for _, s := range specifiers {
+				t.export(exportedName(s[1]), memberAccess(v, s[0]))
+			}
+		} else {
+			if t.at(j).is(esmTokenPunct, ";") {
+				j++
+			}
+			for _, s := range specifiers {
+				t.export(exportedName(s[1]), s[0].value)
+			}
+		}
+		t.blank(start, t.toks[j-1].end)
+		return j, nil
+	case tok.is(esmTokenIdent, "default"):
+		j++
+		decl := j
+		if t.at(j).is(esmTokenIdent, "async") && t.at(j+1).is(esmTokenIdent, "function") && !t.at(j+1).nlBefore {
+			j++
+		}
+		switch {
+		case t.at(j).is(esmTokenIdent, "function"), t.at(j).is(esmTokenIdent, "class"):
+			k := j + 1
+			if t.at(k).is(esmTokenPunct, "*") {
+				k++
+			}
+			t.replace(start, t.toks[decl].start, "")
+			if name := t.at(k); name.kind == esmTokenIdent && name.value != "extends" {
+				t.export("default", name.value)
+			} else {
+				t.replace(t.toks[k-1].end, t.toks[k-1].end, " "+esmDefaultName)
+				t.export("default", esmDefaultName)
+				t.nameDefault(j, k)
+			}
+		default:
+			t.replace(start, t.toks[decl].start, "const "+esmDefaultName+" = ")
+			t.export("default", esmDefaultName)
+		}
+		return decl, nil
+	case tok.is(esmTokenIdent, "var"), tok.is(esmTokenIdent, "let"), tok.is(esmTokenIdent, "const"):
+		t.replace(start, tok.start, "")
+		for _, name := range t.parseDeclarators(j + 1) {
+			t.export(name, name)
+		}
+		return j + 1, nil
+	case tok.is(esmTokenIdent, "async"), tok.is(esmTokenIdent, "function"), tok.is(esmTokenIdent, "class"):
+		k := j + 1
+		if tok.value == "async" {
+			k++
+		}
+		if t.at(k).is(esmTokenPunct, "*") {
+			k++
+		}
+		name := t.at(k)
+		if name.kind != esmTokenIdent {
+			return k, t.errorAt(name, "Unexpected token "+name.value)
+		}
+		t.replace(start, tok.start, "")
+		t.export(name.value, name.value)
+		return j, nil
+	default:
+		return j, t.errorAt(tok, "Unexpected token "+tok.value)
+	}
+}
+
+// nameDefault sets the name of the anonymous function or class declaration at index j exported as the default
+// export to "default" like Node.js does. Function declarations are hoisted, so they're renamed in the prologue,
+// classes are renamed by a static block. k is the index of the token following the class keyword.
+func (t *esmTransformer) nameDefault(j, k int) {
+	const rename = `Object.defineProperty(%s, "name", { value: "default" });`
+	if t.toks[j].value == "function" {
+		fmt.Fprintf(&t.prologue, rename, esmDefaultName)
+		return
+	}
+	for ; k < len(t.toks); k++ {
+		tok := &t.toks[k]
+		if tok.depth == t.toks[j].depth && tok.is(esmTokenPunct, "{") {
+			t.replace(tok.end, tok.end, " static { "+fmt.Sprintf(rename, "this")+" }")
+			return
+		}
+		if tok.is(esmTokenIdent, "import") {
+			// The heritage contains an import expression which is rewritten later
+			return
+		}
+	}
+}
+
+// parseDeclarators returns the names bound by a variable declaration list starting at index j.
+func (t *esmTransformer) parseDeclarators(j int) []string {
+	var names []string
+	for {
+		names, j = t.parseBinding(j, names)
+		if t.at(j).is(esmTokenPunct, "=") {
+			j = t.skipInitializer(j + 1)
+		}
+		if !t.at(j).is(esmTokenPunct, ",") {
+			return names
+		}
+		j++
+	}
+}
+
+func (t *esmTransformer) parseBinding(j int, names []string) ([]string, int) {
+	tok := t.at(j)
+	switch {
+	case tok.kind == esmTokenIdent:
+		return append(names, tok.value), j + 1
+	case tok.is(esmTokenPunct, "{"):
+		end := t.matching(j)
+		for j++; j < end; {
+			if t.at(j).is(esmTokenPunct, "...") {
+				names, j = t.parseBinding(j+1, names)
+			} else {
+				key := t.at(j)
+				if key.is(esmTokenPunct, "[") {
+					j = t.matching(j)
+				}
+				j++
+				if t.at(j).is(esmTokenPunct, ":") {
+					names, j = t.parseBinding(j+1, names)
+				} else if key.kind == esmTokenIdent {
+					names = append(names, key.value)
+				}
+			}
+			j = t.skipElement(j, end)
+		}
+		return names, end + 1
+	case tok.is(esmTokenPunct, "["):
+		end := t.matching(j)
+		for j++; j < end; {
+			if t.at(j).is(esmTokenPunct, "...") {
+				j++
+			}
+			if !t.at(j).is(esmTokenPunct, ",") {
+				names, j = t.parseBinding(j, names)
+			}
+			j = t.skipElement(j, end)
+		}
+		return names, end + 1
+	}
+	return names, j + 1
+}
+
+// skipElement skips a default value of a destructuring pattern element and the following comma.
+func (t *esmTransformer) skipElement(j, end int) int {
+	for ; j < end; j++ {
+		if t.toks[j].depth == t.toks[end].depth+1 && t.toks[j].is(esmTokenPunct, ",") {
+			return j + 1
+		}
+	}
+	return end
+}
+
+// skipInitializer returns the index of the token following the initializer expression starting at index j.
+func (t *esmTransformer) skipInitializer(j int) int {
+	depth := t.at(j).depth
+	for start := j; j < len(t.toks); j++ {
+		tok := &t.toks[j]
+		if tok.depth < depth {
+			return j
+		}
+		if tok.depth > depth {
+			continue
+		}
+		if tok.is(esmTokenPunct, ",") || tok.is(esmTokenPunct, ";") {
+			return j
+		}
+		if tok.nlBefore && j > start && endsExpression(&t.toks[j-1]) && startsStatement(tok) {
+			return j
+		}
+	}
+	return j
+}
+
+func endsExpression(tok *esmToken) bool {
+	switch tok.kind {
+	case esmTokenIdent:
+		return !esmRegexpKeywords[tok.value]
+	case esmTokenPunct:
+		switch tok.value {
+		case ")", "]", "}", "++", "--":
+			return true
+		}
+		return false
+	case esmTokenTemplate:
+		return strings.HasSuffix(tok.value, "`")
+	}
+	return true
+}
+
+func startsStatement(tok *esmToken) bool {
+	switch tok.kind {
+	case esmTokenIdent:
+		return tok.value != "in" && tok.value != "instanceof" && tok.value != "of"
+	case esmTokenPunct:
+		switch tok.value {
+		case "{", "!", "~", "++", "--":
+			return true
+		}
+		return false
+	case esmTokenTemplate:
+		return false
+	}
+	return true
+}
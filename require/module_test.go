@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/dop251/goja"
@@ -79,6 +81,163 @@ func TestRequireNativeModule(t *testing.T) {
 	}
 }
 
+func TestWithNativeModule(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterNativeModule("counter", &counterModule{count: 10})
+	vm := goja.New()
+	registry.Enable(vm, WithNativeModule("test/m", &testNativeModule{}), WithNativeModule("counter", &counterModule{}))
+	v, err := vm.RunString(`require("test/m").test() + ":" + require("counter")()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.String(); s != "passed:1" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// The module is not added to the registry
+	other := goja.New()
+	registry.Enable(other)
+	if _, err := other.RunString(`require("test/m")`); err == nil {
+		t.Fatal("test/m is available in another runtime")
+	}
+}
+
+type counterModule struct {
+	count int
+}
+
+func (m *counterModule) Enable(runtime *goja.Runtime) {
+}
+
+func (m *counterModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	module.Set("exports", func() int {
+		m.count++
+		return m.count
+	})
+}
+
+func TestRegisterNativeModuleFactory(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterNativeModuleFactory("counter", func() NativeModule {
+		return &counterModule{}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vm := goja.New()
+			rr := registry.Enable(vm)
+			if i%2 == 0 {
+				// Modules registered after Enable() are created on the first require()
+				registry.RegisterNativeModuleFactory(fmt.Sprintf("late%d", i), func() NativeModule {
+					return &counterModule{}
+				})
+				if _, err := rr.Require(fmt.Sprintf("late%d", i)); err != nil {
+					errs <- err
+					return
+				}
+			}
+			v, err := vm.RunString(`const counter = require("counter"); counter(); counter(); counter()`)
+			if err == nil && v.ToInteger() != 3 {
+				err = fmt.Errorf("unexpected count: %v", v)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type lazyTestModule struct {
+	enabled int
+}
+
+func (m *lazyTestModule) Enable(runtime *goja.Runtime) {
+	m.enabled++
+	runtime.Set("lazyA", "a")
+	runtime.Set("lazyB", "b")
+}
+
+func (m *lazyTestModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	module.Set("exports", runtime.Get("lazyA"))
+}
+
+func (m *lazyTestModule) Globals() []string {
+	return []string{"lazyA", "lazyB"}
+}
+
+func TestLazyGlobals(t *testing.T) {
+	for i, tc := range []struct {
+		script   string
+		result   string
+		expected int
+	}{
+		{`typeof lazyA + ("lazyB" in globalThis)`, "stringtrue", 1},
+		{`"lazyA" in globalThis`, "true", 0},
+		{`lazyB = 1; [lazyA, lazyB].join()`, "a,1", 1},
+		{`lazyB = 1; lazyB`, "1", 0},
+		{`require("lazy")`, "a", 1},
+	} {
+		m := &lazyTestModule{}
+		registry := NewRegistry()
+		registry.RegisterNativeModule("lazy", m)
+		vm := goja.New()
+		registry.Enable(vm, WithLazyGlobals())
+		v, err := vm.RunString(tc.script)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if s := v.String(); s != tc.result {
+			t.Errorf("%d: unexpected result: %s", i, s)
+		}
+		if m.enabled != tc.expected {
+			t.Errorf("%d: module was enabled %d times", i, m.enabled)
+		}
+	}
+}
+
+func TestAllowedModules(t *testing.T) {
+	files := map[string]string{
+		"/app/lib/a.js":     `exports.a = require("./sub/b.js").b;`,
+		"/app/lib/sub/b.js": `exports.b = "b";`,
+		"/app/secret.js":    `exports.secret = true;`,
+		"/app/main.js":      `exports.main = require("./secret.js").secret;`,
+	}
+	registry := NewRegistry(WithLoader(mapFileSystemSourceLoader(files)))
+	registry.RegisterNativeModule("test/m", &testNativeModule{})
+	registry.RegisterNativeModule("lazy", &lazyTestModule{})
+
+	vm := goja.New()
+	rr := registry.Enable(vm, WithAllowedModules("lazy", "/app/lib/", "/app/main.js"), WithDeniedModules("/app/lib/sub/*"))
+	if _, err := rr.Require("lazy"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"test/m", "/app/lib/a.js", "/app/lib/sub/b.js", "/app/main.js"} {
+		// The errors of nested require() calls are thrown as exceptions
+		if _, err := rr.Require(name); err == nil || !strings.Contains(err.Error(), ErrModuleNotAllowed.Error()) {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	// The globals of the native modules which are not allowed are not enabled
+	if v, _ := vm.RunString(`typeof test + typeof lazyA`); v.String() != "undefinedstring" {
+		t.Fatalf("Unexpected globals: %s", v)
+	}
+
+	vm = goja.New()
+	rr = registry.Enable(vm, WithAllowedModules())
+	if _, err := rr.Require("lazy"); !errors.Is(err, ErrModuleNotAllowed) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
 func TestRequire(t *testing.T) {
 	const SCRIPT = `
 	var m = require("./testdata/m.js");
@@ -311,6 +470,250 @@ func TestErrorPropagation(t *testing.T) {
 	}
 }
 
+func TestExtensionHandler(t *testing.T) {
+	files := map[string]string{
+		"conf.ini":     "name=test\nversion=1",
+		"a.txt":        "plain text",
+		"data.json":    `{"x": 1}`,
+		"lib/index.ts": "module.exports = 'index' as string;",
+		"lib/util.ts":  "export = 'ts' as string;",
+		"lib/util.js":  "module.exports = 'js';",
+		"broken.ini":   "no value",
+	}
+	loads := 0
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(files)),
+		WithExtensionHandler(".ini", ExtensionHandler{
+			Transform: func(filename string, src []byte) (string, error) {
+				code := "module.exports = {};"
+				for _, line := range strings.Split(string(src), "\n") {
+					kv := strings.SplitN(line, "=", 2)
+					if len(kv) != 2 {
+						return "", fmt.Errorf("%s: invalid line %q", filename, line)
+					}
+					code += fmt.Sprintf("module.exports[%q] = %q;", kv[0], kv[1])
+				}
+				return code, nil
+			},
+		}),
+		WithExtensionHandler(".txt", ExtensionHandler{
+			Load: func(runtime *goja.Runtime, filename string, src []byte) (goja.Value, error) {
+				loads++
+				return runtime.ToValue(filename + ": " + string(src)), nil
+			},
+		}),
+		WithExtensionHandler(".ts", ExtensionHandler{
+			Transform: func(filename string, src []byte) (string, error) {
+				code := strings.ReplaceAll(string(src), " as string", "")
+				return strings.ReplaceAll(code, "export =", "module.exports ="), nil
+			},
+		}),
+		WithExtensionHandler(".json", ExtensionHandler{
+			Load: func(runtime *goja.Runtime, filename string, src []byte) (goja.Value, error) {
+				return runtime.ToValue("json"), nil
+			},
+		}),
+	)
+
+	vm := goja.New()
+	r.Enable(vm)
+	res, err := vm.RunString(`
+	const conf = require("./conf");
+	[conf.name, conf.version, require("./a.txt"), require("./a"), require("./data"), require("./lib/util"), require("./lib")].join();
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "test,1,a.txt: plain text,a.txt: plain text,json,js,index" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if loads != 1 {
+		t.Fatalf("Load was called %d times", loads)
+	}
+	if _, err := vm.RunString(`require("./broken.ini")`); err == nil || !strings.Contains(err.Error(), `broken.ini: invalid line "no value"`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The probe order can be changed
+	r = NewRegistry(WithLoader(mapFileSystemSourceLoader(files)), WithProbeOrder(".ts", ".js"),
+		WithExtensionHandler(".ts", r.extensions[".ts"]))
+	vm = goja.New()
+	r.Enable(vm)
+	res, err = vm.RunString(`[require("./lib/util"), require("./lib")].join()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "ts,index" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if _, err := vm.RunString(`require("./data")`); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestModuleObject(t *testing.T) {
+	files := map[string]string{
+		"/app/index.js": `
+			const dep = require("./lib/dep");
+			exports.info = [module.id, module.filename, module.path, __filename, __dirname, module.parent === null].join();
+			exports.children = module.children.map(m => m.id).join();
+			exports.dep = dep;
+			exports.main = require.main === module;
+		`,
+		"/app/lib/dep.js": `
+			exports.parent = module.parent.filename;
+			exports.loadedBefore = module.loaded;
+			exports.paths = module.paths.join();
+			exports.same = module.require === require;
+			exports.resolved = require.resolve("pkg");
+		`,
+		"/app/lib/node_modules/pkg/index.js":   `exports.count = (globalThis.count || 0) + 1; globalThis.count = exports.count;`,
+		"/other/node_modules/pkg/main.js":      `exports.other = true;`,
+		"/other/node_modules/pkg/package.json": `{"main": "main.js"}`,
+	}
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(files)))
+	r.RegisterNativeModule("native", &testNativeModule{})
+	vm := goja.New()
+	r.Enable(vm)
+	res, err := vm.RunScript("/app/test.js", `
+	const index = require("./index");
+	const results = [
+		index.info,
+		index.children,
+		index.main,
+		index.dep.parent,
+		index.dep.loadedBefore,
+		require.cache["/app/index.js"].loaded,
+		index.dep.paths,
+		index.dep.same,
+		index.dep.resolved,
+		require.resolve("./lib/dep"),
+		require.resolve("pkg", { paths: ["/other", "lib"] }),
+		require.resolve("native"),
+		String(require.resolve.paths("native")),
+		require.resolve.paths("./x").join(),
+		require.resolve.paths("x").join(),
+		Object.keys(require.cache).join(),
+	];
+	try {
+		require.resolve("./missing");
+	} catch (e) {
+		results.push("missing");
+	}
+	results.join("\n");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `.,/app/index.js,/app,/app/index.js,/app,true
+/app/lib/dep.js
+true
+/app/index.js
+false
+true
+/app/lib/node_modules,/app/node_modules,/node_modules
+true
+/app/lib/node_modules/pkg/index.js
+/app/lib/dep.js
+/other/node_modules/pkg/main.js
+native
+null
+/app
+/app/node_modules,/node_modules
+/app/index.js,/app/lib/dep.js
+missing`
+	if s := res.String(); s != expected {
+		t.Fatalf("Unexpected result:\n%s", s)
+	}
+
+	// Deleting a module from the cache makes require() evaluate it again
+	res, err = vm.RunScript("/app/test.js", `
+	const pkg = require("./lib/node_modules/pkg");
+	const count = pkg.count;
+	delete require.cache[require.resolve("./lib/node_modules/pkg")];
+	[count, require("./lib/node_modules/pkg").count, require("./lib/node_modules/pkg") === pkg].join();
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "1,2,false" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// The modules added to the cache are returned by require()
+	res, err = vm.RunScript("/app/test.js", `
+	require.cache["/app/lib/dep.js"] = { exports: "mock" };
+	require("./lib/dep");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := res.String(); s != "mock" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	files := map[string]string{
+		"/app/a.js":                          `require("missing")`,
+		"/app/node_modules/pkg/package.json": `{"exports": {"./x": "./x.js"}}`,
+		"/app/node_modules/pkg/x.js":         `exports.x = 1;`,
+	}
+	var traced []string
+	r := NewRegistry(WithLoader(mapFileSystemSourceLoader(files)), WithProbeOrder(".js"),
+		WithResolveTracer(func(request, candidate string, found bool) {
+			traced = append(traced, fmt.Sprintf("%s %s %v", request, candidate, found))
+		}))
+	vm := goja.New()
+	rr := r.Enable(vm)
+
+	resolveError := func(err error) *ResolveError {
+		t.Helper()
+		var resolveErr *ResolveError
+		if ex, ok := err.(*goja.Exception); ok {
+			// The errors thrown by require() are wrapped into GoError
+			err, _ = ex.Value().ToObject(vm).Get("value").Export().(error)
+		}
+		if !errors.As(err, &resolveErr) {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return resolveErr
+	}
+
+	_, err := rr.Require("/app/a.js")
+	resolveErr := resolveError(err)
+	if resolveErr.Request != "missing" || resolveErr.Parent != "/app/a.js" || resolveErr.Base != "/app" ||
+		!errors.Is(resolveErr, ErrInvalidModule) {
+		t.Fatalf("Unexpected error: %#v", resolveErr)
+	}
+	expected := []string{
+		"/app/node_modules/missing", "/app/node_modules/missing.js", "/app/node_modules/missing/index.js",
+		"/node_modules/missing", "/node_modules/missing.js", "/node_modules/missing/index.js",
+	}
+	if strings.Join(resolveErr.Tried, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected paths: %v", resolveErr.Tried)
+	}
+	if msg := resolveErr.Error(); msg != "cannot find module 'missing' required from /app/a.js; tried "+strings.Join(expected, ", ") {
+		t.Fatalf("Unexpected message: %s", msg)
+	}
+
+	_, err = rr.Require("/app/node_modules/pkg/y")
+	if resolveErr = resolveError(err); resolveErr.Parent != "" || resolveErr.Base != "." || len(resolveErr.Tried) != 3 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = vm.RunScript("/app/test.js", `require("pkg/y")`)
+	if resolveErr = resolveError(err); !errors.Is(resolveErr, ErrPackagePathNotExported) || len(resolveErr.Tried) != 0 {
+		t.Fatalf("Unexpected error: %v", resolveErr)
+	}
+
+	traced = nil
+	if _, err := rr.Require("/app/node_modules/pkg/x"); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(traced, ","); s != "/app/node_modules/pkg/x /app/node_modules/pkg/x false,/app/node_modules/pkg/x /app/node_modules/pkg/x.js true" {
+		t.Fatalf("Unexpected trace: %s", s)
+	}
+}
+
 func TestSourceMapLoader(t *testing.T) {
 	vm := goja.New()
 	r := NewRegistry(WithLoader(func(p string) ([]byte, error) {
@@ -0,0 +1,421 @@
+package require
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errNullTarget = errors.New("package target is null")
+
+// packageJSON contains the fields of package.json used by the resolver.
+type packageJSON struct {
+	Name    string
+	Main    string
+	Type    string
+	Exports json.RawMessage
+	Imports json.RawMessage
+}
+
+// jsonField is a member of a JSON object. The exports and imports fields of package.json are decoded
+// preserving the order of the members because conditions are matched in object order.
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+type jsonObject []jsonField
+
+// packageTarget is the result of resolving package exports or imports: either a path inside the package or,
+// for imports only, a bare specifier of another package.
+type packageTarget struct {
+	path string
+	bare bool
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var obj jsonObject
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonField{key.(string), value})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+func decodeOrderedJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: unexpected data after the top-level value", ErrInvalidPackageConfig)
+	}
+	return value, nil
+}
+
+// readPackage returns the parsed package.json of the given directory or nil if there is none.
+func (r *ModuleResolver) readPackage(dir string) *packageJSON {
+	if pkg, exists := r.packages[dir]; exists {
+		return pkg
+	}
+	var pkg *packageJSON
+	if buf, err := r.registry.getSource(path.Join(dir, "package.json")); err == nil {
+		pkg = new(packageJSON)
+		if json.Unmarshal(buf, pkg) != nil {
+			pkg = &packageJSON{}
+		}
+	}
+	r.packages[dir] = pkg
+	return pkg
+}
+
+// packageScope returns the directory of the package.json closest to the given directory.
+func (r *ModuleResolver) packageScope(dir string) (string, *packageJSON) {
+	for {
+		if pkg := r.readPackage(dir); pkg != nil {
+			return dir, pkg
+		}
+		parent := path.Dir(dir)
+		if parent == dir || dir == "." || path.Base(dir) == "node_modules" {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// packageType returns the "type" field of the package.json closest to the given directory.
+func (r *ModuleResolver) packageType(dir string) string {
+	if _, pkg := r.packageScope(dir); pkg != nil {
+		return pkg.Type
+	}
+	return ""
+}
+
+func hasField(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
+
+// splitPackageName splits a bare specifier into the package name and the subpath relative to the package.
+func splitPackageName(spec string) (name, subpath string, err error) {
+	sep := strings.IndexByte(spec, '/')
+	if strings.HasPrefix(spec, "@") {
+		if sep < 0 {
+			return "", "", fmt.Errorf("%w: %s", ErrInvalidModuleName, spec)
+		}
+		if next := strings.IndexByte(spec[sep+1:], '/'); next >= 0 {
+			sep += next + 1
+		} else {
+			sep = -1
+		}
+	}
+	name = spec
+	subpath = "."
+	if sep >= 0 {
+		name, subpath = spec[:sep], "."+spec[sep:]
+	}
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, "\\%") {
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidModuleName, spec)
+	}
+	return
+}
+
+// resolvePackageFile resolves the target of package exports or imports. A missing target is an error, the
+// resolution doesn't fall back to the main field or the index files of the package like Node.
+func (r *ModuleResolver) resolvePackageFile(target packageTarget, pkgDir string, conditions []string) (filename string, err error) {
+	if target.bare {
+		filename, err = r.resolveNodeModules(target.path, pkgDir, conditions)
+	} else {
+		filename, err = r.probe(target.path)
+	}
+	if filename == "" && err == nil {
+		err = ErrInvalidModule
+	}
+	return
+}
+
+// resolveExports resolves the module from the package found in the given node_modules directory if
+// the package.json of the package defines exports.
+func (r *ModuleResolver) resolveExports(modpath, dir string, conditions []string) (string, error) {
+	name, subpath, err := splitPackageName(modpath)
+	if err != nil {
+		return "", nil
+	}
+	pkgDir := path.Join(dir, name)
+	pkg := r.readPackage(pkgDir)
+	if pkg == nil || !hasField(pkg.Exports) {
+		return "", nil
+	}
+	target, err := r.resolvePackageExports(pkgDir, subpath, pkg.Exports, conditions)
+	if err != nil {
+		return "", err
+	}
+	return r.resolvePackageFile(target, pkgDir, conditions)
+}
+
+// resolveSelf resolves the module if it refers to the package enclosing the requiring module by its name.
+func (r *ModuleResolver) resolveSelf(modpath, start string, conditions []string) (string, error) {
+	scope, pkg := r.packageScope(start)
+	if pkg == nil || !hasField(pkg.Exports) || pkg.Name == "" {
+		return "", nil
+	}
+	if modpath != pkg.Name && !strings.HasPrefix(modpath, pkg.Name+"/") {
+		return "", nil
+	}
+	target, err := r.resolvePackageExports(scope, "."+modpath[len(pkg.Name):], pkg.Exports, conditions)
+	if err != nil {
+		return "", err
+	}
+	return r.resolvePackageFile(target, scope, conditions)
+}
+
+// resolveImports resolves a module referred by a #specifier defined in the imports field of the package
+// enclosing the requiring module.
+func (r *ModuleResolver) resolveImports(modpath, start string, conditions []string) (string, error) {
+	if modpath == "#" || strings.HasPrefix(modpath, "#/") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidModuleName, modpath)
+	}
+	scope, pkg := r.packageScope(start)
+	if pkg != nil && hasField(pkg.Imports) {
+		imports, err := decodeOrderedJSON(pkg.Imports)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrInvalidPackageConfig, path.Join(scope, "package.json"), err)
+		}
+		if obj, ok := imports.(jsonObject); ok {
+			target, found, err := r.resolveImportsExports(scope, modpath, obj, true, conditions)
+			if errors.Is(err, errNullTarget) {
+				found, err = false, nil
+			}
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return r.resolvePackageFile(target, scope, conditions)
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrPackageImportNotDefined, modpath)
+}
+
+// resolvePackageExports implements PACKAGE_EXPORTS_RESOLVE described by
+// https://nodejs.org/api/esm.html#resolution-algorithm-specification
+func (r *ModuleResolver) resolvePackageExports(pkgDir, subpath string, raw json.RawMessage, conditions []string) (packageTarget, error) {
+	exports, err := decodeOrderedJSON(raw)
+	if err != nil {
+		return packageTarget{}, fmt.Errorf("%w: %s: %v", ErrInvalidPackageConfig, path.Join(pkgDir, "package.json"), err)
+	}
+	obj, isObject := exports.(jsonObject)
+	dotKeys := 0
+	for _, field := range obj {
+		if strings.HasPrefix(field.key, ".") {
+			dotKeys++
+		}
+	}
+	if dotKeys > 0 && dotKeys != len(obj) {
+		return packageTarget{}, fmt.Errorf("%w: %s: \"exports\" cannot contain some keys starting with '.' and some not",
+			ErrInvalidPackageConfig, path.Join(pkgDir, "package.json"))
+	}
+
+	var (
+		target packageTarget
+		found  bool
+	)
+	if subpath == "." {
+		var mainExport interface{}
+		if !isObject || dotKeys == 0 {
+			mainExport = exports
+		} else {
+			for _, field := range obj {
+				if field.key == "." {
+					mainExport = field.value
+				}
+			}
+		}
+		if mainExport != nil {
+			target, found, err = r.resolvePackageTarget(pkgDir, mainExport, nil, false, conditions)
+		}
+	} else if isObject && dotKeys > 0 {
+		target, found, err = r.resolveImportsExports(pkgDir, subpath, obj, false, conditions)
+	}
+	if errors.Is(err, errNullTarget) {
+		found, err = false, nil
+	}
+	if err == nil && !found {
+		err = fmt.Errorf("%w: subpath '%s' is not defined by \"exports\" in %s",
+			ErrPackagePathNotExported, subpath, path.Join(pkgDir, "package.json"))
+	}
+	return target, err
+}
+
+// patternKeyLess orders pattern keys by specificity as described by PATTERN_KEY_COMPARE.
+func patternKeyLess(a, b string) bool {
+	baseA, baseB := strings.IndexByte(a, '*'), strings.IndexByte(b, '*')
+	if baseA != baseB {
+		return baseA > baseB
+	}
+	return len(a) > len(b)
+}
+
+func (r *ModuleResolver) resolveImportsExports(pkgDir, matchKey string, matchObj jsonObject, isImports bool, conditions []string) (packageTarget, bool, error) {
+	if !strings.Contains(matchKey, "*") {
+		for _, field := range matchObj {
+			if field.key == matchKey {
+				return r.resolvePackageTarget(pkgDir, field.value, nil, isImports, conditions)
+			}
+		}
+	}
+	var expansionKeys []jsonField
+	for _, field := range matchObj {
+		if strings.Count(field.key, "*") == 1 {
+			expansionKeys = append(expansionKeys, field)
+		}
+	}
+	sort.SliceStable(expansionKeys, func(i, j int) bool {
+		return patternKeyLess(expansionKeys[i].key, expansionKeys[j].key)
+	})
+	for _, field := range expansionKeys {
+		star := strings.IndexByte(field.key, '*')
+		patternBase, patternTrailer := field.key[:star], field.key[star+1:]
+		if strings.HasPrefix(matchKey, patternBase) && matchKey != patternBase &&
+			(patternTrailer == "" || strings.HasSuffix(matchKey, patternTrailer) && len(matchKey) >= len(field.key)) {
+			patternMatch := matchKey[len(patternBase) : len(matchKey)-len(patternTrailer)]
+			return r.resolvePackageTarget(pkgDir, field.value, &patternMatch, isImports, conditions)
+		}
+	}
+	return packageTarget{}, false, nil
+}
+
+// hasInvalidSegment reports whether the path contains empty, ".", ".." or "node_modules" segments.
+func hasInvalidSegment(s string) bool {
+	for _, seg := range strings.Split(strings.ReplaceAll(s, "\\", "/"), "/") {
+		switch strings.ToLower(pathUnescape(seg)) {
+		case "", ".", "..", "node_modules":
+			return true
+		}
+	}
+	return false
+}
+
+func pathUnescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// resolvePackageTarget implements PACKAGE_TARGET_RESOLVE. found is false if the target is undefined for
+// the active conditions; an explicitly excluded (null) target is reported as errNullTarget.
+func (r *ModuleResolver) resolvePackageTarget(pkgDir string, target interface{}, patternMatch *string, isImports bool, conditions []string) (packageTarget, bool, error) {
+	invalidTarget := func() error {
+		return fmt.Errorf("%w: %v in %s", ErrInvalidPackageTarget, target, path.Join(pkgDir, "package.json"))
+	}
+	switch target := target.(type) {
+	case string:
+		if !strings.HasPrefix(target, "./") {
+			if !isImports || strings.HasPrefix(target, "../") || strings.HasPrefix(target, "/") || strings.Contains(target, "://") {
+				return packageTarget{}, false, invalidTarget()
+			}
+			if patternMatch != nil {
+				target = strings.ReplaceAll(target, "*", *patternMatch)
+			}
+			return packageTarget{path: target, bare: true}, true, nil
+		}
+		if hasInvalidSegment(target[2:]) {
+			return packageTarget{}, false, invalidTarget()
+		}
+		if patternMatch != nil {
+			if hasInvalidSegment(*patternMatch) {
+				return packageTarget{}, false, fmt.Errorf("%w: pattern match '%s' in %s",
+					ErrInvalidModuleName, *patternMatch, path.Join(pkgDir, "package.json"))
+			}
+			target = strings.ReplaceAll(target, "*", *patternMatch)
+		}
+		return packageTarget{path: path.Join(pkgDir, target)}, true, nil
+	case jsonObject:
+		for _, field := range target {
+			if _, err := strconv.Atoi(field.key); err == nil {
+				return packageTarget{}, false, fmt.Errorf("%w: %s: conditions cannot contain numeric keys",
+					ErrInvalidPackageConfig, path.Join(pkgDir, "package.json"))
+			}
+		}
+		for _, field := range target {
+			if field.key != "default" && !containsString(conditions, field.key) {
+				continue
+			}
+			resolved, found, err := r.resolvePackageTarget(pkgDir, field.value, patternMatch, isImports, conditions)
+			if err != nil || found {
+				return resolved, found, err
+			}
+		}
+		return packageTarget{}, false, nil
+	case []interface{}:
+		lastErr := errNullTarget
+		for _, value := range target {
+			resolved, found, err := r.resolvePackageTarget(pkgDir, value, patternMatch, isImports, conditions)
+			if errors.Is(err, ErrInvalidPackageTarget) {
+				lastErr = err
+				continue
+			}
+			if err != nil || found {
+				return resolved, found, err
+			}
+		}
+		return packageTarget{}, false, lastErr
+	case nil:
+		return packageTarget{}, false, errNullTarget
+	}
+	return packageTarget{}, false, invalidTarget()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
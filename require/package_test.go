@@ -0,0 +1,123 @@
+package require
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestPackageExports(t *testing.T) {
+	fs := map[string]string{
+		"/app/node_modules/str/package.json": `{"main": "ignored.js", "exports": "./main.js"}`,
+		"/app/node_modules/str/main.js":      `exports.name = "str"`,
+
+		"/app/node_modules/cond/package.json": `{"exports": {
+			"custom": "./custom.js",
+			"import": "./esm.mjs",
+			"node": {"require": "./node.js", "default": "./default.js"}
+		}}`,
+		"/app/node_modules/cond/custom.js":  `exports.name = "custom"`,
+		"/app/node_modules/cond/esm.mjs":    `export const name = "import"`,
+		"/app/node_modules/cond/node.js":    `exports.name = "node"`,
+		"/app/node_modules/cond/default.js": `exports.name = "default"`,
+
+		"/app/node_modules/@scope/sub/package.json": `{"exports": {
+			".": "./index.js",
+			"./feature": {"require": "./lib/feature.js"},
+			"./features/*.js": "./lib/features/*.js",
+			"./features/internal/*": null,
+			"./fallback": ["invalid:", "./lib/feature.js"]
+		}}`,
+		"/app/node_modules/@scope/sub/index.js":                   `exports.name = "sub"`,
+		"/app/node_modules/@scope/sub/lib/feature.js":             `exports.name = "feature"`,
+		"/app/node_modules/@scope/sub/lib/features/a.js":          `exports.name = "a"`,
+		"/app/node_modules/@scope/sub/lib/features/internal/b.js": `exports.name = "b"`,
+
+		"/app/package.json": `{"name": "app", "exports": {"./self": "./lib/self.js"}, "imports": {
+			"#dep": {"node": "str", "default": "./missing.js"},
+			"#utils/*": "./lib/utils/*.js"
+		}}`,
+		"/app/lib/self.js":    `exports.name = "self"`,
+		"/app/lib/utils/x.js": `exports.name = "utils/x"`,
+
+		"/app/node_modules/bad/package.json": `{"exports": {".": "./index.js", "main": "./index.js"}}`,
+
+		// The exported file is missing, the main field and index.js must not be used instead
+		"/app/node_modules/gone/package.json": `{"main": "./index.js", "exports": "./missing.js"}`,
+		"/app/node_modules/gone/index.js":     `exports.name = "gone"`,
+	}
+
+	for i, tc := range []struct {
+		conditions []string
+		path       string
+		err        error
+		value      string
+	}{
+		{nil, "str", nil, "str"},
+		{nil, "cond", nil, "node"},
+		{[]string{"custom"}, "cond", nil, "custom"},
+		{[]string{"browser"}, "cond", ErrPackagePathNotExported, ""},
+		{[]string{"browser", "node"}, "cond", nil, "node"},
+		{nil, "@scope/sub", nil, "sub"},
+		{nil, "@scope/sub/feature", nil, "feature"},
+		{nil, "@scope/sub/features/a.js", nil, "a"},
+		{nil, "@scope/sub/features/internal/b.js", ErrPackagePathNotExported, ""},
+		{nil, "@scope/sub/lib/feature.js", ErrPackagePathNotExported, ""},
+		{nil, "@scope/sub/fallback", nil, "feature"},
+		{nil, "app/self", nil, "self"},
+		{nil, "#dep", nil, "str"},
+		{nil, "#utils/x", nil, "utils/x"},
+		{nil, "#missing", ErrPackageImportNotDefined, ""},
+		{nil, "bad", ErrInvalidPackageConfig, ""},
+		{nil, "gone", ErrInvalidModule, ""},
+	} {
+		vm := goja.New()
+		r := NewRegistry(WithConditions(tc.conditions...), WithLoader(mapFileSystemSourceLoader(fs)))
+		if tc.conditions == nil {
+			r = NewRegistry(WithLoader(mapFileSystemSourceLoader(fs)))
+		}
+		rr := r.Enable(vm)
+		_, err := vm.RunScript("/app/test.js", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		module, err := rr.resolveFrom(tc.path, "/app", false, nil)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: require(%q) failed: %v", i, tc.path, err)
+			continue
+		}
+		if value := module.Get("exports").ToObject(vm).Get("name").String(); value != tc.value {
+			t.Errorf("%d: got %q expected %q", i, value, tc.value)
+		}
+	}
+
+	vm := goja.New()
+	rr := NewRegistry(WithLoader(mapFileSystemSourceLoader(fs))).Enable(vm)
+	module, err := rr.resolveFrom("cond", "/app", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := module.Get("exports").ToObject(vm).Get("name").String(); value != "import" {
+		t.Fatalf("Unexpected import result: %q", value)
+	}
+}
+
+func TestPatternKeyOrder(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+	}{
+		{"./features/internal/*", "./features/*"},
+		{"./a/*.js", "./a/*"},
+	} {
+		if !patternKeyLess(tc.a, tc.b) || patternKeyLess(tc.b, tc.a) {
+			t.Errorf("expected %q to be more specific than %q", tc.a, tc.b)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package require
 
 import (
+	"crypto/sha256"
 	"errors"
 	"io"
 	"io/fs"
@@ -20,6 +21,13 @@ var (
 	ErrInvalidModule     = errors.New("invalid module")
 	ErrInvalidModuleName = errors.New("invalid module name")
 	ErrModuleNotExist    = errors.New("module does not exist")
+	ErrAsyncModule       = errors.New("module uses top-level await and cannot be loaded synchronously")
+	ErrModuleNotAllowed  = errors.New("module is not allowed")
+
+	ErrPackagePathNotExported  = errors.New("package path is not exported")
+	ErrPackageImportNotDefined = errors.New("package import specifier is not defined")
+	ErrInvalidPackageTarget    = errors.New("invalid package target")
+	ErrInvalidPackageConfig    = errors.New("invalid package configuration")
 )
 
 // NativeModule is an interface that represents a module with native methods and objects.
@@ -92,14 +100,119 @@ func WithGlobalFolders(globalFolders ...string) Option {
 	}
 }
 
+// WithConditions sets the condition names matched against the conditional "exports" and "imports" of
+// package.json, see https://nodejs.org/api/packages.html#conditional-exports. The "default" condition
+// always matches, as does "require" for modules loaded by require() and "import" for modules loaded by
+// import declarations and import(). By default the conditions list is ["node"].
+func WithConditions(conditions ...string) Option {
+	return func(r *Registry) {
+		r.conditions = conditions
+	}
+}
+
+// ExtensionHandler defines how the module files with a particular extension are loaded, similar to
+// require.extensions in Node.js (see WithExtensionHandler()). Either Transform or Load should be set.
+type ExtensionHandler struct {
+	// Transform converts the file contents into the source code of a CommonJS module. The result is
+	// compiled and cached by the Registry, so the transformation is done once for all runtimes.
+	Transform func(filename string, src []byte) (string, error)
+
+	// Load returns the exports of the module created directly in the runtime. It is called once per runtime,
+	// the module is then cached by the ModuleResolver like any other.
+	Load func(runtime *goja.Runtime, filename string, src []byte) (goja.Value, error)
+}
+
+var jsonHandler = ExtensionHandler{
+	Transform: func(_ string, src []byte) (string, error) {
+		return "module.exports = JSON.parse('" + template.JSEscapeString(string(src)) + "')", nil
+	},
+}
+
+// WithExtensionHandler sets the handler for the module files with the given extension, e.g. ".ts" (note
+// the leading dot). The default handlers of ".js" and ".json" can be replaced too. Files with no handler
+// are loaded as JavaScript.
+// The extensions are appended to the list of extensions tried for module paths without one in the order
+// the handlers were added, unless the list is set with WithProbeOrder().
+func WithExtensionHandler(ext string, handler ExtensionHandler) Option {
+	return func(r *Registry) {
+		if r.extensions == nil {
+			r.extensions = make(map[string]ExtensionHandler)
+		}
+		if _, exists := r.extensions[ext]; !exists {
+			r.extensionOrder = append(r.extensionOrder, ext)
+		}
+		r.extensions[ext] = handler
+	}
+}
+
+// WithProbeOrder sets the extensions which are tried, in order, if a module path does not point to a file
+// and when looking for the index file of a directory. For example, with WithProbeOrder(".ts", ".js")
+// require("./a") tries a, a.ts, a.js, a/index.ts and a/index.js. By default the list is ".js", ".json"
+// followed by the extensions added with WithExtensionHandler().
+func WithProbeOrder(exts ...string) Option {
+	return func(r *Registry) {
+		r.probeOrder = exts
+	}
+}
+
+// ResolveTracer is called for every path probed as a module file while resolving the request. found reports
+// whether there is a module file at the path.
+type ResolveTracer func(request, candidate string, found bool)
+
+// WithResolveTracer sets the function which is called for every path probed by the resolver. The paths tried
+// for a request which is not found are also available from the ResolveError. The resolution results are cached
+// by the ModuleResolver, so the tracer is called only the first time a request is resolved from a directory.
+func WithResolveTracer(tracer ResolveTracer) Option {
+	return func(r *Registry) {
+		r.resolveTracer = tracer
+	}
+}
+
 // Registry contains a cache of compiled modules which can be used by multiple Runtimes
 type Registry struct {
 	sync.Mutex
-	natives  map[string]NativeModule
+	natives  map[string]NativeModuleFactory
 	compiled map[string]*goja.Program
 
-	srcLoader     SourceLoader
-	globalFolders []string
+	srcLoader      SourceLoader
+	globalFolders  []string
+	conditions     []string
+	extensions     map[string]ExtensionHandler
+	extensionOrder []string
+	probeOrder     []string
+	resolveTracer  ResolveTracer
+
+	compileCache *compileCache
+	registered   map[string]bool
+	invalidated  invalidation
+	watchMu      sync.Mutex
+	watched      map[string][sha256.Size]byte
+}
+
+// extensionHandler returns the handler of the file with the given name or nil if it is loaded as JavaScript.
+func (r *Registry) extensionHandler(name string) *ExtensionHandler {
+	ext := path.Ext(name)
+	if h, exists := r.extensions[ext]; exists {
+		return &h
+	}
+	if ext == ".json" {
+		return &jsonHandler
+	}
+	return nil
+}
+
+// probeExtensions returns the extensions tried by the resolver for the module paths without one.
+func (r *Registry) probeExtensions() []string {
+	if r.probeOrder != nil {
+		return r.probeOrder
+	}
+	exts := []string{".js", ".json"}
+	for _, ext := range r.extensionOrder {
+		if ext != ".js" && ext != ".json" {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
 }
 
 func (r *Registry) getSource(p string) ([]byte, error) {
@@ -107,25 +220,78 @@ func (r *Registry) getSource(p string) ([]byte, error) {
 	if srcLoader == nil {
 		srcLoader = DefaultSourceLoader
 	}
-	return srcLoader(p)
+	src, err := srcLoader(p)
+	if err == nil {
+		r.watch(p, src)
+	}
+	return src, err
+}
+
+// wrapSource wraps the module code into a function. CommonJS modules are wrapped into
+// function(exports, require, module, __filename, __dirname) and ES modules (esm == true) into an async function so that
+// top-level await can be used. The code is transformed first if there is a handler for the extension.
+func (r *Registry) wrapSource(name, code string, esm bool) (string, error) {
+	if h := r.extensionHandler(name); h != nil && h.Transform != nil {
+		var err error
+		if code, err = h.Transform(name, []byte(code)); err != nil {
+			return "", err
+		}
+	}
+	code, err := transformModule(name, code, esm)
+	if err != nil {
+		return "", err
+	}
+	if esm {
+		return "(async function(" + esmHelperName + ") {" + code + "\n})", nil
+	}
+	return "(function(exports, require, module, __filename, __dirname, " + esmHelperName + ") {" + code + "\n})", nil
 }
 
-func (r *Registry) compileSource(name, code string) (*goja.Program, error) {
-	if path.Ext(name) == ".json" {
-		code = "module.exports = JSON.parse('" + template.JSEscapeString(code) + "')"
+// transforms reports whether the module code is transformed before it's wrapped, either by the Transform of a
+// custom extension handler or by the ES module transformation.
+func (r *Registry) transforms(name, code string, esm bool) bool {
+	if h, exists := r.extensions[path.Ext(name)]; exists && h.Transform != nil {
+		return true
+	}
+	return needsModuleTransform(code, esm)
+}
+
+// compileSource compiles the wrapped module code. The wrapped code of the transformed modules is taken from the
+// compile cache if it's enabled (see WithCompileCache()). It must be called without holding the registry lock,
+// because the cache is accessed.
+func (r *Registry) compileSource(name, code string, esm bool) (*goja.Program, error) {
+	var (
+		source string
+		cached bool
+		key    string
+	)
+	useCache := r.compileCache != nil && r.transforms(name, code, esm)
+	if useCache {
+		key = compileCacheKey(name, code, esm)
+		source, cached = r.compileCache.get(key)
+	}
+	if !cached {
+		var err error
+		if source, err = r.wrapSource(name, code, esm); err != nil {
+			return nil, err
+		}
 	}
-	source := "(function(exports, require, module) {" + code + "})"
 	parsed, err := goja.Parse(name, source, parser.WithSourceMapLoader(r.srcLoader))
 	if err != nil {
 		return nil, err
 	}
+	if useCache && !cached {
+		r.compileCache.put(key, source)
+	}
 	return goja.CompileAST(parsed, false)
 }
 
-func (r *Registry) getCompiledSource(filepath string) (*goja.Program, error) {
+func (r *Registry) getCompiledSource(filepath string, esm bool) (*goja.Program, error) {
 	r.Lock()
-	defer r.Unlock()
-	if prg, exist := r.compiled[filepath]; exist {
+	prg, exist := r.compiled[filepath]
+	generation := r.invalidated.generation
+	r.Unlock()
+	if exist {
 		return prg, nil
 	}
 
@@ -133,53 +299,114 @@ func (r *Registry) getCompiledSource(filepath string) (*goja.Program, error) {
 	if err != nil {
 		return nil, err
 	}
-	prg, err := r.compileSource(filepath, string(buf))
+	prg, err = r.compileSource(filepath, string(buf), esm)
 	if err != nil {
 		return nil, err
 	}
-	r.compiled[filepath] = prg
+
+	r.Lock()
+	defer r.Unlock()
+	if compiled, exist := r.compiled[filepath]; exist {
+		// Compiled concurrently by another runtime
+		return compiled, nil
+	}
+	// The program isn't kept if the module was invalidated while it was compiled
+	if r.invalidated.generation == generation {
+		if r.compiled == nil {
+			r.compiled = make(map[string]*goja.Program)
+		}
+		r.compiled[filepath] = prg
+	}
 
 	return prg, nil
 }
 
-// Enable adds the require() function to the specified runtime.
-func (r *Registry) Enable(runtime *goja.Runtime) *ModuleResolver {
+// Enable adds the require() function to the specified runtime and enables the registered native modules.
+func (r *Registry) Enable(runtime *goja.Runtime, opts ...EnableOption) *ModuleResolver {
 	resolver := &ModuleResolver{
 		registry:    r,
 		runtime:     runtime,
 		modules:     make(map[string]*goja.Object),
-		nodeModules: make(map[string]*goja.Object),
+		paths:       make(map[string]string),
+		nodeModules: make(map[string]string),
+		esModules:   make(map[*goja.Object]*esModule),
+		packages:    make(map[string]*packageJSON),
+		dependents:  make(map[*goja.Object]map[*goja.Object]struct{}),
+		natives:     make(map[string]NativeModule),
 	}
-	runtime.Set("require", resolver.require)
-	for _, module := range r.natives {
-		module.Enable(runtime)
+	for _, opt := range opts {
+		opt(resolver)
+	}
+	r.Lock()
+	resolver.generation = r.invalidated.generation
+	factories := make(map[string]NativeModuleFactory, len(r.natives))
+	for name, factory := range r.natives {
+		factories[name] = factory
+	}
+	r.Unlock()
+	for name, factory := range factories {
+		if _, exists := resolver.natives[name]; !exists {
+			resolver.natives[name] = factory()
+		}
+	}
+	runtime.Set("require", resolver.newRequire(nil, ""))
+	for name, module := range resolver.natives {
+		if resolver.isAllowed(name) {
+			resolver.enableNative(name, module)
+		}
 	}
 	return resolver
 }
 
+// RegisterJSModule compiles and registers a module source under the given name. Names with the .mjs
+// extension are registered as ES modules.
 func (r *Registry) RegisterJSModule(name, code string) error {
-	r.Lock()
-	defer r.Unlock()
-
-	prg, err := r.compileSource(name, code)
+	prg, err := r.compileSource(name, code, path.Ext(name) == ".mjs")
 	if err != nil {
 		return err
 	}
+
+	r.Lock()
+	defer r.Unlock()
+	if r.compiled == nil {
+		r.compiled = make(map[string]*goja.Program)
+	}
 	r.compiled[name] = prg
+	if r.registered == nil {
+		r.registered = make(map[string]bool)
+	}
+	r.registered[name] = true
 
 	return nil
 }
 
+// NativeModuleFactory creates the instance of a native module used by a single runtime.
+type NativeModuleFactory func() NativeModule
+
+// RegisterNativeModule registers the module instance shared by all runtimes using the registry. Use
+// RegisterNativeModuleFactory() if the module keeps per-runtime state.
 func (r *Registry) RegisterNativeModule(name string, module NativeModule) {
+	r.RegisterNativeModuleFactory(name, func() NativeModule {
+		return module
+	})
+}
+
+// RegisterNativeModuleFactory registers a native module created by the factory for every runtime, so the
+// runtimes don't share the module state. The factory is called by Enable(), or by the first require() of the
+// module if it's registered after the runtime was enabled, and may be called concurrently.
+func (r *Registry) RegisterNativeModuleFactory(name string, factory NativeModuleFactory) {
 	r.Lock()
 	defer r.Unlock()
-	r.natives[path.Clean(name)] = module
+	if r.natives == nil {
+		r.natives = make(map[string]NativeModuleFactory)
+	}
+	r.natives[path.Clean(name)] = factory
 }
 
 func NewRegistry(opts ...Option) *Registry {
 	r := &Registry{
 		compiled: make(map[string]*goja.Program),
-		natives:  make(map[string]NativeModule),
+		natives:  make(map[string]NativeModuleFactory),
 	}
 
 	for _, opt := range opts {
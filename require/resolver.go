@@ -1,9 +1,10 @@
 package require
 
 import (
-	"encoding/json"
 	"errors"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/dop251/goja"
@@ -13,66 +14,208 @@ type ModuleResolver struct {
 	registry    *Registry
 	runtime     *goja.Runtime
 	modules     map[string]*goja.Object
-	nodeModules map[string]*goja.Object
+	paths       map[string]string
+	nodeModules map[string]string
+	esModules   map[*goja.Object]*esModule
+	packages    map[string]*packageJSON
+	main        *goja.Object
+	cache       *goja.Object
+	dependents  map[*goja.Object]map[*goja.Object]struct{}
+	generation  uint64
+	loading     int
+	request     string
+	tried       []string
+	natives     map[string]NativeModule
+	lazy        map[string]*lazyModule
+	allowList   []string
+	denyList    []string
 }
 
+// ResolveError is returned when a module cannot be resolved. It lists the candidate paths probed in order,
+// which helps to find out why a module is not found in a node_modules layout.
+type ResolveError struct {
+	// Request is the module name passed to require() or import.
+	Request string
+	// Parent is the file name of the requiring module. It's empty for the global require() and the Go API.
+	Parent string
+	// Base is the directory the request is resolved from.
+	Base string
+	// Tried contains the paths probed as module files, in order.
+	Tried []string
+	// Err is ErrInvalidModule if none of the candidates exists, otherwise it's the error which stopped
+	// the resolution, e.g. ErrPackagePathNotExported.
+	Err error
+}
+
+func (e *ResolveError) Error() string {
+	var b strings.Builder
+	b.WriteString("cannot find module '" + e.Request + "'")
+	if e.Parent != "" {
+		b.WriteString(" required from " + e.Parent)
+	} else if e.Base != "" {
+		b.WriteString(" from " + e.Base)
+	}
+	if !errors.Is(e.Err, ErrInvalidModule) {
+		b.WriteString(": " + e.Err.Error())
+	}
+	if len(e.Tried) > 0 {
+		b.WriteString("; tried " + strings.Join(e.Tried, ", "))
+	}
+	return b.String()
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+// withParent sets the parent of the ResolveError to the file name of the module.
+func withParent(err error, module *goja.Object) error {
+	var resolveErr *ResolveError
+	if module != nil && errors.As(err, &resolveErr) {
+		if filename := module.Get("filename"); filename != nil {
+			resolveErr.Parent = filename.String()
+		}
+	}
+	return err
+}
+
+// conditions returns the condition names matched against package exports and imports for require()
+// (esm == false) or import (esm == true).
+func (r *ModuleResolver) conditions(esm bool) []string {
+	conditions := r.registry.conditions
+	if conditions == nil {
+		conditions = []string{"node"}
+	}
+	kind := "require"
+	if esm {
+		kind = "import"
+	}
+	return append(conditions[:len(conditions):len(conditions)], kind)
+}
+
+func isRelative(modpath string) bool {
+	return strings.HasPrefix(modpath, "./") ||
+		strings.HasPrefix(modpath, "/") || strings.HasPrefix(modpath, "../") ||
+		modpath == "." || modpath == ".."
+}
+
+// native returns the runtime's instance of the native module or nil if there is no such module.
+func (r *ModuleResolver) native(name string) NativeModule {
+	if module := r.natives[name]; module != nil {
+		return module
+	}
+	r.registry.Lock()
+	factory := r.registry.natives[name]
+	r.registry.Unlock()
+	if factory == nil {
+		return nil
+	}
+	module := factory()
+	r.natives[name] = module
+	return module
+}
+
+func (r *ModuleResolver) isNative(name string) bool {
+	return r.native(name) != nil
+}
+
+// resolveFilename returns the file name of the module without loading it, or the name itself if it is a
+// native module (native == true).
 // Nodejs module search algorithm described by
 // https://nodejs.org/api/modules.html#modules_all_together
-func (r *ModuleResolver) resolve(modpath string) (module *goja.Object, err error) {
+func (r *ModuleResolver) resolveFilename(modpath, base string, esm bool) (filename string, native bool, err error) {
+	r.request, r.tried = modpath, nil
+	defer func() {
+		if err != nil {
+			err = &ResolveError{Request: r.request, Base: base, Tried: r.tried, Err: err}
+		}
+		r.request, r.tried = "", nil
+	}()
+
 	origPath, modpath := modpath, path.Clean(modpath)
 	if modpath == "" {
-		return nil, ErrInvalidModule
+		return "", false, ErrInvalidModule
 	}
 
 	var start string
-	err = nil
 	if path.IsAbs(origPath) {
 		start = "/"
 	} else {
-		start = r.getCurrentModulePath()
+		start = base
 	}
 
 	p := path.Join(start, modpath)
-	if strings.HasPrefix(origPath, "./") ||
-		strings.HasPrefix(origPath, "/") || strings.HasPrefix(origPath, "../") ||
-		origPath == "." || origPath == ".." {
-		if module = r.modules[p]; module != nil {
+	if isRelative(origPath) {
+		if filename = r.paths[p]; filename != "" {
 			return
 		}
-		module, err = r.loadAsFileOrDirectory(p)
-		if err == nil && module != nil {
-			r.modules[p] = module
+		filename, err = r.resolveAsFileOrDirectory(p)
+		if err == nil && filename != "" {
+			r.paths[p] = filename
 		}
 	} else {
-		module, err = r.loadNative(origPath)
-		if err == nil {
-			return
-		} else {
-			err = nil
+		if r.isNative(origPath) {
+			return origPath, true, nil
 		}
-		if module = r.nodeModules[p]; module != nil {
+		key := p
+		if esm {
+			key = "import:" + p
+		}
+		if filename = r.nodeModules[key]; filename != "" {
 			return
 		}
-		module, err = r.loadNodeModules(modpath, start)
-		if err == nil && module != nil {
-			r.nodeModules[p] = module
+		conditions := r.conditions(esm)
+		if strings.HasPrefix(origPath, "#") {
+			filename, err = r.resolveImports(origPath, start, conditions)
+		} else if filename, err = r.resolveSelf(modpath, start, conditions); filename == "" && err == nil {
+			filename, err = r.resolveNodeModules(modpath, start, conditions)
+		}
+		if err == nil && filename != "" {
+			r.nodeModules[key] = filename
 		}
 	}
 
-	if module == nil && err == nil {
+	if filename == "" && err == nil {
 		err = ErrInvalidModule
 	}
 	return
 }
 
+// resolveFrom resolves the module relative to the base directory and loads it unless it is already
+// loaded. The module is added to the children of parent which is nil for the modules required by the
+// global require(), by Require() and by ES modules. The modules invalidated in the registry are
+// evaluated again unless the call is made while loading another module.
+func (r *ModuleResolver) resolveFrom(modpath, base string, esm bool, parent *goja.Object) (*goja.Object, error) {
+	if r.loading == 0 {
+		r.reload()
+	}
+	filename, native, err := r.resolveFilename(modpath, base, esm)
+	if err != nil {
+		return nil, withParent(err, parent)
+	}
+	if err := r.checkAllowed(filename); err != nil {
+		return nil, err
+	}
+	if native {
+		return r.loadNative(filename)
+	}
+	module, err := r.loadModule(filename, parent, parent == nil && !esm && r.main == nil)
+	if err == nil {
+		r.addDependent(module, parent)
+	}
+	return module, err
+}
+
 func (r *ModuleResolver) loadNative(name string) (*goja.Object, error) {
 	module := r.modules[name]
 	if module != nil {
 		return module, nil
 	}
 
-	if native := r.registry.natives[name]; native != nil {
-		module = r.createModuleObject()
+	if native := r.native(name); native != nil {
+		r.enableLazy(name)
+		module = r.runtime.NewObject()
+		module.Set("exports", r.runtime.NewObject())
 		native.Export(r.runtime, module)
 		r.modules[name] = module
 		return module, nil
@@ -80,79 +223,104 @@ func (r *ModuleResolver) loadNative(name string) (*goja.Object, error) {
 	return nil, ErrInvalidModule
 }
 
-func (r *ModuleResolver) loadAsFileOrDirectory(path string) (module *goja.Object, err error) {
-	if module, err = r.loadAsFile(path); module != nil || err != nil {
-		return
+// probe returns the path if there is a module file at it or an empty string otherwise. The path is recorded
+// in the list of tried paths of the current resolution and passed to the resolve tracer.
+func (r *ModuleResolver) probe(p string) (filename string, err error) {
+	r.tried = append(r.tried, p)
+	defer func() {
+		if tracer := r.registry.resolveTracer; tracer != nil {
+			tracer(r.request, p, filename != "")
+		}
+	}()
+	r.registry.Lock()
+	_, compiled := r.registry.compiled[p]
+	r.registry.Unlock()
+	if compiled {
+		return p, nil
 	}
-
-	return r.loadAsDirectory(path)
-}
-
-func (r *ModuleResolver) loadAsFile(path string) (module *goja.Object, err error) {
-	if module, err = r.loadModule(path); module != nil || err != nil {
-		return
+	if _, err := r.registry.getSource(p); err != nil {
+		if errors.Is(err, ErrModuleNotExist) {
+			err = nil
+		}
+		return "", err
 	}
+	return p, nil
+}
 
-	p := path + ".js"
-	if module, err = r.loadModule(p); module != nil || err != nil {
+func (r *ModuleResolver) resolveAsFileOrDirectory(path string) (filename string, err error) {
+	if filename, err = r.resolveAsFile(path); filename != "" || err != nil {
 		return
 	}
 
-	p = path + ".json"
-	return r.loadModule(p)
+	return r.resolveAsDirectory(path)
 }
 
-func (r *ModuleResolver) loadIndex(modpath string) (module *goja.Object, err error) {
-	p := path.Join(modpath, "index.js")
-	if module, err = r.loadModule(p); module != nil || err != nil {
+func (r *ModuleResolver) resolveAsFile(path string) (filename string, err error) {
+	if filename, err = r.probe(path); filename != "" || err != nil {
 		return
 	}
 
-	p = path.Join(modpath, "index.json")
-	return r.loadModule(p)
+	for _, ext := range r.registry.probeExtensions() {
+		if filename, err = r.probe(path + ext); filename != "" || err != nil {
+			return
+		}
+	}
+	return
 }
 
-func (r *ModuleResolver) loadAsDirectory(modpath string) (module *goja.Object, err error) {
-	p := path.Join(modpath, "package.json")
-	buf, err := r.registry.getSource(p)
-	if err != nil {
-		return r.loadIndex(modpath)
-	}
-	var pkg struct {
-		Main string
+func (r *ModuleResolver) resolveIndex(modpath string) (filename string, err error) {
+	for _, ext := range r.registry.probeExtensions() {
+		if filename, err = r.probe(path.Join(modpath, "index"+ext)); filename != "" || err != nil {
+			return
+		}
 	}
-	err = json.Unmarshal(buf, &pkg)
-	if err != nil || len(pkg.Main) == 0 {
-		return r.loadIndex(modpath)
+	return
+}
+
+func (r *ModuleResolver) resolveAsDirectory(modpath string) (filename string, err error) {
+	pkg := r.readPackage(modpath)
+	if pkg == nil || len(pkg.Main) == 0 {
+		return r.resolveIndex(modpath)
 	}
 
 	m := path.Join(modpath, pkg.Main)
-	if module, err = r.loadAsFile(m); module != nil || err != nil {
+	if filename, err = r.resolveAsFile(m); filename != "" || err != nil {
 		return
 	}
 
-	return r.loadIndex(m)
+	return r.resolveIndex(m)
 }
 
-func (r *ModuleResolver) loadNodeModule(modpath, start string) (*goja.Object, error) {
-	return r.loadAsFileOrDirectory(path.Join(start, modpath))
+func (r *ModuleResolver) resolveNodeModule(modpath, start string, conditions []string) (string, error) {
+	if filename, err := r.resolveExports(modpath, start, conditions); filename != "" || err != nil {
+		return filename, err
+	}
+	return r.resolveAsFileOrDirectory(path.Join(start, modpath))
 }
 
-func (r *ModuleResolver) loadNodeModules(modpath, start string) (module *goja.Object, err error) {
+func (r *ModuleResolver) resolveNodeModules(modpath, start string, conditions []string) (filename string, err error) {
 	for _, dir := range r.registry.globalFolders {
-		if module, err = r.loadNodeModule(modpath, dir); module != nil || err != nil {
+		if filename, err = r.resolveNodeModule(modpath, dir, conditions); filename != "" || err != nil {
 			return
 		}
 	}
+	for _, dir := range nodeModulePaths(start) {
+		if filename, err = r.resolveNodeModule(modpath, dir, conditions); filename != "" || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// nodeModulePaths returns the node_modules directories searched for the modules required from the
+// given directory, from the closest one up to the root.
+func nodeModulePaths(start string) []string {
+	var paths []string
 	for {
-		var p string
 		if path.Base(start) != "node_modules" {
-			p = path.Join(start, "node_modules")
+			paths = append(paths, path.Join(start, "node_modules"))
 		} else {
-			p = start
-		}
-		if module, err = r.loadNodeModule(modpath, p); module != nil || err != nil {
-			return
+			paths = append(paths, start)
 		}
 		if start == ".." { // Dir('..') is '.'
 			break
@@ -163,8 +331,7 @@ func (r *ModuleResolver) loadNodeModules(modpath, start string) (module *goja.Ob
 		}
 		start = parent
 	}
-
-	return
+	return paths
 }
 
 func (r *ModuleResolver) getCurrentModulePath() string {
@@ -176,33 +343,96 @@ func (r *ModuleResolver) getCurrentModulePath() string {
 	return path.Dir(frames[1].SrcName())
 }
 
-func (r *ModuleResolver) createModuleObject() *goja.Object {
+// createModuleObject creates the module object of the file with the fields of the Node.js module object.
+func (r *ModuleResolver) createModuleObject(filename string, parent *goja.Object) *goja.Object {
+	dir := path.Dir(filename)
 	module := r.runtime.NewObject()
+	module.Set("id", filename)
+	module.Set("path", dir)
 	module.Set("exports", r.runtime.NewObject())
+	module.Set("filename", filename)
+	module.Set("loaded", false)
+	if parent != nil {
+		module.Set("parent", parent)
+	} else {
+		module.Set("parent", goja.Null())
+	}
+	module.Set("children", r.runtime.NewArray())
+	module.Set("paths", r.stringArray(nodeModulePaths(dir)))
+	module.Set("require", r.newRequire(module, filename))
 	return module
 }
 
-func (r *ModuleResolver) loadModule(path string) (*goja.Object, error) {
-	module := r.modules[path]
-	if module == nil {
-		module = r.createModuleObject()
-		r.modules[path] = module
-		err := r.loadModuleFile(path, module)
-		if err != nil {
-			module = nil
-			delete(r.modules, path)
-			if errors.Is(err, ErrModuleNotExist) {
-				err = nil
-			}
+func (r *ModuleResolver) stringArray(list []string) *goja.Object {
+	values := make([]interface{}, len(list))
+	for i, s := range list {
+		values[i] = s
+	}
+	return r.runtime.NewArray(values...)
+}
+
+// addChild appends the module to parent.children unless it's already there.
+func (r *ModuleResolver) addChild(parent, module *goja.Object) {
+	if parent == nil {
+		return
+	}
+	children, ok := parent.Get("children").(*goja.Object)
+	if !ok {
+		return
+	}
+	length := children.Get("length").ToInteger()
+	for i := int64(0); i < length; i++ {
+		if children.Get(strconv.FormatInt(i, 10)).SameAs(module) {
+			return
+		}
+	}
+	children.Set(strconv.FormatInt(length, 10), module)
+}
+
+// loadModule returns the module loaded from the file, evaluating the file unless it's already cached.
+// The first module loaded by the global require() becomes require.main.
+func (r *ModuleResolver) loadModule(filename string, parent *goja.Object, main bool) (*goja.Object, error) {
+	if module := r.modules[filename]; module != nil {
+		r.addChild(parent, module)
+		return module, nil
+	}
+	module := r.createModuleObject(filename, parent)
+	if main {
+		module.Set("id", ".")
+		r.main = module
+	}
+	r.modules[filename] = module
+	r.addChild(parent, module)
+	r.loading++
+	err := r.loadModuleFile(filename, module)
+	r.loading--
+	if err != nil {
+		delete(r.modules, filename)
+		if r.main == module {
+			r.main = nil
 		}
-		return module, err
+		return nil, err
 	}
+	module.Set("loaded", true)
 	return module, nil
 }
 
-func (r *ModuleResolver) loadModuleFile(path string, gojaModule *goja.Object) error {
+func (r *ModuleResolver) loadModuleFile(filename string, gojaModule *goja.Object) error {
+	if h := r.registry.extensionHandler(filename); h != nil && h.Load != nil {
+		src, err := r.registry.getSource(filename)
+		if err != nil {
+			return err
+		}
+		exports, err := h.Load(r.runtime, filename, src)
+		if err != nil {
+			return err
+		}
+		gojaModule.Set("exports", exports)
+		return nil
+	}
 
-	prg, err := r.registry.getCompiledSource(path)
+	esm := r.isESModule(filename)
+	prg, err := r.registry.getCompiledSource(filename, esm)
 
 	if err != nil {
 		return err
@@ -214,14 +444,18 @@ func (r *ModuleResolver) loadModuleFile(path string, gojaModule *goja.Object) er
 	}
 
 	if call, ok := goja.AssertFunction(f); ok {
+		if esm {
+			return r.evaluateESModule(filename, call, gojaModule)
+		}
 		gojaExports := gojaModule.Get("exports")
-		gojaRequire := r.runtime.Get("require")
+		gojaRequire := gojaModule.Get("require")
 
 		// Run the module source, with "gojaExports" as "this",
 		// "gojaExports" as the "exports" variable, "gojaRequire"
 		// as the "require" variable and "gojaModule" as the
 		// "module" variable (Nodegoja capable).
-		_, err = call(gojaExports, gojaExports, gojaRequire, gojaModule)
+		_, err = call(gojaExports, gojaExports, gojaRequire, gojaModule,
+			r.runtime.ToValue(filename), r.runtime.ToValue(path.Dir(filename)), r.newModuleHelper(filename, gojaModule, nil))
 		if err != nil {
 			return err
 		}
@@ -232,25 +466,143 @@ func (r *ModuleResolver) loadModuleFile(path string, gojaModule *goja.Object) er
 	return nil
 }
 
-func (r *ModuleResolver) require(call goja.FunctionCall) goja.Value {
-	ret, err := r.Require(call.Argument(0).String())
+func (r *ModuleResolver) requireFrom(p, base string, parent *goja.Object) (ret goja.Value, err error) {
+	module, err := r.resolveFrom(p, base, false, parent)
 	if err != nil {
-		if _, ok := err.(*goja.Exception); !ok {
-			panic(r.runtime.NewGoError(err))
+		return
+	}
+	if m := r.esModules[module]; m != nil && m.pending() {
+		return nil, ErrAsyncModule
+	}
+	ret = module.Get("exports")
+	return
+}
+
+// newRequire creates the require() function of the module loaded from the file. The global require()
+// (module == nil) resolves the modules relative to the script calling it.
+func (r *ModuleResolver) newRequire(module *goja.Object, filename string) *goja.Object {
+	base := func() string {
+		if module == nil {
+			return r.getCurrentModulePath()
 		}
-		panic(err)
+		return path.Dir(filename)
 	}
-	return ret
+	runtime := r.runtime
+	require := runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+		ret, err := r.requireFrom(call.Argument(0).String(), base(), module)
+		if err != nil {
+			r.throw(err)
+		}
+		return ret
+	}).(*goja.Object)
+
+	resolve := runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+		request := call.Argument(0).String()
+		dir := base()
+		bases := []string{dir}
+		if options, ok := call.Argument(1).(*goja.Object); ok {
+			if paths := options.Get("paths"); paths != nil && !goja.IsUndefined(paths) {
+				bases = nil
+				if err := runtime.ExportTo(paths, &bases); err != nil {
+					panic(runtime.NewTypeError("options.paths must be an array of strings"))
+				}
+				for i, p := range bases {
+					if !path.IsAbs(p) {
+						bases[i] = path.Join(dir, p)
+					}
+				}
+			}
+		}
+		err := ErrInvalidModule
+		for _, b := range bases {
+			var filename string
+			if filename, _, err = r.resolveFilename(request, b, false); err == nil {
+				return runtime.ToValue(filename)
+			}
+		}
+		r.throw(withParent(err, module))
+		return nil
+	}).(*goja.Object)
+	resolve.Set("paths", func(call goja.FunctionCall) goja.Value {
+		request := call.Argument(0).String()
+		if r.isNative(request) {
+			return goja.Null()
+		}
+		dir := base()
+		if isRelative(request) {
+			return r.stringArray([]string{dir})
+		}
+		paths := append(r.registry.globalFolders[:len(r.registry.globalFolders):len(r.registry.globalFolders)], nodeModulePaths(dir)...)
+		return r.stringArray(paths)
+	})
+	require.Set("resolve", resolve)
+
+	if r.cache == nil {
+		r.cache = runtime.NewDynamicObject(moduleCache{r})
+	}
+	require.Set("cache", r.cache)
+	require.DefineAccessorProperty("main", runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		if r.main == nil {
+			return goja.Undefined()
+		}
+		return r.main
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	return require
+}
+
+// moduleCache implements require.cache: the modules loaded from files keyed by the file name. Deleting
+// a module from the cache makes the next require() evaluate the file again.
+type moduleCache struct {
+	r *ModuleResolver
+}
+
+func (c moduleCache) Get(key string) goja.Value {
+	if module := c.r.modules[key]; module != nil && !c.r.isNative(key) {
+		return module
+	}
+	return nil
+}
+
+func (c moduleCache) Set(key string, val goja.Value) bool {
+	module, ok := val.(*goja.Object)
+	if !ok || c.r.isNative(key) {
+		return false
+	}
+	c.r.modules[key] = module
+	return true
+}
+
+func (c moduleCache) Has(key string) bool {
+	return c.Get(key) != nil
+}
+
+func (c moduleCache) Delete(key string) bool {
+	if c.r.isNative(key) {
+		return true
+	}
+	if module := c.r.modules[key]; module != nil {
+		delete(c.r.esModules, module)
+		delete(c.r.modules, key)
+	}
+	return true
+}
+
+func (c moduleCache) Keys() []string {
+	keys := make([]string, 0, len(c.r.modules))
+	for key := range c.r.modules {
+		if !c.r.isNative(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Require can be used to import modules from Go source (similar to goja require() function).
+// Requiring an ES module returns its namespace object. ErrAsyncModule is returned if the module
+// has not finished evaluating because of top-level await.
 func (r *ModuleResolver) Require(p string) (ret goja.Value, err error) {
-	module, err := r.resolve(p)
-	if err != nil {
-		return
-	}
-	ret = module.Get("exports")
-	return
+	return r.requireFrom(p, r.getCurrentModulePath(), nil)
 }
 
 func Require(runtime *goja.Runtime, name string) (goja.Value, error) {
@@ -0,0 +1,207 @@
+package require
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// invalidation records the modules invalidated in the registry. Every invalidation increments the
+// generation, the resolvers compare it with the generation they have seen to find out what to reload.
+type invalidation struct {
+	generation uint64
+	all        uint64
+	paths      map[string]uint64
+}
+
+// Invalidate drops the compiled program of the module at the given path. The runtimes using the registry
+// evaluate the module again on the next require() or import, as well as all the loaded modules depending on it.
+// The modules registered with RegisterJSModule() keep their programs but are evaluated again too.
+func (r *Registry) Invalidate(path string) {
+	r.Lock()
+	defer r.Unlock()
+	if !r.registered[path] {
+		delete(r.compiled, path)
+	}
+	r.invalidated.generation++
+	if r.invalidated.paths == nil {
+		r.invalidated.paths = make(map[string]uint64)
+	}
+	r.invalidated.paths[path] = r.invalidated.generation
+}
+
+// InvalidateAll drops all compiled programs except the ones registered with RegisterJSModule(). The runtimes
+// using the registry evaluate all modules again except the native ones.
+func (r *Registry) InvalidateAll() {
+	r.Lock()
+	defer r.Unlock()
+	for name := range r.compiled {
+		if !r.registered[name] {
+			delete(r.compiled, name)
+		}
+	}
+	r.invalidated.generation++
+	r.invalidated.all = r.invalidated.generation
+	r.invalidated.paths = nil
+}
+
+// invalidatedSince returns the current generation and the paths invalidated after the given one, all is
+// true if InvalidateAll() was called in the meantime.
+func (r *Registry) invalidatedSince(generation uint64) (current uint64, all bool, paths []string) {
+	r.Lock()
+	defer r.Unlock()
+	current = r.invalidated.generation
+	if current == generation {
+		return
+	}
+	if r.invalidated.all > generation {
+		return current, true, nil
+	}
+	for p, g := range r.invalidated.paths {
+		if g > generation {
+			paths = append(paths, p)
+		}
+	}
+	return
+}
+
+// Watch starts polling the module sources read by the registry every interval and invalidates the modules
+// whose contents changed or which were removed (see Invalidate()). The sources are read with the registry's
+// SourceLoader, so this works with any loader, not only with files on the host's filesystem. The package.json
+// files are watched as well. The registry records the checksums of all the sources it reads, so the modules
+// loaded before Watch() is called are watched too. Call the returned function to stop watching.
+func (r *Registry) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.poll()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+	}
+}
+
+// watch remembers the checksum of the source at the given path, which Watch() compares with the current one.
+func (r *Registry) watch(p string, src []byte) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	if r.watched == nil {
+		r.watched = make(map[string][sha256.Size]byte)
+	}
+	r.watched[p] = sha256.Sum256(src)
+}
+
+func (r *Registry) poll() {
+	r.watchMu.Lock()
+	watched := make(map[string][sha256.Size]byte, len(r.watched))
+	for p, sum := range r.watched {
+		watched[p] = sum
+	}
+	r.watchMu.Unlock()
+
+	for p, sum := range watched {
+		src, err := r.getSource(p)
+		if err == nil && sha256.Sum256(src) == sum {
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrModuleNotExist) {
+			// Keep the module if the source cannot be read temporarily
+			continue
+		}
+		r.watchMu.Lock()
+		if err != nil {
+			delete(r.watched, p)
+		} else {
+			r.watched[p] = sha256.Sum256(src)
+		}
+		r.watchMu.Unlock()
+		r.Invalidate(p)
+	}
+}
+
+// addDependent records that the module is required or imported by the parent module, so the parent is
+// evaluated again if the module is invalidated.
+func (r *ModuleResolver) addDependent(module, parent *goja.Object) {
+	if parent == nil || module == parent {
+		return
+	}
+	dependents := r.dependents[module]
+	if dependents == nil {
+		dependents = make(map[*goja.Object]struct{})
+		r.dependents[module] = dependents
+	}
+	dependents[parent] = struct{}{}
+}
+
+// reload removes the modules invalidated in the registry since the last check and the modules depending on
+// them from the cache, so the next require() evaluates them again.
+func (r *ModuleResolver) reload() {
+	generation, all, paths := r.registry.invalidatedSince(r.generation)
+	if generation == r.generation {
+		return
+	}
+	r.generation = generation
+
+	// A changed package.json or a new file can change the resolution of any module
+	r.paths = make(map[string]string)
+	r.nodeModules = make(map[string]string)
+	r.packages = make(map[string]*packageJSON)
+
+	names := make(map[*goja.Object]string, len(r.modules))
+	for name, module := range r.modules {
+		if !r.isNative(name) {
+			names[module] = name
+		}
+	}
+	removed := make(map[*goja.Object]struct{})
+	var queue []*goja.Object
+	if all {
+		for module := range names {
+			queue = append(queue, module)
+		}
+	} else {
+		for _, p := range paths {
+			if module := r.modules[p]; module != nil {
+				queue = append(queue, module)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		module := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		name, exists := names[module]
+		if !exists {
+			continue
+		}
+		delete(names, module)
+		removed[module] = struct{}{}
+		delete(r.modules, name)
+		delete(r.esModules, module)
+		if r.main == module {
+			r.main = nil
+		}
+		for dependent := range r.dependents[module] {
+			queue = append(queue, dependent)
+		}
+		delete(r.dependents, module)
+	}
+	for _, dependents := range r.dependents {
+		for module := range removed {
+			delete(dependents, module)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package require
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func TestInvalidate(t *testing.T) {
+	files := map[string]string{
+		"/app/a.js":   `exports.value = require("./b.js").value + require("./c.js").value;`,
+		"/app/b.js":   `exports.value = "b1";`,
+		"/app/c.js":   `globalThis.cLoads = (globalThis.cLoads || 0) + 1; exports.value = "c";`,
+		"/app/d.mjs":  `import { value } from "./b.js"; export const d = value;`,
+		"/app/e.js":   `exports.value = "e";`,
+		"/app/f.json": `{"value": 1}`,
+	}
+	var mu sync.Mutex
+	loader := func(p string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return mapFileSystemSourceLoader(files)(p)
+	}
+	r := NewRegistry(WithLoader(loader))
+	if err := r.RegisterJSModule("/app/registered.js", `globalThis.rLoads = (globalThis.rLoads || 0) + 1;`); err != nil {
+		t.Fatal(err)
+	}
+	vm := goja.New()
+	rr := r.Enable(vm)
+
+	run := func(script string) string {
+		t.Helper()
+		v, err := vm.RunScript("/app/test.js", script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.String()
+	}
+	importD := func() string {
+		t.Helper()
+		p := rr.Import("/app/d.mjs")
+		if p.State() != goja.PromiseStateFulfilled {
+			t.Fatalf("Unexpected promise state: %v", p.State())
+		}
+		return p.Result().ToObject(vm).Get("d").String()
+	}
+	const script = `
+	var e1 = e;
+	var e = require("./e.js");
+	require("./registered.js");
+	[require("./a.js").value, cLoads, rLoads, require("./f.json").value, e === e1].join();
+	`
+	if s := run(script); s != "b1c,1,1,1,false" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if s := importD(); s != "b1" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// The dependents of b.js are evaluated again, c.js and e.js are not
+	mu.Lock()
+	files["/app/b.js"] = `exports.value = "b2";`
+	mu.Unlock()
+	r.Invalidate("/app/b.js")
+	if s := run(`[require("./a.js").value, cLoads, require("./e.js") === e].join()`); s != "b2c,1,true" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	if s := importD(); s != "b2" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// All modules are evaluated again, the registered ones keep their programs
+	mu.Lock()
+	files["/app/f.json"] = `{"value": 2}`
+	mu.Unlock()
+	r.InvalidateAll()
+	if s := run(script); s != "b2c,2,2,2,false" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	files := map[string]string{
+		"/app/a.js": `exports.value = require("./b.js").value;`,
+		"/app/b.js": `exports.value = 1;`,
+		"/app/c.js": `exports.value = "c";`,
+	}
+	var mu sync.Mutex
+	r := NewRegistry(WithLoader(func(p string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return mapFileSystemSourceLoader(files)(p)
+	}))
+	stop := r.Watch(time.Millisecond)
+	defer stop()
+
+	vm := goja.New()
+	r.Enable(vm)
+	const script = `[require("./a.js").value, require("./c").value].join()`
+	run := func() string {
+		t.Helper()
+		v, err := vm.RunScript("/app/test.js", script)
+		if err != nil {
+			return err.Error()
+		}
+		return v.String()
+	}
+	if s := run(); s != "1,c" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+	mu.Lock()
+	files["/app/b.js"] = `exports.value = 2;`
+	delete(files, "/app/c.js")
+	files["/app/c/index.js"] = `exports.value = "c/index";`
+	mu.Unlock()
+
+	// The modules are not evaluated again until the change is detected
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := run()
+		if s == "2,c/index" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Change was not detected: %s", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchAfterLoad(t *testing.T) {
+	files := map[string]string{
+		"/app/a.js": `exports.value = 1;`,
+	}
+	var mu sync.Mutex
+	r := NewRegistry(WithLoader(func(p string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return mapFileSystemSourceLoader(files)(p)
+	}))
+	vm := goja.New()
+	r.Enable(vm)
+	run := func() string {
+		t.Helper()
+		v, err := vm.RunScript("/app/test.js", `require("./a.js").value`)
+		if err != nil {
+			return err.Error()
+		}
+		return v.String()
+	}
+	if s := run(); s != "1" {
+		t.Fatalf("Unexpected result: %s", s)
+	}
+
+	// The module was loaded before Watch() was called, it must be watched anyway
+	stop := r.Watch(time.Millisecond)
+	defer stop()
+	mu.Lock()
+	files["/app/a.js"] = `exports.value = 2;`
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s := run()
+		if s == "2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Change was not detected: %s", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,67 @@
+package timers
+
+import (
+	"github.com/dop251/goja"
+)
+
+const (
+	ModuleName         = "node:timers"
+	PromisesModuleName = "node:timers/promises"
+)
+
+var (
+	defaultModule  = TimersModule{}
+	promisesModule = PromisesModule{}
+)
+
+var timerFuncNames = []string{"setTimeout", "setInterval", "setImmediate", "clearTimeout", "clearInterval", "clearImmediate"}
+
+// TimersModule exports the global timer functions installed by the event loop as the node:timers module.
+// The modules are added by eventloop.EnableTimers, or can be registered by the host like any other.
+type TimersModule struct {
+}
+
+func (m *TimersModule) Enable(runtime *goja.Runtime) {
+}
+
+func (m *TimersModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	obj := module.Get("exports").(*goja.Object)
+	for _, name := range timerFuncNames {
+		obj.Set(name, runtime.GlobalObject().Get(name))
+	}
+	promises := runtime.NewObject()
+	promisesModule.exportTo(runtime, promises)
+	obj.Set("promises", promises)
+}
+
+// PromisesModule implements the node:timers/promises module on top of the global timer functions.
+// The signal option accepts the AbortSignals of the events package, enabled by eventloop.EnableTimers, or any
+// object with the aborted and reason properties and the addEventListener and removeEventListener methods.
+type PromisesModule struct {
+}
+
+func (m *PromisesModule) Enable(runtime *goja.Runtime) {
+}
+
+func (m *PromisesModule) Export(runtime *goja.Runtime, module *goja.Object) {
+	m.exportTo(runtime, module.Get("exports").(*goja.Object))
+}
+
+func (m *PromisesModule) exportTo(runtime *goja.Runtime, obj *goja.Object) {
+	t := newTimerPromises(runtime)
+	obj.Set("setTimeout", t.setTimeout)
+	obj.Set("setImmediate", t.setImmediate)
+	obj.Set("setInterval", t.setInterval)
+	scheduler := runtime.NewObject()
+	scheduler.Set("wait", t.wait)
+	scheduler.Set("yield", t.yield)
+	obj.Set("scheduler", scheduler)
+}
+
+func Default() *TimersModule {
+	return &defaultModule
+}
+
+func Promises() *PromisesModule {
+	return &promisesModule
+}
@@ -0,0 +1,120 @@
+package timers_test
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/eventloop"
+)
+
+func runScript(t *testing.T, script string) goja.Value {
+	t.Helper()
+	var result goja.Value
+	loop := eventloop.NewEventLoop(eventloop.EnableTimers())
+	loop.Run(func(vm *goja.Runtime) {
+		v, err := vm.RunString(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = v
+	})
+	p, ok := result.Export().(*goja.Promise)
+	if !ok {
+		t.Fatalf("expected a promise, got %v", result)
+	}
+	if p.State() != goja.PromiseStateFulfilled {
+		t.Fatalf("unexpected promise state %v: %v", p.State(), p.Result())
+	}
+	return p.Result()
+}
+
+func TestPromises(t *testing.T) {
+	t.Parallel()
+	res := runScript(t, `
+	const { setTimeout, setImmediate, scheduler } = require("node:timers/promises");
+	(async function() {
+		const log = [];
+		const a = setTimeout(20, "timeout").then(v => log.push(v));
+		const b = setImmediate("immediate").then(v => log.push(v));
+		await scheduler.wait(5);
+		log.push("wait");
+		await scheduler.yield();
+		await Promise.all([a, b]);
+		return log.join();
+	})();
+	`)
+	if s := res.String(); s != "immediate,wait,timeout" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	t.Parallel()
+	res := runScript(t, `
+	const { setInterval } = require("node:timers/promises");
+	(async function() {
+		const it = setInterval(5, "tick");
+		const log = [];
+		for (let i = 0; i < 3; i++) {
+			const { value, done } = await it.next();
+			log.push(value, done);
+		}
+		await it.return();
+		const { done } = await it.next();
+		log.push(done);
+		return log.join();
+	})();
+	`)
+	if s := res.String(); s != "tick,false,tick,false,tick,false,true" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	t.Parallel()
+	res := runScript(t, `
+	const timers = require("node:timers");
+	(async function() {
+		const log = [];
+		const controller = new AbortController();
+		const signal = controller.signal;
+		const p = timers.promises.setTimeout(10000, "never", { signal });
+		const it = timers.promises.setInterval(10000, "never", { signal });
+		const next = it.next();
+		timers.setTimeout(() => controller.abort("stop"), 5);
+		for (const promise of [p, next]) {
+			try {
+				await promise;
+			} catch (e) {
+				log.push(e.name, e.code, e.cause);
+			}
+		}
+		try {
+			await timers.promises.setImmediate("never", { signal });
+		} catch (e) {
+			log.push(e.name);
+		}
+		return log.join();
+	})();
+	`)
+	if s := res.String(); s != "AbortError,ABORT_ERR,stop,AbortError,ABORT_ERR,stop,AbortError" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+}
+
+func TestAbortSignalTimeout(t *testing.T) {
+	t.Parallel()
+	res := runScript(t, `
+	const { setTimeout } = require("node:timers/promises");
+	(async function() {
+		try {
+			await setTimeout(10000, "never", { signal: AbortSignal.timeout(5) });
+		} catch (e) {
+			return [e.name, e.code, e.cause.name, e.cause.message].join();
+		}
+	})();
+	`)
+	if s := res.String(); s != "AbortError,ABORT_ERR,TimeoutError,The operation was aborted due to timeout" {
+		t.Fatalf("unexpected result: %s", s)
+	}
+}
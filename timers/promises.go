@@ -0,0 +1,259 @@
+package timers
+
+import (
+	"github.com/dop251/goja"
+)
+
+type timerPromises struct {
+	runtime *goja.Runtime
+
+	setTimeoutFn, setIntervalFn, setImmediateFn       goja.Callable
+	clearTimeoutFn, clearIntervalFn, clearImmediateFn goja.Callable
+}
+
+type timerOptions struct {
+	signal *goja.Object
+	ref    bool
+}
+
+type promiseCallbacks struct {
+	resolve, reject func(interface{})
+}
+
+func newTimerPromises(runtime *goja.Runtime) *timerPromises {
+	t := &timerPromises{runtime: runtime}
+	funcs := []*goja.Callable{
+		&t.setTimeoutFn, &t.setIntervalFn, &t.setImmediateFn,
+		&t.clearTimeoutFn, &t.clearIntervalFn, &t.clearImmediateFn,
+	}
+	for i, name := range timerFuncNames {
+		fn, ok := goja.AssertFunction(runtime.GlobalObject().Get(name))
+		if !ok {
+			panic(runtime.NewTypeError("%s is not defined, timers require an event loop", name))
+		}
+		*funcs[i] = fn
+	}
+	return t
+}
+
+func (t *timerPromises) call(fn goja.Callable, args ...goja.Value) goja.Value {
+	ret, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func (t *timerPromises) callMethod(obj *goja.Object, name string, args ...goja.Value) {
+	if fn, ok := goja.AssertFunction(obj.Get(name)); ok {
+		if _, err := fn(obj, args...); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (t *timerPromises) parseOptions(v goja.Value) timerOptions {
+	opts := timerOptions{ref: true}
+	if goja.IsUndefined(v) {
+		return opts
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		panic(t.runtime.NewTypeError(`The "options" argument must be of type object`))
+	}
+	if signal := obj.Get("signal"); signal != nil && !goja.IsUndefined(signal) {
+		s, ok := signal.(*goja.Object)
+		if !ok || s.Get("aborted") == nil {
+			panic(t.runtime.NewTypeError(`The "options.signal" property must be an instance of AbortSignal`))
+		}
+		opts.signal = s
+	}
+	if ref := obj.Get("ref"); ref != nil && !goja.IsUndefined(ref) {
+		opts.ref = ref.ToBoolean()
+	}
+	return opts
+}
+
+func (t *timerPromises) abortError(signal *goja.Object) goja.Value {
+	err, _ := t.runtime.New(t.runtime.Get("Error"), t.runtime.ToValue("The operation was aborted"))
+	err.Set("name", "AbortError")
+	err.Set("code", "ABORT_ERR")
+	err.Set("cause", signal.Get("reason"))
+	return err
+}
+
+// onAbort calls fn once the signal is aborted. The returned function removes the listener.
+func (t *timerPromises) onAbort(signal *goja.Object, fn func()) func() {
+	listener := t.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		fn()
+		return goja.Undefined()
+	})
+	options := t.runtime.NewObject()
+	options.Set("once", true)
+	t.callMethod(signal, "addEventListener", t.runtime.ToValue("abort"), listener, options)
+	return func() {
+		t.callMethod(signal, "removeEventListener", t.runtime.ToValue("abort"), listener)
+	}
+}
+
+// unref calls the unref() method of the timer if it has one.
+func (t *timerPromises) unref(timer goja.Value) {
+	if obj, ok := timer.(*goja.Object); ok {
+		t.callMethod(obj, "unref")
+	}
+}
+
+func (t *timerPromises) schedule(set, clear goja.Callable, opts timerOptions, value goja.Value, args ...goja.Value) goja.Value {
+	p, resolve, reject := t.runtime.NewPromise()
+	if opts.signal != nil && opts.signal.Get("aborted").ToBoolean() {
+		reject(t.abortError(opts.signal))
+		return t.runtime.ToValue(p)
+	}
+	var removeListener func()
+	callback := t.runtime.ToValue(func(goja.FunctionCall) goja.Value {
+		if removeListener != nil {
+			removeListener()
+		}
+		resolve(value)
+		return goja.Undefined()
+	})
+	timer := t.call(set, append([]goja.Value{callback}, args...)...)
+	if !opts.ref {
+		t.unref(timer)
+	}
+	if opts.signal != nil {
+		removeListener = t.onAbort(opts.signal, func() {
+			t.call(clear, timer)
+			reject(t.abortError(opts.signal))
+		})
+	}
+	return t.runtime.ToValue(p)
+}
+
+func (t *timerPromises) setTimeout(call goja.FunctionCall) goja.Value {
+	opts := t.parseOptions(call.Argument(2))
+	return t.schedule(t.setTimeoutFn, t.clearTimeoutFn, opts, call.Argument(1), call.Argument(0))
+}
+
+func (t *timerPromises) setImmediate(call goja.FunctionCall) goja.Value {
+	opts := t.parseOptions(call.Argument(1))
+	return t.schedule(t.setImmediateFn, t.clearImmediateFn, opts, call.Argument(0))
+}
+
+func (t *timerPromises) wait(call goja.FunctionCall) goja.Value {
+	opts := t.parseOptions(call.Argument(1))
+	return t.schedule(t.setTimeoutFn, t.clearTimeoutFn, opts, goja.Undefined(), call.Argument(0))
+}
+
+func (t *timerPromises) yield(call goja.FunctionCall) goja.Value {
+	return t.schedule(t.setImmediateFn, t.clearImmediateFn, timerOptions{ref: true}, goja.Undefined())
+}
+
+// intervalIterator is the async iterator returned by setInterval of node:timers/promises. Ticks which
+// happen while no next() call is pending are queued and returned by the following calls.
+type intervalIterator struct {
+	t              *timerPromises
+	value          goja.Value
+	timer          goja.Value
+	removeListener func()
+	waiting        []promiseCallbacks
+	notYielded     int
+	done           bool
+	abortErr       goja.Value
+}
+
+func (it *intervalIterator) result(value goja.Value, done bool) *goja.Object {
+	res := it.t.runtime.NewObject()
+	res.Set("value", value)
+	res.Set("done", done)
+	return res
+}
+
+func (it *intervalIterator) tick(goja.FunctionCall) goja.Value {
+	if len(it.waiting) > 0 {
+		w := it.waiting[0]
+		it.waiting = it.waiting[1:]
+		w.resolve(it.result(it.value, false))
+	} else {
+		it.notYielded++
+	}
+	return goja.Undefined()
+}
+
+func (it *intervalIterator) stop() {
+	if it.done {
+		return
+	}
+	it.done = true
+	if it.timer != nil {
+		it.t.call(it.t.clearIntervalFn, it.timer)
+	}
+	if it.removeListener != nil {
+		it.removeListener()
+	}
+}
+
+func (it *intervalIterator) next(goja.FunctionCall) goja.Value {
+	p, resolve, reject := it.t.runtime.NewPromise()
+	switch {
+	case it.abortErr != nil:
+		reject(it.abortErr)
+	case it.notYielded > 0:
+		it.notYielded--
+		resolve(it.result(it.value, false))
+	case it.done:
+		resolve(it.result(goja.Undefined(), true))
+	default:
+		it.waiting = append(it.waiting, promiseCallbacks{resolve, reject})
+	}
+	return it.t.runtime.ToValue(p)
+}
+
+func (it *intervalIterator) ret(goja.FunctionCall) goja.Value {
+	it.stop()
+	it.notYielded = 0
+	for _, w := range it.waiting {
+		w.resolve(it.result(goja.Undefined(), true))
+	}
+	it.waiting = nil
+	p, resolve, _ := it.t.runtime.NewPromise()
+	resolve(it.result(goja.Undefined(), true))
+	return it.t.runtime.ToValue(p)
+}
+
+func (it *intervalIterator) abort(signal *goja.Object) {
+	it.abortErr = it.t.abortError(signal)
+	it.stop()
+	for _, w := range it.waiting {
+		w.reject(it.abortErr)
+	}
+	it.waiting = nil
+}
+
+func (t *timerPromises) setInterval(call goja.FunctionCall) goja.Value {
+	opts := t.parseOptions(call.Argument(2))
+	it := &intervalIterator{t: t, value: call.Argument(1)}
+	obj := t.runtime.NewObject()
+	obj.Set("next", it.next)
+	obj.Set("return", it.ret)
+	if sym, ok := t.runtime.Get("Symbol").ToObject(t.runtime).Get("asyncIterator").(*goja.Symbol); ok {
+		self := t.runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+			return call.This
+		})
+		obj.DefineDataPropertySymbol(sym, self, goja.FLAG_TRUE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+	if opts.signal != nil && opts.signal.Get("aborted").ToBoolean() {
+		it.abort(opts.signal)
+		return obj
+	}
+	it.timer = t.call(t.setIntervalFn, t.runtime.ToValue(it.tick), call.Argument(0))
+	if !opts.ref {
+		t.unref(it.timer)
+	}
+	if opts.signal != nil {
+		it.removeListener = t.onAbort(opts.signal, func() {
+			it.abort(opts.signal)
+		})
+	}
+	return obj
+}
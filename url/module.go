@@ -358,6 +358,11 @@ func (m *UrlModule) Enable(runtime *goja.Runtime) {
 	runtime.Set("URL", urlCtor)
 }
 
+// Globals returns the names of the globals set by Enable.
+func (m *UrlModule) Globals() []string {
+	return []string{"URL"}
+}
+
 func (m *UrlModule) Export(runtime *goja.Runtime, module *goja.Object) {
 	urlCtor := runtime.Get("URL")
 	exports := module.Get("exports").(*goja.Object)
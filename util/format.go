@@ -1,13 +1,13 @@
 package util
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/dop251/goja"
 )
@@ -99,6 +99,9 @@ func marshalCircular(v interface{}, visit func(uintptr) bool) string {
 // the object with [Circular] and returning a marshaling error.
 // ⚠️ IMPORTANT: you MUST pass a pointer to the struct object as an argument.
 // Failing to do so may cause improper memory management and unnecessary copying of the struct.
+//
+// Deprecated: the %j format specifier uses the JSON.stringify() of the runtime instead, which handles toJSON
+// methods and the JavaScript objects correctly.
 func JSONStringify(v interface{}) ([]byte, error) {
 	var visited = make(map[uintptr]bool)
 	var err error
@@ -114,54 +117,234 @@ func JSONStringify(v interface{}) ([]byte, error) {
 	return []byte(wrappedValue), err
 }
 
-func replaceSpecifier(s rune, val goja.Value, buf *bytes.Buffer) bool {
+// builtInObjects are the names of the built-in constructors. The instances of their subclasses are formatted with
+// %s like the instances of the built-ins as long as they don't override toString.
+var builtInObjects = map[string]bool{
+	"Object": true, "Function": true, "Array": true, "Number": true, "Boolean": true, "String": true,
+	"Symbol": true, "Date": true, "Promise": true, "RegExp": true, "Error": true, "AggregateError": true,
+	"EvalError": true, "RangeError": true, "ReferenceError": true, "SyntaxError": true, "TypeError": true,
+	"URIError": true, "ArrayBuffer": true, "SharedArrayBuffer": true, "DataView": true, "Uint8Array": true,
+	"Int8Array": true, "Uint16Array": true, "Int16Array": true, "Uint32Array": true, "Int32Array": true,
+	"Float32Array": true, "Float64Array": true, "Uint8ClampedArray": true, "BigInt": true,
+	"BigUint64Array": true, "BigInt64Array": true, "Map": true, "Set": true, "WeakMap": true, "WeakSet": true,
+	"WeakRef": true, "FinalizationRegistry": true, "Proxy": true, "Reflect": true, "JSON": true, "Math": true,
+}
+
+// callBuiltin calls the method of the global object like JSON.stringify and throws the exceptions.
+func callBuiltin(runtime *goja.Runtime, name, method string, args ...goja.Value) goja.Value {
+	fn, ok := goja.AssertFunction(runtime.GlobalObject().Get(name).ToObject(runtime).Get(method))
+	if !ok {
+		panic(runtime.NewTypeError(name + "." + method + " is not a function"))
+	}
+	ret, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// hasBuiltInToString reports whether the toString method of the object is inherited from a built-in, in which
+// case the object is inspected by %s instead of being converted to a string.
+func hasBuiltInToString(runtime *goja.Runtime, obj *goja.Object) bool {
+	if obj.ExportType() == typeProxy {
+		proxy := obj.Export().(goja.Proxy)
+		if proxy.Target() == nil {
+			return true
+		}
+		obj = proxy.Target()
+	}
+	if _, ok := goja.AssertFunction(obj.GetSymbol(goja.SymToPrimitive)); ok {
+		return false
+	}
+	if _, ok := goja.AssertFunction(obj.Get("toString")); !ok {
+		return true
+	}
+	hasOwn := func(o *goja.Object) bool {
+		return callBuiltin(runtime, "Object", "hasOwn", o, runtime.ToValue("toString")).ToBoolean()
+	}
+	if hasOwn(obj) {
+		return false
+	}
+	pointer := obj
+	for {
+		if pointer = pointer.Prototype(); pointer == nil {
+			return false
+		}
+		if hasOwn(pointer) {
+			break
+		}
+	}
+	desc, ok := callBuiltin(runtime, "Object", "getOwnPropertyDescriptor", pointer, runtime.ToValue("constructor")).(*goja.Object)
+	if !ok {
+		return false
+	}
+	ctor, ok := desc.Get("value").(*goja.Object)
+	if !ok || !isFunction(ctor) {
+		return false
+	}
+	return builtInObjects[ctor.Get("name").String()]
+}
+
+func isFunction(obj *goja.Object) bool {
+	_, ok := goja.AssertFunction(obj)
+	return ok
+}
+
+// tryStringify returns the JSON of the value like JSON.stringify() does, or [Circular] if the value contains
+// circular references.
+func tryStringify(runtime *goja.Runtime, v goja.Value) (s string) {
+	defer func() {
+		if x := recover(); x != nil {
+			if ex, ok := x.(*goja.Exception); ok && isCircularError(runtime, ex.Value()) {
+				s = CircularNotation
+				return
+			}
+			panic(x)
+		}
+	}()
+	return callBuiltin(runtime, "JSON", "stringify", v).String()
+}
+
+// isCircularError reports whether the error is the TypeError thrown by JSON.stringify() for circular structures.
+// The message is taken from the runtime, because it's implementation specific.
+func isCircularError(runtime *goja.Runtime, err goja.Value) bool {
+	obj, ok := err.(*goja.Object)
+	if !ok || obj == nil || obj.Get("name").String() != "TypeError" {
+		return false
+	}
+	circular := runtime.NewObject()
+	circular.Set("a", circular)
+	var expected string
+	if ex := runtime.Try(func() { callBuiltin(runtime, "JSON", "stringify", circular) }); ex != nil {
+		expected = firstLine(ex.Value().ToObject(runtime).Get("message").String())
+	}
+	return expected != "" && firstLine(obj.Get("message").String()) == expected
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// formatSpecifier returns the replacement of the format specifier for the argument. The second return value is
+// false if the character isn't a format specifier.
+func formatSpecifier(runtime *goja.Runtime, options InspectOptions, s byte, arg goja.Value) (string, bool) {
+	_, isSymbol := arg.(*goja.Symbol)
 	switch s {
 	case 's':
-		buf.WriteString(val.String())
+		if isNumber(arg) {
+			return formatNumber(stylizeNoColor, arg), true
+		}
+		if isSymbol {
+			return symbolString(arg.(*goja.Symbol)), true
+		}
+		if obj, ok := arg.(*goja.Object); ok && obj != nil && !isFunction(obj) && hasBuiltInToString(runtime, obj) {
+			options.Depth = 0
+			options.Colors = false
+			options.Compact = 3
+			return Inspect(runtime, arg, options), true
+		}
+		return arg.String(), true
+	case 'j':
+		return tryStringify(runtime, arg), true
 	case 'd':
-		buf.WriteString(val.ToNumber().String())
+		if isSymbol {
+			return "NaN", true
+		}
+		return formatNumber(stylizeNoColor, arg.ToNumber()), true
+	case 'O':
+		return Inspect(runtime, arg, options), true
+	case 'o':
+		options.ShowHidden = true
+		options.ShowProxy = true
+		options.Depth = 4
+		return Inspect(runtime, arg, options), true
 	case 'i':
-		buf.WriteString(strconv.Itoa(int(val.ToInteger())))
-	case 'j':
-		data, _ := JSONStringify(val.Export())
-		buf.WriteString(string(data))
-	case '%':
-		buf.WriteByte('%')
-		return false
-	default:
-		buf.WriteByte('%')
-		buf.WriteRune(s)
-		return false
+		if isSymbol {
+			return "NaN", true
+		}
+		str := arg.ToString()
+		n := callBuiltin(runtime, "Number", "parseInt", str)
+		if n.ToFloat() == 0 && strings.HasPrefix(strings.TrimSpace(str.String()), "-") {
+			// The parseInt of goja loses the sign of zero
+			return "-0", true
+		}
+		return formatNumber(stylizeNoColor, n), true
+	case 'f':
+		if isSymbol {
+			return "NaN", true
+		}
+		return formatNumber(stylizeNoColor, callBuiltin(runtime, "Number", "parseFloat", arg)), true
+	case 'c':
+		// CSS styles are ignored
+		return "", true
 	}
-	return true
+	return "", false
 }
 
 // Format is a native implementation of Node.js util.format(). This function replaces format specifiers
 // with the provided goja values and returns the resulting string as a goja.Value.
-// Supported format specifiers: %s, %d, %i, %j, %%.
+// Supported format specifiers: %s, %d, %i, %f, %j, %o, %O, %c, %%.
 func Format(runtime *goja.Runtime, format string, args ...goja.Value) goja.Value {
-	pct := false
-	argNum := 0
-	buf := &bytes.Buffer{}
-	for _, chr := range format {
-		if pct {
-			pct = false
-			if argNum >= len(args) {
-				buf.WriteByte('%')
-				buf.WriteRune(chr)
-			} else if replaceSpecifier(chr, args[argNum], buf) {
-				argNum++
+	return runtime.ToValue(FormatWithOptions(runtime, DefaultInspectOptions, append([]goja.Value{runtime.ToValue(format)}, args...)...))
+}
+
+// FormatWithOptions is a native implementation of Node.js util.formatWithOptions(). The first argument is used
+// as the format string if it's a string. The arguments which aren't consumed by the format specifiers are
+// appended separated by spaces, they're inspected with the options unless they're strings.
+func FormatWithOptions(runtime *goja.Runtime, options InspectOptions, args ...goja.Value) string {
+	buf := &strings.Builder{}
+	a := 0
+	join := ""
+	if len(args) > 0 && isString(args[0]) {
+		first := args[0].String()
+		if len(args) == 1 {
+			return first
+		}
+		lastPos := 0
+		for i := 0; i < len(first)-1; i++ {
+			if first[i] != '%' {
+				continue
 			}
-		} else if chr == '%' {
-			pct = true
-		} else {
-			buf.WriteRune(chr)
+			i++
+			if a+1 == len(args) {
+				if first[i] == '%' {
+					buf.WriteString(first[lastPos:i])
+					lastPos = i + 1
+				}
+				continue
+			}
+			if first[i] == '%' {
+				buf.WriteString(first[lastPos:i])
+				lastPos = i + 1
+				continue
+			}
+			s, ok := formatSpecifier(runtime, options, first[i], args[a+1])
+			if !ok {
+				continue
+			}
+			a++
+			buf.WriteString(first[lastPos : i-1])
+			buf.WriteString(s)
+			lastPos = i + 1
+		}
+		if lastPos != 0 {
+			a++
+			join = " "
+			buf.WriteString(first[lastPos:])
 		}
 	}
 
-	for _, arg := range args[argNum:] {
-		buf.WriteByte(' ')
-		buf.WriteString(arg.String())
+	for ; a < len(args); a++ {
+		buf.WriteString(join)
+		if isString(args[a]) {
+			buf.WriteString(args[a].String())
+		} else {
+			buf.WriteString(Inspect(runtime, args[a], options))
+		}
+		join = " "
 	}
-	return runtime.ToValue(buf.String())
+	return buf.String()
 }
@@ -1,12 +1,16 @@
 package util
 
 import (
+	_ "embed"
 	"testing"
 
 	"github.com/dop251/goja"
 	"github.com/khanghh/goja-nodejs/require"
 )
 
+//go:embed testdata/format_test.js
+var formatTest string
+
 func TestUtil_Format(t *testing.T) {
 	vm := goja.New()
 	ret := Format(vm, "Test: %% %д %s %d, %i, %j", vm.ToValue("string"), vm.ToValue(42), vm.ToValue(1.2), vm.NewObject())
@@ -25,15 +29,12 @@ func TestUtil_Format_NoArgs(t *testing.T) {
 
 func TestUtil_Format_Circular_JSON(t *testing.T) {
 	vm := goja.New()
-	testObj := map[string]interface{}{
-		"name":  "John Doe",
-		"age":   30,
-		"email": "john.doe@example.com",
+	obj, err := vm.RunString(`const obj = { name: "John Doe", age: 30 }; obj.data = obj; obj`)
+	if err != nil {
+		t.Fatal(err)
 	}
-	testObj["data"] = testObj
-	obj := vm.ToValue(testObj).ToObject(vm)
 	ret := Format(vm, "Test: %j", obj)
-	if res := ret.String(); res != `Test: {"age":30,"data":[Circular],"email":"john.doe@example.com","name":"John Doe"}` {
+	if res := ret.String(); res != "Test: [Circular]" {
 		t.Fatalf("Unexpected result: '%s'", res)
 	}
 }
@@ -75,3 +76,20 @@ func TestUtil_RequireUtilModule(t *testing.T) {
 		}
 	}
 }
+
+func TestUtil_Format_JS(t *testing.T) {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+	vm.Set("global", vm.GlobalObject())
+
+	// Script will throw an error on failed validation
+	_, err := vm.RunScript("testdata/format_test.js", formatTest)
+	if err != nil {
+		if ex, ok := err.(*goja.Exception); ok {
+			t.Fatal(ex.String())
+		}
+		t.Fatal("Failed to process format script.", err)
+	}
+}
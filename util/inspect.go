@@ -0,0 +1,1569 @@
+package util
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// InspectOptions are the options of util.inspect().
+type InspectOptions struct {
+	// ShowHidden includes the non-enumerable properties and symbols of the objects.
+	ShowHidden bool
+	// Depth is the number of times to recurse into objects, math.Inf(1) inspects the whole value.
+	Depth float64
+	// Colors styles the output with ANSI color codes.
+	Colors bool
+	// CustomInspect calls the [util.inspect.custom](depth, options, inspect) methods of the objects.
+	CustomInspect bool
+	// ShowProxy shows the target and the handler of proxies instead of inspecting the target.
+	ShowProxy bool
+	// MaxArrayLength is the maximum number of elements of arrays, typed arrays, Maps and Sets to include.
+	MaxArrayLength int
+	// MaxStringLength is the maximum number of characters of strings to include.
+	MaxStringLength int
+	// BreakLength is the length at which the entries of an object are split across multiple lines.
+	BreakLength int
+	// Compact is the number of inner levels combined on a single line as long as they fit within BreakLength,
+	// 0 puts every entry on its own line. A negative value selects the legacy mode of compact: true.
+	Compact int
+	// Sorted sorts the properties of objects and the entries of Maps and Sets, using Compare if it's set.
+	Sorted  bool
+	Compare goja.Callable
+	// Getters calls the getters of objects and includes the values they return.
+	Getters InspectGetters
+}
+
+// InspectGetters selects the getters called by util.inspect(), matching the values of its getters option.
+type InspectGetters string
+
+const (
+	// GettersNone doesn't call any getter, as getters: false.
+	GettersNone InspectGetters = ""
+	// GettersAll calls every getter, as getters: true.
+	GettersAll InspectGetters = "true"
+	// GettersGet calls the getters without a corresponding setter, as getters: 'get'.
+	GettersGet InspectGetters = "get"
+	// GettersSet calls the getters with a corresponding setter, as getters: 'set'.
+	GettersSet InspectGetters = "set"
+)
+
+// DefaultInspectOptions are the defaults of util.inspect().
+var DefaultInspectOptions = InspectOptions{
+	Depth:           2,
+	CustomInspect:   true,
+	MaxArrayLength:  100,
+	MaxStringLength: 10000,
+	BreakLength:     80,
+	Compact:         3,
+}
+
+const (
+	// minLineWidth is the minimum length of strings which are split into lines.
+	minLineWidth = 16
+
+	customInspectKey = "nodejs.util.inspect.custom"
+)
+
+// The kinds of entries passed to formatProperty and reduceToSingleString.
+const (
+	objectType = iota
+	arrayType
+	arrayExtrasType
+)
+
+var (
+	styles = map[string]string{
+		"special":   "cyan",
+		"number":    "yellow",
+		"bigint":    "yellow",
+		"boolean":   "yellow",
+		"undefined": "grey",
+		"null":      "bold",
+		"string":    "green",
+		"symbol":    "green",
+		"date":      "magenta",
+		"regexp":    "red",
+		"module":    "underline",
+	}
+	colors = map[string][2]int{
+		"bold":      {1, 22},
+		"underline": {4, 24},
+		"red":       {31, 39},
+		"green":     {32, 39},
+		"yellow":    {33, 39},
+		"magenta":   {35, 39},
+		"cyan":      {36, 39},
+		"grey":      {90, 39},
+	}
+
+	colorRegExp   = regexp.MustCompile("\x1b\\[\\d\\d?m")
+	keyStrRegExp  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z_0-9]*$`)
+	numberRegExp  = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+	classRegExp   = regexp.MustCompile(`^(\s+[^(]*?)\s*{`)
+	errorRegExp   = regexp.MustCompile(`^([A-Z][a-z_ A-Z0-9[\]()-]+)(?::|\n\s+at)`)
+	errorOnlyExp  = regexp.MustCompile(`^([a-z_A-Z0-9-]*Error)$`)
+	typeSet       = reflect.TypeOf([]interface{}{})
+	typeMap       = reflect.TypeOf([][2]interface{}{})
+	typeProxy     = reflect.TypeOf(goja.Proxy{})
+	typePromise   = reflect.TypeOf((*goja.Promise)(nil))
+	typeBuffer    = reflect.TypeOf(goja.ArrayBuffer{})
+	typeSymbolObj = reflect.TypeOf("")
+)
+
+// inspector keeps the state of a single util.inspect() call.
+type inspector struct {
+	InspectOptions
+	runtime *goja.Runtime
+	stylize func(s, style string) string
+
+	seen           []*goja.Object
+	circular       map[*goja.Object]int
+	indentationLvl int
+	currentDepth   int
+
+	intrinsics map[string]goja.Value
+	custom     *goja.Symbol
+}
+
+// Inspect is a native implementation of Node.js util.inspect(). It returns the representation of the value
+// intended for debugging, the format follows the one of Node.js.
+func Inspect(runtime *goja.Runtime, value goja.Value, options InspectOptions) string {
+	ctx := &inspector{
+		InspectOptions: options,
+		runtime:        runtime,
+		stylize:        stylizeNoColor,
+		intrinsics:     make(map[string]goja.Value),
+	}
+	if options.Colors {
+		ctx.stylize = stylizeWithColor
+	}
+	if ctx.MaxArrayLength < 0 {
+		ctx.MaxArrayLength = 0
+	}
+	if ctx.MaxStringLength < 0 {
+		ctx.MaxStringLength = 0
+	}
+	return ctx.formatValue(value, 0, false)
+}
+
+// ParseInspectOptions returns the options with the properties of the util.inspect() options object applied.
+// The value is ignored unless it's an object.
+func ParseInspectOptions(value goja.Value, options InspectOptions) InspectOptions {
+	obj, ok := value.(*goja.Object)
+	if !ok || obj == nil {
+		return options
+	}
+	isSet := func(v goja.Value) bool {
+		return v != nil && !goja.IsUndefined(v)
+	}
+	limit := func(v goja.Value) int {
+		if goja.IsNull(v) || v.ToFloat() > math.MaxInt32 {
+			return math.MaxInt32
+		}
+		return int(v.ToInteger())
+	}
+	if v := obj.Get("showHidden"); isSet(v) {
+		options.ShowHidden = v.ToBoolean()
+	}
+	if v := obj.Get("depth"); isSet(v) {
+		options.Depth = parseDepth(v)
+	}
+	if v := obj.Get("colors"); isSet(v) {
+		options.Colors = v.ToBoolean()
+	}
+	if v := obj.Get("customInspect"); isSet(v) {
+		options.CustomInspect = v.ToBoolean()
+	}
+	if v := obj.Get("showProxy"); isSet(v) {
+		options.ShowProxy = v.ToBoolean()
+	}
+	if v := obj.Get("maxArrayLength"); isSet(v) {
+		options.MaxArrayLength = limit(v)
+	}
+	if v := obj.Get("maxStringLength"); isSet(v) {
+		options.MaxStringLength = limit(v)
+	}
+	if v := obj.Get("breakLength"); isSet(v) {
+		options.BreakLength = limit(v)
+	}
+	if v := obj.Get("compact"); isSet(v) {
+		switch {
+		case !isBoolean(v):
+			options.Compact = limit(v)
+		case v.ToBoolean():
+			options.Compact = -1
+		default:
+			options.Compact = 0
+		}
+	}
+	if v := obj.Get("sorted"); isSet(v) {
+		options.Compare, options.Sorted = goja.AssertFunction(v)
+		if !options.Sorted {
+			options.Sorted = v.ToBoolean()
+		}
+	}
+	if v := obj.Get("getters"); isSet(v) {
+		switch {
+		case isString(v) && v.String() == string(GettersGet):
+			options.Getters = GettersGet
+		case isString(v) && v.String() == string(GettersSet):
+			options.Getters = GettersSet
+		case v.ToBoolean():
+			options.Getters = GettersAll
+		default:
+			options.Getters = GettersNone
+		}
+	}
+	return options
+}
+
+func parseDepth(v goja.Value) float64 {
+	if goja.IsNull(v) {
+		return math.Inf(1)
+	}
+	return v.ToFloat()
+}
+
+func isBoolean(v goja.Value) bool {
+	if _, isObject := v.(*goja.Object); isObject || v == nil {
+		return false
+	}
+	return v.ExportType() != nil && v.ExportType().Kind() == reflect.Bool
+}
+
+// inspectFunction returns the util.inspect() function of the runtime.
+func inspectFunction(runtime *goja.Runtime) *goja.Object {
+	inspect := runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+		options := DefaultInspectOptions
+		if len(call.Arguments) > 2 {
+			// The legacy signature is inspect(object, showHidden, depth, colors)
+			if depth := call.Arguments[2]; !goja.IsUndefined(depth) {
+				options.Depth = parseDepth(depth)
+			}
+			if colors := call.Argument(3); !goja.IsUndefined(colors) {
+				options.Colors = colors.ToBoolean()
+			}
+		}
+		if opts := call.Argument(1); isBoolean(opts) {
+			options.ShowHidden = opts.ToBoolean()
+		} else {
+			options = ParseInspectOptions(opts, options)
+		}
+		return runtime.ToValue(Inspect(runtime, call.Argument(0), options))
+	}).(*goja.Object)
+	inspect.Set("custom", customInspectSymbol(runtime))
+	return inspect
+}
+
+// customInspectSymbol returns util.inspect.custom which is the same as Symbol.for('nodejs.util.inspect.custom').
+func customInspectSymbol(runtime *goja.Runtime) goja.Value {
+	symbolFor, _ := goja.AssertFunction(runtime.Get("Symbol").ToObject(runtime).Get("for"))
+	sym, err := symbolFor(goja.Undefined(), runtime.ToValue(customInspectKey))
+	if err != nil {
+		panic(err)
+	}
+	return sym
+}
+
+func stylizeNoColor(s, style string) string {
+	return s
+}
+
+func stylizeWithColor(s, style string) string {
+	if color, ok := colors[styles[style]]; ok {
+		return "\x1b[" + strconv.Itoa(color[0]) + "m" + s + "\x1b[" + strconv.Itoa(color[1]) + "m"
+	}
+	return s
+}
+
+func removeColors(s string) string {
+	return colorRegExp.ReplaceAllString(s, "")
+}
+
+// strLen returns the length of the string in UTF-16 code units like String.prototype.length.
+func strLen(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+func (ctx *inspector) width(s string) int {
+	if ctx.Colors {
+		s = removeColors(s)
+	}
+	return strLen(s)
+}
+
+func (ctx *inspector) tooDeep(recurseTimes int) bool {
+	return float64(recurseTimes) > ctx.Depth
+}
+
+// intrinsic returns the built-in value at the path like "Object.prototype.toString". The values are looked up
+// once per call of Inspect.
+func (ctx *inspector) intrinsic(path string) goja.Value {
+	if v, exists := ctx.intrinsics[path]; exists {
+		return v
+	}
+	var v goja.Value = ctx.runtime.GlobalObject()
+	for _, name := range strings.Split(path, ".") {
+		obj, ok := v.(*goja.Object)
+		if !ok {
+			v = goja.Undefined()
+			break
+		}
+		v = obj.Get(name)
+	}
+	ctx.intrinsics[path] = v
+	return v
+}
+
+// call calls the built-in function with the receiver and throws the exceptions.
+func (ctx *inspector) call(path string, this goja.Value, args ...goja.Value) goja.Value {
+	fn, ok := goja.AssertFunction(ctx.intrinsic(path))
+	if !ok {
+		panic(ctx.runtime.NewTypeError(path + " is not a function"))
+	}
+	ret, err := fn(this, args...)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+// try calls the built-in function with the receiver and reports whether it returned without an exception.
+func (ctx *inspector) try(path string, this goja.Value, args ...goja.Value) (goja.Value, bool) {
+	fn, ok := goja.AssertFunction(ctx.intrinsic(path))
+	if !ok {
+		return nil, false
+	}
+	ret, err := fn(this, args...)
+	return ret, err == nil
+}
+
+// brand reports whether the object is accepted as the receiver of the built-in method, which tells the type of
+// the built-in objects that can't be distinguished otherwise.
+func (ctx *inspector) brand(path string, obj *goja.Object) bool {
+	_, ok := ctx.try(path, obj)
+	return ok
+}
+
+func (ctx *inspector) isDataView(obj *goja.Object) bool {
+	_, ok := ctx.getter("DataView.prototype", ctx.runtime.ToValue("byteLength"), obj)
+	return ok
+}
+
+// getter calls the getter of the built-in accessor property and reports whether it returned without an
+// exception. It's used for the brand checks of built-in objects.
+func (ctx *inspector) getter(path string, key goja.Value, this goja.Value) (goja.Value, bool) {
+	cacheKey := path + "[" + key.String() + "]"
+	getter, exists := ctx.intrinsics[cacheKey]
+	if !exists {
+		getter = goja.Undefined()
+		if proto, ok := ctx.intrinsic(path).(*goja.Object); ok {
+			if desc, ok := ctx.descriptor(proto, key).(*goja.Object); ok {
+				getter = desc.Get("get")
+			}
+		}
+		ctx.intrinsics[cacheKey] = getter
+	}
+	fn, ok := goja.AssertFunction(getter)
+	if !ok {
+		return nil, false
+	}
+	ret, err := fn(this)
+	return ret, err == nil
+}
+
+func (ctx *inspector) descriptor(obj *goja.Object, key goja.Value) goja.Value {
+	return ctx.call("Object.getOwnPropertyDescriptor", goja.Undefined(), obj, key)
+}
+
+func get(obj *goja.Object, key goja.Value) goja.Value {
+	if sym, ok := key.(*goja.Symbol); ok {
+		return obj.GetSymbol(sym)
+	}
+	return obj.Get(key.String())
+}
+
+func symbolString(sym *goja.Symbol) string {
+	return "Symbol(" + sym.String() + ")"
+}
+
+func isString(v goja.Value) bool {
+	if _, isObject := v.(*goja.Object); isObject || v == nil {
+		return false
+	}
+	if _, isSymbol := v.(*goja.Symbol); isSymbol {
+		return false
+	}
+	return v.ExportType() != nil && v.ExportType().Kind() == reflect.String
+}
+
+// keys returns the own enumerable string and symbol keys of the object, or all of them if ShowHidden is set.
+func (ctx *inspector) keys(obj *goja.Object) []goja.Value {
+	var keys []goja.Value
+	if ctx.ShowHidden {
+		names := ctx.call("Object.getOwnPropertyNames", goja.Undefined(), obj).(*goja.Object)
+		for _, name := range arrayValues(names) {
+			keys = append(keys, name)
+		}
+		symbols := ctx.call("Object.getOwnPropertySymbols", goja.Undefined(), obj).(*goja.Object)
+		return append(keys, arrayValues(symbols)...)
+	}
+	for _, key := range obj.Keys() {
+		keys = append(keys, ctx.runtime.ToValue(key))
+	}
+	for _, sym := range obj.Symbols() {
+		keys = append(keys, sym)
+	}
+	return keys
+}
+
+func arrayValues(arr *goja.Object) []goja.Value {
+	n := int(arr.Get("length").ToInteger())
+	values := make([]goja.Value, n)
+	for i := range values {
+		values[i] = arr.Get(strconv.Itoa(i))
+	}
+	return values
+}
+
+func isIndex(key goja.Value) bool {
+	if !isString(key) {
+		return false
+	}
+	s := key.String()
+	n, err := strconv.ParseUint(s, 10, 32)
+	return err == nil && n < math.MaxUint32 && strconv.FormatUint(n, 10) == s
+}
+
+// nonIndexKeys returns the keys of the array which aren't indices.
+func (ctx *inspector) nonIndexKeys(obj *goja.Object) []goja.Value {
+	var keys []goja.Value
+	for _, key := range ctx.keys(obj) {
+		if !isIndex(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (ctx *inspector) instanceOf(obj, ctor *goja.Object) (res bool) {
+	ctx.runtime.Try(func() {
+		res = ctx.runtime.InstanceOf(obj, ctor)
+	})
+	return
+}
+
+// isError reports whether the object inherits from Error without being a native error.
+func (ctx *inspector) isError(obj *goja.Object) bool {
+	ctor, ok := ctx.intrinsic("Error").(*goja.Object)
+	return ok && ctx.instanceOf(obj, ctor)
+}
+
+// constructorName returns the name of the first constructor found in the prototype chain of the object. The
+// second return value is false if the object has a null prototype.
+func (ctx *inspector) constructorName(obj *goja.Object, recurseTimes int) (string, bool) {
+	var firstProto *goja.Object
+	for o, first := obj, true; o != nil; first = false {
+		if desc, ok := ctx.descriptor(o, ctx.runtime.ToValue("constructor")).(*goja.Object); ok {
+			if ctor, ok := desc.Get("value").(*goja.Object); ok {
+				if _, isFunc := goja.AssertFunction(ctor); isFunc {
+					if name := ctor.Get("name"); name != nil && name.String() != "" && ctx.instanceOf(obj, ctor) {
+						return name.String(), true
+					}
+				}
+			}
+		}
+		o = o.Prototype()
+		if first {
+			firstProto = o
+		}
+	}
+	if firstProto == nil {
+		return "", false
+	}
+	res := obj.ClassName()
+	if ctx.tooDeep(recurseTimes) {
+		return res + " <Complex prototype>", true
+	}
+	protoConstr, ok := ctx.constructorName(firstProto, recurseTimes+1)
+	if !ok {
+		options := ctx.InspectOptions
+		options.CustomInspect = false
+		options.Depth = -1
+		return res + " <" + Inspect(ctx.runtime, firstProto, options) + ">", true
+	}
+	return res + " <" + protoConstr + ">", true
+}
+
+// prefix returns the prefix of the braces describing the constructor and the tag of the object.
+func prefix(constructor string, hasConstructor bool, tag, fallback, size string) string {
+	if !hasConstructor {
+		if tag != "" && fallback != tag {
+			return "[" + fallback + size + ": null prototype] [" + tag + "] "
+		}
+		return "[" + fallback + size + ": null prototype] "
+	}
+	if tag != "" && constructor != tag {
+		return constructor + size + " [" + tag + "] "
+	}
+	return constructor + size + " "
+}
+
+func remainingText(remaining int) string {
+	s := "... " + strconv.Itoa(remaining) + " more item"
+	if remaining > 1 {
+		s += "s"
+	}
+	return s
+}
+
+func (ctx *inspector) formatValue(value goja.Value, recurseTimes int, typedArray bool) string {
+	obj, ok := value.(*goja.Object)
+	if !ok || obj == nil {
+		return ctx.formatPrimitive(ctx.stylize, value)
+	}
+	context := obj
+	if obj.ExportType() == typeProxy {
+		proxy := obj.Export().(goja.Proxy)
+		if proxy.Target() == nil {
+			return ctx.stylize("<Revoked Proxy>", "special")
+		}
+		if ctx.ShowProxy {
+			return ctx.formatProxy(proxy, recurseTimes)
+		}
+		// Proxies are inspected through their target, so that no traps are triggered
+		for obj.ExportType() == typeProxy {
+			proxy := obj.Export().(goja.Proxy)
+			if proxy.Target() == nil {
+				return ctx.stylize("<Revoked Proxy>", "special")
+			}
+			obj = proxy.Target()
+		}
+	}
+
+	if ctx.CustomInspect {
+		if s, ok := ctx.formatCustom(obj, context, recurseTimes); ok {
+			return s
+		}
+	}
+
+	for _, o := range ctx.seen {
+		if o == obj {
+			index := 1
+			if ctx.circular == nil {
+				ctx.circular = map[*goja.Object]int{obj: index}
+			} else if index, ok = ctx.circular[obj]; !ok {
+				index = len(ctx.circular) + 1
+				ctx.circular[obj] = index
+			}
+			return ctx.stylize("[Circular *"+strconv.Itoa(index)+"]", "special")
+		}
+	}
+	return ctx.formatRaw(obj, recurseTimes, typedArray)
+}
+
+// formatCustom calls the util.inspect.custom method of the object. The second return value is false if there is
+// no such method or it returned the object itself.
+func (ctx *inspector) formatCustom(obj, context *goja.Object, recurseTimes int) (string, bool) {
+	if ctx.custom == nil {
+		ctx.custom, _ = customInspectSymbol(ctx.runtime).(*goja.Symbol)
+	}
+	custom, ok := goja.AssertFunction(obj.GetSymbol(ctx.custom))
+	if !ok {
+		return "", false
+	}
+	// Prototypes aren't inspected with the method they define for their instances
+	if ctor, ok := obj.Get("constructor").(*goja.Object); ok {
+		if proto, ok := ctor.Get("prototype").(*goja.Object); ok && proto == obj {
+			return "", false
+		}
+	}
+	depth := ctx.Depth - float64(recurseTimes)
+	ret, err := custom(context, ctx.runtime.ToValue(depth), ctx.userOptions(depth), inspectFunction(ctx.runtime))
+	if err != nil {
+		panic(err)
+	}
+	if ret == context {
+		return "", false
+	}
+	if !isString(ret) {
+		return ctx.formatValue(ret, recurseTimes, false), true
+	}
+	return strings.ReplaceAll(ret.String(), "\n", "\n"+strings.Repeat(" ", ctx.indentationLvl)), true
+}
+
+// userOptions returns the options passed to util.inspect.custom methods.
+func (ctx *inspector) userOptions(depth float64) *goja.Object {
+	options := ctx.runtime.NewObject()
+	options.Set("stylize", func(call goja.FunctionCall) goja.Value {
+		return ctx.runtime.ToValue(ctx.stylize(call.Argument(0).String(), call.Argument(1).String()))
+	})
+	options.Set("showHidden", ctx.ShowHidden)
+	options.Set("depth", depth)
+	options.Set("colors", ctx.Colors)
+	options.Set("customInspect", ctx.CustomInspect)
+	options.Set("showProxy", ctx.ShowProxy)
+	options.Set("maxArrayLength", ctx.MaxArrayLength)
+	options.Set("maxStringLength", ctx.MaxStringLength)
+	options.Set("breakLength", ctx.BreakLength)
+	if ctx.Compact < 0 {
+		options.Set("compact", true)
+	} else {
+		options.Set("compact", ctx.Compact)
+	}
+	if ctx.Compare != nil {
+		options.Set("sorted", ctx.Compare)
+	} else {
+		options.Set("sorted", ctx.Sorted)
+	}
+	switch ctx.Getters {
+	case GettersGet, GettersSet:
+		options.Set("getters", string(ctx.Getters))
+	default:
+		options.Set("getters", ctx.Getters == GettersAll)
+	}
+	return options
+}
+
+func (ctx *inspector) formatProxy(proxy goja.Proxy, recurseTimes int) string {
+	if ctx.tooDeep(recurseTimes) {
+		return ctx.stylize("Proxy [Array]", "special")
+	}
+	recurseTimes++
+	ctx.indentationLvl += 2
+	output := []string{
+		ctx.formatValue(proxy.Target(), recurseTimes, false),
+		ctx.formatValue(proxy.Handler(), recurseTimes, false),
+	}
+	ctx.indentationLvl -= 2
+	return ctx.reduceToSingleString(output, "", [2]string{"Proxy [", "]"}, arrayExtrasType, recurseTimes, nil)
+}
+
+func (ctx *inspector) formatPrimitive(stylize func(s, style string) string, v goja.Value) string {
+	switch {
+	case v == nil || goja.IsUndefined(v):
+		return stylize("undefined", "undefined")
+	case goja.IsNull(v):
+		return stylize("null", "null")
+	}
+	if sym, ok := v.(*goja.Symbol); ok {
+		return stylize(symbolString(sym), "symbol")
+	}
+	switch v.ExportType().Kind() {
+	case reflect.String:
+		return ctx.formatString(stylize, v.String())
+	case reflect.Bool:
+		return stylize(v.String(), "boolean")
+	}
+	return formatNumber(stylize, v)
+}
+
+func formatNumber(stylize func(s, style string) string, v goja.Value) string {
+	if f := v.ToFloat(); f == 0 && math.Signbit(f) {
+		return stylize("-0", "number")
+	}
+	return stylize(v.String(), "number")
+}
+
+func (ctx *inspector) formatString(stylize func(s, style string) string, s string) string {
+	var trailer string
+	if n := utf8.RuneCountInString(s); n > ctx.MaxStringLength {
+		remaining := n - ctx.MaxStringLength
+		s = string([]rune(s)[:ctx.MaxStringLength])
+		trailer = "... " + strconv.Itoa(remaining) + " more character"
+		if remaining > 1 {
+			trailer += "s"
+		}
+	}
+	if n := strLen(s); ctx.Compact >= 0 && n > minLineWidth && n > ctx.BreakLength-ctx.indentationLvl-4 {
+		var lines []string
+		for len(s) > 0 {
+			i := strings.IndexByte(s, '\n') + 1
+			if i == 0 {
+				i = len(s)
+			}
+			lines = append(lines, stylize(strEscape(s[:i]), "string"))
+			s = s[i:]
+		}
+		return strings.Join(lines, " +\n"+strings.Repeat(" ", ctx.indentationLvl+2)) + trailer
+	}
+	return stylize(strEscape(s), "string") + trailer
+}
+
+// strEscape quotes the string escaping the control characters. Single quotes are used unless the string
+// contains them, then double quotes or backticks are preferred.
+func strEscape(s string) string {
+	quote := byte('\'')
+	if strings.IndexByte(s, '\'') >= 0 {
+		if strings.IndexByte(s, '"') < 0 {
+			quote = '"'
+		} else if strings.IndexByte(s, '`') < 0 && !strings.Contains(s, "${") {
+			quote = '`'
+		}
+	}
+	return string(quote) + escape(s, quote) + string(quote)
+}
+
+// escape escapes the control characters, the backslashes and the quotes if they're single quotes.
+func escape(s string, quote byte) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\'' && quote == '\'':
+			b.WriteString(`\'`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r >= 0x7f && r < 0xa0:
+			b.WriteString(`\x`)
+			b.WriteString(strings.ToUpper(strconv.FormatInt(int64(r)|0x100, 16)[1:]))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// formatRaw formats the object which is not a primitive, a circular reference or inspected by a custom method.
+func (ctx *inspector) formatRaw(obj *goja.Object, recurseTimes int, typedArray bool) string {
+	constructor, hasConstructor := ctx.constructorName(obj, recurseTimes)
+	var tag string
+	if v := obj.GetSymbol(goja.SymToStringTag); isString(v) && v.String() != "" {
+		tag = v.String()
+		if desc, ok := ctx.descriptor(obj, goja.SymToStringTag).(*goja.Object); ok {
+			if ctx.ShowHidden || desc.Get("enumerable").ToBoolean() {
+				tag = ""
+			}
+		}
+	}
+
+	var keys []goja.Value
+	var base string
+	formatter := func(int) []string { return nil }
+	braces := [2]string{"{", "}"}
+	extrasType := objectType
+	className := obj.ClassName()
+	exportType := obj.ExportType()
+	_, isFunc := goja.AssertFunction(obj)
+	isPlainObject := className == "Object" && !isFunc
+
+	switch {
+	case className == "Array":
+		length := int(obj.Get("length").ToInteger())
+		keys = ctx.nonIndexKeys(obj)
+		p := ""
+		if constructor != "Array" || !hasConstructor || tag != "" {
+			p = prefix(constructor, hasConstructor, tag, "Array", "("+strconv.Itoa(length)+")")
+		}
+		braces = [2]string{p + "[", "]"}
+		if length == 0 && len(keys) == 0 {
+			return braces[0] + "]"
+		}
+		extrasType = arrayExtrasType
+		formatter = func(recurseTimes int) []string {
+			return ctx.formatArray(obj, length, recurseTimes)
+		}
+	case isPlainObject && (exportType == typeSet || exportType == typeMap):
+		isSet := exportType == typeSet
+		fallback := "Map"
+		if isSet {
+			fallback = "Set"
+		}
+		var entries [][2]goja.Value
+		ctx.call(fallback+".prototype.forEach", obj, ctx.runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+			entries = append(entries, [2]goja.Value{call.Argument(1), call.Argument(0)})
+			return goja.Undefined()
+		}))
+		p := prefix(constructor, hasConstructor, tag, fallback, "("+strconv.Itoa(len(entries))+")")
+		keys = ctx.keys(obj)
+		if len(entries) == 0 && len(keys) == 0 {
+			return p + "{}"
+		}
+		braces = [2]string{p + "{", "}"}
+		formatter = func(recurseTimes int) []string {
+			return ctx.formatEntries(entries, isSet, recurseTimes)
+		}
+	case isPlainObject && exportType != nil && exportType.Kind() == reflect.Slice:
+		fallback, ok := ctx.getter("Uint8Array.prototype.__proto__", goja.SymToStringTag, obj)
+		if !ok || !isString(fallback) {
+			return ctx.formatObject(obj, recurseTimes, typedArray, constructor, hasConstructor, tag)
+		}
+		length := int(obj.Get("length").ToInteger())
+		keys = ctx.nonIndexKeys(obj)
+		fb := ""
+		if !hasConstructor {
+			fb = fallback.String()
+		}
+		braces = [2]string{prefix(constructor, hasConstructor, tag, fb, "("+strconv.Itoa(length)+")") + "[", "]"}
+		if length == 0 && len(keys) == 0 && !ctx.ShowHidden {
+			return braces[0] + "]"
+		}
+		extrasType = arrayExtrasType
+		formatter = func(recurseTimes int) []string {
+			return ctx.formatTypedArray(obj, length, recurseTimes)
+		}
+	default:
+		return ctx.formatObject(obj, recurseTimes, typedArray, constructor, hasConstructor, tag)
+	}
+	return ctx.formatEntriesOf(obj, recurseTimes, constructor, hasConstructor, tag, keys, base, braces, extrasType, formatter)
+}
+
+// formatObject formats the objects which aren't iterable collections. typedArray is set when the buffer of a
+// typed array is inspected.
+func (ctx *inspector) formatObject(obj *goja.Object, recurseTimes int, typedArray bool, constructor string, hasConstructor bool, tag string) string {
+	keys := ctx.keys(obj)
+	var base string
+	formatter := func(int) []string { return nil }
+	braces := [2]string{"{", "}"}
+	className := obj.ClassName()
+	exportType := obj.ExportType()
+
+	if _, isFunc := goja.AssertFunction(obj); isFunc {
+		base = ctx.functionBase(obj, constructor, hasConstructor, tag)
+		if len(keys) == 0 {
+			return ctx.stylize(base, "special")
+		}
+	} else if hasConstructor && constructor == "Object" && (className == "Object" || className == "Arguments") {
+		if className == "Arguments" {
+			braces[0] = "[Arguments] {"
+		} else if tag != "" {
+			braces[0] = prefix(constructor, hasConstructor, tag, "Object", "") + "{"
+		}
+		if len(keys) == 0 {
+			return braces[0] + "}"
+		}
+	} else if className == "RegExp" {
+		base = ctx.call("RegExp.prototype.toString", obj).String()
+		if p := prefix(constructor, hasConstructor, tag, "RegExp", ""); p != "RegExp " {
+			base = p + base
+		}
+		if len(keys) == 0 || ctx.tooDeep(recurseTimes) {
+			return ctx.stylize(base, "regexp")
+		}
+	} else if className == "Date" {
+		if math.IsNaN(ctx.call("Date.prototype.getTime", obj).ToFloat()) {
+			base = ctx.call("Date.prototype.toString", obj).String()
+		} else {
+			base = ctx.call("Date.prototype.toISOString", obj).String()
+		}
+		if p := prefix(constructor, hasConstructor, tag, "Date", ""); p != "Date " {
+			base = p + base
+		}
+		if len(keys) == 0 {
+			return ctx.stylize(base, "date")
+		}
+	} else if className == "Error" || ctx.isError(obj) {
+		keys, base = ctx.formatError(obj, constructor, hasConstructor, tag, keys)
+		if len(keys) == 0 {
+			return base
+		}
+	} else if exportType == typeBuffer {
+		p := prefix(constructor, hasConstructor, tag, "ArrayBuffer", "")
+		buffer := obj.Export().(goja.ArrayBuffer)
+		if !typedArray {
+			formatter = func(int) []string {
+				return []string{ctx.formatArrayBuffer(buffer)}
+			}
+		} else if len(keys) == 0 {
+			return p + "{ byteLength: " + formatNumber(ctx.stylize, ctx.runtime.ToValue(len(buffer.Bytes()))) + " }"
+		}
+		braces[0] = p + "{"
+		keys = append([]goja.Value{ctx.runtime.ToValue("byteLength")}, keys...)
+	} else if tag == "DataView" && ctx.isDataView(obj) {
+		braces[0] = prefix(constructor, hasConstructor, tag, "DataView", "") + "{"
+		keys = append([]goja.Value{ctx.runtime.ToValue("byteLength"), ctx.runtime.ToValue("byteOffset"), ctx.runtime.ToValue("buffer")}, keys...)
+	} else if exportType == typePromise {
+		braces[0] = prefix(constructor, hasConstructor, tag, "Promise", "") + "{"
+		formatter = func(recurseTimes int) []string {
+			return ctx.formatPromise(obj.Export().(*goja.Promise), recurseTimes)
+		}
+	} else if tag == "WeakSet" && ctx.brand("WeakSet.prototype.has", obj) {
+		braces[0] = prefix(constructor, hasConstructor, tag, "WeakSet", "") + "{"
+		formatter = ctx.formatWeakCollection
+	} else if tag == "WeakMap" && ctx.brand("WeakMap.prototype.has", obj) {
+		braces[0] = prefix(constructor, hasConstructor, tag, "WeakMap", "") + "{"
+		formatter = ctx.formatWeakCollection
+	} else if b, boxed := ctx.boxedBase(obj, &keys, constructor, hasConstructor, tag); boxed {
+		base = b
+		if len(keys) == 0 {
+			return base
+		}
+	} else {
+		if len(keys) == 0 {
+			return prefix(constructor, hasConstructor, tag, "Object", "") + "{}"
+		}
+		braces[0] = prefix(constructor, hasConstructor, tag, "Object", "") + "{"
+	}
+	return ctx.formatEntriesOf(obj, recurseTimes, constructor, hasConstructor, tag, keys, base, braces, objectType, formatter)
+}
+
+// formatEntriesOf formats the entries returned by the formatter followed by the properties of the object.
+func (ctx *inspector) formatEntriesOf(obj *goja.Object, recurseTimes int, constructor string, hasConstructor bool, tag string,
+	keys []goja.Value, base string, braces [2]string, extrasType int, formatter func(recurseTimes int) []string) string {
+	if ctx.tooDeep(recurseTimes) {
+		name := strings.TrimSuffix(prefix(constructor, hasConstructor, tag, "Object", ""), " ")
+		if hasConstructor {
+			name = "[" + name + "]"
+		}
+		return ctx.stylize(name, "special")
+	}
+	recurseTimes++
+	ctx.seen = append(ctx.seen, obj)
+	ctx.currentDepth = recurseTimes
+	output := formatter(recurseTimes)
+	for _, key := range keys {
+		output = append(output, ctx.formatProperty(obj, key, recurseTimes, extrasType, nil))
+	}
+	if index, ok := ctx.circular[obj]; ok {
+		reference := ctx.stylize("<ref *"+strconv.Itoa(index)+">", "special")
+		if ctx.Compact < 0 {
+			braces[0] = reference + " " + braces[0]
+		} else if base == "" {
+			base = reference
+		} else {
+			base = reference + " " + base
+		}
+	}
+	ctx.seen = ctx.seen[:len(ctx.seen)-1]
+	if ctx.Sorted {
+		if extrasType == objectType {
+			ctx.sort(output)
+		} else if len(keys) > 1 {
+			ctx.sort(output[len(output)-len(keys):])
+		}
+	}
+	return ctx.reduceToSingleString(output, base, braces, extrasType, recurseTimes, obj)
+}
+
+func (ctx *inspector) sort(output []string) {
+	if ctx.Compare == nil {
+		sort.SliceStable(output, func(i, j int) bool {
+			return output[i] < output[j]
+		})
+		return
+	}
+	sort.SliceStable(output, func(i, j int) bool {
+		ret, err := ctx.Compare(goja.Undefined(), ctx.runtime.ToValue(output[i]), ctx.runtime.ToValue(output[j]))
+		if err != nil {
+			panic(err)
+		}
+		return ret.ToFloat() < 0
+	})
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func emptyItems(n int) string {
+	s := "<" + strconv.Itoa(n) + " empty item"
+	if n > 1 {
+		s += "s"
+	}
+	return s + ">"
+}
+
+func (ctx *inspector) formatArray(obj *goja.Object, length, recurseTimes int) []string {
+	n := minInt(ctx.MaxArrayLength, length)
+	output := make([]string, 0, n+1)
+	for i := 0; i < n; i++ {
+		key := ctx.runtime.ToValue(strconv.Itoa(i))
+		desc := ctx.descriptor(obj, key)
+		if goja.IsUndefined(desc) {
+			return ctx.formatSpecialArray(obj, length, recurseTimes, n, output, i)
+		}
+		output = append(output, ctx.formatProperty(obj, key, recurseTimes, arrayType, desc))
+	}
+	if remaining := length - n; remaining > 0 {
+		output = append(output, remainingText(remaining))
+	}
+	return output
+}
+
+// formatSpecialArray formats the sparse array starting with the first hole at the index i.
+func (ctx *inspector) formatSpecialArray(obj *goja.Object, length, recurseTimes, maxLength int, output []string, i int) []string {
+	keys := obj.Keys()
+	index := i
+	for ; i < len(keys) && len(output) < maxLength; i++ {
+		key := keys[i]
+		if !numberRegExp.MatchString(key) {
+			break
+		}
+		n, err := strconv.ParseUint(key, 10, 32)
+		if err != nil || n > math.MaxUint32-1 {
+			break
+		}
+		if strconv.Itoa(index) != key {
+			output = append(output, ctx.stylize(emptyItems(int(n)-index), "undefined"))
+			index = int(n)
+			if len(output) == maxLength {
+				break
+			}
+		}
+		output = append(output, ctx.formatProperty(obj, ctx.runtime.ToValue(key), recurseTimes, arrayType, nil))
+		index++
+	}
+	remaining := length - index
+	if len(output) != maxLength {
+		if remaining > 0 {
+			output = append(output, ctx.stylize(emptyItems(remaining), "undefined"))
+		}
+	} else if remaining > 0 {
+		output = append(output, remainingText(remaining))
+	}
+	return output
+}
+
+// formatEntries formats the entries of a Set or a Map.
+func (ctx *inspector) formatEntries(entries [][2]goja.Value, isSet bool, recurseTimes int) []string {
+	n := minInt(ctx.MaxArrayLength, len(entries))
+	output := make([]string, 0, n+1)
+	ctx.indentationLvl += 2
+	for _, entry := range entries[:n] {
+		if isSet {
+			output = append(output, ctx.formatValue(entry[1], recurseTimes, false))
+		} else {
+			output = append(output, ctx.formatValue(entry[0], recurseTimes, false)+" => "+ctx.formatValue(entry[1], recurseTimes, false))
+		}
+	}
+	if remaining := len(entries) - n; remaining > 0 {
+		output = append(output, remainingText(remaining))
+	}
+	ctx.indentationLvl -= 2
+	return output
+}
+
+func (ctx *inspector) formatTypedArray(obj *goja.Object, length, recurseTimes int) []string {
+	n := minInt(ctx.MaxArrayLength, length)
+	output := make([]string, 0, n+1)
+	for i := 0; i < n; i++ {
+		output = append(output, formatNumber(ctx.stylize, obj.Get(strconv.Itoa(i))))
+	}
+	if remaining := length - n; remaining > 0 {
+		output = append(output, remainingText(remaining))
+	}
+	if ctx.ShowHidden {
+		ctx.indentationLvl += 2
+		for _, key := range []string{"BYTES_PER_ELEMENT", "length", "byteLength", "byteOffset", "buffer"} {
+			output = append(output, "["+key+"]: "+ctx.formatValue(obj.Get(key), recurseTimes, true))
+		}
+		ctx.indentationLvl -= 2
+	}
+	return output
+}
+
+func (ctx *inspector) formatArrayBuffer(buffer goja.ArrayBuffer) string {
+	if buffer.Detached() {
+		return ctx.stylize("(detached)", "special")
+	}
+	data := buffer.Bytes()
+	n := minInt(ctx.MaxArrayLength, len(data))
+	hex := make([]string, n)
+	for i, b := range data[:n] {
+		hex[i] = strconv.FormatInt(int64(b)|0x100, 16)[1:]
+	}
+	s := strings.Join(hex, " ")
+	if remaining := len(data) - ctx.MaxArrayLength; remaining > 0 {
+		s += " ... " + strconv.Itoa(remaining) + " more byte"
+		if remaining > 1 {
+			s += "s"
+		}
+	}
+	return ctx.stylize("[Uint8Contents]", "special") + ": <" + s + ">"
+}
+
+func (ctx *inspector) formatPromise(p *goja.Promise, recurseTimes int) []string {
+	if p.State() == goja.PromiseStatePending {
+		return []string{ctx.stylize("<pending>", "special")}
+	}
+	ctx.indentationLvl += 2
+	s := ctx.formatValue(p.Result(), recurseTimes, false)
+	ctx.indentationLvl -= 2
+	if p.State() == goja.PromiseStateRejected {
+		s = ctx.stylize("<rejected>", "special") + " " + s
+	}
+	return []string{s}
+}
+
+func (ctx *inspector) formatWeakCollection(int) []string {
+	return []string{ctx.stylize("<items unknown>", "special")}
+}
+
+// boxedBase formats the primitive wrapper object. The indices of String objects are removed from the keys.
+func (ctx *inspector) boxedBase(obj *goja.Object, keys *[]goja.Value, constructor string, hasConstructor bool, tag string) (string, bool) {
+	var typ string
+	switch className := obj.ClassName(); {
+	case className == "Number", className == "String", className == "Boolean":
+		typ = className
+	case className == "Object" && obj.ExportType() == typeSymbolObj:
+		typ = "Symbol"
+	default:
+		return "", false
+	}
+	value, ok := ctx.try(typ+".prototype.valueOf", obj)
+	if !ok {
+		return "", false
+	}
+	if typ == "String" {
+		length := strLen(value.String())
+		filtered := (*keys)[:0]
+		for _, key := range *keys {
+			if n, err := strconv.Atoi(key.String()); err != nil || !isIndex(key) || n >= length {
+				filtered = append(filtered, key)
+			}
+		}
+		*keys = filtered
+	}
+	base := "[" + typ
+	if typ != constructor {
+		if !hasConstructor {
+			base += " (null prototype)"
+		} else {
+			base += " (" + constructor + ")"
+		}
+	}
+	base += ": " + ctx.formatPrimitive(stylizeNoColor, value) + "]"
+	if tag != "" && tag != constructor {
+		base += " [" + tag + "]"
+	}
+	if len(*keys) != 0 || !ctx.Colors {
+		return base, true
+	}
+	return ctx.stylize(base, strings.ToLower(typ)), true
+}
+
+func (ctx *inspector) functionBase(obj *goja.Object, constructor string, hasConstructor bool, tag string) string {
+	if src, ok := ctx.try("Function.prototype.toString", obj); ok {
+		if s := src.String(); strings.HasPrefix(s, "class") && strings.HasSuffix(s, "}") {
+			slice := s[5 : len(s)-1]
+			if i := strings.IndexByte(slice, '{'); i != -1 && (!strings.Contains(slice[:i], "(") || classRegExp.MatchString(slice)) {
+				return ctx.classBase(obj, constructor, hasConstructor, tag)
+			}
+		}
+	}
+	typ := "Function"
+	switch constructor {
+	case "AsyncFunction", "GeneratorFunction", "AsyncGeneratorFunction":
+		typ = constructor
+	}
+	base := "[" + typ
+	if !hasConstructor {
+		base += " (null prototype)"
+	}
+	if name := obj.Get("name"); name == nil || name.String() == "" {
+		base += " (anonymous)"
+	} else {
+		base += ": " + name.String()
+	}
+	base += "]"
+	if hasConstructor && constructor != typ && constructor != "Function" {
+		base += " " + constructor
+	}
+	if tag != "" && constructor != tag {
+		base += " [" + tag + "]"
+	}
+	return base
+}
+
+func (ctx *inspector) classBase(obj *goja.Object, constructor string, hasConstructor bool, tag string) string {
+	name := "(anonymous)"
+	if _, ok := ctx.descriptor(obj, ctx.runtime.ToValue("name")).(*goja.Object); ok {
+		if v := obj.Get("name"); v != nil && v.ToBoolean() {
+			name = v.String()
+		}
+	}
+	base := "class " + name
+	if constructor != "Function" && hasConstructor {
+		base += " [" + constructor + "]"
+	}
+	if tag != "" && constructor != tag {
+		base += " [" + tag + "]"
+	}
+	if !hasConstructor {
+		base += " extends [null prototype]"
+	} else if proto := obj.Prototype(); proto != nil {
+		if superName := proto.Get("name"); superName != nil && superName.ToBoolean() {
+			base += " extends " + superName.String()
+		}
+	}
+	return "[" + base + "]"
+}
+
+func containsKey(keys []goja.Value, name string) bool {
+	for _, key := range keys {
+		if isString(key) && key.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// stackString returns the stack of the error in the format of V8, goja indents the frames with a tab and ends
+// the stack with a newline.
+func (ctx *inspector) stackString(obj *goja.Object) string {
+	if v := obj.Get("stack"); v != nil && v.ToBoolean() {
+		return strings.ReplaceAll(strings.TrimSuffix(v.String(), "\n"), "\n\tat ", "\n    at ")
+	}
+	return ctx.call("Error.prototype.toString", obj).String()
+}
+
+func (ctx *inspector) formatError(obj *goja.Object, constructor string, hasConstructor bool, tag string, keys []goja.Value) ([]goja.Value, string) {
+	name := "Error"
+	if v := obj.Get("name"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		name = v.String()
+	}
+	stack := ctx.stackString(obj)
+
+	// The name, the message and the stack are omitted if they're already contained in the stack
+	if !ctx.ShowHidden && len(keys) != 0 {
+		for _, n := range []string{"name", "message", "stack"} {
+			if v := obj.Get(n); isString(v) && strings.Contains(stack, v.String()) {
+				filtered := keys[:0]
+				for _, key := range keys {
+					if !isString(key) || key.String() != n {
+						filtered = append(filtered, key)
+					}
+				}
+				keys = filtered
+			}
+		}
+	}
+	if ctx.call("Reflect.has", goja.Undefined(), obj, ctx.runtime.ToValue("cause")).ToBoolean() && !containsKey(keys, "cause") {
+		keys = append(keys, ctx.runtime.ToValue("cause"))
+	}
+	if errors, ok := obj.Get("errors").(*goja.Object); ok && errors.ClassName() == "Array" && !containsKey(keys, "errors") {
+		keys = append(keys, ctx.runtime.ToValue("errors"))
+	}
+
+	stack = improveStack(stack, constructor, hasConstructor, name, tag)
+
+	// The message is ignored when looking for the stack frames
+	pos := 0
+	if msg := obj.Get("message"); msg != nil && msg.ToBoolean() {
+		if i := strings.Index(stack, msg.String()); i > 0 {
+			pos = i + len(msg.String())
+		}
+	}
+	if !strings.Contains(stack[pos:], "\n    at") {
+		stack = "[" + stack + "]"
+	}
+	if ctx.indentationLvl != 0 {
+		stack = strings.ReplaceAll(stack, "\n", "\n"+strings.Repeat(" ", ctx.indentationLvl))
+	}
+	return keys, stack
+}
+
+// improveStack adds the constructor to the stack of the error if it's not mentioned by the name.
+func improveStack(stack, constructor string, hasConstructor bool, name, tag string) string {
+	n := len(name)
+	if !hasConstructor || strings.HasSuffix(name, "Error") && strings.HasPrefix(stack, name) &&
+		(len(stack) == n || stack[n] == ':' || stack[n] == '\n') {
+		fallback := "Error"
+		if !hasConstructor {
+			m := errorRegExp.FindStringSubmatch(stack)
+			if m == nil {
+				m = errorOnlyExp.FindStringSubmatch(stack)
+			}
+			fallback = ""
+			if m != nil {
+				fallback = m[1]
+			}
+			n = len(fallback)
+			if fallback == "" {
+				fallback = "Error"
+			}
+		}
+		p := strings.TrimSuffix(prefix(constructor, hasConstructor, tag, fallback, ""), " ")
+		if name != p {
+			if strings.Contains(p, name) {
+				if n == 0 {
+					stack = p + ": " + stack
+				} else {
+					stack = p + stack[n:]
+				}
+			} else {
+				stack = p + " [" + name + "]" + stack[n:]
+			}
+		}
+	}
+	return stack
+}
+
+// formatGetter returns the representation of an accessor property with the given getter, including the value
+// it returns for obj if the Getters option selects it.
+func (ctx *inspector) formatGetter(obj *goja.Object, getter goja.Value, label string, hasSetter bool, recurseTimes int) string {
+	switch {
+	case ctx.Getters == GettersAll:
+	case ctx.Getters == GettersGet && !hasSetter:
+	case ctx.Getters == GettersSet && hasSetter:
+	default:
+		return ctx.stylize("["+label+"]", "special")
+	}
+	fn, ok := goja.AssertFunction(getter)
+	if !ok {
+		return ctx.stylize("["+label+"]", "special")
+	}
+	tmp, err := fn(obj)
+	if err != nil {
+		message := goja.Undefined()
+		if ex, ok := err.(*goja.Exception); ok {
+			if o, ok := ex.Value().(*goja.Object); ok {
+				message = o.Get("message")
+			}
+		}
+		if message == nil {
+			message = goja.Undefined()
+		}
+		return ctx.stylize("["+label+":", "special") + " <Inspection threw (" + message.String() + ")>" + ctx.stylize("]", "special")
+	}
+	ctx.indentationLvl += 2
+	defer func() { ctx.indentationLvl -= 2 }()
+	if _, ok := tmp.(*goja.Object); ok {
+		return ctx.stylize("["+label+"]", "special") + " " + ctx.formatValue(tmp, recurseTimes, false)
+	}
+	return ctx.stylize("["+label+":", "special") + " " + ctx.formatPrimitive(ctx.stylize, tmp) + ctx.stylize("]", "special")
+}
+
+func (ctx *inspector) formatProperty(obj *goja.Object, key goja.Value, recurseTimes, typ int, desc goja.Value) string {
+	if desc == nil {
+		desc = ctx.descriptor(obj, key)
+	}
+	var value, getter, setter goja.Value
+	enumerable := true
+	if d, ok := desc.(*goja.Object); ok {
+		value, getter, setter = d.Get("value"), d.Get("get"), d.Get("set")
+		enumerable = d.Get("enumerable").ToBoolean()
+	} else {
+		value = get(obj, key)
+	}
+	isDefined := func(v goja.Value) bool {
+		return v != nil && !goja.IsUndefined(v)
+	}
+
+	var str string
+	extra := " "
+	switch {
+	case isDefined(value):
+		diff := 2
+		if ctx.Compact < 0 && typ == objectType {
+			diff = 3
+		}
+		ctx.indentationLvl += diff
+		str = ctx.formatValue(value, recurseTimes, false)
+		if diff == 3 && ctx.BreakLength < ctx.width(str) {
+			extra = "\n" + strings.Repeat(" ", ctx.indentationLvl)
+		}
+		ctx.indentationLvl -= diff
+	case isDefined(getter):
+		label := "Getter"
+		if isDefined(setter) {
+			label = "Getter/Setter"
+		}
+		str = ctx.formatGetter(obj, getter, label, isDefined(setter), recurseTimes)
+	case isDefined(setter):
+		str = ctx.stylize("[Setter]", "special")
+	default:
+		str = ctx.stylize("undefined", "undefined")
+	}
+	if typ == arrayType {
+		return str
+	}
+
+	var name string
+	if sym, ok := key.(*goja.Symbol); ok {
+		name = "[" + ctx.stylize(escape(symbolString(sym), '\''), "symbol") + "]"
+	} else if s := key.String(); s == "__proto__" {
+		name = "['__proto__']"
+	} else if !enumerable {
+		name = "[" + escape(s, '\'') + "]"
+	} else if keyStrRegExp.MatchString(s) {
+		name = ctx.stylize(s, "name")
+	} else {
+		name = ctx.stylize(strEscape(s), "string")
+	}
+	return name + ":" + extra + str
+}
+
+func (ctx *inspector) isBelowBreakLength(output []string, start int, base string) bool {
+	totalLength := len(output) + start
+	if totalLength+len(output) > ctx.BreakLength {
+		return false
+	}
+	for _, s := range output {
+		totalLength += ctx.width(s)
+		if totalLength > ctx.BreakLength {
+			return false
+		}
+	}
+	return base == "" || !strings.Contains(base, "\n")
+}
+
+// reduceToSingleString combines the entries on a single line if they fit, otherwise every entry is put on its
+// own line unless the array elements are grouped into columns.
+func (ctx *inspector) reduceToSingleString(output []string, base string, braces [2]string, extrasType, recurseTimes int, value *goja.Object) string {
+	if ctx.Compact >= 0 {
+		if base != "" {
+			base += " "
+		}
+		if ctx.Compact >= 1 {
+			entries := len(output)
+			if extrasType == arrayExtrasType && entries > 6 {
+				output = ctx.groupArrayElements(output, value)
+			}
+			if ctx.currentDepth-recurseTimes < ctx.Compact && entries == len(output) {
+				start := len(output) + ctx.indentationLvl + strLen(braces[0]) + strLen(base) + 10
+				if ctx.isBelowBreakLength(output, start, base) {
+					if joined := strings.Join(output, ", "); !strings.Contains(joined, "\n") {
+						return base + braces[0] + " " + joined + " " + braces[1]
+					}
+				}
+			}
+		}
+		indentation := "\n" + strings.Repeat(" ", ctx.indentationLvl)
+		return base + braces[0] + indentation + "  " + strings.Join(output, ","+indentation+"  ") + indentation + braces[1]
+	}
+	if base != "" {
+		base = " " + base
+	}
+	if ctx.isBelowBreakLength(output, 0, base) {
+		return braces[0] + base + " " + strings.Join(output, ", ") + " " + braces[1]
+	}
+	indentation := strings.Repeat(" ", ctx.indentationLvl)
+	ln := " "
+	if base != "" || len(braces[0]) != 1 {
+		ln = base + "\n" + indentation + "  "
+	}
+	return braces[0] + ln + strings.Join(output, ",\n"+indentation+"  ") + " " + braces[1]
+}
+
+func isNumber(v goja.Value) bool {
+	if _, isObject := v.(*goja.Object); isObject || v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return false
+	}
+	if _, isSymbol := v.(*goja.Symbol); isSymbol {
+		return false
+	}
+	switch v.ExportType().Kind() {
+	case reflect.Int64, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func pad(s string, width int, start bool) string {
+	if n := strLen(s); n < width {
+		if start {
+			return strings.Repeat(" ", width-n) + s
+		}
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// groupArrayElements groups the elements of arrays with more than six entries into columns.
+func (ctx *inspector) groupArrayElements(output []string, value *goja.Object) []string {
+	const separatorSpace = 2
+	totalLength := 0
+	maxLength := 0
+	outputLength := len(output)
+	if ctx.MaxArrayLength < len(output) {
+		// The "... more items" entry isn't taken into account
+		outputLength--
+	}
+	dataLen := make([]int, outputLength)
+	for i := 0; i < outputLength; i++ {
+		n := ctx.width(output[i])
+		dataLen[i] = n
+		totalLength += n + separatorSpace
+		if maxLength < n {
+			maxLength = n
+		}
+	}
+	actualMax := maxLength + separatorSpace
+	if actualMax*3+ctx.indentationLvl < ctx.BreakLength &&
+		(float64(totalLength)/float64(actualMax) > 5 || maxLength <= 6) {
+		averageBias := math.Sqrt(float64(actualMax) - float64(totalLength)/float64(len(output)))
+		biasedMax := math.Max(float64(actualMax)-3-averageBias, 1)
+		columns := minInt(
+			int(math.Round(math.Sqrt(2.5*biasedMax*float64(outputLength))/biasedMax)),
+			(ctx.BreakLength-ctx.indentationLvl)/actualMax,
+			ctx.Compact*4,
+			15,
+		)
+		if columns <= 1 {
+			return output
+		}
+		maxLineLength := make([]int, 0, columns)
+		for i := 0; i < columns; i++ {
+			lineLength := 0
+			for j := i; j < outputLength; j += columns {
+				if dataLen[j] > lineLength {
+					lineLength = dataLen[j]
+				}
+			}
+			maxLineLength = append(maxLineLength, lineLength+separatorSpace)
+		}
+		// Numbers are aligned to the right
+		padStart := true
+		if value != nil {
+			for i := range output {
+				if !isNumber(value.Get(strconv.Itoa(i))) {
+					padStart = false
+					break
+				}
+			}
+		}
+		var tmp []string
+		for i := 0; i < outputLength; i += columns {
+			max := minInt(i+columns, outputLength)
+			var b strings.Builder
+			j := i
+			for ; j < max-1; j++ {
+				padding := maxLineLength[j-i] + strLen(output[j]) - dataLen[j]
+				b.WriteString(pad(output[j]+", ", padding, padStart))
+			}
+			if padStart {
+				padding := maxLineLength[j-i] + strLen(output[j]) - dataLen[j] - separatorSpace
+				b.WriteString(pad(output[j], padding, true))
+			} else {
+				b.WriteString(output[j])
+			}
+			tmp = append(tmp, b.String())
+		}
+		if ctx.MaxArrayLength < len(output) {
+			tmp = append(tmp, output[outputLength])
+		}
+		output = tmp
+	}
+	return output
+}
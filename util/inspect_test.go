@@ -0,0 +1,197 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/khanghh/goja-nodejs/require"
+)
+
+func TestInspect(t *testing.T) {
+	vm := goja.New()
+	tests := []struct {
+		src, expected string
+	}{
+		{`-0`, `-0`},
+		{`"a'b"`, `"a'b"`},
+		{`"a'b\"c"`, "`a'b\"c`"},
+		{`"\x00\n\x7f"`, `'\x00\n\x7F'`},
+		{`Symbol("x")`, `Symbol(x)`},
+		{`({a: 1, b: "x", c: [1, 2, 3], d: {e: {f: {g: 1}}}})`, `{ a: 1, b: 'x', c: [ 1, 2, 3 ], d: { e: { f: [Object] } } }`},
+		{`[1, , 3]`, `[ 1, <1 empty item>, 3 ]`},
+		{`new Array(5)`, `[ <5 empty items> ]`},
+		{`Object.assign([1, 2], {foo: "bar"})`, `[ 1, 2, foo: 'bar' ]`},
+		{`[[[[1]]]]`, `[ [ [ [Array] ] ] ]`},
+		{`new Map([["a", 1], [{}, []]])`, `Map(2) { 'a' => 1, {} => [] }`},
+		{`new Set([1, "a"])`, `Set(2) { 1, 'a' }`},
+		{`new Map()`, `Map(0) {}`},
+		{`new (class Foo extends Map {})([[1, 2]])`, `Foo(1) [Map] { 1 => 2 }`},
+		{`new Uint8Array([1, 2, 3])`, `Uint8Array(3) [ 1, 2, 3 ]`},
+		{`new Float64Array(0)`, `Float64Array(0) []`},
+		{`new ArrayBuffer(3)`, `ArrayBuffer { [Uint8Contents]: <00 00 00>, byteLength: 3 }`},
+		{`new Date(0)`, `1970-01-01T00:00:00.000Z`},
+		{`new Date(NaN)`, `Invalid Date`},
+		{`/ab+c/gi`, `/ab+c/gi`},
+		{`Object.assign(/x/, {a: 1})`, `/x/ { a: 1 }`},
+		{`new Proxy({a: 1}, {get() { throw new Error("trap") }})`, `{ a: 1 }`},
+		{`(() => { class Foo { constructor() { this.x = 1 } }; return new Foo() })()`, `Foo { x: 1 }`},
+		{`Object.create(null)`, `[Object: null prototype] {}`},
+		{`Object.assign(Object.create(null), {a: 1})`, `[Object: null prototype] { a: 1 }`},
+		{`(() => { const o = {a: 1}; o.self = o; o.arr = [o]; return o })()`, `<ref *1> { a: 1, self: [Circular *1], arr: [ [Circular *1] ] }`},
+		{`(function foo() {})`, `[Function: foo]`},
+		{`(() => {})`, `[Function (anonymous)]`},
+		{`(class A {})`, `[class A]`},
+		{`(() => { class A {}; return class B extends A {} })()`, `[class B extends A]`},
+		{`(async function af() {})`, `[AsyncFunction: af]`},
+		{`(function* g() {})`, `[GeneratorFunction: g]`},
+		{`({[Symbol("s")]: 1, "a-b": 2, get g() { return 1 }, set s(v) {}, get gs() { return 1 }, set gs(v) {}})`,
+			"{\n  'a-b': 2,\n  g: [Getter],\n  s: [Setter],\n  gs: [Getter/Setter],\n  [Symbol(s)]: 1\n}"},
+		{`Promise.resolve(42)`, `Promise { 42 }`},
+		{`new Promise(() => {})`, `Promise { <pending> }`},
+		{`new WeakMap()`, `WeakMap { <items unknown> }`},
+		{`Object(1)`, `[Number: 1]`},
+		{`Object("ab")`, `[String: 'ab']`},
+		{`Object(Symbol("q"))`, `[Symbol: Symbol(q)]`},
+		{`(function() { return arguments })(1, 2)`, `[Arguments] { '0': 1, '1': 2 }`},
+		{`Math`, `Object [Math] {}`},
+		{`Array.from({length: 30}, (_, i) => i)`, `[
+   0,  1,  2,  3,  4,  5,  6,  7,  8,
+   9, 10, 11, 12, 13, 14, 15, 16, 17,
+  18, 19, 20, 21, 22, 23, 24, 25, 26,
+  27, 28, 29
+]`},
+		{`Array.from({length: 10}, (_, i) => "item" + i)`, `[
+  'item0', 'item1',
+  'item2', 'item3',
+  'item4', 'item5',
+  'item6', 'item7',
+  'item8', 'item9'
+]`},
+		{`Array.from({length: 102}, () => 0).length`, `102`},
+		{`(() => { const e = new TypeError("bad"); e.code = "E_BAD"; e.stack = "TypeError: bad\n    at f (file.js:1:1)"; return e })()`, `TypeError: bad
+    at f (file.js:1:1) {
+  code: 'E_BAD'
+}`},
+		{`(() => { class MyError extends Error {}; const e = new MyError("m"); e.stack = "Error: m\n    at f (file.js:1:1)"; return {e} })()`, `{
+  e: MyError: m
+      at f (file.js:1:1)
+}`},
+		{`(() => { const e = new Error("no stack"); e.stack = ""; return e })()`, `[Error: no stack]`},
+		{`({ [Symbol.for("nodejs.util.inspect.custom")](depth, options, inspect) { return "custom " + depth + " " + inspect({a: options.depth}) } })`, `custom 2 { a: 2 }`},
+	}
+	for _, test := range tests {
+		v, err := vm.RunString("(" + test.src + ")")
+		if err != nil {
+			t.Fatal(test.src, err)
+		}
+		if s := Inspect(vm, v, DefaultInspectOptions); s != test.expected {
+			t.Errorf("Unexpected result of %s:\n%s\nexpected:\n%s", test.src, s, test.expected)
+		}
+	}
+
+	// The frames of goja stacks are indented like the ones of V8
+	v, err := vm.RunString(`function thrower() { return new Error("boom") }; thrower()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := Inspect(vm, v, DefaultInspectOptions); s != "Error: boom\n    at thrower (<eval>:1:29(3))\n    at <eval>:1:57(3)" {
+		t.Fatalf("Unexpected error: %q", s)
+	}
+}
+
+func TestInspectOptions(t *testing.T) {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+
+	v, err := vm.RunString(`
+	const { inspect, formatWithOptions } = require("node:util");
+	[
+		inspect({a: {b: {c: {d: 1}}}}, {depth: 0}),
+		inspect({a: {b: {c: {d: 1}}}}, {depth: null}),
+		inspect({a: {b: {c: {}}}}, false, 1),
+		inspect({b: 1, a: 2, c: [3]}, {sorted: true}),
+		inspect({b: 1, a: 2, c: 3}, {sorted: (a, b) => a < b ? 1 : -1}),
+		inspect([1, 2, 3, 4], {maxArrayLength: 2}),
+		inspect(new Set([1, 2, 3]), {maxArrayLength: 1}),
+		inspect({a: 1, b: {c: 2}}, {compact: false}),
+		inspect({a: 1, b: {c: 2}}, {compact: true}),
+		inspect({a: 1, b: 2, c: 3}, {breakLength: 10}),
+		inspect([1, null, undefined, "s"], {colors: true}),
+		inspect("abcdef", {maxStringLength: 3}),
+		inspect(new Proxy({a: 1}, {}), {showProxy: true}),
+		inspect([1, 2], {showHidden: true}),
+		inspect({ [inspect.custom]() { return { replaced: true } } }),
+		inspect({ [inspect.custom]() { return "x" } }, {customInspect: false}),
+		inspect.custom === Symbol.for("nodejs.util.inspect.custom"),
+		formatWithOptions({ colors: true }, "%s:", "a", 1),
+	].join("\n");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{ a: [Object] }\n" +
+		"{\n  a: { b: { c: { d: 1 } } }\n}\n" +
+		"{ a: { b: [Object] } }\n" +
+		"{ a: 2, b: 1, c: [ 3 ] }\n" +
+		"{ c: 3, b: 1, a: 2 }\n" +
+		"[ 1, 2, ... 2 more items ]\n" +
+		"Set(3) { 1, ... 2 more items }\n" +
+		"{\n  a: 1,\n  b: {\n    c: 2\n  }\n}\n" +
+		"{ a: 1, b: { c: 2 } }\n" +
+		"{\n  a: 1,\n  b: 2,\n  c: 3\n}\n" +
+		"[ \x1b[33m1\x1b[39m, \x1b[1mnull\x1b[22m, \x1b[90mundefined\x1b[39m, \x1b[32m's'\x1b[39m ]\n" +
+		"'abc'... 3 more characters\n" +
+		"Proxy [ { a: 1 }, {} ]\n" +
+		"[ 1, 2, [length]: 2 ]\n" +
+		"{ replaced: true }\n" +
+		"{\n  [Symbol(nodejs.util.inspect.custom)]: [Function: [nodejs.util.inspect.custom]]\n}\n" +
+		"true\n" +
+		"a: \x1b[33m1\x1b[39m"
+	if s := v.String(); s != expected {
+		t.Fatalf("Unexpected result:\n%q\nexpected:\n%q", s, expected)
+	}
+}
+
+func TestInspectGetters(t *testing.T) {
+	vm := goja.New()
+	registry := require.NewRegistry()
+	registry.RegisterNativeModule(ModuleName, Default())
+	registry.Enable(vm)
+
+	v, err := vm.RunString(`
+	const { inspect } = require("node:util");
+	const o = {
+		get g() { return 1 },
+		get gs() { return "x" },
+		set gs(v) {},
+		set s(v) {},
+		get obj() { return { a: { b: 1 } } },
+		get t() { throw new Error("bad") },
+		get n() { return null },
+	};
+	[
+		inspect(o, { getters: true }),
+		inspect(o, { getters: "get" }),
+		inspect(o, { getters: "set" }),
+		inspect(o),
+		inspect({ get g() { return 1 } }, { getters: true }),
+		inspect({ [inspect.custom](depth, options) { return options.getters } }, { getters: "set" }),
+	].join("\n");
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "{\n  g: [Getter: 1],\n  gs: [Getter/Setter: 'x'],\n  s: [Setter],\n  obj: [Getter] { a: { b: 1 } },\n" +
+		"  t: [Getter: <Inspection threw (bad)>],\n  n: [Getter: null]\n}\n" +
+		"{\n  g: [Getter: 1],\n  gs: [Getter/Setter],\n  s: [Setter],\n  obj: [Getter] { a: { b: 1 } },\n" +
+		"  t: [Getter: <Inspection threw (bad)>],\n  n: [Getter: null]\n}\n" +
+		"{\n  g: [Getter],\n  gs: [Getter/Setter: 'x'],\n  s: [Setter],\n  obj: [Getter],\n  t: [Getter],\n  n: [Getter]\n}\n" +
+		"{\n  g: [Getter],\n  gs: [Getter/Setter],\n  s: [Setter],\n  obj: [Getter],\n  t: [Getter],\n  n: [Getter]\n}\n" +
+		"{ g: [Getter: 1] }\n" +
+		"set"
+	if s := v.String(); s != expected {
+		t.Fatalf("Unexpected result:\n%q\nexpected:\n%q", s, expected)
+	}
+}
@@ -13,17 +13,18 @@ type Util struct {
 }
 
 func (u *Util) format(call goja.FunctionCall) goja.Value {
-	var fmt string
-	if arg := call.Argument(0); !goja.IsUndefined(arg) {
-		fmt = arg.String()
-	}
+	return u.runtime.ToValue(FormatWithOptions(u.runtime, DefaultInspectOptions, call.Arguments...))
+}
 
-	var args []goja.Value
-	if len(call.Arguments) > 0 {
-		args = call.Arguments[1:]
+func (u *Util) formatWithOptions(call goja.FunctionCall) goja.Value {
+	inspectOptions, ok := call.Argument(0).(*goja.Object)
+	if !ok {
+		err := u.runtime.NewTypeError(`The "inspectOptions" argument must be of type object. Received ` + Inspect(u.runtime, call.Argument(0), DefaultInspectOptions))
+		err.Set("code", "ERR_INVALID_ARG_TYPE")
+		panic(err)
 	}
-
-	return Format(u.runtime, fmt, args...)
+	options := ParseInspectOptions(inspectOptions, DefaultInspectOptions)
+	return u.runtime.ToValue(FormatWithOptions(u.runtime, options, call.Arguments[1:]...))
 }
 
 type UtilModule struct {
@@ -36,6 +37,8 @@ func (m *UtilModule) Export(runtime *goja.Runtime, module *goja.Object) {
 	util := &Util{runtime}
 	obj := module.Get("exports").(*goja.Object)
 	obj.Set("format", util.format)
+	obj.Set("formatWithOptions", util.formatWithOptions)
+	obj.Set("inspect", inspectFunction(runtime))
 }
 
 func Default() *UtilModule {
@@ -0,0 +1,323 @@
+'use strict';
+
+const assert = require("../../assert.js");
+const util = require("node:util");
+
+const symbol = Symbol('foo');
+
+assert.sameValue(util.format(), '');
+assert.sameValue(util.format(''), '');
+assert.sameValue(util.format([]), '[]');
+assert.sameValue(util.format([0]), '[ 0 ]');
+assert.sameValue(util.format({}), '{}');
+assert.sameValue(util.format({ foo: 42 }), '{ foo: 42 }');
+assert.sameValue(util.format(null), 'null');
+assert.sameValue(util.format(true), 'true');
+assert.sameValue(util.format(false), 'false');
+assert.sameValue(util.format('test'), 'test');
+
+// CHECKME this is for console.log() compatibility - but is it *right*?
+assert.sameValue(util.format('foo', 'bar', 'baz'), 'foo bar baz');
+
+// ES6 Symbol handling
+assert.sameValue(util.format(symbol), 'Symbol(foo)');
+assert.sameValue(util.format('foo', symbol), 'foo Symbol(foo)');
+assert.sameValue(util.format('%s', symbol), 'Symbol(foo)');
+assert.sameValue(util.format('%j', symbol), 'undefined');
+
+// Number format specifier
+assert.sameValue(util.format('%d'), '%d');
+assert.sameValue(util.format('%d', 42.0), '42');
+assert.sameValue(util.format('%d', 42), '42');
+assert.sameValue(util.format('%d', '42'), '42');
+assert.sameValue(util.format('%d', '42.0'), '42');
+assert.sameValue(util.format('%d', 1.5), '1.5');
+assert.sameValue(util.format('%d', -0.5), '-0.5');
+assert.sameValue(util.format('%d', -0.0), '-0');
+assert.sameValue(util.format('%d', ''), '0');
+assert.sameValue(util.format('%d', ' -0.000'), '-0');
+assert.sameValue(util.format('%d %d', 42, 43), '42 43');
+assert.sameValue(util.format('%d %d', 42), '42 %d');
+assert.sameValue(util.format('%d', symbol), 'NaN');
+assert.sameValue(util.format('%d', {}), 'NaN');
+
+// Integer format specifier
+assert.sameValue(util.format('%i'), '%i');
+assert.sameValue(util.format('%i', 42.0), '42');
+assert.sameValue(util.format('%i', 42), '42');
+assert.sameValue(util.format('%i', '42'), '42');
+assert.sameValue(util.format('%i', '42.0'), '42');
+assert.sameValue(util.format('%i', 1.5), '1');
+assert.sameValue(util.format('%i', -0.5), '-0');
+assert.sameValue(util.format('%i', ''), 'NaN');
+assert.sameValue(util.format('%i %i', 42, 43), '42 43');
+assert.sameValue(util.format('%i %i', 42), '42 %i');
+assert.sameValue(util.format('%i', symbol), 'NaN');
+
+// Float format specifier
+assert.sameValue(util.format('%f'), '%f');
+assert.sameValue(util.format('%f', 42.0), '42');
+assert.sameValue(util.format('%f', 42), '42');
+assert.sameValue(util.format('%f', '42'), '42');
+assert.sameValue(util.format('%f', '-0.0'), '-0');
+assert.sameValue(util.format('%f', '42.0'), '42');
+assert.sameValue(util.format('%f', 1.5), '1.5');
+assert.sameValue(util.format('%f', -0.5), '-0.5');
+assert.sameValue(util.format('%f', Math.PI), '3.141592653589793');
+assert.sameValue(util.format('%f', ''), 'NaN');
+assert.sameValue(util.format('%f', symbol), 'NaN');
+assert.sameValue(util.format('%f', 'foo'), 'NaN');
+assert.sameValue(util.format('%f', Infinity), 'Infinity');
+assert.sameValue(util.format('%f', -Infinity), '-Infinity');
+assert.sameValue(util.format('%f %f', 42, 43), '42 43');
+assert.sameValue(util.format('%f %f', 42), '42 %f');
+
+// String format specifier
+assert.sameValue(util.format('%s'), '%s');
+assert.sameValue(util.format('%s', undefined), 'undefined');
+assert.sameValue(util.format('%s', null), 'null');
+assert.sameValue(util.format('%s', 'foo'), 'foo');
+assert.sameValue(util.format('%s', 42), '42');
+assert.sameValue(util.format('%s', '42'), '42');
+assert.sameValue(util.format('%s', -0), '-0');
+assert.sameValue(util.format('%s', '-0.0'), '-0.0');
+assert.sameValue(util.format('%s %s', 42, 43), '42 43');
+assert.sameValue(util.format('%s %s', 42), '42 %s');
+assert.sameValue(util.format('%s', Symbol('foo')), 'Symbol(foo)');
+assert.sameValue(util.format('%s', true), 'true');
+assert.sameValue(util.format('%s', { a: [1, 2, 3] }), '{ a: [Array] }');
+assert.sameValue(util.format('%s', { toString() { return 'Foo'; } }), 'Foo');
+assert.sameValue(util.format('%s', { toString: 5 }), '{ toString: 5 }');
+assert.sameValue(util.format('%s', () => 5), '() => 5');
+assert.sameValue(util.format('%s', Infinity), 'Infinity');
+assert.sameValue(util.format('%s', -Infinity), '-Infinity');
+
+// String format specifier including `toString` properties on the prototype.
+{
+  class Foo { toString() { return 'Bar'; } }
+  assert.sameValue(util.format('%s', new Foo()), 'Bar');
+  // The engine doesn't keep the class name of objects without a prototype
+  assert.sameValue(
+    util.format('%s', Object.setPrototypeOf(new Foo(), null)),
+    '[Object: null prototype] {}'
+  );
+  global.Foo = Foo;
+  assert.sameValue(util.format('%s', new Foo()), 'Bar');
+  delete global.Foo;
+  class Bar { abc = true; }
+  assert.sameValue(util.format('%s', new Bar()), 'Bar { abc: true }');
+  class Foobar extends Array { aaa = true; }
+  assert.sameValue(
+    util.format('%s', new Foobar(5)),
+    'Foobar(5) [ <5 empty items>, aaa: true ]'
+  );
+
+  // Subclassing:
+  class B extends Foo {}
+
+  function C() {}
+  C.prototype.toString = function() {
+    return 'Custom';
+  };
+
+  function D() {
+    C.call(this);
+  }
+  D.prototype = Object.create(C.prototype);
+
+  assert.sameValue(util.format('%s', new B()), 'Bar');
+  assert.sameValue(util.format('%s', new C()), 'Custom');
+  assert.sameValue(util.format('%s', new D()), 'Custom');
+
+  D.prototype.constructor = D;
+  assert.sameValue(util.format('%s', new D()), 'Custom');
+
+  D.prototype.constructor = null;
+  assert.sameValue(util.format('%s', new D()), 'Custom');
+
+  D.prototype.constructor = { name: 'Foobar' };
+  assert.sameValue(util.format('%s', new D()), 'Custom');
+
+  Object.defineProperty(D.prototype, 'constructor', {
+    get() {
+      throw new Error();
+    },
+    configurable: true
+  });
+  assert.sameValue(util.format('%s', new D()), 'Custom');
+
+  assert.sameValue(util.format('%s', Object.create(null)), '[Object: null prototype] {}');
+}
+
+// JSON format specifier
+assert.sameValue(util.format('%j'), '%j');
+assert.sameValue(util.format('%j', 42), '42');
+assert.sameValue(util.format('%j', '42'), '"42"');
+assert.sameValue(util.format('%j %j', 42, 43), '42 43');
+assert.sameValue(util.format('%j %j', 42), '42 %j');
+assert.sameValue(util.format('%j', { a: 1, b: [2, '3'] }), '{"a":1,"b":[2,"3"]}');
+assert.sameValue(util.format('%j', { toJSON() { return 'custom'; } }), '"custom"');
+assert.sameValue(util.format('%j', new Date(0)), '"1970-01-01T00:00:00.000Z"');
+assert.sameValue(util.format('%j', undefined), 'undefined');
+assert.sameValue(util.format('%j', () => 5), 'undefined');
+
+// Object format specifier
+const obj = {
+  foo: 'bar',
+  foobar: 1,
+  func: function() {}
+};
+const nestedObj = {
+  foo: 'bar',
+  foobar: {
+    foo: 'bar',
+    func: function() {}
+  }
+};
+const nestedObj2 = {
+  foo: 'bar',
+  foobar: 1,
+  func: [{ a: function() {} }]
+};
+// The engine lists the prototype of functions before their length and name
+assert.sameValue(util.format('%o'), '%o');
+assert.sameValue(util.format('%o', 42), '42');
+assert.sameValue(util.format('%o', 'foo'), '\'foo\'');
+assert.sameValue(
+  util.format('%o', obj),
+  '{\n' +
+  '  foo: \'bar\',\n' +
+  '  foobar: 1,\n' +
+  '  func: <ref *1> [Function: func] {\n' +
+  '    [prototype]: { [constructor]: [Circular *1] },\n' +
+  '    [length]: 0,\n' +
+  '    [name]: \'func\'\n' +
+  '  }\n' +
+  '}');
+assert.sameValue(
+  util.format('%o', nestedObj2),
+  '{\n' +
+  '  foo: \'bar\',\n' +
+  '  foobar: 1,\n' +
+  '  func: [\n' +
+  '    {\n' +
+  '      a: <ref *1> [Function: a] {\n' +
+  '        [prototype]: { [constructor]: [Circular *1] },\n' +
+  '        [length]: 0,\n' +
+  '        [name]: \'a\'\n' +
+  '      }\n' +
+  '    },\n' +
+  '    [length]: 1\n' +
+  '  ]\n' +
+  '}');
+assert.sameValue(util.format('%o', [1, 2]), '[ 1, 2, [length]: 2 ]');
+assert.sameValue(util.format('%o %o', 42, 43), '42 43');
+assert.sameValue(util.format('%o %o', 42), '42 %o');
+
+// Object format specifier with inspect options
+assert.sameValue(util.format('%O'), '%O');
+assert.sameValue(util.format('%O', 42), '42');
+assert.sameValue(util.format('%O', 'foo'), '\'foo\'');
+assert.sameValue(
+  util.format('%O', obj),
+  '{ foo: \'bar\', foobar: 1, func: [Function: func] }');
+assert.sameValue(
+  util.format('%O', nestedObj),
+  '{ foo: \'bar\', foobar: { foo: \'bar\', func: [Function: func] } }');
+assert.sameValue(util.format('%O %O', 42, 43), '42 43');
+assert.sameValue(util.format('%O %O', 42), '42 %O');
+
+// CSS format specifier
+assert.sameValue(util.format('%cab'), '%cab');
+assert.sameValue(util.format('%cab', 'color: blue'), 'ab');
+assert.sameValue(util.format('%cab', 'color: blue', 'c'), 'ab c');
+
+// Various format specifiers
+assert.sameValue(util.format('%%s%s', 'foo'), '%sfoo');
+assert.sameValue(util.format('%s:%s'), '%s:%s');
+assert.sameValue(util.format('%s:%s', undefined), 'undefined:%s');
+assert.sameValue(util.format('%s:%s', 'foo'), 'foo:%s');
+assert.sameValue(util.format('%s:%i', 'foo'), 'foo:%i');
+assert.sameValue(util.format('%s:%f', 'foo'), 'foo:%f');
+assert.sameValue(util.format('%s:%s', 'foo', 'bar'), 'foo:bar');
+assert.sameValue(util.format('%s:%s', 'foo', 'bar', 'baz'), 'foo:bar baz');
+assert.sameValue(util.format('%%%s%%', 'hi'), '%hi%');
+assert.sameValue(util.format('%%%s%%%%', 'hi'), '%hi%%');
+assert.sameValue(util.format('%sbc%%def', 'a'), 'abc%def');
+assert.sameValue(util.format('%d:%d', 12, 30), '12:30');
+assert.sameValue(util.format('%d:%d', 12), '12:%d');
+assert.sameValue(util.format('%d:%d'), '%d:%d');
+assert.sameValue(util.format('%i:%i', 12, 30), '12:30');
+assert.sameValue(util.format('%i:%i', 12), '12:%i');
+assert.sameValue(util.format('%i:%i'), '%i:%i');
+assert.sameValue(util.format('%f:%f', 12, 30), '12:30');
+assert.sameValue(util.format('%f:%f', 12), '12:%f');
+assert.sameValue(util.format('%f:%f'), '%f:%f');
+assert.sameValue(util.format('o: %j, a: %j', {}, []), 'o: {}, a: []');
+assert.sameValue(util.format('o: %j, a: %j', {}), 'o: {}, a: %j');
+assert.sameValue(util.format('o: %j, a: %j'), 'o: %j, a: %j');
+assert.sameValue(util.format('o: %o, a: %O', {}, []), 'o: {}, a: []');
+assert.sameValue(util.format('o: %o, a: %o', {}), 'o: {}, a: %o');
+assert.sameValue(util.format('o: %O, a: %O'), 'o: %O, a: %O');
+
+// Invalid format specifiers
+assert.sameValue(util.format('a% b', 'x'), 'a% b x');
+assert.sameValue(util.format('percent: %d%, fraction: %d', 10, 0.1), 'percent: 10%, fraction: 0.1');
+assert.sameValue(util.format('abc%', 1), 'abc% 1');
+
+// Additional arguments after format specifiers
+assert.sameValue(util.format('%i', 1, 'number'), '1 number');
+assert.sameValue(util.format('%i', 1, () => {}), '1 [Function (anonymous)]');
+
+// %c is consumed but doesn't print anything
+assert.sameValue(util.format('%s %c%s', 'foo', 'color: red', 'bar'), 'foo bar');
+
+{
+  const o = {};
+  o.o = o;
+  assert.sameValue(util.format('%j', o), '[Circular]');
+}
+
+{
+  const o = {
+    toJSON() {
+      throw new Error('Not a circular object but still not serializable');
+    }
+  };
+  assert.throws(() => util.format('%j', o), Error);
+}
+
+// Errors
+const err = new Error('foo');
+assert.sameValue(util.format(err.message), 'foo');
+assert.sameValue(util.format('%s', err.message), 'foo');
+assert.sameValue(util.format('%s', err).split('\n')[0], 'Error: foo');
+
+// Doesn't capture stack trace
+function CustomError(msg) {
+  Error.call(this);
+  Object.defineProperty(this, 'message', { value: msg, enumerable: false });
+  Object.defineProperty(this, 'name', { value: 'CustomError', enumerable: false });
+}
+Object.setPrototypeOf(CustomError.prototype, Error.prototype);
+assert.sameValue(util.format(new CustomError('bar')), '[CustomError: bar]');
+
+// Multiple format specifiers with a string
+assert.sameValue(util.format('%s', 'a', 'b', 'c'), 'a b c');
+assert.sameValue(util.format(5, 'str'), '5 str');
+
+// formatWithOptions
+assert.sameValue(
+  util.formatWithOptions({ colors: true }, true, undefined, Symbol(), 1, false, null, 'foobar'),
+  '\u001b[33mtrue\u001b[39m ' +
+    '\u001b[90mundefined\u001b[39m ' +
+    '\u001b[32mSymbol()\u001b[39m ' +
+    '\u001b[33m1\u001b[39m ' +
+    '\u001b[33mfalse\u001b[39m ' +
+    '\u001b[1mnull\u001b[22m ' +
+    'foobar'
+);
+assert.sameValue(
+  util.formatWithOptions({ colors: true, compact: 3 }, '%s', [ 1, { a: true }]),
+  '[ 1, [Object] ]'
+);